@@ -1,6 +1,7 @@
 package commands
 
 import (
+	"context"
 	"fmt"
 	"runtime"
 	"strings"
@@ -31,15 +32,16 @@ func AbandonCmd() *cobra.Command {
 	opts := &AbandonOptions{}
 
 	cmd := &cobra.Command{
-		Use:   "abandon [--all | <branchname>] [<project>...]",
-		Short: "Permanently abandon a development branch",
+		Use:               "abandon [--all | <branchname>] [<project>...]",
+		Short:             "Permanently abandon a development branch",
+		ValidArgsFunction: completeBranchThenProjects("all"),
 		Long: `This subcommand permanently abandons a development branch by
 deleting it (and all its history) from your local repository.
 
 It is equivalent to "git branch -D <branchname>".`,
 		Args: cobra.MinimumNArgs(0),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runAbandon(opts, args)
+			return runAbandon(cmd.Context(), opts, args)
 		},
 	}
 
@@ -59,7 +61,7 @@ It is equivalent to "git branch -D <branchname>".`,
 }
 
 // runAbandon 执行abandon命令
-func runAbandon(opts *AbandonOptions, args []string) error {
+func runAbandon(ctx context.Context, opts *AbandonOptions, args []string) error {
 	// 初始化日志系
 	log := logger.NewDefaultLogger()
 	if opts.Quiet {
@@ -115,6 +117,9 @@ func runAbandon(opts *AbandonOptions, args []string) error {
 		log.Error("加载配置文件失败: %v", err)
 		return fmt.Errorf("failed to load config: %w", err)
 	}
+	if err := requireGitClient(cfg, "abandon"); err != nil {
+		return err
+	}
 
 	log.Debug("正在解析清单文件 %s...", cfg.ManifestName)
 	parser := manifest.NewParser()
@@ -148,7 +153,7 @@ func runAbandon(opts *AbandonOptions, args []string) error {
 
 	// 创建引擎并设置选项
 	log.Debug("创建同步引擎，并行任务数: %d", opts.Jobs)
-	engine := repo_sync.NewEngine(&repo_sync.Options{
+	engine := repo_sync.NewEngineWithContext(ctx, &repo_sync.Options{
 		JobsCheckout: opts.Jobs,
 		Quiet:        opts.Quiet,
 		Verbose:      opts.Verbose,
@@ -1,7 +1,9 @@
 package commands
 
 import (
+	"encoding/json"
 	"fmt"
+	"os"
 	"sort"
 	"strings"
 	"sync"
@@ -19,12 +21,13 @@ import (
 type BranchOptions struct {
 	All         bool
 	Current     bool
-	Color       string
+	UseColor    bool
 	List        bool
 	Verbose     bool
 	SetUpstream string
 	Jobs        int
 	Quiet       bool
+	Format      string         // 输出格式: text 或 json
 	Config      *config.Config // <-- Add this field
 	CommonManifestOptions
 }
@@ -34,22 +37,44 @@ func BranchCmd() *cobra.Command {
 	opts := &BranchOptions{}
 
 	cmd := &cobra.Command{
-		Use:   "branches [<project>...]",
-		Short: "View current topic branches",
-		Long:  `Summarizes the currently available topic branches.`,
+		Use:               "branches [<project>...]",
+		Short:             "View current topic branches",
+		ValidArgsFunction: completeProjects,
+		Long: `Summarizes the currently available topic branches.
+
+Each topic branch name is shown once, with the projects that have it:
+
+  *P  topic-a                  | in all projects
+   pM topic-b                  | in sub/pb, pa
+      topic-c                  | not in pa
+
+The first column is '*' when the branch is checked out in one of its projects.
+The second column is 'P' when every commit of the branch has been uploaded
+with repo upload (recorded in refs/published/<branch>), 'p' when only some
+of its projects are published. The third
+column is 'M' when the branch is fully merged into the manifest revision in
+all of its projects, 'm' when only in some of them. Branches without commits
+ahead of the manifest revision are empty and are neither published nor merged.
+
+Local branches named after the manifest revision are tracking branches and
+are only listed with --all. --format=json prints every branch with the
+number of commits it is ahead of the manifest revision in each project.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			// --color 是根命令的全局选项
+			colorMode, _ := cmd.Flags().GetString("color")
+			opts.UseColor = color.ShouldUseColor(colorMode)
 			return runBranch(opts, args)
 		},
 	}
 
-	cmd.Flags().BoolVarP(&opts.All, "all", "a", false, "show all branches")
+	cmd.Flags().BoolVarP(&opts.All, "all", "a", false, "also show branches named after the manifest revision")
 	cmd.Flags().BoolVar(&opts.Current, "current", false, "consider only the current branch")
-	cmd.Flags().StringVar(&opts.Color, "color", "auto", "control color usage: auto, always, never")
 	cmd.Flags().BoolVarP(&opts.List, "list", "l", false, "list branches")
-	cmd.Flags().BoolVarP(&opts.Verbose, "verbose", "v", false, "show hash and subject, give twice for upstream branch")
+	cmd.Flags().BoolVarP(&opts.Verbose, "verbose", "v", false, "show the state of the branch in each project")
 	cmd.Flags().StringVar(&opts.SetUpstream, "set-upstream", "", "set upstream for git pull/fetch")
 	cmd.Flags().IntVarP(&opts.Jobs, "jobs", "j", 8, "number of jobs to run in parallel")
 	cmd.Flags().BoolVarP(&opts.Quiet, "quiet", "q", false, "only show errors")
+	cmd.Flags().StringVar(&opts.Format, "format", "text", "output format: text or json")
 	AddManifestFlags(cmd, &CommonManifestOptions{})
 
 	return cmd
@@ -57,6 +82,10 @@ func BranchCmd() *cobra.Command {
 
 // runBranch executes the branch command logic
 func runBranch(opts *BranchOptions, args []string) error {
+	if opts.Format != "text" && opts.Format != "json" {
+		return fmt.Errorf("invalid --format %q, expected text or json", opts.Format)
+	}
+
 	// 初始化日志系
 	log := logger.NewDefaultLogger()
 	if opts.Quiet {
@@ -81,6 +110,9 @@ func runBranch(opts *BranchOptions, args []string) error {
 		log.Error("加载配置文件失败: %v", err)
 		return fmt.Errorf("failed to load config: %w", err)
 	}
+	if err := requireGitClient(cfg, "branches"); err != nil {
+		return err
+	}
 
 	log.Debug("正在解析清单文件 %s...", cfg.ManifestName)
 	parser := manifest.NewParser()
@@ -112,147 +144,336 @@ func runBranch(opts *BranchOptions, args []string) error {
 		log.Debug("共获取到 %d 个项目", len(projects))
 	}
 
-	type branchResult struct {
-		ProjectName   string
-		CurrentBranch string
-		Branches      []string
-		Published     map[string]bool // 分支是否已发布
-		Err           error
+	jobs := opts.Jobs
+	if jobs <= 0 {
+		jobs = 8
 	}
-
-	log.Info("正在获取项目分支信息，并行任务数: %d...", opts.Jobs)
+	log.Debug("正在获取项目分支信息，并行任务数: %d...", jobs)
 
 	// 创建进度显示器
-	prog := progress.NewProgress("Fetching branches", len(projects), opts.Quiet)
+	prog := progress.NewProgress("Fetching branches", len(projects), opts.Quiet || opts.Format == "json")
 
-	results := make(chan branchResult, len(projects))
-	sem := make(chan struct{}, opts.Jobs)
+	results := make([]*projectBranches, len(projects))
+	sem := make(chan struct{}, jobs)
 	var wg sync.WaitGroup
-
-	for _, p := range projects {
-		p := p
+	for i, p := range projects {
 		wg.Add(1)
-		go func() {
+		go func(i int, p *project.Project) {
 			defer wg.Done()
 			sem <- struct{}{}
 			defer func() { <-sem }()
 
 			log.Debug("获取项目 %s 的分支信息...", p.Name)
+			results[i] = collectProjectBranches(opts, p)
+			prog.Update(p.Name)
+		}(i, p)
+	}
+	wg.Wait()
+	prog.Finish("")
 
-			currentBranchBytes, err := p.GitRepo.RunCommand("rev-parse", "--abbrev-ref", "HEAD")
-			if err != nil {
-				log.Error("获取项目 %s 的当前分支失败: %v", p.Name, err)
-				results <- branchResult{ProjectName: p.Name, Err: err}
-				return
-			}
+	failCount := 0
+	for _, res := range results {
+		if res.err != nil {
+			failCount++
+			log.Error("获取项目 %s 的分支信息失败: %v", res.project.Name, res.err)
+		}
+	}
+	log.Debug("共处理 %d 个项目，失败: %d", len(projects), failCount)
 
-			branchArgs := []string{"branch", "--list"}
-			if opts.All {
-				branchArgs = append(branchArgs, "-a")
-			}
+	summaries := summarizeBranches(results)
+	if opts.Format == "json" {
+		return printBranchesJSON(summaries, results)
+	}
+	if !opts.Quiet {
+		printBranchesText(opts, summaries, results)
+	}
+	if failCount > 0 {
+		return fmt.Errorf("failed to read branches of %d project(s)", failCount)
+	}
+	return nil
+}
 
-			branchesOutputBytes, err := p.GitRepo.RunCommand(branchArgs...)
-			if err != nil {
-				log.Error("获取项目 %s 的分支列表失败 %v", p.Name, err)
-				results <- branchResult{ProjectName: p.Name, Err: err}
-				return
-			}
+// projectBranch 表示分支在单个项目中的状态
+type projectBranch struct {
+	current   bool
+	published bool
+	merged    bool
+	empty     bool // 没有领先清单修订版本的提交，不参与发布和合并状态的统计
+	ahead     int  // 领先清单修订版本的提交数
+}
 
-			currentBranch := strings.TrimSpace(string(currentBranchBytes))
-			branches := strings.Split(strings.TrimSpace(string(branchesOutputBytes)), "\n")
+// projectBranches 记录单个项目的本地分支
+type projectBranches struct {
+	project  *project.Project
+	branches map[string]*projectBranch
+	missing  bool
+	err      error
+}
 
-			log.Debug("项目 %s 当前分支: %s, 共有 %d 个分支", p.Name, currentBranch, len(branches))
+// branchSummary 汇总同名分支在所有项目中的状态
+type branchSummary struct {
+	name     string
+	projects []*projectBranches // 拥有该分支的项目，按清单顺序
+}
 
-			// 更新进度
-			prog.Update(p.Name)
+// collectProjectBranches 读取项目的本地分支及其发布、合并状态
+func collectProjectBranches(opts *BranchOptions, p *project.Project) *projectBranches {
+	res := &projectBranches{project: p, branches: make(map[string]*projectBranch)}
+	if _, err := os.Stat(p.Worktree); err != nil {
+		res.missing = true
+		return res
+	}
 
-			results <- branchResult{ProjectName: p.Name, CurrentBranch: currentBranch, Branches: branches}
-		}()
+	names, err := p.GitRepo.LocalBranches()
+	if err != nil {
+		res.err = err
+		return res
 	}
-	// 启动一个goroutine 来关闭结果通道
-	go func() {
-		wg.Wait()
-		close(results)
-	}()
+	current, _ := p.GitRepo.CurrentBranch()
 
-	branchInfo := make(map[string][]string)
-	currentBranches := make(map[string]bool)
-	successCount := 0
-	failCount := 0
+	// 清单修订版本在本地尚不存在时（未同步）无法判断合并状态
+	ref := p.RevisionRef()
+	if ref != "" {
+		if _, err := p.GitRepo.RevParse(ref); err != nil {
+			ref = ""
+		}
+	}
+	trackingBranch := strings.TrimPrefix(p.Revision, "refs/heads/")
 
-	// 收集结果
-	for res := range results {
-		if res.Err != nil {
-			failCount++
-			log.Error("获取项目 %s 的分支信息失败: %v", res.ProjectName, res.Err)
+	for _, name := range names {
+		if name == trackingBranch && !opts.All {
+			continue
+		}
+		if opts.Current && name != current {
 			continue
 		}
 
-		successCount++
-		currentBranches[res.CurrentBranch] = true
-
-		for _, branch := range res.Branches {
-			branch = strings.TrimSpace(branch)
-			if branch == "" {
-				continue
+		b := &projectBranch{current: name == current}
+		if ref != "" {
+			if ahead, _, err := p.GitRepo.AheadBehind(ref, name); err == nil {
+				b.ahead = ahead
+				b.empty = ahead == 0
+			}
+		}
+		// 空分支没有需要发布或合并的提交
+		if b.empty {
+			res.branches[name] = b
+			continue
+		}
+		// 上传到 Gerrit 不产生远程跟踪分支，按上传时记录的 refs/published/<branch> 判断
+		if published, err := p.GitRepo.IsPublished(name); err == nil {
+			b.published = published
+		}
+		if ref != "" {
+			if unmerged, err := p.GitRepo.UnmergedCommits(ref, name); err == nil && len(unmerged) == 0 {
+				b.merged = true
 			}
+		}
+		res.branches[name] = b
+	}
+	return res
+}
 
-			// 处理分支名称，移除前导的 '*' 或空格
-			if strings.HasPrefix(branch, "* ") {
-				branch = strings.TrimPrefix(branch, "* ")
-			} else if strings.HasPrefix(branch, "  ") {
-				branch = strings.TrimPrefix(branch, "  ")
+// summarizeBranches 按分支名汇总各项目的分支
+func summarizeBranches(results []*projectBranches) []*branchSummary {
+	byName := make(map[string]*branchSummary)
+	for _, res := range results {
+		for name := range res.branches {
+			summary, ok := byName[name]
+			if !ok {
+				summary = &branchSummary{name: name}
+				byName[name] = summary
 			}
+			summary.projects = append(summary.projects, res)
+		}
+	}
+
+	summaries := make([]*branchSummary, 0, len(byName))
+	for _, summary := range byName {
+		summaries = append(summaries, summary)
+	}
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].name < summaries[j].name })
+	return summaries
+}
 
-			branchInfo[branch] = append(branchInfo[branch], res.ProjectName)
+// count 返回满足条件的项目数
+func (s *branchSummary) count(match func(*projectBranch) bool) int {
+	n := 0
+	for _, res := range s.projects {
+		if match(res.branches[s.name]) {
+			n++
 		}
 	}
+	return n
+}
 
-	log.Debug("共处理 %d 个项目，成功: %d，失败: %d", len(projects), successCount, failCount)
-	// 对分支名称进行排序，以便有序显示
-	var branchNames []string
-	for branch := range branchInfo {
-		branchNames = append(branchNames, branch)
+// flag 根据满足条件的项目数返回全部（大写）、部分（小写）或空白标记，分支为空的项目不参与统计
+func (s *branchSummary) flag(mark string, match func(*projectBranch) bool) string {
+	total := s.count(func(b *projectBranch) bool { return !b.empty })
+	switch n := s.count(func(b *projectBranch) bool { return !b.empty && match(b) }); {
+	case n == 0:
+		return " "
+	case n == total:
+		return strings.ToUpper(mark)
+	default:
+		return strings.ToLower(mark)
 	}
-	sort.Strings(branchNames)
+}
 
-	// 显示分支信息
-	if !opts.Quiet {
-		// 完成进度
-		prog.Finish("")
-
-		// 创建颜色输出器
-		useColor := color.ShouldUseColor(opts.Color)
-		coloring := color.NewBranchColoring(useColor)
-
-		log.Info("分支信息汇总")
-
-		for _, branch := range branchNames {
-			projs := branchInfo[branch]
-			prefix := " "
-			branchText := branch
-
-			if currentBranches[branch] {
-				prefix = "*"
-				// 当前分支用绿色显示
-				branchText = coloring.Current(branch)
-			} else {
-				// 普通分支
-				branchText = coloring.Local(branch)
-			}
+// printBranchesText 以上游 repo 的格式输出分支概览
+func printBranchesText(opts *BranchOptions, summaries []*branchSummary, results []*projectBranches) {
+	if len(summaries) == 0 {
+		fmt.Println("   (no branches)")
+		return
+	}
 
-			// 处理百分号转义
-			branchDisplay := strings.ReplaceAll(branchText, "%", "%%")
+	coloring := color.NewBranchColoring(opts.UseColor)
 
-			if len(projs) == len(projects) {
-				log.Info("%s %-30s | 所有项目", prefix, branchDisplay)
-			} else {
-				log.Info("%s %-30s | 在项目 %s", prefix, branchDisplay, strings.Join(projs, ", "))
+	// 只有实际存在的项目才参与"不在哪些项目中"的统计
+	var available []*projectBranches
+	for _, res := range results {
+		if !res.missing && res.err == nil {
+			available = append(available, res)
+		}
+	}
+
+	width := 25
+	for _, s := range summaries {
+		if len(s.name) > width {
+			width = len(s.name)
+		}
+	}
+
+	for _, s := range summaries {
+		current := " "
+		if s.count(func(b *projectBranch) bool { return b.current }) > 0 {
+			current = "*"
+		}
+		published := s.flag("P", func(b *projectBranch) bool { return b.published })
+		merged := s.flag("M", func(b *projectBranch) bool { return b.merged })
+
+		name := fmt.Sprintf("%-*s", width, s.name)
+		switch {
+		case current == "*":
+			name = coloring.Current(name)
+		case published == "P":
+			name = coloring.Published(name)
+		default:
+			name = coloring.Local(name)
+		}
+
+		fmt.Printf("%s%s%s %s | %s\n", current, published, merged, name, branchProjectsText(s, available, coloring))
+
+		if opts.Verbose {
+			for _, res := range s.projects {
+				b := res.branches[s.name]
+				var states []string
+				if b.current {
+					states = append(states, "current")
+				}
+				if b.empty {
+					states = append(states, "empty")
+				} else if b.merged {
+					states = append(states, "merged")
+				} else if b.published {
+					states = append(states, "published")
+				}
+				line := fmt.Sprintf("%d commit(s) ahead", b.ahead)
+				if len(states) > 0 {
+					line += ", " + strings.Join(states, ", ")
+				}
+				fmt.Printf("    %-*s %s\n", width, projectRelPath(res.project)+"/", line)
 			}
 		}
+	}
+}
+
+// branchProjectsText 描述分支所在的项目，项目较多时改为列出不包含该分支的项目
+func branchProjectsText(s *branchSummary, available []*projectBranches, coloring *color.BranchColoring) string {
+	if len(s.projects) >= len(available) {
+		return "in all projects"
+	}
+
+	has := make(map[*projectBranches]bool, len(s.projects))
+	var in []string
+	for _, res := range s.projects {
+		has[res] = true
+		in = append(in, projectRelPath(res.project))
+	}
+	var notIn []string
+	for _, res := range available {
+		if !has[res] {
+			notIn = append(notIn, projectRelPath(res.project))
+		}
+	}
+
+	if len(in) <= len(notIn) {
+		return "in " + strings.Join(in, ", ")
+	}
+	return coloring.NotInProject("not in " + strings.Join(notIn, ", "))
+}
 
-		log.Info("\n共有 %d 个分支", len(branchNames))
+// branchJSONProject 分支在单个项目中的JSON表示
+type branchJSONProject struct {
+	Name      string `json:"name"`
+	Path      string `json:"path"`
+	Current   bool   `json:"current"`
+	Published bool   `json:"published"`
+	Merged    bool   `json:"merged"`
+	Empty     bool   `json:"empty"`
+	Ahead     int    `json:"ahead"`
+}
+
+// branchJSON 分支的JSON表示
+type branchJSON struct {
+	Name      string              `json:"name"`
+	Current   bool                `json:"current"`
+	Published bool                `json:"published"`
+	Merged    bool                `json:"merged"`
+	Projects  []branchJSONProject `json:"projects"`
+	NotIn     []string            `json:"not_in"`
+}
+
+// printBranchesJSON 以JSON格式输出所有分支
+func printBranchesJSON(summaries []*branchSummary, results []*projectBranches) error {
+	output := struct {
+		Branches []branchJSON `json:"branches"`
+	}{Branches: []branchJSON{}}
+
+	for _, s := range summaries {
+		entry := branchJSON{
+			Name:      s.name,
+			Current:   s.count(func(b *projectBranch) bool { return b.current }) > 0,
+			Published: s.flag("P", func(b *projectBranch) bool { return b.published }) == "P",
+			Merged:    s.flag("M", func(b *projectBranch) bool { return b.merged }) == "M",
+			NotIn:     []string{},
+		}
+		has := make(map[*projectBranches]bool, len(s.projects))
+		for _, res := range s.projects {
+			has[res] = true
+			b := res.branches[s.name]
+			entry.Projects = append(entry.Projects, branchJSONProject{
+				Name:      res.project.Name,
+				Path:      projectRelPath(res.project),
+				Current:   b.current,
+				Published: b.published,
+				Merged:    b.merged,
+				Empty:     b.empty,
+				Ahead:     b.ahead,
+			})
+		}
+		for _, res := range results {
+			if !has[res] && !res.missing && res.err == nil {
+				entry.NotIn = append(entry.NotIn, projectRelPath(res.project))
+			}
+		}
+		output.Branches = append(output.Branches, entry)
 	}
 
+	data, err := json.MarshalIndent(output, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode branches: %w", err)
+	}
+	fmt.Println(string(data))
 	return nil
 }
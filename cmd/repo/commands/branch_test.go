@@ -0,0 +1,136 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/leopardxu/repo-go/internal/project"
+	"github.com/leopardxu/repo-go/internal/testutil"
+	"github.com/spf13/cobra"
+)
+
+func TestCollectProjectBranches(t *testing.T) {
+	dir := testutil.NewRepo(t)
+	// empty: 刚创建，没有自己的提交
+	testutil.Git(t, dir, "branch", "empty")
+	// published: 提交已上传待审核
+	testutil.Git(t, dir, "checkout", "--quiet", "-b", "published", "--track", "origin/main")
+	testutil.Commit(t, dir, "published.txt", "published", "published")
+	uploadCurrentBranch(t, dir)
+	// amended: 上传之后又有新的提交
+	testutil.Git(t, dir, "checkout", "--quiet", "-b", "amended", "--track", "origin/main")
+	testutil.Commit(t, dir, "amended.txt", "amended", "amended")
+	uploadCurrentBranch(t, dir)
+	testutil.Commit(t, dir, "amended2.txt", "amended", "amended again")
+	// merged: 提交以 cherry-pick 的方式合入了清单修订版本
+	testutil.Git(t, dir, "checkout", "--quiet", "-b", "merged", "main")
+	testutil.Commit(t, dir, "merged.txt", "merged", "merged")
+	// 上游先有了新提交，cherry-pick 得到的提交与分支上的提交不同
+	testutil.Git(t, dir, "checkout", "--quiet", "--detach", "origin/main")
+	testutil.Commit(t, dir, "upstream.txt", "upstream", "upstream")
+	testutil.Git(t, dir, "cherry-pick", "merged")
+	testutil.Git(t, dir, "update-ref", "refs/remotes/origin/main", "HEAD")
+	// local: 未发布也未合并，是当前分支
+	testutil.Git(t, dir, "checkout", "--quiet", "-b", "local", "main")
+	testutil.Commit(t, dir, "local.txt", "local", "local")
+
+	p := testutil.NewProject(dir, "p")
+	res := collectProjectBranches(&BranchOptions{}, p)
+	if res.err != nil {
+		t.Fatal(res.err)
+	}
+	want := map[string]projectBranch{
+		"empty":     {empty: true},
+		"published": {published: true, ahead: 1},
+		"amended":   {ahead: 2},
+		"merged":    {merged: true, ahead: 1},
+		"local":     {current: true, ahead: 1},
+	}
+	if len(res.branches) != len(want) {
+		t.Errorf("branches = %v, want %v", res.branches, want)
+	}
+	for name, w := range want {
+		if b := res.branches[name]; b == nil || *b != w {
+			t.Errorf("branch %s = %+v, want %+v", name, b, w)
+		}
+	}
+
+	// 与清单修订版本同名的分支只在 --all 时列出，--current 只看当前分支
+	if res := collectProjectBranches(&BranchOptions{All: true}, p); res.branches["main"] == nil {
+		t.Error("--all did not list the main branch")
+	}
+	if res := collectProjectBranches(&BranchOptions{Current: true}, p); len(res.branches) != 1 || res.branches["local"] == nil {
+		t.Errorf("--current listed %v, want only local", res.branches)
+	}
+}
+
+func TestBranchSummaryFlags(t *testing.T) {
+	newResult := func(name string, branches map[string]*projectBranch) *projectBranches {
+		return &projectBranches{project: &project.Project{Name: name, Path: name}, branches: branches}
+	}
+	results := []*projectBranches{
+		newResult("a", map[string]*projectBranch{
+			"topic": {published: true, merged: true, ahead: 1},
+			"wip":   {current: true, ahead: 2},
+			"new":   {empty: true},
+			"mixed": {empty: true},
+		}),
+		newResult("b", map[string]*projectBranch{
+			"topic": {published: true, ahead: 1},
+			"new":   {empty: true},
+			"mixed": {published: true, merged: true, ahead: 1},
+		}),
+	}
+
+	summaries := summarizeBranches(results)
+	var names []string
+	for _, s := range summaries {
+		names = append(names, s.name)
+	}
+	if got, want := names, []string{"mixed", "new", "topic", "wip"}; len(got) != len(want) || got[0] != want[0] || got[3] != want[3] {
+		t.Fatalf("summarizeBranches() names = %v, want %v", got, want)
+	}
+
+	published := func(b *projectBranch) bool { return b.published }
+	merged := func(b *projectBranch) bool { return b.merged }
+	tests := []struct {
+		branch       string
+		wantP, wantM string
+	}{
+		{"topic", "P", "m"},
+		{"wip", " ", " "},
+		// 空分支没有可发布或合并的提交
+		{"new", " ", " "},
+		// 空的项目不参与统计
+		{"mixed", "P", "M"},
+	}
+	for _, tt := range tests {
+		for _, s := range summaries {
+			if s.name != tt.branch {
+				continue
+			}
+			if got := s.flag("P", published); got != tt.wantP {
+				t.Errorf("%s published flag = %q, want %q", tt.branch, got, tt.wantP)
+			}
+			if got := s.flag("M", merged); got != tt.wantM {
+				t.Errorf("%s merged flag = %q, want %q", tt.branch, got, tt.wantM)
+			}
+		}
+	}
+}
+
+func TestBranchCmdUsesGlobalColor(t *testing.T) {
+	cmd := BranchCmd()
+	if cmd.Flags().Lookup("color") != nil {
+		t.Fatal("branches defines its own --color flag, which shadows the global one")
+	}
+
+	root := &cobra.Command{Use: "repo"}
+	root.PersistentFlags().String("color", "auto", "control color usage: auto, always, never")
+	root.AddCommand(cmd)
+	if err := cmd.ParseFlags([]string{"--color=never"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := cmd.Flags().GetString("color"); got != "never" {
+		t.Errorf("--color = %q, want the global flag value never", got)
+	}
+}
@@ -1,8 +1,11 @@
 package commands
 
 import (
+	"context"
 	"fmt"
+	"os"
 	"strings"
+	"sync"
 
 	"github.com/leopardxu/repo-go/internal/config"
 	"github.com/leopardxu/repo-go/internal/logger"
@@ -15,10 +18,11 @@ import (
 // CheckoutOptions holds the options for the checkout command
 // 简化参数结构体，与原生git-repo保持一致
 type CheckoutOptions struct {
-	JobsCheckout int
-	Quiet        bool
-	Verbose      bool
-	Config       *config.Config
+	JobsCheckout     int
+	Quiet            bool
+	Verbose          bool
+	DetachToManifest bool // 将所有项目分离到清单修订版本，保留本地分支
+	Config           *config.Config
 	CommonManifestOptions
 }
 
@@ -26,28 +30,39 @@ type CheckoutOptions struct {
 func CheckoutCmd() *cobra.Command {
 	opts := &CheckoutOptions{}
 	cmd := &cobra.Command{
-		Use:   "checkout <branchname> [<project>...]",
-		Short: "Checkout a branch for development",
-		Long:  `Checks out an existing branch that was previously created by 'repo start'.`,
-		Args:  cobra.MinimumNArgs(1),
+		Use:               "checkout {<branchname> | --detach-to-manifest} [<project>...]",
+		Short:             "Checkout a branch for development",
+		ValidArgsFunction: completeBranchThenProjects("detach-to-manifest"),
+		Long: `Checks out an existing branch that was previously created by 'repo start'.
+
+With --detach-to-manifest, no branch name is given: every project is moved to
+a detached HEAD at its manifest revision. Local branches are kept, so they
+can be checked out again later.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if !opts.DetachToManifest && len(args) < 1 {
+				return fmt.Errorf("missing branch name")
+			}
 			cfg, err := config.Load()
 			if err != nil {
 				return fmt.Errorf("failed to load config: %w", err)
 			}
+			if err := requireGitClient(cfg, "checkout"); err != nil {
+				return err
+			}
 			opts.Config = cfg
-			return runCheckout(opts, args)
+			return runCheckout(cmd.Context(), opts, args)
 		},
 	}
 	cmd.Flags().IntVarP(&opts.JobsCheckout, "jobs", "j", 8, "number of projects to checkout in parallel")
 	cmd.Flags().BoolVarP(&opts.Quiet, "quiet", "q", false, "only show errors")
 	cmd.Flags().BoolVarP(&opts.Verbose, "verbose", "v", false, "show all output")
+	cmd.Flags().BoolVar(&opts.DetachToManifest, "detach-to-manifest", false, "detach every project to its manifest revision, keeping local branches")
 	AddManifestFlags(cmd, &opts.CommonManifestOptions)
 	return cmd
 }
 
 // runCheckout executes the checkout command logic
-func runCheckout(opts *CheckoutOptions, args []string) error {
+func runCheckout(ctx context.Context, opts *CheckoutOptions, args []string) error {
 	// 初始化日志记录器
 	log := logger.NewDefaultLogger()
 	if opts.Verbose {
@@ -66,15 +81,15 @@ func runCheckout(opts *CheckoutOptions, args []string) error {
 	}
 	defer RestoreWorkDir(originalDir, log)
 
-	if len(args) < 1 {
-		return fmt.Errorf("missing branch name")
+	var branchName string
+	projectNames := args
+	if !opts.DetachToManifest {
+		branchName = args[0]
+		projectNames = args[1:]
+		log.Info("正在检出分支 '%s'", branchName)
 	}
-	branchName := args[0]
-	projectNames := args[1:]
 	cfg := opts.Config
 
-	log.Info("正在检出分'%s'", branchName)
-
 	parser := manifest.NewParser()
 	manifestObj, err := parser.ParseFromFile(cfg.ManifestName, strings.Split(cfg.Groups, ","))
 	if err != nil {
@@ -100,6 +115,10 @@ func runCheckout(opts *CheckoutOptions, args []string) error {
 		}
 	}
 
+	if opts.DetachToManifest {
+		return detachToManifest(ctx, opts, projects, log)
+	}
+
 	log.Info("开始检出 %d 个项目", len(projects))
 
 	// 使用 repo_sync 包中的 Engine 进行检出操作
@@ -109,7 +128,7 @@ func runCheckout(opts *CheckoutOptions, args []string) error {
 		Verbose:      opts.Verbose,
 	}
 
-	engine := repo_sync.NewEngine(syncOpts, nil, log)
+	engine := repo_sync.NewEngineWithContext(ctx, syncOpts, nil, log)
 	// 设置分支名称
 	engine.SetBranchName(branchName)
 	// 执行检出操作
@@ -132,3 +151,108 @@ func runCheckout(opts *CheckoutOptions, args []string) error {
 
 	return nil
 }
+
+// detachResult 记录单个项目分离到清单修订版本的结果
+type detachResult struct {
+	project *project.Project
+	branch  string // 分离前所在的分支
+	commit  string
+	skipped bool // 已经处于清单修订版本
+	err     error
+}
+
+// detachToManifest 将项目的HEAD分离到清单修订版本，本地分支保持不变
+func detachToManifest(ctx context.Context, opts *CheckoutOptions, projects []*project.Project, log logger.Logger) error {
+	jobs := opts.JobsCheckout
+	if jobs <= 0 {
+		jobs = 8
+	}
+
+	results := make([]*detachResult, len(projects))
+	sem := make(chan struct{}, jobs)
+	var wg sync.WaitGroup
+	for i, p := range projects {
+		wg.Add(1)
+		go func(i int, p *project.Project) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			results[i] = detachProject(ctx, p)
+		}(i, p)
+	}
+	wg.Wait()
+
+	failed := 0
+	for _, res := range results {
+		path := projectRelPath(res.project)
+		switch {
+		case res.err != nil:
+			failed++
+			log.Error("项目 %s: %v", path, res.err)
+		case res.skipped:
+			log.Debug("项目 %s 已处于清单修订版本", path)
+		case !opts.Quiet:
+			if res.branch != "" {
+				fmt.Printf("project %s/: detached from %s at %s\n", path, res.branch, res.commit)
+			} else {
+				fmt.Printf("project %s/: detached at %s\n", path, res.commit)
+			}
+		}
+	}
+
+	if !opts.Quiet {
+		log.Info("已将 %d 个项目分离到清单修订版本, 失败 %d 个", len(projects)-failed, failed)
+	}
+	if failed > 0 {
+		return fmt.Errorf("checkout failed for %d projects", failed)
+	}
+	return nil
+}
+
+// detachProject 将单个项目分离到清单修订版本
+func detachProject(ctx context.Context, p *project.Project) *detachResult {
+	res := &detachResult{project: p}
+	if _, err := os.Stat(p.Worktree); err != nil {
+		res.err = fmt.Errorf("not synced, run repo sync first")
+		return res
+	}
+	l, err := lockProject(ctx, p)
+	if err != nil {
+		res.err = err
+		return res
+	}
+	defer l.Release()
+	if p.GitRepo.RebaseInProgress() {
+		res.err = fmt.Errorf("a rebase is in progress, run 'repo rebase --continue' or 'repo rebase --abort' first")
+		return res
+	}
+
+	ref := p.RevisionRef()
+	target, err := p.GitRepo.RevParse(ref)
+	if err != nil {
+		// 标签等修订版本没有对应的远程跟踪引用
+		if target, err = p.GitRepo.RevParse(p.Revision); err != nil {
+			res.err = fmt.Errorf("manifest revision %s not found, run repo sync first", p.Revision)
+			return res
+		}
+	}
+	if len(target) > 12 {
+		res.commit = target[:12]
+	}
+
+	branch, _ := p.GitRepo.CurrentBranch()
+	head, _ := p.GitRepo.RevParse("HEAD")
+	if strings.HasPrefix(branch, "HEAD detached") && head == target {
+		res.skipped = true
+		return res
+	}
+	if !strings.HasPrefix(branch, "HEAD detached") {
+		res.branch = branch
+	}
+
+	if _, err := p.GitRepo.RunCommandContext(ctx, "checkout", "--quiet", "--detach", target); err != nil {
+		res.err = err
+	}
+	p.GitRepo.ClearCache()
+	return res
+}
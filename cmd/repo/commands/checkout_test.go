@@ -0,0 +1,89 @@
+package commands
+
+import (
+	"context"
+	"testing"
+
+	"github.com/leopardxu/repo-go/internal/git"
+	"github.com/leopardxu/repo-go/internal/logger"
+	"github.com/leopardxu/repo-go/internal/project"
+	"github.com/leopardxu/repo-go/internal/testutil"
+)
+
+// newDetachFixture 创建一个检出在本地分支 topic 上的项目：
+// v1.0 标签指向第一个提交，origin/main 指向第二个提交
+func newDetachFixture(t *testing.T, path string) (*project.Project, map[string]string) {
+	t.Helper()
+	dir := testutil.NewRepo(t)
+	testutil.Git(t, dir, "tag", "v1.0")
+	testutil.Commit(t, dir, "f", "upstream\n", "upstream")
+	testutil.Git(t, dir, "update-ref", "refs/remotes/origin/main", "HEAD")
+	testutil.Git(t, dir, "checkout", "--quiet", "-b", "topic")
+	testutil.Commit(t, dir, "f", "topic\n", "topic")
+
+	commits := map[string]string{
+		"tag":      testutil.Git(t, dir, "rev-parse", "v1.0^{commit}"),
+		"upstream": testutil.Git(t, dir, "rev-parse", "origin/main"),
+		"topic":    testutil.Git(t, dir, "rev-parse", "topic"),
+	}
+	p := testutil.NewProject(dir, path)
+	p.Revision = ""
+	return p, commits
+}
+
+func TestDetachToManifest(t *testing.T) {
+	log := logger.NewDefaultLogger()
+	log.SetLevel(logger.LogLevelError)
+
+	upstream, upstreamCommits := newDetachFixture(t, "upstream")
+	upstream.Revision = "main"
+	sha, shaCommits := newDetachFixture(t, "sha")
+	sha.Revision = shaCommits["tag"]
+	tag, tagCommits := newDetachFixture(t, "tag")
+	tag.Revision = "v1.0"
+	fullTag, fullTagCommits := newDetachFixture(t, "full-tag")
+	fullTag.Revision = "refs/tags/v1.0"
+
+	tests := []struct {
+		p       *project.Project
+		commits map[string]string
+		want    string
+	}{
+		{upstream, upstreamCommits, "upstream"},
+		{sha, shaCommits, "tag"},
+		{tag, tagCommits, "tag"},
+		{fullTag, fullTagCommits, "tag"},
+	}
+	var projects []*project.Project
+	for _, tt := range tests {
+		projects = append(projects, tt.p)
+	}
+	if err := detachToManifest(context.Background(), &CheckoutOptions{Quiet: true}, projects, log); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		dir := tt.p.Worktree
+		if got := testutil.Git(t, dir, "rev-parse", "HEAD"); got != tt.commits[tt.want] {
+			t.Errorf("project %s: HEAD = %s, want %s commit %s", tt.p.Path, got, tt.want, tt.commits[tt.want])
+		}
+		if _, err := git.NewRunner().RunInDir(dir, "symbolic-ref", "--quiet", "HEAD"); err == nil {
+			t.Errorf("project %s: HEAD is still on a branch", tt.p.Path)
+		}
+		// 本地分支保持不变
+		if got := testutil.Git(t, dir, "rev-parse", "refs/heads/topic"); got != tt.commits["topic"] {
+			t.Errorf("project %s: topic = %s, want %s", tt.p.Path, got, tt.commits["topic"])
+		}
+	}
+
+	// 已经处于清单修订版本时再次执行不报错
+	if err := detachToManifest(context.Background(), &CheckoutOptions{Quiet: true}, projects, log); err != nil {
+		t.Errorf("second detachToManifest() = %v", err)
+	}
+
+	// 清单修订版本不存在（未同步）时报告失败
+	missing, _ := newDetachFixture(t, "missing")
+	missing.Revision = "no-such-branch"
+	if err := detachToManifest(context.Background(), &CheckoutOptions{Quiet: true}, []*project.Project{missing}, log); err == nil {
+		t.Error("detachToManifest() with a missing revision succeeded")
+	}
+}
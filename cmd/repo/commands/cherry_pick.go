@@ -1,6 +1,7 @@
 package commands
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
@@ -14,11 +15,14 @@ import (
 
 // CherryPickOptions holds the options for the cherry-pick command
 type CherryPickOptions struct {
-	All     bool
-	Jobs    int
-	Quiet   bool
-	Verbose bool
-	Config  *config.Config
+	All      bool
+	Jobs     int
+	Quiet    bool
+	Verbose  bool
+	Continue bool
+	Abort    bool
+	Skip     bool
+	Config   *config.Config
 	CommonManifestOptions
 }
 
@@ -26,29 +30,56 @@ type CherryPickOptions struct {
 func CherryPickCmd() *cobra.Command {
 	opts := &CherryPickOptions{}
 	cmd := &cobra.Command{
-		Use:   "cherry-pick <commit> [<project>...]",
-		Short: "Cherry-pick a commit onto the current branch",
-		Long:  `Applies the changes introduced by the named commit(s) onto the current branch.`,
-		Args:  cobra.MinimumNArgs(1),
+		Use:               "cherry-pick {<change-id> | <commit> | <rev1>..<rev2>} [<project>...]",
+		Short:             "Cherry-pick a change onto the current branch",
+		ValidArgsFunction: completeProjectsAfter(1),
+		Long: `Applies the changes introduced by the named commit(s) onto the current branch
+of every selected project.
+
+The change is resolved separately in each project:
+  <change-id>    the newest commit carrying the 'Change-Id:' trailer; projects
+                 whose current branch already has the change are skipped
+  <rev1>..<rev2> every commit in the range, applied oldest first; the range
+                 is listed once in the first project that has both revisions
+                 and each commit is looked up by its 'Change-Id:' trailer,
+                 commits already on the current branch are skipped
+  <commit>       a single revision
+
+Projects in which the change cannot be found are skipped. Each picked commit
+records its origin in a '(cherry picked from commit ...)' line. When a pick
+stops with conflicts, resolve them and run 'repo cherry-pick --continue', use
+'repo cherry-pick --skip' to drop the current commit, or 'repo cherry-pick
+--abort' to give up; these act on every project that was left in the middle
+of a cherry-pick.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if cherryPickAction(opts) == "" && len(args) < 1 {
+				return fmt.Errorf("missing change to cherry-pick")
+			}
 			cfg, err := config.Load()
 			if err != nil {
 				return fmt.Errorf("failed to load config: %w", err)
 			}
+			if err := requireGitClient(cfg, "cherry-pick"); err != nil {
+				return err
+			}
 			opts.Config = cfg
-			return runCherryPick(opts, args)
+			return runCherryPick(cmd.Context(), opts, args)
 		},
 	}
 	cmd.Flags().BoolVar(&opts.All, "all", false, "cherry-pick in all projects")
 	cmd.Flags().IntVarP(&opts.Jobs, "jobs", "j", 8, "number of projects to cherry-pick in parallel")
 	cmd.Flags().BoolVarP(&opts.Quiet, "quiet", "q", false, "only show errors")
 	cmd.Flags().BoolVarP(&opts.Verbose, "verbose", "v", false, "show all output")
+	cmd.Flags().BoolVar(&opts.Continue, "continue", false, "continue the cherry-pick after resolving conflicts")
+	cmd.Flags().BoolVar(&opts.Abort, "abort", false, "abort the cherry-pick in every project")
+	cmd.Flags().BoolVar(&opts.Skip, "skip", false, "skip the current commit and continue the cherry-pick")
+	cmd.MarkFlagsMutuallyExclusive("continue", "abort", "skip")
 	AddManifestFlags(cmd, &opts.CommonManifestOptions)
 	return cmd
 }
 
 // runCherryPick executes the cherry-pick command logic
-func runCherryPick(opts *CherryPickOptions, args []string) error {
+func runCherryPick(ctx context.Context, opts *CherryPickOptions, args []string) error {
 	// 初始化日志记录器
 	log := logger.NewDefaultLogger()
 	if opts.Verbose {
@@ -67,15 +98,15 @@ func runCherryPick(opts *CherryPickOptions, args []string) error {
 	}
 	defer RestoreWorkDir(originalDir, log)
 
-	if len(args) < 1 {
-		return fmt.Errorf("missing commit hash")
+	var spec string
+	action := cherryPickAction(opts)
+	projectNames := args
+	if action == "" {
+		spec = args[0]
+		projectNames = args[1:]
 	}
-	commit := args[0]
-	projectNames := args[1:]
 	cfg := opts.Config
 
-	log.Info("正在应用 cherry-pick '%s'", commit)
-
 	parser := manifest.NewParser()
 	manifestObj, err := parser.ParseFromFile(cfg.ManifestName, strings.Split(cfg.Groups, ","))
 	if err != nil {
@@ -101,35 +132,103 @@ func runCherryPick(opts *CherryPickOptions, args []string) error {
 		}
 	}
 
-	log.Info("开始在 %d 个项目中应用 cherry-pick", len(projects))
-
 	// 使用 repo_sync 包中Engine 进行 cherry-pick 操作
 	syncOpts := &repo_sync.Options{
 		Jobs:    opts.Jobs,
 		Quiet:   opts.Quiet,
 		Verbose: opts.Verbose,
 	}
+	engine := repo_sync.NewEngineWithContext(ctx, syncOpts, nil, log)
 
-	engine := repo_sync.NewEngine(syncOpts, nil, log)
-	// 设置提交哈希
-	engine.SetCommitHash(commit)
-	// 执行 cherry-pick 操作
-	err = engine.CherryPickCommit(projects)
-	if err != nil {
-		log.Error("Cherry-pick 失败: %v", err)
-		return err
+	if action != "" {
+		err = engine.ResumeCherryPick(projects, action)
+	} else {
+		log.Info("开始在 %d 个项目中应用 cherry-pick '%s'", len(projects), spec)
+		engine.SetCherryPickSpec(spec)
+		err = engine.CherryPickCommit(projects)
 	}
 
-	// 获取 cherry-pick 结果
-	success, failed := engine.GetCherryPickStats()
+	results := engine.CherryPickResults()
+	if action != "" && len(results) == 0 {
+		log.Info("没有正在进行的 cherry-pick")
+		return nil
+	}
+	printCherryPickResults(opts, results)
 
+	success, failed := engine.GetCherryPickStats()
 	if !opts.Quiet {
-		log.Info("Cherry-pick 提交 '%s' 完成: %d 成功, %d 失败", commit, success, failed)
+		log.Info("Cherry-pick 完成: %d 成功, %d 失败", success, failed)
+	}
+	for _, res := range results {
+		if res.State == repo_sync.CherryPickConflict {
+			log.Error("请解决冲突后使用 'repo cherry-pick --continue' 继续，使用 --skip 跳过当前提交，或使用 --abort 放弃")
+			break
+		}
+	}
+	return err
+}
+
+// cherryPickAction 返回恢复操作对应的 git cherry-pick 参数，未指定时返回空字符串
+func cherryPickAction(opts *CherryPickOptions) string {
+	switch {
+	case opts.Continue:
+		return "--continue"
+	case opts.Abort:
+		return "--abort"
+	case opts.Skip:
+		return "--skip"
 	}
+	return ""
+}
 
-	if failed > 0 {
-		return fmt.Errorf("cherry-pick failed for %d projects", failed)
+// printCherryPickResults 按清单顺序输出每个项目的结果
+func printCherryPickResults(opts *CherryPickOptions, results []repo_sync.CherryPickResult) {
+	applied, notFound := 0, 0
+	for _, res := range results {
+		var lines []string
+		switch res.State {
+		case repo_sync.CherryPickApplied:
+			applied++
+			switch {
+			case opts.Abort:
+				lines = append(lines, "cherry-pick aborted")
+			case opts.Continue, opts.Skip:
+				lines = append(lines, "cherry-pick completed")
+			default:
+				lines = append(lines, fmt.Sprintf("picked %d commit(s)", len(res.Commits)))
+			}
+		case repo_sync.CherryPickPresent:
+			if !opts.Quiet {
+				lines = append(lines, "change already present")
+			}
+		case repo_sync.CherryPickNotFound:
+			notFound++
+			if opts.Verbose {
+				lines = append(lines, "change not found")
+			}
+		case repo_sync.CherryPickConflict:
+			if len(res.Conflicts) == 0 {
+				// 提交在解决冲突后或本身已经为空
+				lines = append(lines, "cherry-pick stopped, the commit is empty")
+			} else {
+				lines = append(lines, "conflict, cherry-pick stopped")
+			}
+			for _, file := range res.Conflicts {
+				lines = append(lines, "  CONFLICT "+file)
+			}
+		case repo_sync.CherryPickFailed:
+			lines = append(lines, fmt.Sprintf("error: %v", res.Error))
+		}
+		if len(lines) == 0 {
+			continue
+		}
+		fmt.Printf("project %s/\n", projectRelPath(res.Project))
+		for _, line := range lines {
+			fmt.Printf("  %s\n", line)
+		}
 	}
 
-	return nil
+	if cherryPickAction(opts) == "" && applied == 0 && notFound == len(results) {
+		fmt.Println("change not found in any project")
+	}
 }
@@ -1,11 +1,15 @@
 package commands
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"path/filepath"
 
 	"github.com/leopardxu/repo-go/internal/config"
+	"github.com/leopardxu/repo-go/internal/lock"
 	"github.com/leopardxu/repo-go/internal/logger"
+	"github.com/leopardxu/repo-go/internal/project"
 )
 
 // EnsureRepoRoot 确保当前工作目录在repo根目录下
@@ -55,3 +59,38 @@ func RestoreWorkDir(originalDir string, log logger.Logger) error {
 
 	return nil
 }
+
+// projectRelPath 返回项目相对于repo根目录（当前工作目录）的路径，使用/分隔
+func projectRelPath(p *project.Project) string {
+	if !filepath.IsAbs(p.Path) {
+		return filepath.ToSlash(filepath.Clean(p.Path))
+	}
+	if cwd, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(cwd, p.Path); err == nil {
+			return filepath.ToSlash(rel)
+		}
+	}
+	return filepath.ToSlash(p.Path)
+}
+
+// lockProject 获取项目锁，防止 repo sync 等其他 repo 进程同时修改该项目
+// 在 EnsureRepoRoot 之后调用，当前目录即仓库根目录
+func lockProject(ctx context.Context, p *project.Project) (*lock.Lock, error) {
+	root, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	path := p.Path
+	if path == "" {
+		path = p.Name
+	}
+	return lock.Acquire(ctx, lock.ProjectPath(root, path), lock.Exclusive)
+}
+
+// requireGitClient 在归档检出中拒绝需要 git 仓库的命令，归档检出的项目只有导出的文件，没有 .git
+func requireGitClient(cfg *config.Config, name string) error {
+	if cfg != nil && cfg.Archive {
+		return fmt.Errorf("repo %s is not available in an archive client: projects are exported without .git; run repo init without --archive to get git checkouts", name)
+	}
+	return nil
+}
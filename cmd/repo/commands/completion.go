@@ -0,0 +1,303 @@
+package commands
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/leopardxu/repo-go/internal/config"
+	"github.com/leopardxu/repo-go/internal/git"
+	"github.com/leopardxu/repo-go/internal/logger"
+	"github.com/leopardxu/repo-go/internal/manifest"
+	"github.com/spf13/cobra"
+)
+
+// CompletionCmd 返回completion命令
+func CompletionCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "completion {bash | zsh | fish | powershell}",
+		Short: "Generate the shell completion script",
+		Long: `Generate the completion script of repo for the specified shell.
+
+Besides subcommands and options, the script completes project names and paths,
+manifest groups for -g, local topic branches for abandon, checkout and
+upload --branch, and manifest files for init -m, read from the current client.
+
+To load completions:
+
+  bash:  source <(repo completion bash)
+  zsh:   repo completion zsh > "${fpath[1]}/_repo"
+  fish:  repo completion fish > ~/.config/fish/completions/repo.fish
+  powershell:
+         repo completion powershell | Out-String | Invoke-Expression`,
+		Args:                  cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+		ValidArgs:             []string{"bash", "zsh", "fish", "powershell"},
+		DisableFlagsInUseLine: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			root := cmd.Root()
+			out := cmd.OutOrStdout()
+			switch args[0] {
+			case "bash":
+				return root.GenBashCompletionV2(out, true)
+			case "zsh":
+				return root.GenZshCompletion(out)
+			case "fish":
+				return root.GenFishCompletion(out, true)
+			case "powershell":
+				return root.GenPowerShellCompletionWithDesc(out)
+			}
+			return fmt.Errorf("unsupported shell %q", args[0])
+		},
+	}
+}
+
+// completionClient 是补全时读取的客户端信息，同一次补全只加载一次，清单通过解析器的缓存读取
+type completionClient struct {
+	root     string
+	manifest *manifest.Manifest
+	groups   []string // 客户端配置的清单组
+}
+
+var (
+	completionOnce   sync.Once
+	completionLoaded *completionClient
+)
+
+// loadCompletionClient 加载当前客户端的配置和清单，不在客户端中时返回 nil，清单无法加载时 manifest 为 nil
+// 补全的输出就是候选项，所以加载时只保留错误日志，且错误日志输出到标准错误
+func loadCompletionClient() *completionClient {
+	completionOnce.Do(func() {
+		logger.SetLevel(logger.LogLevelError)
+
+		root := completionRepoRoot()
+		if root == "" {
+			return
+		}
+		c := &completionClient{root: root}
+		// 补全不应修改客户端，只读取配置，不迁移
+		cfg, err := config.LoadReadOnly(root)
+		if err != nil {
+			completionLoaded = c
+			return
+		}
+		if cfg.Groups != "" {
+			c.groups = strings.Split(cfg.Groups, ",")
+		}
+		parser := manifest.NewParser()
+		parser.SetSilentMode(true)
+		// 解析全部项目，按组过滤在补全时进行，组的候选项需要所有项目的组
+		if m, err := parser.ParseFromFile(cfg.ManifestName, nil); err == nil {
+			c.manifest = m
+		}
+		completionLoaded = c
+	})
+	return completionLoaded
+}
+
+// completionRepoRoot 从当前目录向上查找客户端根目录，找不到时返回空字符串，不输出日志
+func completionRepoRoot() string {
+	dir, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+	for {
+		if info, err := os.Stat(filepath.Join(dir, ".repo")); err == nil && info.IsDir() {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// projects 返回客户端配置的组中的项目
+func (c *completionClient) projects() []manifest.Project {
+	if c == nil || c.manifest == nil {
+		return nil
+	}
+	if len(c.groups) == 0 {
+		return c.manifest.Projects
+	}
+	var projects []manifest.Project
+	for _, p := range c.manifest.Projects {
+		if p.InGroups(c.groups) {
+			projects = append(projects, p)
+		}
+	}
+	return projects
+}
+
+// completeProjects 补全项目名称和路径，已经输入的项目不再提示
+func completeProjects(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	used := make(map[string]bool, len(args))
+	for _, arg := range args {
+		used[arg] = true
+	}
+	seen := make(map[string]bool)
+	var candidates []string
+	for _, p := range loadCompletionClient().projects() {
+		for _, s := range []string{p.Name, p.Path} {
+			if s == "" || seen[s] || used[s] || !strings.HasPrefix(s, toComplete) {
+				continue
+			}
+			seen[s] = true
+			candidates = append(candidates, s)
+		}
+	}
+	sort.Strings(candidates)
+	return candidates, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeProjectsAfter 返回补全项目的函数，前 n 个位置参数不是项目，不提供候选项
+func completeProjectsAfter(n int) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) < n {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return completeProjects(cmd, args[n:], toComplete)
+	}
+}
+
+// completeBranchThenProjects 返回第一个参数补全本地分支、之后补全项目的函数
+// 设置了 skipFlag 时命令不需要分支名，所有参数都补全项目
+func completeBranchThenProjects(skipFlag string) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if skip, _ := cmd.Flags().GetBool(skipFlag); skip {
+			return completeProjects(cmd, args, toComplete)
+		}
+		if len(args) == 0 {
+			return completeBranches(cmd, args, toComplete)
+		}
+		return completeProjects(cmd, args[1:], toComplete)
+	}
+}
+
+// completeGroups 补全逗号分隔的清单组，只补全最后一个逗号之后的部分
+func completeGroups(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	prefix, current := "", toComplete
+	if i := strings.LastIndex(toComplete, ","); i >= 0 {
+		prefix, current = toComplete[:i+1], toComplete[i+1:]
+	}
+	negate := ""
+	if strings.HasPrefix(current, "-") {
+		negate, current = "-", current[1:]
+	}
+
+	groups := map[string]bool{"all": true, "default": true}
+	if c := loadCompletionClient(); c != nil && c.manifest != nil {
+		for _, p := range c.manifest.Projects {
+			for _, g := range strings.Split(p.Groups, ",") {
+				if g = strings.TrimSpace(g); g != "" {
+					groups[g] = true
+				}
+			}
+		}
+	}
+	var candidates []string
+	for g := range groups {
+		if strings.HasPrefix(g, current) {
+			candidates = append(candidates, prefix+negate+g)
+		}
+	}
+	sort.Strings(candidates)
+	return candidates, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveNoSpace
+}
+
+// completeBranches 补全所有项目中的本地主题分支
+func completeBranches(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	c := loadCompletionClient()
+	branches := make(map[string]bool)
+	for _, p := range c.projects() {
+		path := p.Path
+		if path == "" {
+			path = p.Name
+		}
+		for _, b := range localBranches(filepath.Join(c.root, path)) {
+			branches[b] = true
+		}
+	}
+	var candidates []string
+	for b := range branches {
+		if strings.HasPrefix(b, toComplete) {
+			candidates = append(candidates, b)
+		}
+	}
+	sort.Strings(candidates)
+	return candidates, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeManifestFiles 补全 .repo/manifests 中的清单文件
+func completeManifestFiles(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	root := completionRepoRoot()
+	if root == "" {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	dir := filepath.Join(root, ".repo", "manifests")
+	var candidates []string
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err == nil && strings.HasSuffix(rel, ".xml") && strings.HasPrefix(filepath.ToSlash(rel), toComplete) {
+			candidates = append(candidates, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	return candidates, cobra.ShellCompDirectiveNoFileComp
+}
+
+// localBranches 读取工作树中 git 仓库的本地分支，直接读取引用文件，避免为每个项目启动 git
+func localBranches(worktree string) []string {
+	gitDir := git.CommonDir(worktree)
+	if gitDir == "" {
+		return nil
+	}
+
+	// 打包后又更新的分支同时出现在引用文件和 packed-refs 中
+	var branches []string
+	seen := make(map[string]bool)
+	add := func(branch string) {
+		if !seen[branch] {
+			seen[branch] = true
+			branches = append(branches, branch)
+		}
+	}
+	heads := filepath.Join(gitDir, "refs", "heads")
+	filepath.WalkDir(heads, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		if rel, err := filepath.Rel(heads, path); err == nil {
+			add(filepath.ToSlash(rel))
+		}
+		return nil
+	})
+
+	f, err := os.Open(filepath.Join(gitDir, "packed-refs"))
+	if err != nil {
+		return branches
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && strings.HasPrefix(fields[1], "refs/heads/") {
+			add(strings.TrimPrefix(fields[1], "refs/heads/"))
+		}
+	}
+	return branches
+}
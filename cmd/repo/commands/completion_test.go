@@ -0,0 +1,78 @@
+package commands
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/leopardxu/repo-go/internal/manifest"
+	"github.com/leopardxu/repo-go/internal/testutil"
+	"github.com/spf13/cobra"
+)
+
+// setCompletionClient 让补全使用客户端 c，而不是从当前目录加载
+func setCompletionClient(t *testing.T, c *completionClient) {
+	completionOnce.Do(func() {})
+	old := completionLoaded
+	completionLoaded = c
+	t.Cleanup(func() { completionLoaded = old })
+}
+
+func TestLocalBranches(t *testing.T) {
+	dir := testutil.NewRepo(t)
+	testutil.Git(t, dir, "branch", "packed")
+	testutil.Git(t, dir, "branch", "topic/packed")
+	testutil.Git(t, dir, "pack-refs", "--all")
+	// 打包后新建的分支只有引用文件，main 打包后又更新，两处都有
+	testutil.Git(t, dir, "branch", "loose")
+	testutil.Git(t, dir, "branch", "topic/loose")
+	testutil.Commit(t, dir, "f", "more\n", "more")
+	// 已删除的打包分支不再列出
+	testutil.Git(t, dir, "branch", "gone", "packed")
+	testutil.Git(t, dir, "pack-refs", "--all")
+	testutil.Git(t, dir, "branch", "-D", "gone")
+
+	got := localBranches(dir)
+	sort.Strings(got)
+	if want := []string{"loose", "main", "packed", "topic/loose", "topic/packed"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("localBranches() = %v, want %v", got, want)
+	}
+	if got := localBranches(t.TempDir()); got != nil {
+		t.Errorf("localBranches() outside a repository = %v", got)
+	}
+}
+
+func TestCompleteGroups(t *testing.T) {
+	setCompletionClient(t, &completionClient{manifest: &manifest.Manifest{Projects: []manifest.Project{
+		{Name: "a", Groups: "docs, tools"},
+		{Name: "b", Groups: "default,notdefault"},
+		{Name: "c"},
+	}}})
+
+	tests := []struct {
+		toComplete string
+		want       []string
+	}{
+		{"", []string{"all", "default", "docs", "notdefault", "tools"}},
+		{"d", []string{"default", "docs"}},
+		{"docs,t", []string{"docs,tools"}},
+		{"-n", []string{"-notdefault"}},
+		{"all,-d", []string{"all,-default", "all,-docs"}},
+		{"x", nil},
+	}
+	for _, tt := range tests {
+		got, directive := completeGroups(&cobra.Command{}, nil, tt.toComplete)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("completeGroups(%q) = %v, want %v", tt.toComplete, got, tt.want)
+		}
+		if directive&cobra.ShellCompDirectiveNoSpace == 0 {
+			t.Errorf("completeGroups(%q) adds a space after the candidate", tt.toComplete)
+		}
+	}
+
+	// 不在客户端中时只有内置的组
+	setCompletionClient(t, nil)
+	if got, _ := completeGroups(&cobra.Command{}, nil, ""); !reflect.DeepEqual(got, []string{"all", "default"}) {
+		t.Errorf("completeGroups() outside a client = %v", got)
+	}
+}
@@ -0,0 +1,183 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/leopardxu/repo-go/internal/config"
+	"github.com/spf13/cobra"
+)
+
+// ConfigOptions 包含config命令的选项
+type ConfigOptions struct {
+	Global    bool
+	ShowScope bool
+}
+
+// ConfigCmd 返回config命令
+func ConfigCmd() *cobra.Command {
+	opts := &ConfigOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "config [--global] {get | set | unset | list} [<key> [<value>]]",
+		Short: "Get and set repo settings",
+		Long: `Get and set repo settings. Settings use git-config style keys and are
+layered, later layers overriding earlier ones:
+
+  default  built-in defaults
+  global   ~/.repoconfig/config
+  client   the manifest repository's git config (.repo/manifests.git/config)
+  env      GOGO_GROUPS, GOGO_PLATFORM, GOGO_MIRROR, GOGO_ARCHIVE, GOGO_DEPTH
+
+Without --global, set and unset edit the client settings and get and list
+show the merged value. With --global, all actions use ~/.repoconfig/config.
+
+Supported keys include color.ui, manifest.groups, manifest.platform,
+repo.depth, repo.partialclone, repo.clonefilter, repo.mirror, repo.archive,
+repo.worktree, repo.reference, repo.dissociate, repo.superproject,
+repo.clonebundle, repo.git-lfs, repo.submodules and
+review.<url>.autoupload.`,
+	}
+	cmd.PersistentFlags().BoolVar(&opts.Global, "global", false, "use the global settings in ~/.repoconfig/config")
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "get <key>",
+		Short: "Print the value of a setting",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runConfigGet(opts, args[0])
+		},
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:   "set <key> <value>",
+		Short: "Set a setting",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runConfigSet(opts, args[0], args[1])
+		},
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:   "unset <key>",
+		Short: "Remove a setting",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runConfigUnset(opts, args[0])
+		},
+	})
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "List all settings",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runConfigList(opts)
+		},
+	}
+	list.Flags().BoolVar(&opts.ShowScope, "show-scope", false, "show the layer each setting comes from")
+	cmd.AddCommand(list)
+
+	return cmd
+}
+
+// settingsPath 返回 set 和 unset 修改的设置文件
+func settingsPath(opts *ConfigOptions) (string, error) {
+	if opts.Global {
+		return config.GlobalSettingsPath()
+	}
+	repoRoot, err := config.GetRepoRoot()
+	if err != nil {
+		return "", fmt.Errorf("not in a repo client, use --global to edit ~/.repoconfig/config")
+	}
+	return config.ClientSettingsPath(repoRoot), nil
+}
+
+// loadConfigSettings 加载 get 和 list 使用的设置
+// 使用 --global 时只读取全局设置文件
+func loadConfigSettings(opts *ConfigOptions) ([]config.Setting, error) {
+	if opts.Global {
+		path, err := config.GlobalSettingsPath()
+		if err != nil {
+			return nil, err
+		}
+		values, err := config.ReadSettingsFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var settings []config.Setting
+		for key, value := range values {
+			settings = append(settings, config.Setting{Key: key, Value: value, Scope: config.ScopeGlobal})
+		}
+		return settings, nil
+	}
+
+	repoRoot, err := config.GetRepoRoot()
+	if err != nil {
+		return nil, fmt.Errorf("not in a repo client, use --global to read ~/.repoconfig/config")
+	}
+	settings, err := config.LoadSettings(repoRoot)
+	if err != nil {
+		return nil, err
+	}
+	return settings.All(), nil
+}
+
+// runConfigGet 输出设置的值，未设置时返回错误
+func runConfigGet(opts *ConfigOptions, key string) error {
+	settings, err := loadConfigSettings(opts)
+	if err != nil {
+		return err
+	}
+	key = config.CanonicalKey(key)
+	for _, setting := range settings {
+		if setting.Key == key {
+			fmt.Println(setting.Value)
+			return nil
+		}
+	}
+	return fmt.Errorf("%s is not set", key)
+}
+
+// runConfigSet 设置键值
+func runConfigSet(opts *ConfigOptions, key, value string) error {
+	if err := config.ValidateSetting(key, value); err != nil {
+		return err
+	}
+	path, err := settingsPath(opts)
+	if err != nil {
+		return err
+	}
+	return config.SetSetting(path, config.CanonicalKey(key), value)
+}
+
+// runConfigUnset 删除设置，之后使用下一层的值
+func runConfigUnset(opts *ConfigOptions, key string) error {
+	path, err := settingsPath(opts)
+	if err != nil {
+		return err
+	}
+	key = config.CanonicalKey(key)
+	if err := config.UnsetSetting(path, key); err != nil {
+		if errors.Is(err, config.ErrSettingNotSet) {
+			return fmt.Errorf("%s is not set in %s", key, path)
+		}
+		return err
+	}
+	return nil
+}
+
+// runConfigList 按键名顺序输出所有设置
+func runConfigList(opts *ConfigOptions) error {
+	settings, err := loadConfigSettings(opts)
+	if err != nil {
+		return err
+	}
+	sort.Slice(settings, func(i, j int) bool { return settings[i].Key < settings[j].Key })
+	for _, setting := range settings {
+		if opts.ShowScope {
+			fmt.Printf("%s\t%s=%s\n", setting.Scope, setting.Key, setting.Value)
+		} else {
+			fmt.Printf("%s=%s\n", setting.Key, setting.Value)
+		}
+	}
+	return nil
+}
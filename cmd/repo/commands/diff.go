@@ -71,6 +71,9 @@ func runDiff(opts *DiffOptions, projectNames []string) error {
 		log.Error("加载配置失败: %v", err)
 		return fmt.Errorf("failed to load config: %w", err)
 	}
+	if err := requireGitClient(cfg, "diff"); err != nil {
+		return err
+	}
 	opts.Config = cfg
 
 	log.Debug("解析清单文件")
@@ -148,9 +151,10 @@ func runDiff(opts *DiffOptions, projectNames []string) error {
 func DiffCmd() *cobra.Command {
 	opts := &DiffOptions{}
 	cmd := &cobra.Command{
-		Use:   "diff [<project>...]",
-		Short: "Show changes between commit, working tree, etc",
-		Long:  `Shows changes between the working tree and the index or a commit.`,
+		Use:               "diff [<project>...]",
+		Short:             "Show changes between commit, working tree, etc",
+		ValidArgsFunction: completeProjects,
+		Long:              `Shows changes between the working tree and the index or a commit.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			return runDiff(opts, args)
 		},
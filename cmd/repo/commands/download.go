@@ -31,9 +31,10 @@ type DownloadOptions struct {
 func DownloadCmd() *cobra.Command {
 	opts := &DownloadOptions{}
 	cmd := &cobra.Command{
-		Use:   "download [<project>...] [<change>...]",
-		Short: "Download project changes from the remote server",
-		Long:  `Downloads changes for the specified projects from their remote repositories. If change IDs are provided, they will be cherry-picked.`,
+		Use:               "download [<project>...] [<change>...]",
+		Short:             "Download project changes from the remote server",
+		ValidArgsFunction: completeProjects,
+		Long:              `Downloads changes for the specified projects from their remote repositories. If change IDs are provided, they will be cherry-picked.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			return runDownload(opts, args)
 		},
@@ -153,6 +154,9 @@ func runDownload(opts *DownloadOptions, args []string) error {
 		log.Error("Failed to load config: %v", err)
 		return fmt.Errorf("failed to load config: %w", err)
 	}
+	if err := requireGitClient(cfg, "download"); err != nil {
+		return err
+	}
 	opts.Config = cfg
 
 	// 加载清单
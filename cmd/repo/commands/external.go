@@ -0,0 +1,99 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/leopardxu/repo-go/internal/config"
+	"github.com/leopardxu/repo-go/internal/external"
+	"github.com/leopardxu/repo-go/internal/logger"
+	"github.com/leopardxu/repo-go/internal/manifest"
+	"github.com/leopardxu/repo-go/internal/project"
+)
+
+// RunExternal 运行 PATH 中的外部子命令 repo-<name>，返回其退出码
+// 在客户端中运行时通过环境变量传入客户端根目录、清单路径和项目列表
+func RunExternal(path, clientRoot string, args []string, log logger.Logger) (int, error) {
+	env, cleanup, err := externalEnv(clientRoot, log)
+	if err != nil {
+		return 1, err
+	}
+	defer cleanup()
+
+	log.Debug("运行外部命令: %s %s", path, strings.Join(args, " "))
+	return external.Run(path, args, env)
+}
+
+// externalEnv 返回外部子命令的客户端环境变量，清单无法解析时只传入客户端根目录
+func externalEnv(clientRoot string, log logger.Logger) ([]string, func(), error) {
+	if clientRoot == "" {
+		return external.Env("", "", nil)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Debug("加载配置失败，外部命令不会收到清单信息: %v", err)
+		return external.Env(clientRoot, "", nil)
+	}
+
+	manifestPath := filepath.Join(clientRoot, ".repo", "manifest.xml")
+	if _, err := os.Stat(manifestPath); err != nil {
+		manifestPath = filepath.Join(clientRoot, ".repo", "manifests", cfg.ManifestName)
+	}
+
+	// 解析清单和创建项目管理器的日志不应混入外部命令的输出
+	logger.SetLevel(logger.LogLevelWarn)
+	parser := manifest.NewParser()
+	parser.SetSilentMode(true)
+	manifestObj, err := parser.ParseFromFile(cfg.ManifestName, strings.Split(cfg.Groups, ","))
+	if err != nil {
+		log.Warn("解析清单文件失败，外部命令不会收到项目列表: %v", err)
+		return external.Env(clientRoot, manifestPath, nil)
+	}
+	projects, err := project.NewManagerFromManifest(manifestObj, cfg).GetProjectsInGroups(nil)
+	if err != nil {
+		log.Warn("获取项目失败，外部命令不会收到项目列表: %v", err)
+		return external.Env(clientRoot, manifestPath, nil)
+	}
+
+	paths := make([]string, 0, len(projects))
+	for _, p := range projects {
+		paths = append(paths, p.Path)
+	}
+	return external.Env(clientRoot, manifestPath, paths)
+}
+
+// WriteExternalHelp 在帮助信息中列出命令别名和 PATH 中的外部命令
+func WriteExternalHelp(w io.Writer, aliases map[string]string) {
+	var names []string
+	for name := range aliases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	cmds := external.List()
+	width := 0
+	for _, name := range names {
+		width = max(width, len(name))
+	}
+	for _, c := range cmds {
+		width = max(width, len(c.Name))
+	}
+
+	if len(cmds) > 0 {
+		fmt.Fprintln(w, "\nExternal Commands:")
+		for _, c := range cmds {
+			fmt.Fprintln(w, strings.TrimRight(fmt.Sprintf("  %-*s  %s", width, c.Name, c.Description), " "))
+		}
+	}
+	if len(names) > 0 {
+		fmt.Fprintln(w, "\nAliases:")
+		for _, name := range names {
+			fmt.Fprintf(w, "  %-*s  alias for '%s'\n", width, name, aliases[name])
+		}
+	}
+}
@@ -1,29 +1,40 @@
 package commands
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"os"
 	"os/exec"
+	"regexp"
+	"runtime"
 	"strings"
-	"sync"
 
 	"github.com/leopardxu/repo-go/internal/config"
+	"github.com/leopardxu/repo-go/internal/git"
+	"github.com/leopardxu/repo-go/internal/lock"
 	"github.com/leopardxu/repo-go/internal/logger"
 	"github.com/leopardxu/repo-go/internal/manifest"
+	"github.com/leopardxu/repo-go/internal/progress"
 	"github.com/leopardxu/repo-go/internal/project"
 	"github.com/spf13/cobra"
 )
 
 // ForallOptions holds the options for the forall command
 type ForallOptions struct {
-	Command      string
-	Parallel     bool
-	Jobs         int
-	IgnoreErrors bool
-	Quiet        bool
-	Verbose      bool
-	Groups       string
-	Config       *config.Config
+	Command       string
+	Parallel      bool
+	Jobs          int
+	IgnoreErrors  bool
+	AbortOnErrors bool
+	ProjectHeader bool
+	Regex         bool
+	InverseRegex  bool
+	IgnoreMissing bool
+	Quiet         bool
+	Verbose       bool
+	Groups        string
+	Config        *config.Config
 	CommonManifestOptions
 }
 
@@ -31,9 +42,27 @@ type ForallOptions struct {
 func ForallCmd() *cobra.Command {
 	opts := &ForallOptions{}
 	cmd := &cobra.Command{
-		Use:   "forall [<project>...] -c <command> [<arg>...]",
-		Short: "Run a shell command in each project",
-		Long:  `Executes the same shell command in the working directory of each specified project.`,
+		Use:               "forall [<project>...] -c <command> [<arg>...]",
+		Short:             "Run a shell command in each project",
+		ValidArgsFunction: completeProjects,
+		Long: `Executes the same shell command in the working directory of each specified project.
+
+The following environment variables are set for each invocation:
+
+  REPO_PROJECT      name of the project
+  REPO_PATH         path of the project relative to the client root
+  REPO_REMOTE       name of the remote
+  REPO_LREV         commit the manifest revision resolves to locally
+  REPO_RREV         revision as written in the manifest
+  REPO_UPSTREAM     upstream attribute of the project
+  REPO_DEST_BRANCH  dest-branch attribute of the project
+  REPO_I, REPO_COUNT  1-based index of the project and number of projects
+  REPO__<name>      value of each <annotation> of the project
+
+Output of every project is buffered and printed as one block in manifest
+order; -p prints a "project <path>/" header before each non-empty block.
+With -r the arguments are regular expressions matched against project
+names and paths; --inverse-regex selects the projects that do not match.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			cfg, err := config.Load()
 			if err != nil {
@@ -53,18 +82,30 @@ func ForallCmd() *cobra.Command {
 				return fmt.Errorf("command (-c) is required")
 			}
 
-			return runForall(opts, projectNames)
+			// --parallel 保留兼容，未显式指定 -j 时按CPU数并行
+			if opts.Parallel && !cmd.Flags().Changed("jobs") {
+				opts.Jobs = runtime.NumCPU()
+			}
+
+			return runForall(cmd.Context(), opts, projectNames)
 		},
 	}
 
 	// Add flags
 	cmd.Flags().StringVarP(&opts.Command, "command", "c", "", "command and arguments to execute")
-	cmd.Flags().BoolVarP(&opts.Parallel, "parallel", "p", false, "run commands in parallel")
-	cmd.Flags().IntVarP(&opts.Jobs, "jobs", "j", 8, "number of jobs to run in parallel (if -p is specified)")
+	cmd.Flags().BoolVarP(&opts.ProjectHeader, "project-header", "p", false, "show project headers before output")
+	cmd.Flags().BoolVar(&opts.Parallel, "parallel", false, "run commands in parallel")
+	cmd.Flags().IntVarP(&opts.Jobs, "jobs", "j", 1, "number of jobs to run in parallel")
 	cmd.Flags().BoolVar(&opts.IgnoreErrors, "ignore-errors", false, "continue executing even if a command fails")
+	cmd.Flags().BoolVarP(&opts.AbortOnErrors, "abort-on-errors", "e", false, "abort if a command exits unsuccessfully")
+	cmd.Flags().BoolVarP(&opts.Regex, "regex", "r", false, "execute the command only on projects matching regex or wildcard expression")
+	cmd.Flags().BoolVarP(&opts.InverseRegex, "inverse-regex", "i", false, "execute the command only on projects not matching regex or wildcard expression")
+	cmd.Flags().BoolVar(&opts.IgnoreMissing, "ignore-missing", false, "silently skip & do not exit non-zero due missing checkouts")
 	cmd.Flags().BoolVarP(&opts.Quiet, "quiet", "q", false, "only show errors")
 	cmd.Flags().BoolVarP(&opts.Verbose, "verbose", "v", false, "show commands being executed")
 	cmd.Flags().StringVarP(&opts.Groups, "groups", "g", "", "restrict execution to projects in specified groups (comma-separated)")
+	cmd.RegisterFlagCompletionFunc("groups", completeGroups)
+	cmd.Flags().MarkDeprecated("parallel", "use -j instead")
 	AddManifestFlags(cmd, &opts.CommonManifestOptions)
 
 	return cmd
@@ -72,13 +113,24 @@ func ForallCmd() *cobra.Command {
 
 // forallStats tracks command execution statistics
 type forallStats struct {
-	mu      sync.Mutex
 	Success int
 	Failed  int
+	Skipped int
+}
+
+// forallResult holds the buffered output of one project
+type forallResult struct {
+	project *project.Project
+	path    string
+	stdout  bytes.Buffer
+	stderr  bytes.Buffer
+	err     error
+	skipped bool
+	done    chan struct{}
 }
 
 // runForall executes the forall command logic
-func runForall(opts *ForallOptions, projectNames []string) error {
+func runForall(parent context.Context, opts *ForallOptions, projectNames []string) error {
 	// 初始化日志记录器
 	log := logger.NewDefaultLogger()
 	if opts.Verbose {
@@ -100,7 +152,7 @@ func runForall(opts *ForallOptions, projectNames []string) error {
 	// 加载清单
 	log.Debug("Loading manifest file")
 	parser := manifest.NewParser()
-	manifest, err := parser.ParseFromFile(opts.Config.ManifestName, strings.Split(opts.Config.Groups, ","))
+	manifestObj, err := parser.ParseFromFile(opts.Config.ManifestName, strings.Split(opts.Config.Groups, ","))
 	if err != nil {
 		log.Error("Failed to parse manifest: %v", err)
 		return fmt.Errorf("failed to parse manifest: %w", err)
@@ -108,7 +160,7 @@ func runForall(opts *ForallOptions, projectNames []string) error {
 
 	// 创建项目管理器
 	log.Debug("Creating project manager")
-	manager := project.NewManagerFromManifest(manifest, opts.Config)
+	manager := project.NewManagerFromManifest(manifestObj, opts.Config)
 
 	// 获取要处理的项目
 	log.Debug("Getting projects to operate on")
@@ -118,12 +170,17 @@ func runForall(opts *ForallOptions, projectNames []string) error {
 		groupsArg = strings.Split(opts.Groups, ",")
 	}
 
-	if len(projectNames) == 0 {
+	if len(projectNames) == 0 || opts.Regex || opts.InverseRegex {
 		projects, err = manager.GetProjectsInGroups(groupsArg)
 		if err != nil {
 			log.Error("Failed to get projects: %v", err)
 			return fmt.Errorf("failed to get projects: %w", err)
 		}
+		if opts.Regex || opts.InverseRegex {
+			if projects, err = filterProjectsByRegex(projects, projectNames, opts.InverseRegex); err != nil {
+				return err
+			}
+		}
 	} else {
 		// 过滤指定的项目
 		filteredProjects, err := manager.GetProjectsByNames(projectNames)
@@ -143,85 +200,225 @@ func runForall(opts *ForallOptions, projectNames []string) error {
 	}
 
 	// 执行命令
-	log.Info("Executing command '%s' in %d projects", opts.Command, len(projects))
-
-	type forallResult struct {
-		Name string
-		Err  error
-	}
+	log.Debug("Executing command '%s' in %d projects", opts.Command, len(projects))
 
 	// 设置并发控制
 	maxConcurrency := opts.Jobs
 	if maxConcurrency <= 0 {
-		maxConcurrency = 8
-	}
-
-	// 如果不是并行模式，将并发数设为1
-	if !opts.Parallel {
 		maxConcurrency = 1
 	}
 
-	// 创建通道和等待组
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+
 	sem := make(chan struct{}, maxConcurrency)
-	results := make(chan forallResult, len(projects))
-	var wg sync.WaitGroup
+	results := make([]*forallResult, len(projects))
 	stats := forallStats{}
 
-	// 并发执行命令
-	for _, p := range projects {
-		if p.Worktree == "" { // 跳过没有工作目录的项
-			log.Debug("Skipping project %s (no worktree)", p.Name)
-			continue
-		}
-
-		wg.Add(1)
-		go func(proj *project.Project) {
-			defer wg.Done()
-			sem <- struct{}{}
-			defer func() { <-sem }()
+	for i, p := range projects {
+		results[i] = &forallResult{project: p, path: projectRelPath(p), done: make(chan struct{})}
+	}
 
-			log.Debug("Executing command in project %s", proj.Name)
-			cmd := exec.Command("sh", "-c", opts.Command)
-			cmd.Dir = proj.Worktree
-			cmd.Stdout = os.Stdout
-			cmd.Stderr = os.Stderr
-			err := cmd.Run()
-			results <- forallResult{Name: proj.Name, Err: err}
-		}(p)
+	// 并行执行时显示进行中的项目；逐个执行时命令直接使用终端，不显示进度
+	var pm progress.Reporter
+	if maxConcurrency > 1 && !opts.Quiet {
+		pm = progress.NewConsoleReporterWithTitle("Running")
+		pm.Start(len(results))
 	}
 
-	// 关闭结果通道
+	// 按清单顺序派发任务，--abort-on-errors 后排队中的任务不再执行
 	go func() {
-		wg.Wait()
-		close(results)
+		for i, res := range results {
+			sem <- struct{}{}
+			if ctx.Err() != nil {
+				res.skipped = true
+				close(res.done)
+				<-sem
+				continue
+			}
+			go func(i int, res *forallResult) {
+				defer close(res.done)
+				defer func() { <-sem }()
+				if pm != nil {
+					pm.StartTask(res.path)
+				}
+				res.err = runForallProject(ctx, opts, manifestObj, res, i+1, len(results), maxConcurrency == 1)
+				if pm != nil {
+					pm.FinishTask(res.path, res.err == nil)
+				}
+				if res.err != nil && opts.AbortOnErrors {
+					cancel()
+				}
+			}(i, res)
+		}
 	}()
 
-	// 处理结果
-	for res := range results {
-		if res.Err != nil {
-			errMsg := fmt.Sprintf("Error in %s: %v", res.Name, res.Err)
-			log.Error(errMsg)
-			stats.mu.Lock()
+	// 按清单顺序输出每个项目的完整结果
+	var firstErr *forallResult
+	for _, res := range results {
+		<-res.done
+		if pm != nil {
+			pm.Suspend(func() { printForallResult(res, opts.ProjectHeader) })
+		} else {
+			printForallResult(res, opts.ProjectHeader)
+		}
+
+		switch {
+		case res.err != nil:
+			log.Error("Error in %s: %v", res.project.Name, res.err)
 			stats.Failed++
-			stats.mu.Unlock()
-			if !opts.IgnoreErrors {
-				return fmt.Errorf("command failed in project %s", res.Name)
+			if firstErr == nil {
+				firstErr = res
 			}
-		} else {
-			log.Debug("Command executed successfully in project %s", res.Name)
-			stats.mu.Lock()
+		case res.skipped:
+			stats.Skipped++
+		default:
 			stats.Success++
-			stats.mu.Unlock()
 		}
 	}
 
+	if pm != nil {
+		pm.Finish()
+	}
+	if err := parent.Err(); err != nil {
+		return err
+	}
+
 	// 输出统计信息
-	log.Info("Command execution complete. Success: %d, Failed: %d", stats.Success, stats.Failed)
+	log.Debug("Command execution complete. Success: %d, Failed: %d, Skipped: %d", stats.Success, stats.Failed, stats.Skipped)
 
-	// 如果有失败的项目，返回错
-	if stats.Failed > 0 {
+	if stats.Failed > 0 && !opts.IgnoreErrors {
+		if opts.AbortOnErrors {
+			return fmt.Errorf("command failed in project %s, aborted", firstErr.project.Name)
+		}
 		return fmt.Errorf("forall command failed in %d projects", stats.Failed)
 	}
 
 	return nil
 }
+
+// runForallProject 在单个项目中执行命令，输出写入结果缓冲区
+func runForallProject(ctx context.Context, opts *ForallOptions, m *manifest.Manifest, res *forallResult, index, count int, interactive bool) error {
+	if _, err := os.Stat(res.project.Worktree); res.project.Worktree == "" || err != nil {
+		res.skipped = true
+		if opts.IgnoreMissing {
+			return nil
+		}
+		return fmt.Errorf("project %s is not checked out", res.path)
+	}
+
+	// 命令执行期间持有项目锁；命令中再运行的 repo 命令继承该锁，不会互相等待
+	l, err := lockProject(ctx, res.project)
+	if err != nil {
+		if ctx.Err() != nil {
+			// --abort-on-errors 取消了等待中的项目
+			res.skipped = true
+			return nil
+		}
+		return err
+	}
+	defer l.Release()
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", opts.Command)
+	if !interactive {
+		// 与 git 命令一样在独立的进程组中运行，取消时命令启动的子进程也被终止；
+		// 交互式命令需要留在前台进程组中读取终端
+		git.SetProcessGroup(cmd)
+	}
+	cmd.Dir = res.project.Worktree
+	cmd.Env = append(append(os.Environ(), lock.ChildEnv()), forallEnv(m, res.project, res.path, index, count)...)
+	cmd.Stdout = &res.stdout
+	if opts.ProjectHeader {
+		// 带项目头时合并标准输出与标准错误，保持原有顺序
+		cmd.Stderr = &res.stdout
+	} else {
+		cmd.Stderr = &res.stderr
+	}
+	if interactive {
+		cmd.Stdin = os.Stdin
+	}
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			// --abort-on-errors 或中断终止了正在运行的命令
+			res.skipped = true
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+// printForallResult 输出单个项目缓冲的输出
+func printForallResult(res *forallResult, header bool) {
+	if res.stdout.Len() == 0 && res.stderr.Len() == 0 {
+		return
+	}
+	if header {
+		fmt.Printf("\nproject %s/\n", res.path)
+	}
+	os.Stdout.Write(res.stdout.Bytes())
+	os.Stderr.Write(res.stderr.Bytes())
+}
+
+// forallEnv 构建与原生repo一致的 REPO_* 环境变量
+func forallEnv(m *manifest.Manifest, p *project.Project, path string, index, count int) []string {
+	env := []string{
+		"REPO_PROJECT=" + p.Name,
+		"REPO_PATH=" + path,
+		"REPO_REMOTE=" + p.RemoteName,
+		"REPO_RREV=" + p.Revision,
+		fmt.Sprintf("REPO_I=%d", index),
+		fmt.Sprintf("REPO_COUNT=%d", count),
+	}
+
+	lrev := ""
+	if ref := p.RevisionRef(); ref != "" {
+		lrev, _ = p.GitRepo.RevParse(ref)
+	}
+	env = append(env, "REPO_LREV="+lrev)
+
+	upstream := m.Default.Upstream
+	destBranch := m.Default.DestBranch
+	if mp := findManifestProject(m, p); mp != nil {
+		if mp.Upstream != "" {
+			upstream = mp.Upstream
+		}
+		if mp.DestBranch != "" {
+			destBranch = mp.DestBranch
+		}
+		for _, a := range mp.Annotations {
+			env = append(env, "REPO__"+a.Name+"="+a.Value)
+		}
+	}
+	return append(env, "REPO_UPSTREAM="+upstream, "REPO_DEST_BRANCH="+destBranch)
+}
+
+// filterProjectsByRegex 按正则表达式匹配项目名或路径，inverse 为 true 时保留不匹配的项目
+func filterProjectsByRegex(projects []*project.Project, patterns []string, inverse bool) ([]*project.Project, error) {
+	if len(patterns) == 0 {
+		return nil, fmt.Errorf("--regex and --inverse-regex require at least one pattern")
+	}
+	var regexes []*regexp.Regexp
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex %q: %w", pattern, err)
+		}
+		regexes = append(regexes, re)
+	}
+
+	var filtered []*project.Project
+	for _, p := range projects {
+		matched := false
+		for _, re := range regexes {
+			if re.MatchString(p.Name) || re.MatchString(projectRelPath(p)) {
+				matched = true
+				break
+			}
+		}
+		if matched != inverse {
+			filtered = append(filtered, p)
+		}
+	}
+	return filtered, nil
+}
@@ -0,0 +1,195 @@
+package commands
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/leopardxu/repo-go/internal/git"
+	"github.com/leopardxu/repo-go/internal/lock"
+	"github.com/leopardxu/repo-go/internal/manifest"
+	"github.com/leopardxu/repo-go/internal/project"
+	"github.com/leopardxu/repo-go/internal/testutil"
+)
+
+func TestForallEnv(t *testing.T) {
+	dir := t.TempDir()
+	testutil.Git(t, dir, "init", "--quiet")
+	testutil.Git(t, dir, "commit", "--quiet", "--allow-empty", "-m", "init")
+	testutil.Git(t, dir, "update-ref", "refs/remotes/origin/main", "HEAD")
+	head := testutil.Git(t, dir, "rev-parse", "HEAD")
+
+	// 同名项目检出到两个路径，每个路径使用自己的清单定义
+	m := &manifest.Manifest{
+		Default: manifest.Default{Upstream: "main", DestBranch: "main"},
+		Projects: []manifest.Project{
+			{Name: "platform/build", Path: "build", Upstream: "stable",
+				Annotations: []manifest.Annotation{{Name: "KIND", Value: "primary"}}},
+			{Name: "platform/build", Path: "out/build", DestBranch: "release",
+				Annotations: []manifest.Annotation{{Name: "KIND", Value: "copy"}}},
+		},
+	}
+	newProject := func(path string) *project.Project {
+		return &project.Project{
+			Name:       "platform/build",
+			Path:       path,
+			RemoteName: "origin",
+			Revision:   "main",
+			GitRepo:    git.NewRepository(dir, git.NewRunner()),
+		}
+	}
+
+	tests := []struct {
+		path string
+		want []string
+	}{
+		{"build", []string{
+			"REPO_PROJECT=platform/build", "REPO_PATH=build", "REPO_REMOTE=origin", "REPO_RREV=main",
+			"REPO_I=1", "REPO_COUNT=2", "REPO_LREV=" + head,
+			"REPO__KIND=primary", "REPO_UPSTREAM=stable", "REPO_DEST_BRANCH=main",
+		}},
+		{"out/build", []string{
+			"REPO_PROJECT=platform/build", "REPO_PATH=out/build", "REPO_REMOTE=origin", "REPO_RREV=main",
+			"REPO_I=1", "REPO_COUNT=2", "REPO_LREV=" + head,
+			"REPO__KIND=copy", "REPO_UPSTREAM=main", "REPO_DEST_BRANCH=release",
+		}},
+	}
+	for _, tt := range tests {
+		got := forallEnv(m, newProject(tt.path), tt.path, 1, 2)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("forallEnv(%s) =\n%v\nwant\n%v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestFilterProjectsByRegex(t *testing.T) {
+	projects := []*project.Project{
+		{Name: "platform/build", Path: "build"},
+		{Name: "platform/art", Path: "art"},
+		{Name: "device/google/pixel", Path: filepath.Join("device", "pixel")},
+	}
+	names := func(ps []*project.Project) []string {
+		var result []string
+		for _, p := range ps {
+			result = append(result, p.Name)
+		}
+		return result
+	}
+
+	tests := []struct {
+		name     string
+		patterns []string
+		inverse  bool
+		want     []string
+		wantErr  bool
+	}{
+		{"name", []string{"^platform/"}, false, []string{"platform/build", "platform/art"}, false},
+		{"path", []string{"^device/pixel$"}, false, []string{"device/google/pixel"}, false},
+		{"any pattern", []string{"art$", "pixel"}, false, []string{"platform/art", "device/google/pixel"}, false},
+		{"inverse", []string{"^platform/"}, true, []string{"device/google/pixel"}, false},
+		{"no match", []string{"kernel"}, false, nil, false},
+		{"no patterns", nil, false, nil, true},
+		{"invalid", []string{"("}, false, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := filterProjectsByRegex(projects, tt.patterns, tt.inverse)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("filterProjectsByRegex() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(names(got), tt.want) {
+				t.Errorf("filterProjectsByRegex() = %v, want %v", names(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestRunForallProjectLock(t *testing.T) {
+	root := t.TempDir()
+	worktree := filepath.Join(root, "build")
+	if err := os.MkdirAll(worktree, 0755); err != nil {
+		t.Fatal(err)
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(root); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(cwd)
+	t.Setenv(lock.ParentsEnv, "")
+
+	p := &project.Project{Name: "platform/build", Path: "build", Worktree: worktree}
+	lockDir := lock.ProjectPath(root, "build")
+	// 命令运行时项目锁由 forall 持有，命令中的 repo 命令通过环境变量继承
+	res := &forallResult{project: p, path: "build"}
+	opts := &ForallOptions{Command: `echo "$` + lock.ParentsEnv + `"; ls "$LOCK_DIR"`}
+	t.Setenv("LOCK_DIR", lockDir)
+	if err := runForallProject(context.Background(), opts, &manifest.Manifest{}, res, 1, 1, false); err != nil {
+		t.Fatalf("runForallProject() = %v\n%s", err, res.stderr.String())
+	}
+	lines := strings.Split(strings.TrimSpace(res.stdout.String()), "\n")
+	if len(lines) != 2 || lines[0] != strconv.Itoa(os.Getpid()) || !strings.HasPrefix(lines[1], "exclusive-"+strconv.Itoa(os.Getpid())+"-") {
+		t.Errorf("command output = %q, want the forall pid and its project lock", lines)
+	}
+
+	if holders, err := lock.Holders(lockDir); err != nil || len(holders) != 0 {
+		t.Errorf("project lock still held after the command: %v, %v", holders, err)
+	}
+}
+
+func TestRunForallProjectCancel(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("process groups are not used on windows")
+	}
+	root := t.TempDir()
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(root); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(cwd)
+	t.Setenv(lock.ParentsEnv, "")
+	pidFile := filepath.Join(root, "pid")
+	t.Setenv("PID_FILE", pidFile)
+
+	// 命令启动的后台进程持有输出管道，只有终止整个进程组命令才会很快结束
+	p := &project.Project{Name: "build", Path: "build", Worktree: root}
+	res := &forallResult{project: p, path: "build"}
+	opts := &ForallOptions{Command: `sleep 30 & echo $! > "$PID_FILE"; wait`}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- runForallProject(ctx, opts, &manifest.Manifest{}, res, 1, 1, false) }()
+
+	for start := time.Now(); ; time.Sleep(10 * time.Millisecond) {
+		if _, err := os.Stat(pidFile); err == nil {
+			break
+		}
+		if time.Since(start) > 10*time.Second {
+			t.Fatal("command did not start")
+		}
+	}
+	started := time.Now()
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil || !res.skipped {
+			t.Errorf("runForallProject() = %v, skipped %v, want the canceled command skipped", err, res.skipped)
+		}
+		if elapsed := time.Since(started); elapsed > 3*time.Second {
+			t.Errorf("command took %v to stop, the background process was not terminated", elapsed)
+		}
+	case <-time.After(20 * time.Second):
+		t.Fatal("runForallProject() did not return after cancellation")
+	}
+}
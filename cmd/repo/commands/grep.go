@@ -1,12 +1,17 @@
 package commands
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"os"
 	"os/exec"
 	"strings"
 	"sync"
 
+	"github.com/leopardxu/repo-go/internal/color"
 	"github.com/leopardxu/repo-go/internal/config"
+	"github.com/leopardxu/repo-go/internal/git"
 	"github.com/leopardxu/repo-go/internal/logger"
 	"github.com/leopardxu/repo-go/internal/manifest"
 	"github.com/leopardxu/repo-go/internal/project"
@@ -24,13 +29,46 @@ type GrepOptions struct {
 	Jobs             int
 	Pattern          string
 	Groups           string
+	Expressions      []string // 按命令行顺序记录的 -e/--and/--or/--not/括号
+	Revisions        []string // 搜索指定修订版本而不是工作区
+	Context          int
+	AfterContext     int
+	BeforeContext    int
+	Cached           bool
+	Untracked        bool
+	WordRegexp       bool
+	UseColor         bool
 	Config           *config.Config
 	CommonManifestOptions
 }
 
+// grepExprValue 将表达式类选项按出现顺序追加到同一个列表，保留 git grep 的运算符优先级语义
+type grepExprValue struct {
+	list     *[]string
+	flag     string
+	hasValue bool // -e 需要参数，其余为无参数的运算符
+}
+
+func (v *grepExprValue) String() string { return "" }
+
+func (v *grepExprValue) Set(value string) error {
+	if v.hasValue {
+		*v.list = append(*v.list, v.flag, value)
+	} else {
+		*v.list = append(*v.list, v.flag)
+	}
+	return nil
+}
+
+func (v *grepExprValue) Type() string {
+	if v.hasValue {
+		return "pattern"
+	}
+	return "bool"
+}
+
 // grepStats tracks grep execution statistics
 type grepStats struct {
-	mu      sync.Mutex
 	Success int
 	Failed  int
 	Matches int
@@ -40,18 +78,57 @@ type grepStats struct {
 func GrepCmd() *cobra.Command {
 	opts := &GrepOptions{}
 	cmd := &cobra.Command{
-		Use:   "grep <pattern> [<project>...]",
+		Use:   "grep {pattern | -e pattern} [<project>...]",
 		Short: "Print lines matching a pattern",
-		Long:  `Looks for specified patterns in the working tree files of the specified projects.`,
-		Args:  cobra.MinimumNArgs(1), // Requires at least the pattern
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			// 没有 -e 时第一个参数是模式
+			if cmd.Flags().Changed("regexp") {
+				return completeProjects(cmd, args, toComplete)
+			}
+			return completeProjectsAfter(1)(cmd, args, toComplete)
+		},
+		Long: `Looks for specified patterns in the working tree files of the specified projects.
+
+Boolean Options:
+  Several patterns may be given with -e. Patterns are combined with --or by
+  default; --and, --or, --not and the grouping options -( and -) follow the
+  rules of git grep and are passed on in the order given, e.g.
+
+    repo grep -e '#define' --and -\( -e MAX_PATH -e PATH_MAX -\)
+
+Revisions:
+  -r searches the tree of the given revision instead of the working tree and
+  may be repeated. --cached searches the index, --untracked also searches
+  files that are not tracked.
+
+Every line of output is prefixed with the project path. Colors follow the
+global --color option.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			cfg, err := config.Load()
 			if err != nil {
 				return fmt.Errorf("failed to load config: %w", err)
 			}
+			if err := requireGitClient(cfg, "grep"); err != nil {
+				return err
+			}
 			opts.Config = cfg
-			opts.Pattern = args[0]
-			return runGrep(opts, args[1:])
+
+			// 未使用 -e 时第一个参数是模式
+			if len(opts.Expressions) == 0 {
+				if len(args) == 0 {
+					return fmt.Errorf("no pattern given")
+				}
+				opts.Pattern = args[0]
+				opts.Expressions = []string{"-e", args[0]}
+				args = args[1:]
+			}
+			if len(opts.Revisions) > 0 && (opts.Cached || opts.Untracked) {
+				return fmt.Errorf("--cached and --untracked cannot be used with --revision")
+			}
+
+			colorMode, _ := cmd.Flags().GetString("color")
+			opts.UseColor = color.ShouldUseColor(colorMode)
+			return runGrep(cmd.Context(), opts, args)
 		},
 	}
 
@@ -64,13 +141,34 @@ func GrepCmd() *cobra.Command {
 	cmd.Flags().BoolVarP(&opts.Verbose, "verbose", "v", false, "show detailed output")
 	cmd.Flags().IntVarP(&opts.Jobs, "jobs", "j", 8, "number of jobs to run in parallel")
 	cmd.Flags().StringVarP(&opts.Groups, "groups", "g", "", "restrict execution to projects in specified groups (comma-separated)")
+	cmd.RegisterFlagCompletionFunc("groups", completeGroups)
+	cmd.Flags().BoolVarP(&opts.WordRegexp, "word-regexp", "w", false, "match the pattern only at word boundaries")
+	cmd.Flags().IntVarP(&opts.Context, "context", "C", 0, "show CONTEXT lines around match")
+	cmd.Flags().IntVarP(&opts.AfterContext, "after-context", "A", 0, "show CONTEXT lines after match")
+	cmd.Flags().IntVarP(&opts.BeforeContext, "before-context", "B", 0, "show CONTEXT lines before match")
+	cmd.Flags().StringArrayVarP(&opts.Revisions, "revision", "r", nil, "search files in the given revision instead of the working tree (repeatable)")
+	cmd.Flags().BoolVar(&opts.Cached, "cached", false, "search the index instead of the working tree")
+	cmd.Flags().BoolVar(&opts.Untracked, "untracked", false, "also search untracked files in the working tree")
+
+	// 表达式选项共享一个有序列表
+	cmd.Flags().VarP(&grepExprValue{list: &opts.Expressions, flag: "-e", hasValue: true}, "regexp", "e", "pattern to search for (repeatable)")
+	for _, op := range []struct{ name, short, flag, usage string }{
+		{"and", "", "--and", "require the next pattern to match as well"},
+		{"or", "", "--or", "match either the previous or the next pattern (default)"},
+		{"not", "", "--not", "invert the next pattern"},
+		{"open-paren", "(", "(", "start a group of patterns"},
+		{"close-paren", ")", ")", "end a group of patterns"},
+	} {
+		cmd.Flags().VarP(&grepExprValue{list: &opts.Expressions, flag: op.flag}, op.name, op.short, op.usage)
+		cmd.Flags().Lookup(op.name).NoOptDefVal = "true"
+	}
 	AddManifestFlags(cmd, &opts.CommonManifestOptions)
 
 	return cmd
 }
 
 // runGrep executes the grep command logic
-func runGrep(opts *GrepOptions, projectNames []string) error {
+func runGrep(ctx context.Context, opts *GrepOptions, projectNames []string) error {
 	// 初始化日志记录器
 	log := logger.NewDefaultLogger()
 	if opts.Verbose {
@@ -138,28 +236,16 @@ func runGrep(opts *GrepOptions, projectNames []string) error {
 
 	// 构建 git grep 参数
 	log.Debug("构建 git grep 参数...")
-	grepArgs := []string{"grep"}
-	if opts.IgnoreCase {
-		grepArgs = append(grepArgs, "-i")
-	}
-	if opts.FixedStrings {
-		grepArgs = append(grepArgs, "-F")
-	}
-	if opts.LineNumber {
-		grepArgs = append(grepArgs, "-n")
-	}
-	if opts.FilesWithMatches {
-		grepArgs = append(grepArgs, "-l")
-	}
-	grepArgs = append(grepArgs, "--color=always")
-	grepArgs = append(grepArgs, "-e", opts.Pattern)
+	grepArgs := buildGrepArgs(opts)
+	log.Debug("git %s", strings.Join(grepArgs, " "))
 
-	// 在每个项目中并发执行 grep
-	log.Info("正在 %d 个项目中搜索 '%s'...", len(projects), opts.Pattern)
+	// 在每个项目中并发执行 grep，结果按清单顺序输出
+	log.Debug("正在 %d 个项目中搜索...", len(projects))
 
 	type grepResult struct {
 		project *project.Project
 		output  []byte
+		stderr  string
 		err     error
 	}
 
@@ -170,82 +256,85 @@ func runGrep(opts *GrepOptions, projectNames []string) error {
 	}
 
 	sem := make(chan struct{}, maxWorkers)
-	results := make(chan grepResult, len(projects))
+	results := make([]*grepResult, len(projects))
 	var wg sync.WaitGroup
 	stats := grepStats{}
 
 	// 跟踪有工作目录的项目数量
 	validProjects := 0
 
-	for _, p := range projects {
+	for i, p := range projects {
 		if p.Worktree == "" {
 			log.Debug("跳过项目 %s (无工作目录)", p.Name)
 			continue
 		}
+		if _, err := os.Stat(p.Worktree); err != nil {
+			log.Debug("跳过项目 %s (工作目录不存在)", p.Name)
+			continue
+		}
 
 		validProjects++
 		wg.Add(1)
-		go func(p *project.Project) {
+		go func(i int, p *project.Project) {
 			defer wg.Done()
 			sem <- struct{}{}
 			defer func() { <-sem }()
 
 			log.Debug("在项目 %s 中执行 grep...", p.Name)
-			cmd := exec.Command("git", grepArgs...)
-			cmd.Dir = p.Worktree
-			output, err := cmd.CombinedOutput()
-			results <- grepResult{p, output, err}
-		}(p)
+			var stdout, stderr bytes.Buffer
+			cmd := git.Command(ctx, p.Worktree, grepArgs...)
+			cmd.Stdout = &stdout
+			cmd.Stderr = &stderr
+			err := git.Run(cmd)
+			results[i] = &grepResult{p, stdout.Bytes(), strings.TrimSpace(stderr.String()), err}
+		}(i, p)
 	}
-
-	// 关闭结果通道
-	go func() {
-		wg.Wait()
-		close(results)
-	}()
+	wg.Wait()
 
 	// 处理结果
 	var foundMatches bool
 	var errors []error
 
-	for res := range results {
+	for _, res := range results {
+		if res == nil {
+			continue
+		}
 		if res.err != nil {
 			if exitErr, ok := res.err.(*exec.ExitError); ok {
-				if exitErr.ExitCode() == 1 {
+				if exitErr.ExitCode() == 1 && res.stderr == "" {
 					// 退出码 1 表示没有找到匹配项，这不是错误
 					log.Debug("项目 %s 中没有找到匹配项", res.project.Name)
-					stats.mu.Lock()
 					stats.Success++
-					stats.mu.Unlock()
 					continue
 				}
 			}
-			log.Error("在项%s 中执grep 失败: %v", res.project.Name, res.err)
+			log.Error("在项目 %s 中执行 grep 失败: %v", res.project.Name, res.err)
+			if res.stderr != "" {
+				log.Error("%s", res.stderr)
+			}
 			errors = append(errors, fmt.Errorf("error grepping in %s: %v", res.project.Name, res.err))
-			stats.mu.Lock()
 			stats.Failed++
-			stats.mu.Unlock()
 			continue
 		}
 
-		if len(res.output) > 0 {
-			foundMatches = true
-			lines := strings.Split(strings.TrimSpace(string(res.output)), "\n")
-			log.Debug("项目 %s 中找%d 个匹配项", res.project.Name, len(lines))
-
-			stats.mu.Lock()
+		output := strings.TrimRight(string(res.output), "\n")
+		if output == "" {
+			log.Debug("项目 %s 中没有找到匹配项", res.project.Name)
 			stats.Success++
-			stats.Matches += len(lines)
-			stats.mu.Unlock()
+			continue
+		}
+
+		foundMatches = true
+		lines := strings.Split(output, "\n")
+		log.Debug("项目 %s 中找到 %d 行输出", res.project.Name, len(lines))
+		stats.Success++
 
-			for _, line := range lines {
-				fmt.Printf("%s:%s\n", res.project.Name, line)
+		prefix := projectRelPath(res.project) + "/"
+		for _, line := range lines {
+			if !isGrepSeparator(line) {
+				stats.Matches++
 			}
-		} else {
-			log.Debug("项目 %s 中没有找到匹配项", res.project.Name)
-			stats.mu.Lock()
-			stats.Success++
-			stats.mu.Unlock()
+			fmt.Println(prefixGrepLine(line, prefix, opts.Revisions))
 		}
 	}
 
@@ -257,18 +346,90 @@ func runGrep(opts *GrepOptions, projectNames []string) error {
 		}
 	}
 
-	// 输出统计信息
-	log.Info("搜索完成. 处理项目: %d, 成功: %d, 失败: %d, 找到匹配 %d",
+	log.Debug("搜索完成. 处理项目: %d, 成功: %d, 失败: %d, 输出行数: %d",
 		validProjects, stats.Success, stats.Failed, stats.Matches)
 
-	if !foundMatches && !opts.Quiet {
-		log.Info("没有找到匹配项")
-	}
-
 	// 如果有失败的项目，返回错误
 	if stats.Failed > 0 {
 		return fmt.Errorf("grep command failed in %d projects", stats.Failed)
 	}
+	if !foundMatches && !opts.Quiet {
+		log.Info("没有找到匹配项")
+	}
 
 	return nil
 }
+
+// buildGrepArgs 构建 git grep 参数，表达式保持命令行中的顺序
+func buildGrepArgs(opts *GrepOptions) []string {
+	args := []string{"grep"}
+	if opts.UseColor {
+		args = append(args, "--color=always")
+	} else {
+		args = append(args, "--color=never")
+	}
+	if opts.IgnoreCase {
+		args = append(args, "-i")
+	}
+	if opts.FixedStrings {
+		args = append(args, "-F")
+	}
+	if opts.WordRegexp {
+		args = append(args, "-w")
+	}
+	if opts.LineNumber {
+		args = append(args, "-n")
+	}
+	if opts.FilesWithMatches {
+		args = append(args, "-l")
+	}
+	if opts.Context > 0 {
+		args = append(args, fmt.Sprintf("-C%d", opts.Context))
+	}
+	if opts.AfterContext > 0 {
+		args = append(args, fmt.Sprintf("-A%d", opts.AfterContext))
+	}
+	if opts.BeforeContext > 0 {
+		args = append(args, fmt.Sprintf("-B%d", opts.BeforeContext))
+	}
+	if opts.Cached {
+		args = append(args, "--cached")
+	}
+	if opts.Untracked {
+		args = append(args, "--untracked")
+	}
+	args = append(args, opts.Expressions...)
+	args = append(args, opts.Revisions...)
+	return append(args, "--")
+}
+
+// isGrepSeparator 判断是否为上下文分组之间的 "--" 分隔行
+func isGrepSeparator(line string) bool {
+	return stripANSIPrefix(line) == "--" || strings.HasPrefix(stripANSIPrefix(line), "--\x1b[")
+}
+
+// stripANSIPrefix 去除行首的颜色转义序列
+func stripANSIPrefix(line string) string {
+	if strings.HasPrefix(line, "\x1b[") {
+		if end := strings.IndexByte(line, 'm'); end >= 0 {
+			return line[end+1:]
+		}
+	}
+	return line
+}
+
+// prefixGrepLine 在文件名前加上项目路径
+// 搜索修订版本时 git 输出为 <rev>:<file>，项目路径插入到修订版本之后
+func prefixGrepLine(line, prefix string, revisions []string) string {
+	if isGrepSeparator(line) {
+		return line
+	}
+	rest := stripANSIPrefix(line)
+	lead := line[:len(line)-len(rest)]
+	for _, rev := range revisions {
+		if strings.HasPrefix(rest, rev+":") {
+			return lead + rev + ":" + prefix + rest[len(rev)+1:]
+		}
+	}
+	return lead + prefix + rest
+}
@@ -0,0 +1,160 @@
+package commands
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/leopardxu/repo-go/internal/testutil"
+)
+
+func TestBuildGrepArgs(t *testing.T) {
+	// 表达式选项按命令行中的顺序传给 git grep
+	exprTests := []struct {
+		args []string
+		want []string
+	}{
+		{
+			args: []string{"-e", "foo"},
+			want: []string{"-e", "foo"},
+		},
+		{
+			args: []string{"-e", "#define", "--and", "-(", "-e", "MAX_PATH", "--or", "-e", "PATH_MAX", "-)"},
+			want: []string{"-e", "#define", "--and", "(", "-e", "MAX_PATH", "--or", "-e", "PATH_MAX", ")"},
+		},
+		{
+			args: []string{"--not", "-e", "foo", "--and", "-e", "bar"},
+			want: []string{"--not", "-e", "foo", "--and", "-e", "bar"},
+		},
+		{
+			args: []string{"-(", "-e", "a", "--or", "--not", "-e", "b", "-)", "--and", "-e", "c"},
+			want: []string{"(", "-e", "a", "--or", "--not", "-e", "b", ")", "--and", "-e", "c"},
+		},
+	}
+	for _, tt := range exprTests {
+		cmd := GrepCmd()
+		if err := cmd.ParseFlags(tt.args); err != nil {
+			t.Fatalf("ParseFlags(%q): %v", tt.args, err)
+		}
+		expressions := *cmd.Flags().Lookup("regexp").Value.(*grepExprValue).list
+		got := buildGrepArgs(&GrepOptions{Expressions: expressions})
+		want := append(append([]string{"grep", "--color=never"}, tt.want...), "--")
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("buildGrepArgs(%q) = %q, want %q", tt.args, got, want)
+		}
+	}
+
+	expressions := []string{"-e", "foo"}
+	tests := []struct {
+		name string
+		opts GrepOptions
+		want []string
+	}{
+		{
+			name: "color",
+			opts: GrepOptions{UseColor: true, Expressions: expressions},
+			want: []string{"grep", "--color=always", "-e", "foo", "--"},
+		},
+		{
+			name: "options before expressions",
+			opts: GrepOptions{IgnoreCase: true, FixedStrings: true, WordRegexp: true, LineNumber: true, Context: 2, Expressions: expressions},
+			want: []string{"grep", "--color=never", "-i", "-F", "-w", "-n", "-C2", "-e", "foo", "--"},
+		},
+		{
+			name: "revisions after expressions",
+			opts: GrepOptions{FilesWithMatches: true, Expressions: expressions, Revisions: []string{"main", "v1.0"}},
+			want: []string{"grep", "--color=never", "-l", "-e", "foo", "main", "v1.0", "--"},
+		},
+		{
+			name: "cached and untracked",
+			opts: GrepOptions{Cached: true, Untracked: true, AfterContext: 1, BeforeContext: 3, Expressions: expressions},
+			want: []string{"grep", "--color=never", "-A1", "-B3", "--cached", "--untracked", "-e", "foo", "--"},
+		},
+	}
+	for _, tt := range tests {
+		if got := buildGrepArgs(&tt.opts); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: buildGrepArgs() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPrefixGrepLine(t *testing.T) {
+	tests := []struct {
+		name      string
+		line      string
+		revisions []string
+		want      string
+	}{
+		{"working tree", "src/a.c:12:foo", nil, "p/src/a.c:12:foo"},
+		{"files with matches", "src/a.c", nil, "p/src/a.c"},
+		{"revision", "main:src/a.c:12:foo", []string{"main"}, "main:p/src/a.c:12:foo"},
+		{"second revision", "v1.0:src/a.c:foo", []string{"main", "v1.0"}, "v1.0:p/src/a.c:foo"},
+		{"revision with slash", "origin/main:a.c:foo", []string{"origin/main"}, "origin/main:p/a.c:foo"},
+		{"context line", "main:src/a.c-11-bar", []string{"main"}, "main:p/src/a.c-11-bar"},
+		{"separator", "--", []string{"main"}, "--"},
+		{
+			name: "color",
+			line: "\x1b[35msrc/a.c\x1b[m\x1b[36m:\x1b[mfoo",
+			want: "\x1b[35mp/src/a.c\x1b[m\x1b[36m:\x1b[mfoo",
+		},
+		{
+			name:      "color with revision",
+			line:      "\x1b[35mmain:src/a.c\x1b[m\x1b[36m:\x1b[m\x1b[32m12\x1b[m\x1b[36m:\x1b[mfoo",
+			revisions: []string{"main"},
+			want:      "\x1b[35mmain:p/src/a.c\x1b[m\x1b[36m:\x1b[m\x1b[32m12\x1b[m\x1b[36m:\x1b[mfoo",
+		},
+		{"color separator", "\x1b[36m--\x1b[m", nil, "\x1b[36m--\x1b[m"},
+	}
+	for _, tt := range tests {
+		if got := prefixGrepLine(tt.line, "p/", tt.revisions); got != tt.want {
+			t.Errorf("%s: prefixGrepLine(%q) = %q, want %q", tt.name, tt.line, got, tt.want)
+		}
+	}
+}
+
+// TestPrefixGrepLineRevisionOutput 检查 git grep -r <rev> 的实际输出格式 rev:path:line
+func TestPrefixGrepLineRevisionOutput(t *testing.T) {
+	dir := testutil.NewRepo(t)
+	for _, color := range []bool{false, true} {
+		args := buildGrepArgs(&GrepOptions{UseColor: color, LineNumber: true, Expressions: []string{"-e", "base"}, Revisions: []string{"main"}})
+		got := prefixGrepLine(testutil.Git(t, dir, args...), "p/", []string{"main"})
+		if stripped := stripANSI(got); stripped != "main:p/f:1:base" {
+			t.Errorf("color %v: prefixGrepLine() = %q, want main:p/f:1:base", color, got)
+		}
+	}
+}
+
+// stripANSI 去除所有颜色转义序列
+func stripANSI(s string) string {
+	var out []byte
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\x1b' {
+			for i < len(s) && s[i] != 'm' {
+				i++
+			}
+			continue
+		}
+		out = append(out, s[i])
+	}
+	return string(out)
+}
+
+func TestIsGrepSeparator(t *testing.T) {
+	tests := []struct {
+		line string
+		want bool
+	}{
+		{"--", true},
+		{"\x1b[36m--\x1b[m", true},
+		{"\x1b[1;36m--\x1b[m", true},
+		{"---", false},
+		{"--foo.c:1:bar", false},
+		{"a.c-1---", false},
+		{"\x1b[35m--foo.c\x1b[m", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := isGrepSeparator(tt.line); got != tt.want {
+			t.Errorf("isGrepSeparator(%q) = %v, want %v", tt.line, got, tt.want)
+		}
+	}
+}
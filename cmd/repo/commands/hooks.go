@@ -0,0 +1,324 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/leopardxu/repo-go/internal/config"
+	"github.com/leopardxu/repo-go/internal/git"
+	"github.com/leopardxu/repo-go/internal/hook"
+	"github.com/leopardxu/repo-go/internal/logger"
+	"github.com/leopardxu/repo-go/internal/manifest"
+	"github.com/leopardxu/repo-go/internal/project"
+	"github.com/spf13/cobra"
+)
+
+// HooksOptions 包含hooks命令的选项
+type HooksOptions struct {
+	CommonManifestOptions
+	Jobs       int
+	FixCommits bool
+	Verbose    bool
+	Quiet      bool
+}
+
+// hooksResult 记录单个项目的hooks检查与修复结果
+type hooksResult struct {
+	Project   *project.Project
+	Statuses  []hook.HookStatus
+	Linked    bool
+	Missing   map[string]int // 分支 -> 缺少Change-Id的未发布提交数
+	Fixed     map[string]int // 分支 -> 补充Change-Id的提交数
+	NotSynced bool
+	Err       error
+}
+
+// HooksCmd 返回hooks命令
+func HooksCmd() *cobra.Command {
+	opts := &HooksOptions{
+		Jobs: runtime.NumCPU() * 2,
+	}
+
+	cmd := &cobra.Command{
+		Use:   "hooks",
+		Short: "Manage git hooks installed in projects",
+		Long: `Manage the git hooks (commit-msg, pre-commit, ...) that repo installs into
+every project from .repo/hooks.
+
+  install  refresh .repo/hooks and link the hooks into every project
+  status   report projects whose hooks are missing or outdated
+  repair   relink only the projects whose hooks are missing or outdated
+
+With --fix-commits, install and repair also add a Change-Id trailer to
+unpublished commits on local branches that were made without the
+commit-msg hook.`,
+	}
+
+	cmd.PersistentFlags().IntVarP(&opts.Jobs, "jobs", "j", opts.Jobs, "number of jobs to run in parallel")
+	cmd.PersistentFlags().BoolVarP(&opts.Verbose, "verbose", "v", false, "show all output")
+	cmd.PersistentFlags().BoolVarP(&opts.Quiet, "quiet", "q", false, "only show errors")
+	AddManifestFlags(cmd, &opts.CommonManifestOptions)
+
+	for _, action := range []struct{ name, short string }{
+		{"install", "Install hooks into all projects"},
+		{"status", "Show projects with missing or outdated hooks"},
+		{"repair", "Relink missing or outdated hooks"},
+	} {
+		action := action
+		sub := &cobra.Command{
+			Use:               action.name + " [<project>...]",
+			Short:             action.short,
+			ValidArgsFunction: completeProjects,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return runHooks(cmd.Context(), opts, action.name, args)
+			},
+		}
+		if action.name != "status" {
+			sub.Flags().BoolVar(&opts.FixCommits, "fix-commits", false, "add Change-Ids to unpublished commits on local branches")
+		}
+		cmd.AddCommand(sub)
+	}
+
+	return cmd
+}
+
+// runHooks 执行hooks子命令
+func runHooks(ctx context.Context, opts *HooksOptions, action string, args []string) error {
+	log := logger.NewDefaultLogger()
+	if opts.Quiet {
+		log.SetLevel(logger.LogLevelError)
+	} else if opts.Verbose {
+		log.SetLevel(logger.LogLevelDebug)
+	} else {
+		log.SetLevel(logger.LogLevelInfo)
+	}
+	hook.SetLogger(log)
+
+	originalDir, err := EnsureRepoRoot(log)
+	if err != nil {
+		log.Error("查找repo根目录失败: %v", err)
+		return fmt.Errorf("failed to locate repo root: %w", err)
+	}
+	defer RestoreWorkDir(originalDir, log)
+
+	repoRoot, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get current directory: %w", err)
+	}
+	hooksDir := filepath.Join(repoRoot, ".repo", "hooks")
+
+	if action == "install" {
+		if err := hook.InitHooks(repoRoot); err != nil {
+			log.Error("初始化hooks失败: %v", err)
+			return fmt.Errorf("failed to init hooks: %w", err)
+		}
+	} else if _, err := os.Stat(hooksDir); err != nil {
+		return fmt.Errorf("hooks directory %s not found, run 'repo hooks install' first", hooksDir)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Error("加载配置文件失败: %v", err)
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if err := requireGitClient(cfg, "hooks"); err != nil {
+		return err
+	}
+
+	parser := manifest.NewParser()
+	manifestObj, err := parser.ParseFromFile(cfg.ManifestName, strings.Split(cfg.Groups, ","))
+	if err != nil {
+		log.Error("解析清单文件失败: %v", err)
+		return fmt.Errorf("failed to parse manifest: %w", err)
+	}
+
+	manager := project.NewManagerFromManifest(manifestObj, cfg)
+	var projects []*project.Project
+	if len(args) == 0 {
+		projects, err = manager.GetProjectsInGroups(nil)
+	} else {
+		projects, err = manager.GetProjectsByNames(args)
+	}
+	if err != nil {
+		log.Error("获取项目失败: %v", err)
+		return fmt.Errorf("failed to get projects: %w", err)
+	}
+
+	jobs := opts.Jobs
+	if jobs < 1 {
+		jobs = 1
+	}
+
+	// 结果按清单顺序保存
+	results := make([]*hooksResult, len(projects))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, jobs)
+	for i, p := range projects {
+		wg.Add(1)
+		go func(i int, p *project.Project) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			results[i] = processProjectHooks(ctx, opts, action, p, hooksDir, log)
+		}(i, p)
+	}
+	wg.Wait()
+
+	return printHooksReport(action, results)
+}
+
+// processProjectHooks 检查、按需重新链接单个项目的hooks，并处理缺少Change-Id的提交
+func processProjectHooks(ctx context.Context, opts *HooksOptions, action string, p *project.Project, hooksDir string, log logger.Logger) *hooksResult {
+	result := &hooksResult{Project: p}
+
+	if _, err := os.Stat(filepath.Join(p.Worktree, ".git")); err != nil {
+		result.NotSynced = true
+		return result
+	}
+
+	statuses, err := hook.CheckHooks(p.Worktree, hooksDir)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	if action == "install" || (action == "repair" && !hook.HooksUpToDate(statuses)) {
+		log.Debug("正在为项目 %s 链接hooks", p.Name)
+		if err := hook.LinkHooks(p.Worktree, hooksDir); err != nil {
+			result.Err = err
+			return result
+		}
+		result.Linked = true
+		if statuses, err = hook.CheckHooks(p.Worktree, hooksDir); err != nil {
+			result.Err = err
+			return result
+		}
+	}
+	result.Statuses = statuses
+
+	branches, err := p.GitRepo.LocalBranches()
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	for _, branch := range branches {
+		commits, err := p.GitRepo.UnpublishedCommits(branch, p.RemoteName)
+		if err != nil {
+			result.Err = err
+			return result
+		}
+
+		if action != "status" && opts.FixCommits {
+			fixed, err := hook.FixChangeIDs(ctx, p.Worktree, branch, commits)
+			if err != nil {
+				result.Err = fmt.Errorf("branch %s: %w", branch, err)
+				return result
+			}
+			if fixed > 0 {
+				if result.Fixed == nil {
+					result.Fixed = make(map[string]int)
+				}
+				result.Fixed[branch] = fixed
+			}
+			continue
+		}
+
+		missing, err := countCommitsWithoutChangeID(p.GitRepo, commits)
+		if err != nil {
+			result.Err = err
+			return result
+		}
+		if missing > 0 {
+			if result.Missing == nil {
+				result.Missing = make(map[string]int)
+			}
+			result.Missing[branch] = missing
+		}
+	}
+
+	return result
+}
+
+// countCommitsWithoutChangeID 统计提交列表中缺少Change-Id的数量
+func countCommitsWithoutChangeID(repo *git.Repository, commits []string) (int, error) {
+	missing := 0
+	for _, commit := range commits {
+		message, err := repo.CommitMessage(commit)
+		if err != nil {
+			return 0, err
+		}
+		if git.ParseChangeID(message) == "" {
+			missing++
+		}
+	}
+	return missing, nil
+}
+
+// printHooksReport 按清单顺序输出hooks状态，只列出需要关注的项目
+func printHooksReport(action string, results []*hooksResult) error {
+	var errs []error
+	unhealthy := 0
+	withoutChangeID := 0
+
+	for _, r := range results {
+		if r.NotSynced {
+			continue
+		}
+		if r.Err != nil {
+			fmt.Printf("project %s: error: %v\n", r.Project.Name, r.Err)
+			errs = append(errs, fmt.Errorf("project %s: %w", r.Project.Name, r.Err))
+			continue
+		}
+
+		var problems []string
+		for _, s := range r.Statuses {
+			if s.State != hook.HookOK {
+				problems = append(problems, fmt.Sprintf("%s %s", s.State, s.Name))
+			}
+		}
+		if len(problems) > 0 {
+			unhealthy++
+			fmt.Printf("project %s: %s\n", r.Project.Name, strings.Join(problems, ", "))
+		} else if r.Linked && action == "repair" {
+			fmt.Printf("project %s: hooks relinked\n", r.Project.Name)
+		}
+
+		for _, branch := range sortedKeys(r.Fixed) {
+			fmt.Printf("project %s: branch %s: added Change-Id to %d commit(s)\n", r.Project.Name, branch, r.Fixed[branch])
+		}
+		for _, branch := range sortedKeys(r.Missing) {
+			withoutChangeID++
+			fmt.Printf("project %s: branch %s: %d unpublished commit(s) without Change-Id\n", r.Project.Name, branch, r.Missing[branch])
+		}
+	}
+
+	if unhealthy > 0 {
+		fmt.Printf("%d project(s) have missing or outdated hooks, run 'repo hooks repair'\n", unhealthy)
+	}
+	if withoutChangeID > 0 {
+		fmt.Printf("%d branch(es) have commits without Change-Id, run 'repo hooks repair --fix-commits'\n", withoutChangeID)
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%d projects failed: %w", len(errs), errors.Join(errs...))
+	}
+	return nil
+}
+
+// sortedKeys 返回按字典序排列的map键
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -1,11 +1,18 @@
 package commands
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 
+	"github.com/leopardxu/repo-go/internal/archive"
 	"github.com/leopardxu/repo-go/internal/config"
+	"github.com/leopardxu/repo-go/internal/git"
 	"github.com/leopardxu/repo-go/internal/logger"
 	"github.com/leopardxu/repo-go/internal/manifest"
 	"github.com/leopardxu/repo-go/internal/project"
@@ -24,27 +31,35 @@ type InfoOptions struct {
 	OuterManifest    bool
 	NoOuterManifest  bool
 	ThisManifestOnly bool
+	Format           string // 输出格式: text 或 json
+	Jobs             int
 	Config           *config.Config // <-- Add this field
 	CommonManifestOptions
 }
 
-// infoStats 用于统计info命令的执行结果
-type infoStats struct {
-	mu      sync.Mutex
-	success int
-	failed  int
-}
-
 // InfoCmd 返回info命令
 func InfoCmd() *cobra.Command {
 	opts := &InfoOptions{}
 
 	cmd := &cobra.Command{
-		Use:   "info [-dl] [-o [-c]] [<project>...]",
-		Short: "Get info on the manifest branch, current branch or unmerged branches",
-		Long:  `Show detailed information about projects including branch info.`,
+		Use:               "info [-dl] [-o [-c]] [<project>...]",
+		Short:             "Get info on the manifest branch, current branch or unmerged branches",
+		ValidArgsFunction: completeProjects,
+		Long: `Show information about the manifest branch, the current branch and the local
+branches of each project.
+
+With -d, the commits of the current branch that are not in the manifest
+revision (local commits) and the commits of the manifest revision that are not
+in the current branch (remote commits) are listed. The remote is fetched first
+unless -l is given.
+
+With -o, an overview of every topic branch and its commits that are not yet
+in the manifest revision is printed instead. -c restricts the overview to the
+checked out branches.
+
+--format=json prints the same information as a JSON document.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runInfo(opts, args)
+			return runInfo(cmd.Context(), opts, args)
 		},
 	}
 
@@ -56,6 +71,8 @@ func InfoCmd() *cobra.Command {
 	cmd.Flags().BoolVarP(&opts.LocalOnly, "local-only", "l", false, "disable all remote operations")
 	cmd.Flags().BoolVarP(&opts.Verbose, "verbose", "v", false, "show all output")
 	cmd.Flags().BoolVarP(&opts.Quiet, "quiet", "q", false, "only show errors")
+	cmd.Flags().StringVar(&opts.Format, "format", "text", "output format: text or json")
+	cmd.Flags().IntVarP(&opts.Jobs, "jobs", "j", 8, "number of jobs to run in parallel")
 	cmd.Flags().BoolVar(&opts.OuterManifest, "outer-manifest", false, "operate starting at the outermost manifest")
 	cmd.Flags().BoolVar(&opts.NoOuterManifest, "no-outer-manifest", false, "do not operate on outer manifests")
 	cmd.Flags().BoolVar(&opts.ThisManifestOnly, "this-manifest-only", false, "only operate on this (sub)manifest")
@@ -63,9 +80,51 @@ func InfoCmd() *cobra.Command {
 	return cmd
 }
 
+// manifestInfo 描述清单仓库的状态
+type manifestInfo struct {
+	Branch       string            `json:"branch"`
+	MergeBranch  string            `json:"merge_branch"`
+	Groups       string            `json:"groups"`
+	Superproject *superprojectInfo `json:"superproject,omitempty"`
+}
+
+// superprojectInfo 描述清单中的超级项目
+type superprojectInfo struct {
+	Name     string `json:"name"`
+	Revision string `json:"revision,omitempty"`
+	Enabled  bool   `json:"enabled"`
+}
+
+// infoBranch 描述一个本地分支及其未合入清单修订版本的提交
+type infoBranch struct {
+	Name    string   `json:"name"`
+	Current bool     `json:"current"`
+	Date    string   `json:"date,omitempty"`
+	Commits []string `json:"commits"`
+}
+
+// infoProject 描述单个项目的信息
+type infoProject struct {
+	Name             string       `json:"name"`
+	Path             string       `json:"path"`
+	MountPath        string       `json:"mount_path"`
+	CurrentRevision  string       `json:"current_revision,omitempty"`
+	CurrentBranch    string       `json:"current_branch,omitempty"`
+	ManifestRevision string       `json:"manifest_revision"`
+	LocalBranches    []string     `json:"local_branches"`
+	LocalCommits     []string     `json:"local_commits,omitempty"`
+	RemoteCommits    []string     `json:"remote_commits,omitempty"`
+	Branches         []infoBranch `json:"branches,omitempty"`
+	Missing          bool         `json:"missing,omitempty"`
+	Error            string       `json:"error,omitempty"`
+}
+
 // runInfo 执行info命令
-// runInfo executes the info command logic
-func runInfo(opts *InfoOptions, args []string) error {
+func runInfo(ctx context.Context, opts *InfoOptions, args []string) error {
+	if opts.Format != "text" && opts.Format != "json" {
+		return fmt.Errorf("invalid --format %q, expected text or json", opts.Format)
+	}
+
 	// 初始化日志记录器
 	log := logger.NewDefaultLogger()
 	if opts.Verbose {
@@ -76,8 +135,6 @@ func runInfo(opts *InfoOptions, args []string) error {
 		log.SetLevel(logger.LogLevelInfo)
 	}
 
-	log.Info("Starting info command")
-
 	// 确保在repo根目录下执行
 	originalDir, err := EnsureRepoRoot(log)
 	if err != nil {
@@ -87,276 +144,324 @@ func runInfo(opts *InfoOptions, args []string) error {
 	defer RestoreWorkDir(originalDir, log)
 
 	// 加载配置
-	cfg, err := config.Load() // 声明err
+	cfg, err := config.Load()
 	if err != nil {
 		log.Error("Failed to load config: %v", err)
 		return err
 	}
-	opts.Config = cfg // 分配加载的配置
+	opts.Config = cfg
+	if opts.Diff {
+		if err := requireGitClient(cfg, "info -d"); err != nil {
+			return err
+		}
+	}
+	if opts.Overview {
+		if err := requireGitClient(cfg, "info -o"); err != nil {
+			return err
+		}
+	}
 
 	// 加载manifest
 	log.Debug("Loading manifest from %s", cfg.ManifestName)
 	parser := manifest.NewParser()
-	manifest, err := parser.ParseFromFile(cfg.ManifestName, strings.Split(cfg.Groups, ",")) // 重用err
+	manifestObj, err := parser.ParseFromFile(cfg.ManifestName, strings.Split(cfg.Groups, ","))
 	if err != nil {
 		log.Error("Failed to parse manifest: %v", err)
 		return err
 	}
 
 	// 创建项目管理器
-	manager := project.NewManagerFromManifest(manifest, cfg)
+	manager := project.NewManagerFromManifest(manifestObj, cfg)
 
-	// 声明projects变量
 	var projects []*project.Project
-
-	// 获取要操作的项目
 	if len(args) == 0 {
 		log.Debug("Getting all projects")
-		projects, err = manager.GetProjectsInGroups(nil) // 使用=，使用nil
+		projects, err = manager.GetProjectsInGroups(nil)
 		if err != nil {
 			log.Error("Failed to get projects: %v", err)
 			return err
 		}
 	} else {
 		log.Debug("Getting projects by names: %v", args)
-		projects, err = manager.GetProjectsByNames(args) // 使用=
+		projects, err = manager.GetProjectsByNames(args)
 		if err != nil {
 			log.Error("Failed to get projects by name: %v", err)
 			return err
 		}
 	}
+	log.Debug("Found %d projects to process", len(projects))
 
-	log.Info("Found %d projects to process", len(projects))
+	header := collectManifestInfo(ctx, cfg, manifestObj)
 
-	// 并发获取项目信息
-	type infoResult struct {
-		Project *project.Project
-		Output  string
-		Err     error
+	jobs := opts.Jobs
+	if jobs <= 0 {
+		jobs = 8
 	}
-
-	results := make(chan infoResult, len(projects))
-	sem := make(chan struct{}, 8) // 控制并发数
+	infos := make([]*infoProject, len(projects))
+	sem := make(chan struct{}, jobs)
 	var wg sync.WaitGroup
-	stats := &infoStats{}
-
-	for _, p := range projects {
+	for i, p := range projects {
 		wg.Add(1)
-		sem <- struct{}{}
-		go func(proj *project.Project) {
-			defer func() {
-				<-sem
-				wg.Done()
-			}()
-
-			var output string
-			var err error
-			var outputBytes []byte
-
-			log.Debug("Processing project %s", proj.Name)
-
-			// 根据选项显示不同信息
-			switch {
-			case opts.Diff:
-				log.Debug("Getting diff for project %s", proj.Name)
-				outputBytes, err = proj.GitRepo.RunCommand("log", "--oneline", "HEAD..@{upstream}")
-				if err == nil {
-					output = strings.TrimSpace(string(outputBytes))
-				}
-			case opts.Overview:
-				log.Debug("Getting overview for project %s", proj.Name)
-				outputBytes, err = proj.GitRepo.RunCommand("log", "--oneline", "-10")
-				if err == nil {
-					output = strings.TrimSpace(string(outputBytes))
-				}
-			case opts.CurrentBranch:
-				log.Debug("Getting current branch for project %s", proj.Name)
-				outputBytes, err = proj.GitRepo.RunCommand("rev-parse", "--abbrev-ref", "HEAD")
-				if err == nil {
-					output = strings.TrimSpace(string(outputBytes))
-				}
-			default:
-				log.Debug("Getting status for project %s", proj.Name)
-				outputBytes, err = proj.GitRepo.RunCommand("status", "--short")
-				if err == nil {
-					output = strings.TrimSpace(string(outputBytes))
-				}
-			}
-
-			results <- infoResult{Project: proj, Output: output, Err: err}
-		}(p)
+		go func(i int, p *project.Project) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			log.Debug("Processing project %s", p.Name)
+			infos[i] = collectProjectInfo(ctx, opts, p, log)
+		}(i, p)
 	}
+	wg.Wait()
 
-	// 等待所有goroutine完成后关闭结果通道
-	go func() {
-		wg.Wait()
-		close(results)
-	}()
-
-	// 收集并显示结果
-	for res := range results {
-		if res.Err != nil {
-			stats.mu.Lock()
-			stats.failed++
-			stats.mu.Unlock()
-
-			log.Error("Error getting info for %s: %v", res.Project.Name, res.Err)
-			continue
+	failed := 0
+	for _, info := range infos {
+		if info.Error != "" {
+			failed++
+			log.Error("Error getting info for %s: %s", info.Name, info.Error)
 		}
+	}
 
-		stats.mu.Lock()
-		stats.success++
-		stats.mu.Unlock()
-
-		if res.Output != "" {
-			log.Info("--- %s ---\n%s", res.Project.Name, res.Output)
-		} else if !opts.Quiet {
-			log.Info("--- %s ---\n(No changes)", res.Project.Name)
+	if opts.Format == "json" {
+		data, err := encodeInfoJSON(header, infos)
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+	} else {
+		printManifestInfo(header)
+		if opts.Overview {
+			printInfoOverview(infos)
+		} else {
+			for _, info := range infos {
+				printProjectInfo(opts, info)
+			}
 		}
 	}
 
-	// 显示统计信息
-	log.Info("Info command completed: %d successful, %d failed", stats.success, stats.failed)
-
-	if stats.failed > 0 {
-		return fmt.Errorf("%d projects failed", stats.failed)
+	if failed > 0 {
+		return fmt.Errorf("%d projects failed", failed)
 	}
-
 	return nil
 }
 
-// showDiff 显示完整信息和提交差异
-func showDiff(p *project.Project) {
-	fmt.Println("Commit differences:")
-
-	// 获取本地和远程分支之间的差异
-	outputBytes, err := p.GitRepo.RunCommand("log", "--oneline", "HEAD..@{upstream}")
+// encodeInfoJSON 把清单头部和各项目信息编码为 --format=json 输出的文档
+func encodeInfoJSON(header *manifestInfo, infos []*infoProject) ([]byte, error) {
+	output := struct {
+		Manifest *manifestInfo  `json:"manifest"`
+		Projects []*infoProject `json:"projects"`
+	}{header, infos}
+	data, err := json.MarshalIndent(output, "", "  ")
 	if err != nil {
-		fmt.Printf("Error getting commit diff: %v\n", err)
-		return
-	}
-
-	output := strings.TrimSpace(string(outputBytes))
-	if output != "" {
-		fmt.Println(output)
-	} else {
-		fmt.Println("No differences found")
+		return nil, fmt.Errorf("failed to encode info: %w", err)
 	}
+	return data, nil
 }
 
-// showLocalBranches 显示本地分支信息
-func showLocalBranches(p *project.Project) {
-	fmt.Println("Local branches:")
+// collectManifestInfo 读取清单仓库的分支、合并分支、组和超级项目状态
+func collectManifestInfo(ctx context.Context, cfg *config.Config, m *manifest.Manifest) *manifestInfo {
+	info := &manifestInfo{Branch: cfg.ManifestBranch, Groups: cfg.Groups}
+	if info.Groups == "" {
+		info.Groups = "default"
+	}
 
-	outputBytes, err := p.GitRepo.RunCommand("branch")
-	if err != nil {
-		fmt.Printf("Error getting local branches: %v\n", err)
-		return
+	manifestsDir := filepath.Join(".repo", "manifests")
+	if output, err := runGitIn(ctx, manifestsDir, "symbolic-ref", "--short", "HEAD"); err == nil {
+		info.Branch = output
+	}
+	if output, err := runGitIn(ctx, manifestsDir, "config", "--get", "branch.default.merge"); err == nil {
+		info.MergeBranch = output
+	} else if info.Branch != "" {
+		info.MergeBranch = "refs/heads/" + strings.TrimPrefix(info.Branch, "refs/heads/")
 	}
 
-	output := strings.TrimSpace(string(outputBytes))
-	if output != "" {
-		fmt.Println(output)
-	} else {
-		fmt.Println("No local branches found")
+	if m.Superproject != nil {
+		info.Superproject = &superprojectInfo{
+			Name:     m.Superproject.Name,
+			Revision: m.Superproject.Revision,
+			Enabled:  cfg.IsSuperprojectEnabled(),
+		}
 	}
+	return info
 }
 
-// showRemoteBranches 显示远程分支信息
-func showRemoteBranches(p *project.Project) {
-	fmt.Println("Remote branches:")
-
-	outputBytes, err := p.GitRepo.RunCommand("branch", "-r")
-	if err != nil {
-		fmt.Printf("Error getting remote branches: %v\n", err)
-		return
-	}
-	output := strings.TrimSpace(string(outputBytes))
-	if output != "" {
-		fmt.Println(output)
-	} else {
-		fmt.Println("No remote branches found")
+// runGitIn 在指定目录执行git命令并返回去除首尾空白的输出，不记录失败日志
+func runGitIn(ctx context.Context, dir string, args ...string) (string, error) {
+	var stdout bytes.Buffer
+	cmd := git.Command(ctx, dir, args...)
+	cmd.Stdout = &stdout
+	if err := git.Run(cmd); err != nil {
+		return "", err
 	}
+	return strings.TrimSpace(stdout.String()), nil
 }
 
-// showCurrentBranchInfo 显示当前分支信息
-func showCurrentBranchInfo(p *project.Project) {
-	fmt.Println("Current branch info:")
+// collectProjectInfo 收集单个项目的信息
+func collectProjectInfo(ctx context.Context, opts *InfoOptions, p *project.Project, log logger.Logger) *infoProject {
+	info := &infoProject{
+		Name:             p.Name,
+		Path:             projectRelPath(p),
+		MountPath:        p.Worktree,
+		ManifestRevision: p.Revision,
+		LocalBranches:    []string{},
+	}
+	if abs, err := filepath.Abs(p.Worktree); err == nil {
+		info.MountPath = abs
+	}
+	if _, err := os.Stat(p.Worktree); err != nil {
+		info.Missing = true
+		return info
+	}
+	// 归档检出没有 git 仓库，当前修订版本是最近一次导出的提交
+	if opts.Config != nil && opts.Config.Archive {
+		state, err := archive.LoadState(opts.Config.RepoRoot, info.Path)
+		if err != nil {
+			info.Error = err.Error()
+			return info
+		}
+		info.CurrentRevision = state.Commit
+		return info
+	}
+	repo := p.GitRepo
 
-	// 获取当前分支的最近提
-	outputBytes, err := p.GitRepo.RunCommand("log", "-1", "--oneline")
+	if head, err := repo.RevParse("HEAD"); err == nil {
+		info.CurrentRevision = head
+	}
+	if branch, err := repo.CurrentBranch(); err == nil && !strings.HasPrefix(branch, "HEAD detached") {
+		info.CurrentBranch = branch
+	}
+	branches, err := repo.LocalBranches()
 	if err != nil {
-		fmt.Printf("Error getting current branch info: %v\n", err)
-		return
+		info.Error = err.Error()
+		return info
 	}
-	output := strings.TrimSpace(string(outputBytes))
-	if output != "" {
-		fmt.Println(output)
-	} else {
-		fmt.Println("No commit info found")
+	if branches != nil {
+		info.LocalBranches = branches
 	}
-}
 
-// showAllBranches 显示所有分支信
-func showAllBranches(p *project.Project) {
-	fmt.Println("All branches:")
+	if !opts.Diff && !opts.Overview {
+		return info
+	}
 
-	outputBytes, err := p.GitRepo.RunCommand("branch", "-a")
-	if err != nil {
-		fmt.Printf("Error getting all branches: %v\n", err)
-		return
+	// -d 需要最新的远程修订版本，-l 时只使用本地已有的引用
+	if opts.Diff && !opts.LocalOnly && p.RemoteName != "" {
+		log.Debug("Fetching %s in %s", p.RemoteName, p.Name)
+		if _, err := repo.Runner.RunInDirContext(ctx, p.Worktree, "fetch", "--quiet", p.RemoteName); err != nil {
+			log.Warn("Failed to fetch %s in %s: %v", p.RemoteName, p.Name, err)
+		}
 	}
 
-	output := strings.TrimSpace(string(outputBytes))
-	if output != "" {
-		fmt.Println(output)
-	} else {
-		fmt.Println("No branches found")
+	ref := p.RevisionRef()
+	if ref == "" {
+		return info
+	}
+	if _, err := repo.RevParse(ref); err != nil {
+		info.Error = fmt.Sprintf("manifest revision %s not found, run repo sync first", ref)
+		return info
 	}
-}
 
-// showCommitOverview 显示所有本地提交的概览
-func showCommitOverview(p *project.Project) {
-	fmt.Println("Commit overview:")
+	if opts.Diff && info.CurrentRevision != "" {
+		info.LocalCommits, _ = repo.CommitSummaries(ref, "HEAD")
+		info.RemoteCommits, _ = repo.CommitSummaries("HEAD", ref)
+	}
 
-	outputBytes, err := p.GitRepo.RunCommand("log", "--oneline", "-10")
-	if err != nil {
-		fmt.Printf("Error getting commit overview: %v\n", err)
-		return
+	if opts.Overview {
+		for _, name := range branches {
+			if opts.CurrentBranch && !opts.NoCurrentBranch && name != info.CurrentBranch {
+				continue
+			}
+			commits, err := repo.CommitSummaries(ref, name)
+			if err != nil || len(commits) == 0 {
+				continue
+			}
+			b := infoBranch{Name: name, Current: name == info.CurrentBranch, Commits: commits}
+			if output, err := repo.Runner.RunInDirContext(ctx, p.Worktree, "log", "-1", "--format=%cd", "--date=format:%Y-%m-%d %H:%M", name); err == nil {
+				b.Date = strings.TrimSpace(string(output))
+			}
+			info.Branches = append(info.Branches, b)
+		}
 	}
+	return info
+}
+
+// infoSeparator 分隔各项目输出的横线
+const infoSeparator = "----------------------------"
 
-	output := strings.TrimSpace(string(outputBytes))
-	if output != "" {
-		fmt.Println(output)
+// printManifestInfo 输出清单头部信息
+func printManifestInfo(info *manifestInfo) {
+	fmt.Printf("Manifest branch: %s\n", info.Branch)
+	fmt.Printf("Manifest merge branch: %s\n", info.MergeBranch)
+	fmt.Printf("Manifest groups: %s\n", info.Groups)
+	if info.Superproject == nil {
+		fmt.Println("Superproject: none")
 	} else {
-		fmt.Println("No commits found")
+		state := "disabled"
+		if info.Superproject.Enabled {
+			state = "enabled"
+		}
+		fmt.Printf("Superproject: %s (%s)\n", info.Superproject.Name, state)
+		if info.Superproject.Revision != "" {
+			fmt.Printf("Superproject revision: %s\n", info.Superproject.Revision)
+		}
 	}
+	fmt.Println(infoSeparator)
 }
 
-// showBasicInfo 显示基本信息
-func showBasicInfo(p *project.Project) {
-	// 获取最近的提交
-	outputBytes, err := p.GitRepo.RunCommand("log", "-1", "--oneline")
-	if err != nil {
-		fmt.Printf("Error getting latest commit: %v\n", err)
-		return
+// printProjectInfo 输出单个项目的信息
+func printProjectInfo(opts *InfoOptions, info *infoProject) {
+	fmt.Printf("Project: %s\n", info.Name)
+	fmt.Printf("Mount path: %s\n", info.MountPath)
+	if info.Missing {
+		fmt.Println(`Current revision: (missing, run "repo sync")`)
+	} else {
+		fmt.Printf("Current revision: %s\n", info.CurrentRevision)
+	}
+	if info.CurrentBranch != "" {
+		fmt.Printf("Current branch: %s\n", info.CurrentBranch)
+	}
+	fmt.Printf("Manifest revision: %s\n", info.ManifestRevision)
+	fmt.Printf("Local Branches: %d", len(info.LocalBranches))
+	if len(info.LocalBranches) > 0 {
+		fmt.Printf(" [%s]", strings.Join(info.LocalBranches, ", "))
 	}
+	fmt.Println()
 
-	output := strings.TrimSpace(string(outputBytes))
-	fmt.Printf("Latest commit: %s\n", output)
+	if opts.Diff && !info.Missing {
+		printInfoCommits("Local Commits", info.LocalCommits)
+		printInfoCommits("Remote Commits", info.RemoteCommits)
+	}
+	fmt.Println(infoSeparator)
+}
 
-	// 获取未提交的更改
-	outputBytes, err = p.GitRepo.RunCommand("status", "--short")
-	if err != nil {
-		fmt.Printf("Error getting status: %v\n", err)
-		return
+// printInfoCommits 输出提交计数和列表
+func printInfoCommits(title string, commits []string) {
+	fmt.Printf("%s: %d\n", title, len(commits))
+	for _, commit := range commits {
+		fmt.Printf("  %s\n", commit)
 	}
+}
 
-	output = strings.TrimSpace(string(outputBytes))
-	if output != "" {
-		fmt.Println("Uncommitted changes:")
-		fmt.Println(output)
-	} else {
-		fmt.Println("Working directory clean")
+// printInfoOverview 输出所有项目中未合入清单修订版本的主题分支
+func printInfoOverview(infos []*infoProject) {
+	fmt.Println("Projects Overview")
+	found := false
+	for _, info := range infos {
+		if len(info.Branches) == 0 {
+			continue
+		}
+		found = true
+		fmt.Printf("project %s/\n", info.Path)
+		for _, b := range info.Branches {
+			marker := " "
+			if b.Current {
+				marker = "*"
+			}
+			fmt.Printf("%s %-33s (%s)\n", marker, b.Name, b.Date)
+			for _, commit := range b.Commits {
+				fmt.Printf("    - %s\n", commit)
+			}
+		}
+	}
+	if !found {
+		fmt.Println("  (no unmerged topic branches)")
 	}
 }
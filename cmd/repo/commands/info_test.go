@@ -0,0 +1,200 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/leopardxu/repo-go/internal/config"
+	"github.com/leopardxu/repo-go/internal/manifest"
+	"github.com/leopardxu/repo-go/internal/project"
+	"github.com/leopardxu/repo-go/internal/testutil"
+)
+
+// newInfoFixture 创建一个检出在 topic 上的项目：topic 有一个本地提交，上游 origin/main 有一个新提交，
+// 另有一个没有自己提交的分支 empty；远程地址不存在，fetch 会失败
+func newInfoFixture(t *testing.T) *project.Project {
+	t.Helper()
+	dir := testutil.NewRepo(t)
+	testutil.Git(t, dir, "branch", "empty")
+	testutil.Git(t, dir, "checkout", "--quiet", "--detach")
+	testutil.Commit(t, dir, "upstream", "upstream\n", "upstream change")
+	testutil.Git(t, dir, "update-ref", "refs/remotes/origin/main", "HEAD")
+	testutil.Git(t, dir, "checkout", "--quiet", "-b", "topic", "main")
+	testutil.Commit(t, dir, "local", "local\n", "local change")
+	testutil.Git(t, dir, "remote", "set-url", "origin", filepath.Join(t.TempDir(), "missing"))
+	return testutil.NewProject(dir, "p")
+}
+
+// jsonKeys 返回对象编码为 JSON 后的字段名
+func jsonKeys(t *testing.T, v interface{}) []string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestCollectProjectInfo(t *testing.T) {
+	p := newInfoFixture(t)
+	ctx := context.Background()
+	head := testutil.Git(t, p.Worktree, "rev-parse", "HEAD")
+
+	info := collectProjectInfo(ctx, &InfoOptions{}, p, &recordLogger{})
+	want := &infoProject{
+		Name:             "p",
+		Path:             "p",
+		MountPath:        p.Worktree,
+		CurrentRevision:  head,
+		CurrentBranch:    "topic",
+		ManifestRevision: "main",
+		LocalBranches:    []string{"empty", "main", "topic"},
+	}
+	if !reflect.DeepEqual(info, want) {
+		t.Errorf("collectProjectInfo() = %+v, want %+v", info, want)
+	}
+	// 没有 -d 和 -o 时不输出提交和分支列表
+	if got, want := jsonKeys(t, info), []string{"current_branch", "current_revision", "local_branches", "manifest_revision", "mount_path", "name", "path"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("JSON fields = %v, want %v", got, want)
+	}
+
+	// -d 先获取远程，-l 跳过获取只使用本地引用
+	log := &recordLogger{}
+	info = collectProjectInfo(ctx, &InfoOptions{Diff: true, LocalOnly: true}, p, log)
+	if len(log.lines) != 0 {
+		t.Errorf("-l fetched the remote: %q", log.lines)
+	}
+	if len(info.LocalCommits) != 1 || !strings.HasSuffix(info.LocalCommits[0], " local change") {
+		t.Errorf("local commits = %q", info.LocalCommits)
+	}
+	if len(info.RemoteCommits) != 1 || !strings.HasSuffix(info.RemoteCommits[0], " upstream change") {
+		t.Errorf("remote commits = %q", info.RemoteCommits)
+	}
+	collectProjectInfo(ctx, &InfoOptions{Diff: true}, p, log)
+	if len(log.lines) != 1 || !strings.Contains(log.lines[0], "Failed to fetch origin") {
+		t.Errorf("-d without -l did not fetch the remote: %q", log.lines)
+	}
+
+	// -o 列出有未合并提交的分支，-c 只看当前分支
+	testutil.Git(t, p.Worktree, "branch", "other", "topic")
+	info = collectProjectInfo(ctx, &InfoOptions{Overview: true}, p, &recordLogger{})
+	var names []string
+	for _, b := range info.Branches {
+		names = append(names, b.Name)
+		if b.Date == "" || len(b.Commits) != 1 || b.Current != (b.Name == "topic") {
+			t.Errorf("overview branch = %+v", b)
+		}
+	}
+	if !reflect.DeepEqual(names, []string{"other", "topic"}) {
+		t.Errorf("overview branches = %v, want other and topic", names)
+	}
+	info = collectProjectInfo(ctx, &InfoOptions{Overview: true, CurrentBranch: true}, p, &recordLogger{})
+	if len(info.Branches) != 1 || info.Branches[0].Name != "topic" {
+		t.Errorf("overview -c branches = %+v, want only topic", info.Branches)
+	}
+
+	// 未同步的项目只标记缺失
+	missing := testutil.NewProject(filepath.Join(t.TempDir(), "missing"), "missing")
+	info = collectProjectInfo(ctx, &InfoOptions{Diff: true}, missing, &recordLogger{})
+	if !info.Missing || info.CurrentRevision != "" || info.LocalBranches == nil {
+		t.Errorf("collectProjectInfo(missing) = %+v", info)
+	}
+}
+
+func TestInfoJSON(t *testing.T) {
+	root := t.TempDir()
+	manifests := filepath.Join(root, ".repo", "manifests")
+	if err := os.MkdirAll(manifests, 0755); err != nil {
+		t.Fatal(err)
+	}
+	testutil.Git(t, manifests, "init", "--quiet", "-b", "stable")
+	testutil.Git(t, manifests, "config", "branch.default.merge", "refs/heads/release")
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(root); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(cwd)
+
+	cfg := &config.Config{ManifestBranch: "main", UseSuperproject: true}
+	m := &manifest.Manifest{Superproject: &manifest.Superproject{Name: "super", Revision: "main"}}
+	header := collectManifestInfo(context.Background(), cfg, m)
+
+	p := newInfoFixture(t)
+	info := collectProjectInfo(context.Background(), &InfoOptions{Diff: true, LocalOnly: true}, p, &recordLogger{})
+	data, err := encodeInfoJSON(header, []*infoProject{info})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var doc struct {
+		Manifest map[string]interface{}   `json:"manifest"`
+		Projects []map[string]interface{} `json:"projects"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, data)
+	}
+	wantManifest := map[string]interface{}{
+		"branch":       "stable",
+		"merge_branch": "refs/heads/release",
+		"groups":       "default",
+		"superproject": map[string]interface{}{"name": "super", "revision": "main", "enabled": true},
+	}
+	if !reflect.DeepEqual(doc.Manifest, wantManifest) {
+		t.Errorf("manifest = %v, want %v", doc.Manifest, wantManifest)
+	}
+	if len(doc.Projects) != 1 {
+		t.Fatalf("projects = %v", doc.Projects)
+	}
+	got := doc.Projects[0]
+	for key, want := range map[string]interface{}{
+		"name":              "p",
+		"path":              "p",
+		"mount_path":        p.Worktree,
+		"current_branch":    "topic",
+		"manifest_revision": "main",
+		"current_revision":  testutil.Git(t, p.Worktree, "rev-parse", "HEAD"),
+	} {
+		if got[key] != want {
+			t.Errorf("projects[0].%s = %v, want %v", key, got[key], want)
+		}
+	}
+	for _, key := range []string{"local_branches", "local_commits", "remote_commits"} {
+		if list, ok := got[key].([]interface{}); !ok || len(list) == 0 {
+			t.Errorf("projects[0].%s = %v, want a non-empty list", key, got[key])
+		}
+	}
+	for _, key := range []string{"missing", "error", "branches"} {
+		if _, ok := got[key]; ok {
+			t.Errorf("projects[0] has %s: %v", key, got[key])
+		}
+	}
+
+	// 没有超级项目时省略该字段，合并分支取自清单分支
+	testutil.Git(t, manifests, "config", "--unset", "branch.default.merge")
+	header = collectManifestInfo(context.Background(), &config.Config{Groups: "all"}, &manifest.Manifest{})
+	if want := (&manifestInfo{Branch: "stable", MergeBranch: "refs/heads/stable", Groups: "all"}); !reflect.DeepEqual(header, want) {
+		t.Errorf("collectManifestInfo() = %+v, want %+v", header, want)
+	}
+	if keys := jsonKeys(t, header); !reflect.DeepEqual(keys, []string{"branch", "groups", "merge_branch"}) {
+		t.Errorf("manifest JSON fields = %v", keys)
+	}
+}
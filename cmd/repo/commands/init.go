@@ -111,7 +111,9 @@ func InitCmd() *cobra.Command {
 	cmd.Flags().StringVarP(&opts.ManifestURL, "manifest-url", "u", "", "manifest repository location")
 	cmd.Flags().StringVarP(&opts.ManifestBranch, "manifest-branch", "b", "", "manifest branch or revision (use HEAD for default)")
 	cmd.Flags().StringVarP(&opts.ManifestName, "manifest-name", "m", "default.xml", "initial manifest file")
+	cmd.RegisterFlagCompletionFunc("manifest-name", completeManifestFiles)
 	cmd.Flags().StringVarP(&opts.Groups, "groups", "g", "", "restrict manifest projects to ones with specified group(s)")
+	cmd.RegisterFlagCompletionFunc("groups", completeGroups)
 	cmd.Flags().StringVarP(&opts.Platform, "platform", "p", "", "restrict manifest projects to ones with a specified platform group")
 	cmd.Flags().BoolVar(&opts.Submodules, "submodules", false, "sync any submodules associated with the manifest repo")
 	cmd.Flags().BoolVar(&opts.StandaloneManifest, "standalone-manifest", false, "download the manifest as a static file")
@@ -546,6 +548,12 @@ func validateOptions(opts *InitOptions) error {
 	if opts.OuterManifest && opts.NoOuterManifest {
 		return fmt.Errorf("cannot specify both --outer-manifest and --no-outer-manifest")
 	}
+	if opts.Mirror && opts.Archive {
+		return fmt.Errorf("cannot specify both --mirror and --archive")
+	}
+	if opts.Worktree && (opts.Mirror || opts.Archive) {
+		return fmt.Errorf("cannot specify --worktree with --mirror or --archive")
+	}
 	return nil
 }
 
@@ -45,8 +45,9 @@ func ListCmd() *cobra.Command {
 	opts := &ListOptions{}
 
 	cmd := &cobra.Command{
-		Use:   "list [-f] [<project>...]",
-		Short: "List projects and their associated directories",
+		Use:               "list [-f] [<project>...]",
+		Short:             "List projects and their associated directories",
+		ValidArgsFunction: completeProjects,
 		Long: `List all projects; pass '.' to list the project for the cwd.
 
 By default, only projects that currently exist in the checkout are shown. If you
@@ -67,6 +68,7 @@ This is similar to running: repo forall -c 'echo "$REPO_PATH : $REPO_PROJECT"'`,
 	cmd.Flags().BoolVar(&opts.FullName, "full-name", false, "show project name and directory")
 	cmd.Flags().BoolVarP(&opts.FullPath, "fullpath", "f", false, "display the full work tree path instead of the relative path")
 	cmd.Flags().StringVarP(&opts.Groups, "groups", "g", "", "filter projects by groups")
+	cmd.RegisterFlagCompletionFunc("groups", completeGroups)
 	cmd.Flags().BoolVar(&opts.MissingOK, "missing-ok", false, "don't exit with an error if a project doesn't exist")
 	cmd.Flags().StringVar(&opts.PathPrefix, "path-prefix", "", "limit to projects with path prefix")
 	cmd.Flags().StringVarP(&opts.Regex, "regex", "r", "", "filter the project list based on regex or wildcard matching of strings")
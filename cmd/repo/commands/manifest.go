@@ -7,6 +7,7 @@ import (
 	"strings"
 	"sync"
 
+	"github.com/leopardxu/repo-go/internal/archive"
 	"github.com/leopardxu/repo-go/internal/config"
 	"github.com/leopardxu/repo-go/internal/logger"
 	"github.com/leopardxu/repo-go/internal/manifest"
@@ -110,8 +111,8 @@ func runManifest(opts *ManifestOptions, args []string) error {
 
 	log.Debug("清单文件解析成功，包含 %d 个项目", len(manifestObj.Projects))
 
-	// 如果需要创建快照
-	if opts.Snapshot {
+	// 如果需要创建快照，-r 把修订版本固定为各项目当前的提交
+	if opts.Snapshot || opts.RevisionAsHEAD {
 		log.Info("正在创建清单快照...")
 		// 创建快照清单
 		snapshotManifest, err := createSnapshotManifest(manifestObj, cfg, opts, log)
@@ -169,6 +170,22 @@ func runManifest(opts *ManifestOptions, args []string) error {
 	return nil
 }
 
+// projectHead 返回项目当前检出的提交，归档检出中返回最近一次导出的提交
+func projectHead(cfg *config.Config, p *project.Project) (string, error) {
+	if cfg.Archive {
+		state, err := archive.LoadState(cfg.RepoRoot, projectRelPath(p))
+		if err != nil {
+			return "", err
+		}
+		return state.Commit, nil
+	}
+	output, err := p.GitRepo.Runner.RunInDir(p.Path, "rev-parse", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
 // createSnapshotManifest 创建快照清单
 func createSnapshotManifest(m *manifest.Manifest, cfg *config.Config, opts *ManifestOptions, log logger.Logger) (*manifest.Manifest, error) {
 	// 创建快照清单的副本
@@ -231,7 +248,7 @@ func createSnapshotManifest(m *manifest.Manifest, cfg *config.Config, opts *Mani
 
 			// 获取当前HEAD提交哈希
 			log.Debug("正在获取项目 %s 的HEAD提交哈希", projName)
-			output, err := update.proj.GitRepo.Runner.RunInDir(update.proj.Path, "rev-parse", "HEAD")
+			commitHash, err := projectHead(cfg, update.proj)
 			if err != nil {
 				log.Warn("获取项目 %s 的HEAD提交哈希失败: %v", projName, err)
 				update.err = err
@@ -245,18 +262,11 @@ func createSnapshotManifest(m *manifest.Manifest, cfg *config.Config, opts *Mani
 				return
 			}
 
-			// 获取提交哈希（去除末尾的换行符）
-			commitHash := strings.TrimSpace(string(output))
 			log.Debug("项目 %s 的HEAD提交哈希: %s", projName, commitHash)
 
-			// 根据选项更新修订版本
-			if opts.RevisionAsHEAD {
-				log.Debug("将项%s 的修订版本设置为HEAD", projName)
-				snapshotManifest.Projects[update.index].Revision = "HEAD"
-			} else {
-				log.Debug("将项%s 的修订版本设置为提交哈希: %s", projName, commitHash)
-				snapshotManifest.Projects[update.index].Revision = commitHash
-			}
+			// 修订版本固定为当前检出的提交
+			log.Debug("将项%s 的修订版本设置为提交哈希: %s", projName, commitHash)
+			snapshotManifest.Projects[update.index].Revision = commitHash
 
 			// 处理SuppressUpstreamRevision选项
 			if opts.SuppressUpstreamRevision {
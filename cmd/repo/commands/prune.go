@@ -1,6 +1,7 @@
 package commands
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -12,6 +13,7 @@ import (
 	"github.com/leopardxu/repo-go/internal/logger"
 	"github.com/leopardxu/repo-go/internal/manifest"
 	"github.com/leopardxu/repo-go/internal/project"
+	"github.com/leopardxu/repo-go/internal/repo_sync"
 	"github.com/spf13/cobra"
 )
 
@@ -22,6 +24,7 @@ type PruneOptions struct {
 	Verbose          bool
 	Quiet            bool
 	Jobs             int
+	Orphans          bool // 清理不在清单中的项目目录
 	OuterManifest    bool
 	NoOuterManifest  bool
 	ThisManifestOnly bool
@@ -40,9 +43,21 @@ func PruneCmd() *cobra.Command {
 	opts := &PruneOptions{}
 
 	cmd := &cobra.Command{
-		Use:   "prune [<project>...]",
-		Short: "Prune (delete) already merged topics",
-		Long:  `Prune (delete) already merged topics.`,
+		Use:               "prune [<project>...]",
+		Short:             "Prune (delete) already merged topics",
+		ValidArgsFunction: completeProjects,
+		Long: `Prune (delete) already merged topics.
+
+Every local branch of every project is compared with the project's manifest
+revision. Branches whose commits have all been merged (including changes
+that were rebased or cherry-picked on submit) are deleted. Branches that
+were uploaded with repo upload but are not merged yet (recorded in
+refs/published/<branch>), and branches with commits that were never
+uploaded, are reported and kept. The local branch named after the manifest
+revision is never pruned.
+
+With --orphans, top-level git directories that are not part of the manifest
+are removed as well.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			return runPrune(opts, args)
 		},
@@ -54,6 +69,7 @@ func PruneCmd() *cobra.Command {
 	cmd.Flags().BoolVarP(&opts.Verbose, "verbose", "v", false, "show all output")
 	cmd.Flags().BoolVarP(&opts.Quiet, "quiet", "q", false, "only show errors")
 	cmd.Flags().IntVarP(&opts.Jobs, "jobs", "j", 8, "number of jobs to run in parallel")
+	cmd.Flags().BoolVar(&opts.Orphans, "orphans", false, "also delete git directories that are not in the manifest")
 	cmd.Flags().BoolVar(&opts.OuterManifest, "outer-manifest", false, "operate starting at the outermost manifest")
 	cmd.Flags().BoolVar(&opts.NoOuterManifest, "no-outer-manifest", false, "do not operate on outer manifests")
 	cmd.Flags().BoolVar(&opts.ThisManifestOnly, "this-manifest-only", false, "only operate on this (sub)manifest")
@@ -81,8 +97,6 @@ func runPrune(opts *PruneOptions, args []string) error {
 	}
 	defer RestoreWorkDir(originalDir, log)
 
-	log.Info("开始清理不在清单中的项目")
-
 	// 加载配置
 	log.Debug("正在加载配置...")
 	cfg, err := config.Load()
@@ -90,6 +104,9 @@ func runPrune(opts *PruneOptions, args []string) error {
 		log.Error("加载配置失败: %v", err)
 		return fmt.Errorf("failed to load config: %w", err)
 	}
+	if err := requireGitClient(cfg, "prune"); err != nil {
+		return err
+	}
 
 	// 加载清单
 	log.Debug("正在解析清单文件...")
@@ -124,11 +141,209 @@ func runPrune(opts *PruneOptions, args []string) error {
 		}
 	}
 
+	if err := pruneMergedBranches(opts, projects, log); err != nil {
+		return err
+	}
+	if !opts.Orphans {
+		return nil
+	}
+
+	// 清单中的所有项目都不属于孤立目录，即使未在命令行中指定
+	allProjects, err := manager.GetProjectsInGroups(nil)
+	if err != nil {
+		log.Error("获取所有项目失败: %v", err)
+		return fmt.Errorf("failed to get projects: %w", err)
+	}
+	return pruneOrphanProjects(opts, allProjects, log)
+}
+
+// branchPruneState 表示分支的合并状态
+type branchPruneState int
+
+const (
+	branchMerged    branchPruneState = iota // 已合入清单修订版本，可以删除
+	branchPublished                         // 已推送到远程但尚未合并
+	branchUnmerged                          // 包含未推送的提交
+)
+
+// branchPruneInfo 记录单个分支的检查结果
+type branchPruneInfo struct {
+	name     string
+	state    branchPruneState
+	commits  int    // 未合并的提交数
+	deleted  bool   // 是否已删除
+	skipped  string // 已合并但未删除的原因
+	current  bool   // 是否为当前分支
+	errorMsg string
+}
+
+// projectPruneResult 记录单个项目的清理结果
+type projectPruneResult struct {
+	project  *project.Project
+	branches []branchPruneInfo
+	err      error
+}
+
+// pruneMergedBranches 检查所有项目的本地分支，删除已合并的分支并按清单顺序输出报告
+func pruneMergedBranches(opts *PruneOptions, projects []*project.Project, log logger.Logger) error {
+	jobs := opts.Jobs
+	if jobs <= 0 {
+		jobs = 8
+	}
+
+	results := make([]*projectPruneResult, len(projects))
+	sem := make(chan struct{}, jobs)
+	var wg sync.WaitGroup
+	for i, p := range projects {
+		wg.Add(1)
+		go func(i int, p *project.Project) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			results[i] = pruneProjectBranches(opts, p, log)
+		}(i, p)
+	}
+	wg.Wait()
+
+	var errs []error
+	merged, pending, unmerged := 0, 0, 0
+	for _, r := range results {
+		if r.err != nil {
+			log.Error("项目 %s: %v", r.project.Name, r.err)
+			errs = append(errs, fmt.Errorf("project %s: %w", r.project.Name, r.err))
+			continue
+		}
+		if len(r.branches) == 0 {
+			continue
+		}
+
+		fmt.Printf("project %s/\n", projectRelPath(r.project))
+		for _, b := range r.branches {
+			switch b.state {
+			case branchMerged:
+				merged++
+				switch {
+				case b.errorMsg != "":
+					fmt.Printf("  merged     %s (delete failed: %s)\n", b.name, b.errorMsg)
+				case b.skipped != "":
+					fmt.Printf("  merged     %s (kept: %s)\n", b.name, b.skipped)
+				case opts.DryRun:
+					fmt.Printf("  merged     %s (would delete)\n", b.name)
+				default:
+					fmt.Printf("  merged     %s (deleted)\n", b.name)
+				}
+			case branchPublished:
+				pending++
+				fmt.Printf("  published  %s (%d commit(s) not merged yet)\n", b.name, b.commits)
+			case branchUnmerged:
+				unmerged++
+				fmt.Printf("  unmerged   %s (%d commit(s))\n", b.name, b.commits)
+			}
+		}
+	}
+
+	log.Info("分支清理完成: 已合并 %d 个, 已发布未合并 %d 个, 未合并 %d 个", merged, pending, unmerged)
+	if len(errs) > 0 {
+		return fmt.Errorf("encountered %d errors during pruning", len(errs))
+	}
+	return nil
+}
+
+// pruneProjectBranches 对单个项目的本地分支分类并删除已合并的分支
+func pruneProjectBranches(opts *PruneOptions, p *project.Project, log logger.Logger) *projectPruneResult {
+	result := &projectPruneResult{project: p}
+	if _, err := os.Stat(p.Worktree); err != nil {
+		return result
+	}
+
+	ref := p.RevisionRef()
+	if ref == "" {
+		return result
+	}
+	if _, err := p.GitRepo.RevParse(ref); err != nil {
+		result.err = fmt.Errorf("manifest revision %s not found, run repo sync first", ref)
+		return result
+	}
+
+	branches, err := p.GitRepo.LocalBranches()
+	if err != nil {
+		result.err = err
+		return result
+	}
+	currentBranch, _ := p.GitRepo.CurrentBranch()
+	// 与清单修订版本同名的本地分支只是跟踪分支，不是主题分支
+	trackingBranch := strings.TrimPrefix(p.Revision, "refs/heads/")
+
+	for _, branch := range branches {
+		if branch == trackingBranch {
+			continue
+		}
+		info := branchPruneInfo{name: branch, current: branch == currentBranch}
+
+		unmerged, err := p.GitRepo.UnmergedCommits(ref, branch)
+		if err != nil {
+			result.err = err
+			return result
+		}
+		if len(unmerged) > 0 {
+			info.commits = len(unmerged)
+			// 上传到 Gerrit 不产生远程跟踪分支，按上传时记录的 refs/published/<branch> 判断
+			info.state = branchUnmerged
+			if published, err := p.GitRepo.IsPublished(branch); err == nil && published {
+				info.state = branchPublished
+			}
+			result.branches = append(result.branches, info)
+			continue
+		}
+
+		info.state = branchMerged
+		if !opts.DryRun {
+			deleteMergedBranch(opts, p, ref, &info, log)
+		}
+		result.branches = append(result.branches, info)
+	}
+	return result
+}
+
+// deleteMergedBranch 删除已合并的分支；当前分支需先分离到清单修订版本
+func deleteMergedBranch(opts *PruneOptions, p *project.Project, ref string, info *branchPruneInfo, log logger.Logger) {
+	if info.current {
+		if !opts.Force {
+			clean, err := p.GitRepo.IsClean()
+			if err != nil {
+				info.errorMsg = err.Error()
+				return
+			}
+			if !clean {
+				info.skipped = "current branch has local changes, use --force"
+				return
+			}
+		}
+		if _, err := p.GitRepo.RunCommand("checkout", "--quiet", "--detach", ref); err != nil {
+			info.errorMsg = err.Error()
+			return
+		}
+		log.Debug("项目 %s: 已将HEAD分离到 %s", p.Name, ref)
+	}
+
+	if _, err := p.GitRepo.RunCommand("branch", "-D", info.name); err != nil {
+		info.errorMsg = err.Error()
+		return
+	}
+	info.deleted = true
+	log.Debug("项目 %s: 已删除分支 %s", p.Name, info.name)
+	if err := p.GitRepo.DeletePublished(context.Background(), info.name); err != nil {
+		log.Warn("项目 %s: 删除分支 %s 的上传记录失败: %v", p.Name, info.name, err)
+	}
+}
+
+// pruneOrphanProjects 删除repo根目录下不在清单中的git目录
+func pruneOrphanProjects(opts *PruneOptions, projects []*project.Project, log logger.Logger) error {
 	// 创建项目路径映射
 	log.Debug("创建项目路径映射...")
 	projectPaths := make(map[string]bool)
 	for _, p := range projects {
-		projectPaths[p.Path] = true
+		projectPaths[projectRelPath(p)] = true
 	}
 
 	// 获取工作目录中的所有目
@@ -171,14 +386,14 @@ func runPrune(opts *PruneOptions, args []string) error {
 		}
 	}
 
-	// 如果没有要删除的项目，直接返
+	// 如果没有要删除的项目，直接返回
 	if len(prunedProjects) == 0 {
-		log.Info("没有需要清理的项目")
+		log.Info("没有需要清理的孤立项目目录")
 		return nil
 	}
 
 	// 显示要删除的项目
-	log.Info("找到 %d 个需要清理的项目:", len(prunedProjects))
+	log.Info("找到 %d 个不在清单中的项目目录:", len(prunedProjects))
 	for _, name := range prunedProjects {
 		log.Info("  %s", name)
 	}
@@ -246,37 +461,13 @@ func runPrune(opts *PruneOptions, args []string) error {
 			// 删除项目目录
 			log.Debug("删除项目目录: %s", projectPath)
 
-			// 安全检查：确保要删除的目录在repo根目录下
-			repoRoot, repoErr := config.GetRepoRoot()
-			if repoErr != nil {
-				log.Error("无法获取repo根目录: %v", repoErr)
-				errChan <- fmt.Errorf("无法获取repo根目录: %w", repoErr)
-
-				// 更新统计信息
-				stats.mu.Lock()
-				stats.failed++
-				stats.mu.Unlock()
-				return
-			}
-
-			// 检查工作目录是否在repo根目录下
-			absProjectPath, absErr := filepath.Abs(projectPath)
-			if absErr != nil {
-				log.Error("无法获取项目目录绝对路径: %v", absErr)
-				errChan <- fmt.Errorf("无法获取项目目录绝对路径: %w", absErr)
-
-				// 更新统计信息
-				stats.mu.Lock()
-				stats.failed++
-				stats.mu.Unlock()
-				return
-			}
-
-			relPath, relErr := filepath.Rel(repoRoot, absProjectPath)
-			if relErr != nil || strings.HasPrefix(relPath, "..") {
-				// 目录不在repo根目录下，拒绝删除
-				log.Error("项目目录 %s 不在repo根目录下，拒绝删除", projectPath)
-				errChan <- fmt.Errorf("项目目录 %s 不在repo根目录下，拒绝删除", projectPath)
+			// 安全检查：确保要删除的目录在repo根目录下且不是关键目录
+			if err := repo_sync.IsSafeToDelete(projectPath, workDir); err != nil || projectPath == workDir {
+				if err == nil {
+					err = fmt.Errorf("拒绝删除repo根目录")
+				}
+				log.Error("拒绝删除 %s: %v", projectPath, err)
+				errChan <- fmt.Errorf("refusing to remove %s: %w", projectPath, err)
 
 				// 更新统计信息
 				stats.mu.Lock()
@@ -285,7 +476,8 @@ func runPrune(opts *PruneOptions, args []string) error {
 				return
 			}
 
-			if err := os.RemoveAll(projectPath); err != nil {
+			// 工作树模式下的项目通过 git worktree remove 删除，同时清除共享仓库中的记录
+			if err := removeProjectDir(projectPath, workDir); err != nil {
 				log.Error("删除项目 %s 失败: %v", name, err)
 				errChan <- fmt.Errorf("failed to remove project %s: %w", name, err)
 
@@ -329,3 +521,14 @@ func runPrune(opts *PruneOptions, args []string) error {
 
 	return nil
 }
+
+// removeProjectDir 删除项目目录，共享仓库的工作区通过 git worktree remove 删除
+func removeProjectDir(projectPath, repoRoot string) error {
+	common := git.CommonDir(projectPath)
+	for _, shared := range project.SharedGitDirs(repoRoot) {
+		if common != "" && common == shared {
+			return git.RemoveWorktree(context.Background(), shared, projectPath, true)
+		}
+	}
+	return os.RemoveAll(projectPath)
+}
@@ -0,0 +1,181 @@
+package commands
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/leopardxu/repo-go/internal/git"
+	"github.com/leopardxu/repo-go/internal/project"
+	"github.com/leopardxu/repo-go/internal/testutil"
+)
+
+// localBranchNames 返回 dir 中的本地分支
+func localBranchNames(t *testing.T, dir string) string {
+	return strings.Join(strings.Fields(testutil.Git(t, dir, "for-each-ref", "--format=%(refname:short)", "refs/heads/")), " ")
+}
+
+func TestPruneProjectBranches(t *testing.T) {
+	dir := testutil.NewRepo(t)
+	// merged: 上传后原样合入清单修订版本
+	testutil.Git(t, dir, "checkout", "--quiet", "-b", "merged", "--track", "origin/main")
+	testutil.Commit(t, dir, "merged.txt", "merged", "merged")
+	uploadCurrentBranch(t, dir)
+	// picked: 提交时被 cherry-pick 到上游，提交与分支上的不同
+	testutil.Git(t, dir, "checkout", "--quiet", "-b", "picked", "--track", "origin/main")
+	testutil.Commit(t, dir, "picked.txt", "picked", "picked")
+	testutil.Git(t, dir, "checkout", "--quiet", "--detach", "merged")
+	testutil.Commit(t, dir, "upstream.txt", "upstream", "upstream")
+	testutil.Git(t, dir, "cherry-pick", "picked")
+	testutil.Git(t, dir, "update-ref", "refs/remotes/origin/main", "HEAD")
+	// published: 已上传尚未合并
+	testutil.Git(t, dir, "checkout", "--quiet", "-b", "published", "--track", "origin/main")
+	testutil.Commit(t, dir, "published.txt", "published", "published")
+	uploadCurrentBranch(t, dir)
+	// unmerged: 从未上传
+	testutil.Git(t, dir, "checkout", "--quiet", "-b", "unmerged", "--track", "origin/main")
+	testutil.Commit(t, dir, "unmerged.txt", "unmerged", "unmerged")
+	// 已合并的当前分支需要先分离 HEAD 才能删除
+	testutil.Git(t, dir, "checkout", "--quiet", "merged")
+
+	want := map[string]branchPruneState{
+		"merged":    branchMerged,
+		"picked":    branchMerged,
+		"published": branchPublished,
+		"unmerged":  branchUnmerged,
+	}
+	check := func(res *projectPruneResult) {
+		t.Helper()
+		if res.err != nil {
+			t.Fatal(res.err)
+		}
+		if len(res.branches) != len(want) {
+			t.Errorf("branches = %+v, want %v", res.branches, want)
+		}
+		for _, b := range res.branches {
+			if state, ok := want[b.name]; !ok || b.state != state {
+				t.Errorf("branch %s state = %d, want %d", b.name, b.state, state)
+			}
+			if b.errorMsg != "" || b.skipped != "" {
+				t.Errorf("branch %s: error %q, skipped %q", b.name, b.errorMsg, b.skipped)
+			}
+		}
+	}
+
+	// 模拟运行只分类不删除
+	check(pruneProjectBranches(&PruneOptions{DryRun: true}, testutil.NewProject(dir, "p"), &recordLogger{}))
+	if got := localBranchNames(t, dir); got != "main merged picked published unmerged" {
+		t.Fatalf("--dry-run left branches %q", got)
+	}
+
+	check(pruneProjectBranches(&PruneOptions{}, testutil.NewProject(dir, "p"), &recordLogger{}))
+	// 已合并的分支用 git branch -D 删除，清单修订版本同名的 main 不清理
+	if got := localBranchNames(t, dir); got != "main published unmerged" {
+		t.Errorf("branches after prune = %q, want main published unmerged", got)
+	}
+	if got, want := testutil.Git(t, dir, "rev-parse", "HEAD"), testutil.Git(t, dir, "rev-parse", "origin/main"); got != want {
+		t.Errorf("HEAD = %s, want it detached at the manifest revision %s", got, want)
+	}
+	// 删除分支时一并删除上传记录
+	if refs := testutil.Git(t, dir, "for-each-ref", "--format=%(refname)", "refs/published/"); refs != "refs/published/published" {
+		t.Errorf("published refs after prune = %q", refs)
+	}
+}
+
+func TestPruneProjectBranchesDirtyCurrent(t *testing.T) {
+	dir := testutil.NewRepo(t)
+	testutil.Git(t, dir, "checkout", "--quiet", "-b", "done", "--track", "origin/main")
+	if err := os.WriteFile(filepath.Join(dir, "f"), []byte("dirty\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	res := pruneProjectBranches(&PruneOptions{}, testutil.NewProject(dir, "p"), &recordLogger{})
+	if res.err != nil || len(res.branches) != 1 || res.branches[0].skipped == "" || res.branches[0].deleted {
+		t.Fatalf("pruneProjectBranches() = %+v, %v, want the dirty current branch kept", res.branches, res.err)
+	}
+	res = pruneProjectBranches(&PruneOptions{Force: true}, testutil.NewProject(dir, "p"), &recordLogger{})
+	if res.err != nil || len(res.branches) != 1 || !res.branches[0].deleted {
+		t.Fatalf("pruneProjectBranches(--force) = %+v, %v, want the branch deleted", res.branches, res.err)
+	}
+	if got := localBranchNames(t, dir); got != "main" {
+		t.Errorf("branches after prune --force = %q", got)
+	}
+}
+
+func TestPruneOrphanProjects(t *testing.T) {
+	testutil.SetIdentity(t)
+	root := t.TempDir()
+	newProject := func(name string) string {
+		dir := filepath.Join(root, name)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		testutil.Git(t, dir, "init", "--quiet", "-b", "main")
+		testutil.Commit(t, dir, "f", name+"\n", name)
+		return dir
+	}
+	newProject("kept")
+	newProject("old")
+	dirty := newProject("dirty")
+	if err := os.WriteFile(filepath.Join(dirty, "f"), []byte("changed\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// 工作树模式的孤立项目是共享仓库的工作区
+	shared := project.SharedGitDir(root, "wt")
+	testutil.Git(t, root, "clone", "--quiet", "--bare", filepath.Join(root, "kept"), shared)
+	if err := git.AddWorktree(context.Background(), shared, filepath.Join(root, "wt"), "HEAD"); err != nil {
+		t.Fatal(err)
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(root); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(cwd)
+
+	projects := []*project.Project{{Name: "kept", Path: "kept"}}
+	exists := func(name string) bool {
+		_, err := os.Stat(filepath.Join(root, name))
+		return err == nil
+	}
+
+	if err := pruneOrphanProjects(&PruneOptions{DryRun: true}, projects, &recordLogger{}); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"kept", "old", "dirty", "wt"} {
+		if !exists(name) {
+			t.Errorf("--dry-run removed %s", name)
+		}
+	}
+
+	// 有本地修改的目录不加 --force 时保留
+	if err := pruneOrphanProjects(&PruneOptions{}, projects, &recordLogger{}); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]bool{"kept": true, "old": false, "dirty": true, "wt": false} {
+		if exists(name) != want {
+			t.Errorf("%s exists = %v, want %v", name, !want, want)
+		}
+	}
+	worktrees, err := git.ListWorktrees(context.Background(), shared)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, wt := range worktrees {
+		if !wt.Bare {
+			t.Errorf("worktree %s is still registered in %s", wt.Path, shared)
+		}
+	}
+
+	if err := pruneOrphanProjects(&PruneOptions{Force: true}, projects, &recordLogger{}); err != nil {
+		t.Fatal(err)
+	}
+	if exists("dirty") || !exists("kept") {
+		t.Error("--force did not remove only the dirty orphan")
+	}
+}
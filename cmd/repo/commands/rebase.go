@@ -1,11 +1,16 @@
 package commands
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
 
 	"github.com/leopardxu/repo-go/internal/config"
+	"github.com/leopardxu/repo-go/internal/git"
 	"github.com/leopardxu/repo-go/internal/logger"
 	"github.com/leopardxu/repo-go/internal/manifest"
 	"github.com/leopardxu/repo-go/internal/project"
@@ -34,12 +39,31 @@ type RebaseOptions struct {
 	Jobs             int
 }
 
-// rebaseStats 用于统计rebase命令的执行结果
-type rebaseStats struct {
-	mu      sync.Mutex
-	success int
-	failed  int
-	total   int
+// rebaseState 表示单个项目的rebase结果
+type rebaseState int
+
+const (
+	rebaseUpToDate rebaseState = iota // 已基于最新的基线，无需rebase
+	rebaseDone                        // rebase成功
+	rebaseConflict                    // 出现冲突，等待 --continue/--abort
+	rebaseFailed                      // 执行失败
+	rebaseNoBranch                    // 处于分离头指针状态，跳过
+	rebaseMissing                     // 工作区不存在
+	rebaseNotRun                      // 因 --fail-fast 未执行
+)
+
+// rebaseResult 记录单个项目的rebase结果
+type rebaseResult struct {
+	project      *project.Project
+	state        rebaseState
+	branch       string
+	base         string
+	commits      int      // 被rebase的本地提交数
+	stashed      bool     // 是否自动暂存了本地修改
+	stashKept    bool     // 自动暂存未能恢复，修改保留在stash中
+	conflicts    []string // 冲突文件
+	resumeAction string   // --continue/--abort/--skip 时执行的操作
+	err          error
 }
 
 // RebaseCmd 返回rebase命令
@@ -47,13 +71,23 @@ func RebaseCmd() *cobra.Command {
 	opts := &RebaseOptions{}
 
 	cmd := &cobra.Command{
-		Use:   "rebase {[<project>...] | -i <project>...}",
-		Short: "Rebase local branches on upstream branch",
+		Use:               "rebase {[<project>...] | -i <project>...}",
+		Short:             "Rebase local branches on upstream branch",
+		ValidArgsFunction: completeProjects,
 		Long: `'repo rebase' uses git rebase to move local changes in the current topic branch
 to the HEAD of the upstream history, useful when you have made commits in a
-topic branch but need to incorporate new upstream changes "underneath" them.`,
+topic branch but need to incorporate new upstream changes "underneath" them.
+
+The base of each topic branch is the branch it tracks (branch.<name>.merge),
+or the project's manifest revision when no tracking branch is configured.
+With --onto-manifest the branch is always rebased onto the revision recorded
+in the current manifest, even when it tracks a different branch.
+
+When a project stops with conflicts, resolve them and run
+'repo rebase --continue' (or --skip/--abort); it resumes every project that
+was left in the middle of a rebase.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runRebase(opts, args)
+			return runRebase(cmd.Context(), opts, args)
 		},
 	}
 
@@ -81,7 +115,7 @@ topic branch but need to incorporate new upstream changes "underneath" them.`,
 }
 
 // runRebase 执行rebase命令
-func runRebase(opts *RebaseOptions, args []string) error {
+func runRebase(ctx context.Context, opts *RebaseOptions, args []string) error {
 	// 初始化日志记录器
 	log := logger.NewDefaultLogger()
 	if opts.Verbose {
@@ -109,6 +143,9 @@ func runRebase(opts *RebaseOptions, args []string) error {
 		log.Error("加载配置失败: %v", err)
 		return fmt.Errorf("failed to load config: %w", err)
 	}
+	if err := requireGitClient(cfg, "rebase"); err != nil {
+		return err
+	}
 
 	// 加载清单
 	log.Debug("正在解析清单文件...")
@@ -158,182 +195,351 @@ func runRebase(opts *RebaseOptions, args []string) error {
 		}
 	}
 
+	// 继续、跳过或中止未完成的rebase
+	if opts.Abort || opts.Continue || opts.Skip {
+		return runRebaseResume(ctx, opts, projects, log)
+	}
+
+	if opts.Onto != "" && opts.OntoManifest {
+		return fmt.Errorf("--onto and --onto-manifest are mutually exclusive")
+	}
+	if opts.Interactive && len(projects) != 1 {
+		return fmt.Errorf("interactive rebase requires exactly one project")
+	}
+
 	log.Info("找到 %d 个项目需要执行rebase操作", len(projects))
 
-	// 构建rebase命令选项
-	log.Debug("构建rebase命令选项...")
-	rebaseArgs := []string{"rebase"}
-
-	if opts.Abort {
-		rebaseArgs = append(rebaseArgs, "--abort")
-		log.Debug("添加--abort选项")
-	} else if opts.Continue {
-		rebaseArgs = append(rebaseArgs, "--continue")
-		log.Debug("添加--continue选项")
-	} else if opts.Skip {
-		rebaseArgs = append(rebaseArgs, "--skip")
-		log.Debug("添加--skip选项")
-	} else {
-		if opts.Interactive {
-			rebaseArgs = append(rebaseArgs, "--interactive")
-			log.Debug("添加--interactive选项")
-		}
+	maxWorkers := opts.Jobs
+	if maxWorkers <= 0 {
+		maxWorkers = 8
+	}
+	if opts.Interactive {
+		maxWorkers = 1
+	}
+	log.Debug("设置并发数为: %d", maxWorkers)
 
-		if opts.Autosquash {
-			rebaseArgs = append(rebaseArgs, "--autosquash")
-			log.Debug("添加--autosquash选项")
-		}
+	// 并发执行rebase操作，结果按清单顺序保存
+	results := make([]*rebaseResult, len(projects))
+	sem := make(chan struct{}, maxWorkers)
+	var wg sync.WaitGroup
+	var stop atomic.Bool
 
-		if opts.Onto != "" {
-			rebaseArgs = append(rebaseArgs, "--onto", opts.Onto)
-			log.Debug("添加--onto %s选项", opts.Onto)
-		}
+	for i, p := range projects {
+		wg.Add(1)
+		go func(i int, p *project.Project) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
 
-		if opts.Force {
-			rebaseArgs = append(rebaseArgs, "--force")
-			log.Debug("添加--force选项")
-		}
+			if stop.Load() {
+				results[i] = &rebaseResult{project: p, state: rebaseNotRun}
+				return
+			}
+			res := rebaseProject(ctx, opts, p, log)
+			if opts.FailFast && (res.state == rebaseFailed || res.state == rebaseConflict) {
+				stop.Store(true)
+			}
+			results[i] = res
+		}(i, p)
+	}
+	wg.Wait()
 
-		if opts.NoFF {
-			rebaseArgs = append(rebaseArgs, "--no-ff")
-			log.Debug("添加--no-ff选项")
-		}
+	return reportRebaseResults(opts, results, log)
+}
 
-		if opts.Whitespace != "" {
-			rebaseArgs = append(rebaseArgs, "--whitespace", opts.Whitespace)
-			log.Debug("添加--whitespace %s选项", opts.Whitespace)
+// rebaseBase 确定分支rebase的基线
+// 优先使用 --onto，其次是分支跟踪的上游，最后是清单中记录的修订版本
+func rebaseBase(opts *RebaseOptions, p *project.Project, branch string) (string, error) {
+	if opts.Onto != "" {
+		return opts.Onto, nil
+	}
+	if !opts.OntoManifest {
+		tracking, err := p.GitRepo.TrackingBranch(branch)
+		if err != nil {
+			return "", err
 		}
+		if tracking != "" {
+			return tracking, nil
+		}
+	}
+	ref := p.RevisionRef()
+	if ref == "" {
+		return "", fmt.Errorf("no manifest revision for project %s", p.Name)
+	}
+	return ref, nil
+}
+
+// rebaseProject 将项目当前主题分支rebase到其基线上
+func rebaseProject(ctx context.Context, opts *RebaseOptions, p *project.Project, log logger.Logger) *rebaseResult {
+	res := &rebaseResult{project: p}
+	if _, err := os.Stat(p.Worktree); err != nil {
+		res.state = rebaseMissing
+		return res
+	}
+	repo := p.GitRepo
+
+	if repo.RebaseInProgress() {
+		res.state = rebaseFailed
+		res.err = fmt.Errorf("a rebase is already in progress, use --continue, --skip or --abort")
+		return res
+	}
+
+	branch, err := repo.CurrentBranch()
+	if err != nil {
+		res.state = rebaseFailed
+		res.err = err
+		return res
+	}
+	if strings.HasPrefix(branch, "HEAD detached") {
+		log.Debug("项目 %s 不在任何分支上，跳过", p.Name)
+		res.state = rebaseNoBranch
+		return res
+	}
+	res.branch = branch
+
+	base, err := rebaseBase(opts, p, branch)
+	if err != nil {
+		res.state = rebaseFailed
+		res.err = err
+		return res
+	}
+	res.base = base
 
-		if opts.AutoStash {
-			rebaseArgs = append(rebaseArgs, "--autostash")
-			log.Debug("添加--autostash选项")
+	ahead, behind, err := repo.AheadBehind(base, branch)
+	if err != nil {
+		res.state = rebaseFailed
+		res.err = fmt.Errorf("cannot compare %s with %s, run repo sync first: %w", branch, base, err)
+		return res
+	}
+	res.commits = ahead
+	if behind == 0 && !opts.Force && !opts.NoFF && !opts.Interactive {
+		res.state = rebaseUpToDate
+		return res
+	}
+
+	clean, err := repo.IsClean()
+	if err != nil {
+		res.state = rebaseFailed
+		res.err = err
+		return res
+	}
+	if !clean {
+		if !opts.AutoStash {
+			res.state = rebaseFailed
+			res.err = fmt.Errorf("uncommitted changes in working tree, commit them or use --auto-stash")
+			return res
 		}
+		res.stashed = true
+	}
+	stashesBefore := countStashes(p)
 
-		// 定义上游分支
-		upstream := "origin" // 默认值，根据需要调
-		// 或者根据项目配置动态确
-		// upstream := project.upstream
+	args := rebaseArgs(opts, base)
+	log.Debug("正在对项目 %s 执行: git %s", p.Name, strings.Join(args, " "))
+	if opts.Interactive {
+		err = git.Run(git.InteractiveCommand(ctx, p.Worktree, args...))
+	} else {
+		_, err = repo.Runner.RunInDirContext(ctx, p.Worktree, args...)
+	}
+	repo.ClearCache()
 
-		log.Info("将rebase%s", upstream)
+	if err != nil {
+		return rebaseFailure(res, err)
 	}
+	res.state = rebaseDone
+	// git 在自动暂存无法干净恢复时会把修改保留在stash中
+	res.stashKept = res.stashed && countStashes(p) > stashesBefore
+	return res
+}
 
-	// 创建统计对象
-	stats := &rebaseStats{total: len(projects)}
+// rebaseArgs 构建 git rebase 参数
+func rebaseArgs(opts *RebaseOptions, base string) []string {
+	args := []string{"rebase"}
+	if opts.Interactive {
+		args = append(args, "--interactive")
+	}
+	if opts.Autosquash {
+		args = append(args, "--autosquash")
+	}
+	if opts.Force {
+		args = append(args, "--force-rebase")
+	}
+	if opts.NoFF {
+		args = append(args, "--no-ff")
+	}
+	if opts.Whitespace != "" {
+		args = append(args, "--whitespace="+opts.Whitespace)
+	}
+	if opts.AutoStash {
+		args = append(args, "--autostash")
+	}
+	return append(args, base)
+}
 
-	// 并发执行rebase操作
-	log.Debug("开始并发执行rebase操作...")
-	type rebaseResult struct {
-		Project *project.Project
-		Output  string
-		Err     error
+// rebaseFailure 根据仓库状态区分冲突和普通失败
+func rebaseFailure(res *rebaseResult, err error) *rebaseResult {
+	if res.project.GitRepo.RebaseInProgress() {
+		res.state = rebaseConflict
+		res.conflicts, _ = res.project.GitRepo.ConflictedFiles()
+		return res
 	}
+	res.state = rebaseFailed
+	res.err = err
+	return res
+}
 
-	// 设置并发控制
-	maxWorkers := opts.Jobs
-	if maxWorkers <= 0 {
-		maxWorkers = 8
+// countStashes 返回stash条目数
+func countStashes(p *project.Project) int {
+	output, err := p.GitRepo.Runner.RunInDir(p.Worktree, "stash", "list")
+	if err != nil {
+		return 0
 	}
-	log.Debug("设置并发数为: %d", maxWorkers)
+	count := 0
+	for _, line := range strings.Split(string(output), "\n") {
+		if strings.TrimSpace(line) != "" {
+			count++
+		}
+	}
+	return count
+}
 
-	results := make(chan rebaseResult, len(projects))
-	sem := make(chan struct{}, maxWorkers)
-	var wg sync.WaitGroup
+// runRebaseResume 对所有处于rebase中的项目执行 --continue、--skip 或 --abort
+func runRebaseResume(ctx context.Context, opts *RebaseOptions, projects []*project.Project, log logger.Logger) error {
+	action := "--continue"
+	switch {
+	case opts.Abort:
+		action = "--abort"
+	case opts.Skip:
+		action = "--skip"
+	}
 
+	var pending []*project.Project
 	for _, p := range projects {
-		wg.Add(1)
-		p := p
-		go func() {
-			defer wg.Done()
-			sem <- struct{}{}
-			defer func() { <-sem }()
-
-			log.Debug("正在对项%s 执行rebase操作...", p.Name)
-			outputBytes, err := p.GitRepo.RunCommand(rebaseArgs...)
-			output := string(outputBytes)
-
-			if err != nil {
-				// 显示详细的错误信息
-				log.Error("项目 %s rebase失败: %v", p.Name, err)
-				if output != "" {
-					log.Error("Git 输出:\n%s", output)
-				}
-
-				// 更新统计信息
-				stats.mu.Lock()
-				stats.failed++
-				stats.mu.Unlock()
-			} else {
-				if !opts.Quiet {
-					log.Info("项目 %s rebase成功", p.Name)
-				}
-
-				// 更新统计信息
-				stats.mu.Lock()
-				stats.success++
-				stats.mu.Unlock()
+		if _, err := os.Stat(p.Worktree); err != nil {
+			continue
+		}
+		if p.GitRepo.RebaseInProgress() {
+			pending = append(pending, p)
+		}
+	}
+	if len(pending) == 0 {
+		log.Info("没有正在进行的rebase")
+		return nil
+	}
+	log.Info("对 %d 个项目执行 rebase %s", len(pending), action)
+
+	// 按顺序处理，--continue 可能需要为每个项目提交冲突解决结果
+	results := make([]*rebaseResult, len(pending))
+	for i, p := range pending {
+		res := &rebaseResult{project: p, resumeAction: action}
+		res.branch = rebaseHeadName(p)
+		// 冲突解决后 git 会要求编辑提交信息，这里沿用原有信息
+		_, err := p.GitRepo.Runner.RunInDirContext(ctx, p.Worktree, "-c", "core.editor=true", "rebase", action)
+		p.GitRepo.ClearCache()
+		if err != nil {
+			rebaseFailure(res, err)
+		} else {
+			res.state = rebaseDone
+		}
+		results[i] = res
+		if opts.FailFast && res.state != rebaseDone {
+			for j := i + 1; j < len(pending); j++ {
+				results[j] = &rebaseResult{project: pending[j], state: rebaseNotRun}
 			}
+			break
+		}
+	}
 
-			results <- rebaseResult{
-				Project: p,
-				Output:  output,
-				Err:     err,
-			}
-		}()
+	return reportRebaseResults(opts, results, log)
+}
+
+// rebaseHeadName 返回正在rebase的分支名
+func rebaseHeadName(p *project.Project) string {
+	for _, name := range []string{"rebase-merge/head-name", "rebase-apply/head-name"} {
+		output, err := p.GitRepo.Runner.RunInDir(p.Worktree, "rev-parse", "--git-path", name)
+		if err != nil {
+			continue
+		}
+		path := strings.TrimSpace(string(output))
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(p.Worktree, path)
+		}
+		if data, err := os.ReadFile(path); err == nil {
+			return strings.TrimPrefix(strings.TrimSpace(string(data)), "refs/heads/")
+		}
 	}
+	return ""
+}
 
-	// 等待所有goroutine完成
-	log.Debug("等待所有rebase任务完成...")
-	wg.Wait()
-	close(results)
-
-	// 处理结果
-	log.Debug("处理rebase结果...")
-	var hasError bool
-	var errs []error
-	var failedProjects []string
-
-	// 收集所有结
-	for res := range results {
-		if res.Err != nil {
-			hasError = true
-			failedProjects = append(failedProjects, res.Project.Name)
-			errs = append(errs, fmt.Errorf("项目 %s: %w", res.Project.Name, res.Err))
-
-			if opts.Verbose || !opts.Quiet {
-				log.Error("项目 %s rebase失败", res.Project.Name)
-				if res.Output != "" {
-					log.Error("Git 输出:\n%s", res.Output)
-				}
+// reportRebaseResults 按清单顺序输出每个项目的结果并汇总
+func reportRebaseResults(opts *RebaseOptions, results []*rebaseResult, log logger.Logger) error {
+	var upToDate, rebased, conflicts, failed int
+	var needResolve []string
+
+	for _, res := range results {
+		var lines []string
+		switch res.state {
+		case rebaseUpToDate:
+			upToDate++
+			if !opts.Quiet {
+				lines = append(lines, fmt.Sprintf("up to date with %s", res.base))
 			}
-
-			if opts.FailFast {
-				log.Error("由于设置了fail-fast选项，在首次错误后停止")
-				return fmt.Errorf("failed to rebase project %s: %w", res.Project.Name, res.Err)
+		case rebaseDone:
+			rebased++
+			var line string
+			switch res.resumeAction {
+			case "":
+				line = fmt.Sprintf("rebased %d commit(s) onto %s", res.commits, res.base)
+			case "--abort":
+				line = "rebase aborted"
+			default:
+				line = "rebase completed"
 			}
+			if res.stashKept {
+				line += " (auto-stash could not be applied cleanly, changes kept in 'git stash list')"
+			} else if res.stashed {
+				line += " (auto-stash restored)"
+			}
+			lines = append(lines, line)
+		case rebaseConflict:
+			conflicts++
+			needResolve = append(needResolve, projectRelPath(res.project))
+			lines = append(lines, "conflict, rebase stopped")
+			for _, file := range res.conflicts {
+				lines = append(lines, "  CONFLICT "+file)
+			}
+		case rebaseFailed:
+			failed++
+			lines = append(lines, fmt.Sprintf("error: %v", res.err))
+		case rebaseMissing:
+			lines = append(lines, `missing (run "repo sync")`)
+		case rebaseNotRun:
+			lines = append(lines, "not attempted (--fail-fast)")
+		}
+		if len(lines) == 0 {
 			continue
 		}
 
-		if !opts.Quiet {
-			log.Info("\n项目 %s:", res.Project.Name)
-			if res.Output != "" {
-				log.Info(res.Output)
-			} else {
-				log.Info("Rebase完成")
-			}
+		header := fmt.Sprintf("project %s/", projectRelPath(res.project))
+		if res.branch != "" {
+			header += fmt.Sprintf(" (branch %s)", res.branch)
+		}
+		fmt.Println(header)
+		for _, line := range lines {
+			fmt.Printf("  %s\n", line)
 		}
 	}
 
-	// 输出统计信息
-	log.Info("Rebase操作完成: 总计 %d 个项目, 成功 %d 个, 失败 %d 个",
-		stats.total, stats.success, stats.failed)
+	log.Info("Rebase操作完成: 总计 %d 个项目, 已是最新 %d 个, 成功 %d 个, 冲突 %d 个, 失败 %d 个",
+		len(results), upToDate, rebased, conflicts, failed)
 
-	if hasError {
-		log.Error("以下项目 rebase 失败:")
-		for _, name := range failedProjects {
-			log.Error("  - %s", name)
+	if conflicts > 0 {
+		log.Error("以下项目存在冲突，请解决后使用 'repo rebase --continue' 继续，或使用 'repo rebase --abort' 放弃:")
+		for _, path := range needResolve {
+			log.Error("  - %s", path)
 		}
-		log.Error("\n请检查错误信息并手动解决冲突，然后使用 'repo rebase --continue' 继续")
-		return fmt.Errorf("some projects failed to rebase")
+	}
+	if conflicts > 0 || failed > 0 {
+		return fmt.Errorf("rebase failed in %d project(s)", conflicts+failed)
 	}
 	return nil
 }
@@ -0,0 +1,130 @@
+package commands
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/leopardxu/repo-go/internal/logger"
+	"github.com/leopardxu/repo-go/internal/project"
+	"github.com/leopardxu/repo-go/internal/testutil"
+)
+
+// newRebaseFixture 创建一个仓库：远程跟踪分支 origin/main 和 origin/stable，
+// 以及从 main 创建、修改了同一文件的主题分支 topic
+func newRebaseFixture(t *testing.T) *project.Project {
+	t.Helper()
+	dir := testutil.NewRepo(t)
+	testutil.Git(t, dir, "update-ref", "refs/remotes/origin/stable", "HEAD")
+
+	testutil.Git(t, dir, "checkout", "--quiet", "-b", "topic")
+	testutil.Commit(t, dir, "f", "topic\n", "topic")
+
+	// 上游修改了同一文件，rebase 会产生冲突
+	testutil.Git(t, dir, "checkout", "--quiet", "main")
+	testutil.Commit(t, dir, "f", "upstream\n", "upstream")
+	testutil.Git(t, dir, "update-ref", "refs/remotes/origin/main", "HEAD")
+	testutil.Git(t, dir, "checkout", "--quiet", "topic")
+
+	return testutil.NewProject(dir, "p")
+}
+
+func TestRebaseBase(t *testing.T) {
+	p := newRebaseFixture(t)
+
+	tests := []struct {
+		name     string
+		opts     RebaseOptions
+		tracking string
+		revision string
+		want     string
+		wantErr  bool
+	}{
+		{"onto wins", RebaseOptions{Onto: "HEAD~1"}, "stable", "main", "HEAD~1", false},
+		{"tracking branch", RebaseOptions{}, "stable", "main", "refs/remotes/origin/stable", false},
+		{"onto manifest ignores tracking", RebaseOptions{OntoManifest: true}, "stable", "main", "refs/remotes/origin/main", false},
+		{"manifest without tracking", RebaseOptions{}, "", "refs/heads/main", "refs/remotes/origin/main", false},
+		{"pinned manifest revision", RebaseOptions{}, "", "refs/tags/v1.0", "refs/tags/v1.0", false},
+		{"no base", RebaseOptions{}, "", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.tracking != "" {
+				testutil.Git(t, p.Worktree, "branch", "--quiet", "--set-upstream-to=origin/"+tt.tracking, "topic")
+			} else {
+				// 上一个用例可能没有设置跟踪分支，忽略 --unset-upstream 的失败
+				exec.Command("git", "-C", p.Worktree, "branch", "--unset-upstream", "topic").Run()
+			}
+			p.Revision = tt.revision
+
+			got, err := rebaseBase(&tt.opts, p, "topic")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("rebaseBase() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("rebaseBase() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunRebaseResume(t *testing.T) {
+	log := logger.NewDefaultLogger()
+	log.SetLevel(logger.LogLevelError)
+	ctx := context.Background()
+
+	// 开始一次会冲突的 rebase
+	startRebase := func(t *testing.T) *project.Project {
+		p := newRebaseFixture(t)
+		res := rebaseProject(ctx, &RebaseOptions{OntoManifest: true}, p, log)
+		if res.state != rebaseConflict || len(res.conflicts) != 1 || res.conflicts[0] != "f" {
+			t.Fatalf("rebaseProject() = %+v, want a conflict in f", res)
+		}
+		return p
+	}
+
+	t.Run("abort", func(t *testing.T) {
+		p := startRebase(t)
+		topic := testutil.Git(t, p.Worktree, "rev-parse", "refs/heads/topic")
+
+		if err := runRebaseResume(ctx, &RebaseOptions{Abort: true}, []*project.Project{p}, log); err != nil {
+			t.Fatal(err)
+		}
+		if p.GitRepo.RebaseInProgress() {
+			t.Error("rebase still in progress after --abort")
+		}
+		if got := testutil.Git(t, p.Worktree, "rev-parse", "HEAD"); got != topic {
+			t.Errorf("HEAD = %s after --abort, want %s", got, topic)
+		}
+	})
+
+	t.Run("continue", func(t *testing.T) {
+		p := startRebase(t)
+		if err := os.WriteFile(filepath.Join(p.Worktree, "f"), []byte("resolved\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		testutil.Git(t, p.Worktree, "add", "f")
+
+		if err := runRebaseResume(ctx, &RebaseOptions{Continue: true}, []*project.Project{p}, log); err != nil {
+			t.Fatal(err)
+		}
+		if p.GitRepo.RebaseInProgress() {
+			t.Error("rebase still in progress after --continue")
+		}
+		if got, want := testutil.Git(t, p.Worktree, "rev-parse", "topic~1"), testutil.Git(t, p.Worktree, "rev-parse", "origin/main"); got != want {
+			t.Errorf("topic~1 = %s, want origin/main %s", got, want)
+		}
+		if got := testutil.Git(t, p.Worktree, "symbolic-ref", "--short", "HEAD"); got != "topic" {
+			t.Errorf("HEAD is on %q after --continue, want topic", got)
+		}
+	})
+
+	t.Run("nothing in progress", func(t *testing.T) {
+		p := newRebaseFixture(t)
+		if err := runRebaseResume(ctx, &RebaseOptions{Continue: true}, []*project.Project{p}, log); err != nil {
+			t.Errorf("runRebaseResume() with no rebase in progress = %v", err)
+		}
+	})
+}
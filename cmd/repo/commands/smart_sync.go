@@ -18,9 +18,10 @@ func SmartSyncCmd() *cobra.Command {
 	}
 
 	cmd := &cobra.Command{
-		Use:   "smartsync [<project>...]",
-		Short: "Update working tree to the latest known good revision",
-		Long:  `The 'repo smartsync' command is a shortcut for sync -s.`,
+		Use:               "smartsync [<project>...]",
+		Short:             "Update working tree to the latest known good revision",
+		ValidArgsFunction: completeProjects,
+		Long:              `The 'repo smartsync' command is a shortcut for sync -s.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			// 创建日志记录器
 			log := logger.NewDefaultLogger()
@@ -38,7 +39,7 @@ func SmartSyncCmd() *cobra.Command {
 			opts.SmartSync = true
 
 			// 调用 sync 命令的执行逻辑
-			return runSync(opts, args, log)
+			return runSync(cmd.Context(), opts, args, log)
 		},
 	}
 
@@ -50,6 +51,7 @@ func SmartSyncCmd() *cobra.Command {
 	cmd.Flags().BoolVar(&opts.NoCurrentBranch, "no-current-branch", false, "fetch all branches from server")
 	cmd.Flags().BoolVarP(&opts.Detach, "detach", "d", false, "detach projects back to manifest revision")
 	cmd.Flags().BoolVarP(&opts.ForceSync, "force-sync", "f", false, "overwrite local changes")
+	cmd.Flags().BoolVar(&opts.NoRebase, "no-rebase", false, "do not rebase local commits onto the new manifest revision")
 	cmd.Flags().BoolVar(&opts.ForceRemoveDirty, "force-remove-dirty", false, "force remove projects with uncommitted modifications")
 	cmd.Flags().BoolVar(&opts.ForceOverwrite, "force-overwrite", false, "force cleanup local uncommitted changes")
 	cmd.Flags().BoolVar(&opts.ForceBroken, "force-broken", false, "continue syncing other projects if a project sync fails")
@@ -1,16 +1,24 @@
 package commands
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"os"
+	"path"
+	"path/filepath"
 	"runtime"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 
 	"github.com/leopardxu/repo-go/internal/config"
+	"github.com/leopardxu/repo-go/internal/git"
 	"github.com/leopardxu/repo-go/internal/logger"
 	"github.com/leopardxu/repo-go/internal/manifest"
 	"github.com/leopardxu/repo-go/internal/project"
+	"github.com/leopardxu/repo-go/internal/ui"
 	"github.com/spf13/cobra"
 )
 
@@ -58,9 +66,19 @@ func StageCmd() *cobra.Command {
 	}
 
 	cmd := &cobra.Command{
-		Use:   "stage [<project>...] [<file>...]",
-		Short: "Stage file contents to the index",
-		Long:  `Stage file contents to the index (equivalent to 'git add').`,
+		Use:               "stage [<project>...] [<file>...]",
+		Short:             "Stage file contents to the index",
+		ValidArgsFunction: completeProjects,
+		Long: `Stage file contents to the index (equivalent to 'git add').
+
+Files may be given relative to the client root and may contain glob
+patterns; each path is staged in the project that owns it. If the first
+argument names a project, the remaining files are relative to that project.
+
+With -i, a numbered menu of the projects with a dirty working tree is shown.
+Select a project by number, name or path (glob patterns are accepted) and
+'git add --interactive' is run in it; the menu is shown again afterwards.
+Project arguments given together with -i restrict the menu.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			// 创建日志记录器
 			log := logger.NewDefaultLogger()
@@ -80,9 +98,12 @@ func StageCmd() *cobra.Command {
 				log.Error("加载配置失败: %v", err)
 				return fmt.Errorf("failed to load config: %w", err)
 			}
+			if err := requireGitClient(cfg, "stage"); err != nil {
+				return err
+			}
 			opts.Config = cfg
 
-			return runStage(opts, args, log)
+			return runStage(cmd.Context(), opts, args, log)
 		},
 	}
 
@@ -102,7 +123,7 @@ func StageCmd() *cobra.Command {
 }
 
 // runStage 执行stage命令
-func runStage(opts *StageOptions, args []string, log logger.Logger) error {
+func runStage(ctx context.Context, opts *StageOptions, args []string, log logger.Logger) error {
 	// 确保在repo根目录下执行
 	originalDir, err := EnsureRepoRoot(log)
 	if err != nil {
@@ -114,6 +135,10 @@ func runStage(opts *StageOptions, args []string, log logger.Logger) error {
 	// 创建统计对象
 	stats := &stageStats{}
 
+	if opts.Interactive {
+		return runStageInteractive(ctx, opts, args, log)
+	}
+
 	if len(args) == 0 && !opts.All {
 		log.Error("未指定文件且未使用--all选项")
 		return fmt.Errorf("no files specified and --all not used")
@@ -135,49 +160,37 @@ func runStage(opts *StageOptions, args []string, log logger.Logger) error {
 	log.Debug("正在初始化项目管理器...")
 	manager := project.NewManagerFromManifest(manifest, opts.Config)
 
-	// 确定文件和项目列表
-	var files []string
-	var projectNames []string
-
-	// 解析参数，区分项目名和文件名
-	log.Debug("解析命令行参..")
+	// 解析参数：第一个参数是项目时其余文件相对于该项目，否则按repo根目录路径分配到所属项目
+	log.Debug("解析命令行参数...")
+	var targets []stageTarget
 	if len(args) > 0 {
-		// 检查第一个参数是否是项目
-		projects, err := manager.GetProjectsByNames([]string{args[0]})
-		if err == nil && len(projects) > 0 {
-			// 第一个参数是项目
-			projectNames = []string{args[0]}
-			if len(args) > 1 {
-				files = args[1:]
+		if named, err := manager.GetProjectsByNames([]string{args[0]}); err == nil && len(named) > 0 {
+			log.Debug("指定项目: %s, 文件数量: %d", args[0], len(args)-1)
+			for _, p := range named {
+				targets = append(targets, stageTarget{project: p, files: args[1:]})
 			}
-			log.Debug("指定项目: %s, 文件数量: %d", args[0], len(files))
 		} else {
-			// 所有参数都是文件名
-			files = args
-			log.Debug("未指定项目，文件数量: %d", len(files))
+			allProjects, err := manager.GetProjectsInGroups(nil)
+			if err != nil {
+				log.Error("获取项目失败: %v", err)
+				return fmt.Errorf("failed to get projects: %w", err)
+			}
+			if targets, err = routeStagePaths(allProjects, args); err != nil {
+				return err
+			}
+			log.Debug("文件分布在 %d 个项目中", len(targets))
 		}
-	}
-
-	// 获取要处理的项目
-	var projects []*project.Project
-	if len(projectNames) == 0 {
-		// 如果没有指定项目，则处理所有项
-		log.Debug("获取所有项..")
-		projects, err = manager.GetProjectsInGroups(nil)
+	} else {
+		// 仅使用--all时处理所有项目
+		projects, err := manager.GetProjectsInGroups(nil)
 		if err != nil {
 			log.Error("获取项目失败: %v", err)
 			return fmt.Errorf("failed to get projects: %w", err)
 		}
-		log.Debug("共获取到 %d 个项目", len(projects))
-	} else {
-		// 否则，只处理指定的项目
-		log.Debug("根据名称获取项目: %v", projectNames)
-		projects, err = manager.GetProjectsByNames(projectNames)
-		if err != nil {
-			log.Error("根据名称获取项目失败: %v", err)
-			return fmt.Errorf("failed to get projects: %w", err)
+		for _, p := range projects {
+			targets = append(targets, stageTarget{project: p})
 		}
-		log.Debug("共获取到 %d 个项目", len(projects))
+		log.Debug("共获取到 %d 个项目", len(targets))
 	}
 
 	// 构建stage命令选项（实际上是git add命令）
@@ -188,10 +201,6 @@ func runStage(opts *StageOptions, args []string, log logger.Logger) error {
 		stageArgs = append(stageArgs, "--all")
 	}
 
-	if opts.Interactive {
-		stageArgs = append(stageArgs, "--interactive")
-	}
-
 	if opts.Patch {
 		stageArgs = append(stageArgs, "--patch")
 	}
@@ -208,21 +217,20 @@ func runStage(opts *StageOptions, args []string, log logger.Logger) error {
 		stageArgs = append(stageArgs, "--verbose")
 	}
 
-	// 添加文件参数
-	if len(files) > 0 {
-		stageArgs = append(stageArgs, files...)
-	}
-
 	// 使用goroutine池并发执行stage
 	log.Info("开始暂存文件，并行任务 %d...", opts.Jobs)
 
 	var wg sync.WaitGroup
-	errChan := make(chan error, len(projects))
-	resultChan := make(chan string, len(projects))
+	errChan := make(chan error, len(targets))
+	resultChan := make(chan string, len(targets))
 	sem := make(chan struct{}, opts.Jobs) // 使用信号量控制并发数
 
-	for _, p := range projects {
-		p := p // 创建副本避免闭包问题
+	for _, t := range targets {
+		p := t.project
+		projectArgs := stageArgs
+		if len(t.files) > 0 {
+			projectArgs = append(append(append([]string{}, stageArgs...), "--"), t.files...)
+		}
 		wg.Add(1)
 
 		go func() {
@@ -231,7 +239,7 @@ func runStage(opts *StageOptions, args []string, log logger.Logger) error {
 			defer func() { <-sem }() // 释放信号
 
 			log.Debug("在项%s 中执行git add命令...", p.Name)
-			outputBytes, err := p.GitRepo.RunCommand(stageArgs...)
+			outputBytes, err := p.GitRepo.RunCommandContext(ctx, projectArgs...)
 			if err != nil {
 				log.Error("项目 %s 暂存失败: %v", p.Name, err)
 				errChan <- fmt.Errorf("project %s: %w", p.Name, err)
@@ -279,3 +287,176 @@ func runStage(opts *StageOptions, args []string, log logger.Logger) error {
 
 	return nil
 }
+
+// stageTarget 表示需要在某个项目中暂存的文件，files为空时由git add选项决定范围
+type stageTarget struct {
+	project *project.Project
+	files   []string
+}
+
+// routeStagePaths 展开相对于repo根目录的路径和通配符，并按所属项目分组
+// 路径归属于路径前缀最长的项目，结果按清单顺序排列
+func routeStagePaths(projects []*project.Project, args []string) ([]stageTarget, error) {
+	index := make(map[*project.Project]int)
+	var targets []stageTarget
+
+	for _, arg := range args {
+		paths, err := filepath.Glob(arg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", arg, err)
+		}
+		// 没有匹配时原样传给git（例如已删除的文件）
+		if len(paths) == 0 {
+			paths = []string{arg}
+		}
+
+		for _, file := range paths {
+			file = filepath.ToSlash(filepath.Clean(file))
+			owner, rel := owningProject(projects, file)
+			if owner == nil {
+				return nil, fmt.Errorf("%s is not in any project", file)
+			}
+			i, ok := index[owner]
+			if !ok {
+				i = len(targets)
+				index[owner] = i
+				targets = append(targets, stageTarget{project: owner})
+			}
+			targets[i].files = append(targets[i].files, rel)
+		}
+	}
+
+	// 按清单顺序输出
+	order := make(map[*project.Project]int)
+	for i, p := range projects {
+		order[p] = i
+	}
+	sort.SliceStable(targets, func(i, j int) bool { return order[targets[i].project] < order[targets[j].project] })
+	return targets, nil
+}
+
+// owningProject 返回包含file的项目（最长前缀匹配）以及file相对于该项目的路径
+func owningProject(projects []*project.Project, file string) (*project.Project, string) {
+	var owner *project.Project
+	ownerPath := ""
+	for _, p := range projects {
+		projectPath := projectRelPath(p)
+		if file != projectPath && !strings.HasPrefix(file, projectPath+"/") {
+			continue
+		}
+		if owner == nil || len(projectPath) > len(ownerPath) {
+			owner, ownerPath = p, projectPath
+		}
+	}
+	if owner == nil {
+		return nil, ""
+	}
+	if file == ownerPath {
+		return owner, "."
+	}
+	return owner, strings.TrimPrefix(file, ownerPath+"/")
+}
+
+// runStageInteractive 显示有未提交修改的项目菜单，依次在选中的项目中运行 git add --interactive
+func runStageInteractive(ctx context.Context, opts *StageOptions, args []string, log logger.Logger) error {
+	parser := manifest.NewParser()
+	manifestObj, err := parser.ParseFromFile(opts.Config.ManifestName, strings.Split(opts.Config.Groups, ","))
+	if err != nil {
+		log.Error("解析清单失败: %v", err)
+		return fmt.Errorf("failed to parse manifest: %w", err)
+	}
+	manager := project.NewManagerFromManifest(manifestObj, opts.Config)
+
+	projects, err := manager.GetProjectsInGroups(nil)
+	if err != nil {
+		log.Error("获取项目失败: %v", err)
+		return fmt.Errorf("failed to get projects: %w", err)
+	}
+	if len(args) > 0 {
+		projects = matchStageProjects(projects, args)
+		if len(projects) == 0 {
+			return fmt.Errorf("no project matches %s", strings.Join(args, " "))
+		}
+	}
+
+	for {
+		dirty := dirtyProjects(projects)
+		if len(dirty) == 0 {
+			fmt.Println("no projects have uncommitted modifications")
+			return nil
+		}
+
+		fmt.Println("        project")
+		for i, p := range dirty {
+			fmt.Printf("%3d:    %s/\n", i+1, projectRelPath(p))
+		}
+		fmt.Println()
+
+		choice := ui.GlobalUI.GetInput("Select a project (number, name or path; q to quit)")
+		if choice == "" || choice == "q" {
+			return nil
+		}
+
+		selected := selectStageProjects(dirty, choice)
+		if len(selected) == 0 {
+			fmt.Printf("no project matches %q, try again\n", choice)
+			continue
+		}
+		if len(selected) > 1 && !ui.GlobalUI.Confirmation(fmt.Sprintf("Stage interactively in %d projects", len(selected))) {
+			continue
+		}
+
+		for _, p := range selected {
+			fmt.Printf("project %s/\n", projectRelPath(p))
+			if err := git.Run(git.InteractiveCommand(ctx, p.Worktree, "add", "--interactive")); err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				log.Error("项目 %s 交互式暂存失败: %v", p.Name, err)
+			}
+		}
+	}
+}
+
+// dirtyProjects 返回工作区有修改的项目，保持清单顺序
+func dirtyProjects(projects []*project.Project) []*project.Project {
+	var dirty []*project.Project
+	for _, p := range projects {
+		if _, err := os.Stat(p.Worktree); err != nil {
+			continue
+		}
+		if entries, err := p.GitRepo.StatusEntries(); err == nil && len(entries) > 0 {
+			dirty = append(dirty, p)
+		}
+	}
+	return dirty
+}
+
+// selectStageProjects 按菜单编号或名称/路径过滤选择项目
+func selectStageProjects(projects []*project.Project, choice string) []*project.Project {
+	if n, err := strconv.Atoi(choice); err == nil {
+		if n >= 1 && n <= len(projects) {
+			return projects[n-1 : n]
+		}
+		return nil
+	}
+	return matchStageProjects(projects, []string{choice})
+}
+
+// matchStageProjects 返回名称或路径与任一模式匹配的项目，模式支持通配符
+func matchStageProjects(projects []*project.Project, patterns []string) []*project.Project {
+	var matched []*project.Project
+	for _, p := range projects {
+		relPath := projectRelPath(p)
+		for _, pattern := range patterns {
+			pattern = strings.TrimSuffix(pattern, "/")
+			nameMatch, _ := path.Match(pattern, p.Name)
+			pathMatch, _ := path.Match(pattern, relPath)
+			if nameMatch || pathMatch {
+				matched = append(matched, p)
+				break
+			}
+		}
+	}
+	return matched
+}
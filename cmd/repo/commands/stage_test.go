@@ -0,0 +1,122 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/leopardxu/repo-go/internal/project"
+)
+
+func TestOwningProject(t *testing.T) {
+	projects := []*project.Project{
+		{Name: "device", Path: "device/vendor"},
+		{Name: "board", Path: "device/vendor/board"},
+		{Name: "build", Path: "build"},
+	}
+
+	tests := []struct {
+		file      string
+		wantOwner string
+		wantRel   string
+	}{
+		{"build/Makefile", "build", "Makefile"},
+		{"build", "build", "."},
+		{"device/vendor/README", "device", "README"},
+		{"device/vendor/board/BoardConfig.mk", "board", "BoardConfig.mk"},
+		{"device/vendor/board", "board", "."},
+		{"device/vendor/boardx/file", "device", "boardx/file"},
+		{"buildx/file", "", ""},
+		{"README", "", ""},
+	}
+	for _, tt := range tests {
+		owner, rel := owningProject(projects, tt.file)
+		name := ""
+		if owner != nil {
+			name = owner.Name
+		}
+		if name != tt.wantOwner || rel != tt.wantRel {
+			t.Errorf("owningProject(%q) = %q, %q, want %q, %q", tt.file, name, rel, tt.wantOwner, tt.wantRel)
+		}
+	}
+}
+
+func TestRouteStagePaths(t *testing.T) {
+	projects := []*project.Project{
+		{Name: "build", Path: "build"},
+		{Name: "device", Path: "device/vendor"},
+		{Name: "board", Path: "device/vendor/board"},
+	}
+
+	// 通配符在 repo 根目录下展开
+	root := t.TempDir()
+	for _, file := range []string{"build/a.mk", "build/b.mk", "device/vendor/board/c.mk"} {
+		path := filepath.Join(root, filepath.FromSlash(file))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(root); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(cwd)
+
+	type target struct {
+		project string
+		files   []string
+	}
+	tests := []struct {
+		name    string
+		args    []string
+		want    []target
+		wantErr bool
+	}{
+		{
+			name: "grouped in manifest order",
+			args: []string{"device/vendor/board/c.mk", "build/deleted.mk", "device/vendor/README"},
+			want: []target{
+				{"build", []string{"deleted.mk"}},
+				{"device", []string{"README"}},
+				{"board", []string{"c.mk"}},
+			},
+		},
+		{
+			name: "glob",
+			args: []string{"build/*.mk"},
+			want: []target{{"build", []string{"a.mk", "b.mk"}}},
+		},
+		{
+			name: "nested project directory",
+			args: []string{"device/vendor/board/"},
+			want: []target{{"board", []string{"."}}},
+		},
+		{
+			name:    "outside projects",
+			args:    []string{"out/file"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			targets, err := routeStagePaths(projects, tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("routeStagePaths() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var got []target
+			for _, tg := range targets {
+				got = append(got, target{tg.project.Name, tg.files})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("routeStagePaths() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,8 +1,11 @@
 package commands
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
 	"runtime"
 	"strings"
 	"sync"
@@ -59,10 +62,19 @@ func StartCmd() *cobra.Command {
 	}
 
 	cmd := &cobra.Command{
-		Use:   "start <branch_name> [<project>...]",
-		Short: "Start a new branch for development",
-		Long:  `Create a new branch for development based on the current manifest.`,
-		Args:  cobra.MinimumNArgs(1),
+		Use:               "start <branch_name> [<project>...]",
+		Short:             "Start a new branch for development",
+		ValidArgsFunction: completeProjectsAfter(1),
+		Long: `Create a new branch for development based on the current manifest.
+
+The branch starts at the manifest revision of each project (or --rev) and is
+checked out. Its upstream (branch.<name>.remote and branch.<name>.merge) is
+set to the manifest revision, so that 'repo upload' and 'repo rebase' know
+the base. Projects pinned to a commit or tag track their upstream or
+dest-branch annotation, falling back to the default revision.
+
+Projects in the middle of a rebase are refused.`,
+		Args: cobra.MinimumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			// 创建日志记录器
 			log := logger.NewDefaultLogger()
@@ -82,9 +94,12 @@ func StartCmd() *cobra.Command {
 				log.Error("加载配置失败: %v", err)
 				return fmt.Errorf("failed to load config: %w", err)
 			}
+			if err := requireGitClient(cfg, "start"); err != nil {
+				return err
+			}
 			opts.Config = cfg
 
-			return runStart(opts, args, log)
+			return runStart(cmd.Context(), opts, args, log)
 		},
 	}
 
@@ -102,7 +117,7 @@ func StartCmd() *cobra.Command {
 }
 
 // runStart 执行start命令
-func runStart(opts *StartOptions, args []string, log logger.Logger) error {
+func runStart(ctx context.Context, opts *StartOptions, args []string, log logger.Logger) error {
 	// 确保在repo根目录下执行
 	originalDir, err := EnsureRepoRoot(log)
 	if err != nil {
@@ -171,6 +186,11 @@ func runStart(opts *StartOptions, args []string, log logger.Logger) error {
 		log.Debug("共获取到 %d 个项目", len(projects))
 	}
 
+	// 工作树模式下同名项目共享一个仓库，同一分支只能在其中一个工作树中检出
+	if opts.Config.Worktree {
+		projects = oneWorktreePerRepo(projects, branchName, log)
+	}
+
 	// 使用goroutine池并发创建分支
 	log.Info("开始创建分支，并行任务数 %d...", opts.Jobs)
 
@@ -206,44 +226,49 @@ func runStart(opts *StartOptions, args []string, log logger.Logger) error {
 
 			log.Debug("在项%s 中创建分'%s'...", p.Name, branchName)
 
-			// 确定使用的修订版和分支合并目标
-			revision := opts.Rev
-
-			// 如果没有指定revision，使用项目的revision
-			if revision == "" {
-				revision = p.Revision
+			if _, err := os.Stat(p.Worktree); err != nil {
+				log.Error("项目 %s 的工作目录不存在，请先执行 repo sync", p.Name)
+				errChan <- fmt.Errorf("project %s: not synced, run repo sync first", p.Name)
+				stats.increment(false)
+				prog.Update(p.Name + " - 失败")
+				return
 			}
 
-			// 确定上游分支（用于设置跟踪关系）
-			upstream := ""
-			if projInfo, exists := manifestProjectInfo[p.Name]; exists {
-				upstream = projInfo.Upstream
+			l, err := lockProject(ctx, p)
+			if err != nil {
+				log.Error("项目 %s 获取项目锁失败: %v", p.Name, err)
+				errChan <- fmt.Errorf("project %s: %w", p.Name, err)
+				stats.increment(false)
+				prog.Update(p.Name + " - 失败")
+				return
 			}
-			if upstream == "" {
-				// 如果项目没有指定upstream，使用default的upstream
-				if manifest.Default.Upstream != "" {
-					upstream = manifest.Default.Upstream
-				} else {
-					// 否则使用revision作为upstream
-					upstream = revision
-				}
+			defer l.Release()
+
+			// 未完成的rebase会在切换分支后丢失上下文，拒绝继续
+			if p.GitRepo.RebaseInProgress() {
+				log.Error("项目 %s 正在进行rebase，请先执行 repo rebase --continue 或 --abort", p.Name)
+				errChan <- fmt.Errorf("project %s: a rebase is in progress, run 'repo rebase --continue' or 'repo rebase --abort' first", p.Name)
+				stats.increment(false)
+				prog.Update(p.Name + " - 失败")
+				return
 			}
 
-			// 处理不可变修订版本（SHA1、tag、change）
-			// 如果是不可变的修订版本（如SHA1、tag），使用默认修订版本作为上游
-			if git.IsImmutable(p.Revision) {
-				if manifest.Default.Revision != "" {
-					// 使用默认修订版本作为分支合并目标
-					log.Debug("项目 %s 的修订版本 %s 是不可变的，使用默认修订版本 %s 作为上游",
-						p.Name, p.Revision, manifest.Default.Revision)
-					upstream = manifest.Default.Revision
+			// 确定起点：--rev 优先，否则使用清单修订版本对应的远程跟踪引用
+			startPoint := opts.Rev
+			if startPoint == "" {
+				startPoint = p.RevisionRef()
+				if _, err := p.GitRepo.RevParse(startPoint); err != nil || startPoint == "" {
+					startPoint = p.Revision
 				}
 			}
 
-			log.Debug("项目 %s 使用修订版本: %s, 上游分支: %s", p.Name, revision, upstream)
+			// 确定分支合并目标，供之后的 upload 和 rebase 使用
+			info := manifestProjectInfo[p.Name]
+			pinned := isPinnedRevision(p)
+			merge := startMergeTarget(p.Revision, pinned, info.Upstream, info.DestBranch, manifest.Default.Upstream, manifest.Default.Revision)
+			log.Debug("项目 %s 起点: %s, 合并目标: %s", p.Name, startPoint, merge)
 
-			// 创建分支
-			if err := p.GitRepo.CreateBranch(branchName, revision); err != nil {
+			if err := startBranch(p, branchName, startPoint, merge); err != nil {
 				log.Error("项目 %s 创建分支失败: %v", p.Name, err)
 				errChan <- fmt.Errorf("project %s: %w", p.Name, err)
 				stats.increment(false)
@@ -288,3 +313,117 @@ func runStart(opts *StartOptions, args []string, log logger.Logger) error {
 
 	return nil
 }
+
+// startBranch 检出分支（不存在时从起点创建）并记录跟踪的远程和合并目标
+func startBranch(p *project.Project, branch, startPoint, merge string) error {
+	_, err := p.GitRepo.RevParse("refs/heads/" + branch)
+	exists := err == nil
+
+	args := []string{"checkout", "--quiet", branch}
+	if !exists {
+		args = []string{"checkout", "--quiet", "-b", branch, startPoint}
+	}
+	if _, err := p.GitRepo.RunCommand(args...); err != nil {
+		return fmt.Errorf("failed to checkout branch %s: %w", branch, err)
+	}
+	p.GitRepo.ClearCache()
+
+	// 已有分支若已配置跟踪关系则保持不变
+	if exists {
+		if tracking, err := p.GitRepo.TrackingBranch(branch); err == nil && tracking != "" {
+			return nil
+		}
+	}
+
+	remote := p.RemoteName
+	if remote == "" {
+		remote = "origin"
+	}
+	if _, err := p.GitRepo.RunCommand("config", fmt.Sprintf("branch.%s.remote", branch), remote); err != nil {
+		return fmt.Errorf("failed to set upstream remote: %w", err)
+	}
+	if _, err := p.GitRepo.RunCommand("config", fmt.Sprintf("branch.%s.merge", branch), merge); err != nil {
+		return fmt.Errorf("failed to set upstream branch: %w", err)
+	}
+	return nil
+}
+
+// oneWorktreePerRepo 在共享同一仓库的项目中只保留一个工作树创建分支：
+// 已检出该分支的工作树优先，否则使用清单中的第一个路径，其他路径跳过
+func oneWorktreePerRepo(projects []*project.Project, branch string, log logger.Logger) []*project.Project {
+	chosen := make(map[string]*project.Project)
+	var commons []string
+	for _, p := range projects {
+		common := git.CommonDir(p.Worktree)
+		if common == "" {
+			continue
+		}
+		if _, ok := chosen[common]; !ok {
+			chosen[common] = p
+			commons = append(commons, common)
+		}
+	}
+	for _, common := range commons {
+		worktrees, err := git.ListWorktrees(context.Background(), common)
+		if err != nil {
+			continue
+		}
+		for _, wt := range worktrees {
+			if wt.Branch != branch {
+				continue
+			}
+			for _, p := range projects {
+				if abs, err := filepath.Abs(p.Worktree); err == nil && abs == wt.Path {
+					chosen[common] = p
+				}
+			}
+		}
+	}
+
+	var result []*project.Project
+	for _, p := range projects {
+		common := git.CommonDir(p.Worktree)
+		if c, ok := chosen[common]; ok && c != p {
+			log.Warn("项目 %s 的 %s 与 %s 共享仓库，分支 '%s' 只能在一个工作树中检出，跳过",
+				p.Name, projectRelPath(p), projectRelPath(c), branch)
+			continue
+		}
+		result = append(result, p)
+	}
+	return result
+}
+
+// startMergeTarget 确定新分支的 branch.<name>.merge
+// 清单修订版本是分支时直接使用；固定到提交或标签时依次使用项目的 upstream、
+// dest-branch、默认 upstream 和默认修订版本，都没有时保留修订版本本身
+func startMergeTarget(revision string, pinned bool, fallbacks ...string) string {
+	if !pinned {
+		return branchMergeRef(revision)
+	}
+	for _, candidate := range fallbacks {
+		if candidate != "" && !git.IsImmutable(candidate) {
+			return branchMergeRef(candidate)
+		}
+	}
+	return revision
+}
+
+// isPinnedRevision 检查清单修订版本是否固定到提交或标签
+func isPinnedRevision(p *project.Project) bool {
+	if git.IsImmutable(p.Revision) {
+		return true
+	}
+	if strings.HasPrefix(p.Revision, "refs/") {
+		return false
+	}
+	_, err := p.GitRepo.Runner.RunInDir(p.Worktree, "show-ref", "--verify", "--quiet", "refs/tags/"+p.Revision)
+	return err == nil
+}
+
+// branchMergeRef 将分支名转换为完整引用
+func branchMergeRef(name string) string {
+	if strings.HasPrefix(name, "refs/") {
+		return name
+	}
+	return "refs/heads/" + name
+}
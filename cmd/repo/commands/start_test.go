@@ -0,0 +1,75 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/leopardxu/repo-go/internal/git"
+	"github.com/leopardxu/repo-go/internal/project"
+	"github.com/leopardxu/repo-go/internal/testutil"
+)
+
+func TestStartMergeTarget(t *testing.T) {
+	sha := "0123456789abcdef0123456789abcdef01234567"
+	tests := []struct {
+		name      string
+		revision  string
+		pinned    bool
+		fallbacks []string
+		want      string
+	}{
+		{"branch", "main", false, nil, "refs/heads/main"},
+		{"full branch ref", "refs/heads/main", false, []string{"stable"}, "refs/heads/main"},
+		{"pinned sha uses upstream", sha, true, []string{"stable", "main"}, "refs/heads/stable"},
+		{"pinned tag uses dest-branch", "refs/tags/v1.0", true, []string{"", "release"}, "refs/heads/release"},
+		// 固定修订版本的默认 revision 本身也固定时跳过
+		{"skip immutable fallback", sha, true, []string{"", "", "refs/tags/v2.0", "main"}, "refs/heads/main"},
+		{"no fallback", sha, true, []string{"", ""}, sha},
+	}
+	for _, tt := range tests {
+		if got := startMergeTarget(tt.revision, tt.pinned, tt.fallbacks...); got != tt.want {
+			t.Errorf("%s: startMergeTarget() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestIsPinnedRevision(t *testing.T) {
+	dir := t.TempDir()
+	testutil.Git(t, dir, "init", "--quiet", "-b", "main")
+	testutil.Git(t, dir, "commit", "--quiet", "--allow-empty", "-m", "base")
+	testutil.Git(t, dir, "tag", "v1.0")
+	head := testutil.Git(t, dir, "rev-parse", "HEAD")
+
+	tests := []struct {
+		revision string
+		want     bool
+	}{
+		{head, true},
+		{"refs/tags/v1.0", true},
+		// 清单中常见不带 refs/tags/ 前缀的标签
+		{"v1.0", true},
+		{"main", false},
+		{"refs/heads/main", false},
+		{"refs/heads/v1.0", false},
+	}
+	for _, tt := range tests {
+		p := &project.Project{Worktree: dir, Revision: tt.revision, GitRepo: git.NewRepository(dir, git.NewRunner())}
+		if got := isPinnedRevision(p); got != tt.want {
+			t.Errorf("isPinnedRevision(%q) = %v, want %v", tt.revision, got, tt.want)
+		}
+	}
+}
+
+func TestBranchMergeRef(t *testing.T) {
+	tests := map[string]string{
+		"main":            "refs/heads/main",
+		"release/1.0":     "refs/heads/release/1.0",
+		"refs/heads/main": "refs/heads/main",
+		"refs/for/main":   "refs/for/main",
+		"refs/tags/v1.0":  "refs/tags/v1.0",
+	}
+	for name, want := range tests {
+		if got := branchMergeRef(name); got != want {
+			t.Errorf("branchMergeRef(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
@@ -1,15 +1,21 @@
 package commands
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
 	"runtime"
+	"sort"
 	"strings"
 	"sync"
 
 	"github.com/leopardxu/repo-go/internal/config"
+	"github.com/leopardxu/repo-go/internal/git"
 	"github.com/leopardxu/repo-go/internal/logger"
 	"github.com/leopardxu/repo-go/internal/manifest"
+	"github.com/leopardxu/repo-go/internal/progress"
 	"github.com/leopardxu/repo-go/internal/project"
 	"github.com/spf13/cobra"
 )
@@ -22,6 +28,7 @@ type StatusOptions struct {
 	Quiet   bool
 	Verbose bool
 	Branch  bool
+	Format  string // 输出格式: text 或 json
 	Config  *config.Config
 }
 
@@ -52,9 +59,29 @@ func StatusCmd() *cobra.Command {
 	}
 
 	cmd := &cobra.Command{
-		Use:   "status [<project>...]",
-		Short: "Show the working tree status",
-		Long:  `Show the status of the working tree. This includes projects with uncommitted changes, projects with unpushed commits, and projects on different branches than specified in the manifest.`,
+		Use:               "status [<project>...]",
+		Short:             "Show the working tree status",
+		ValidArgsFunction: completeProjects,
+		Long: `Compares the working tree to the staging area (aka index), and the most
+recent commit on this branch (HEAD), in each project specified. A summary
+is displayed, one line per file where there is a difference between these
+three states. Projects with a clean working tree are not shown.
+
+The project header shows the current branch and how many commits it is
+ahead of or behind the manifest revision:
+
+  project pa/                                     branch topic [ahead 2]
+   -m     subcmds/status.py
+
+The first column is the index state and the second the working tree state:
+
+  A: added       M/m: modified    D/d: deleted
+  R: renamed     C: copied        T/t: type changed
+  U/u: unmerged  -: unchanged     --: untracked
+
+With --orphans, files and directories in the client that do not belong to
+any project are listed as well. --format=json prints every project with
+its branch, ahead/behind counts and files for IDE integrations.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			// 创建日志记录器
 			log := logger.NewDefaultLogger()
@@ -77,6 +104,7 @@ func StatusCmd() *cobra.Command {
 	cmd.Flags().BoolVarP(&opts.Orphans, "orphans", "o", false, "include objects in working directory outside of repo projects")
 	cmd.Flags().BoolVarP(&opts.Quiet, "quiet", "q", false, "only show errors")
 	cmd.Flags().BoolVarP(&opts.Verbose, "verbose", "v", false, "show all output including debug logs")
+	cmd.Flags().StringVar(&opts.Format, "format", "text", "output format: text or json")
 	AddManifestFlags(cmd, &opts.CommonManifestOptions)
 
 	return cmd
@@ -84,6 +112,10 @@ func StatusCmd() *cobra.Command {
 
 // runStatus 执行status命令
 func runStatus(opts *StatusOptions, args []string, log logger.Logger) error {
+	if opts.Format != "text" && opts.Format != "json" {
+		return fmt.Errorf("invalid --format %q, expected text or json", opts.Format)
+	}
+
 	// 创建统计对象
 	stats := &statusStats{}
 
@@ -101,6 +133,9 @@ func runStatus(opts *StatusOptions, args []string, log logger.Logger) error {
 		log.Error("加载配置失败: %v", err)
 		return fmt.Errorf("failed to load config: %w", err)
 	}
+	if err := requireGitClient(cfg, "status"); err != nil {
+		return err
+	}
 	opts.Config = cfg
 
 	// 加载清单
@@ -140,72 +175,309 @@ func runStatus(opts *StatusOptions, args []string, log logger.Logger) error {
 		log.Debug("共获取到 %d 个项目", len(projects))
 	}
 
-	// 使用goroutine池并发获取项目状态
-	log.Info("开始检查项目状态，并行任务数 %d...", opts.Jobs)
+	// 使用goroutine池并发获取项目状态，结果按清单顺序保存
+	log.Debug("开始检查项目状态，并行任务数 %d...", opts.Jobs)
 
-	type statusResult struct {
-		Project *project.Project
-		Status  string
-		Err     error
+	jobs := opts.Jobs
+	if jobs < 1 {
+		jobs = 1
 	}
-
+	results := make([]*projectStatus, len(projects))
 	var wg sync.WaitGroup
-	results := make(chan statusResult, len(projects))
-	sem := make(chan struct{}, opts.Jobs) // 使用信号量控制并发数
+	sem := make(chan struct{}, jobs) // 使用信号量控制并发数
 
-	for _, p := range projects {
-		p := p // 创建副本避免闭包问题
-		wg.Add(1)
+	var pm progress.Reporter
+	if !opts.Quiet {
+		pm = progress.NewConsoleReporterWithTitle("Checking status")
+		pm.Start(len(projects))
+	}
 
-		go func() {
+	for i, p := range projects {
+		wg.Add(1)
+		go func(i int, p *project.Project) {
 			defer wg.Done()
 			sem <- struct{}{}        // 获取信号
 			defer func() { <-sem }() // 释放信号
 
-			log.Debug("正在检查项%s 的状..", p.Name)
-
-			status, err := p.GetStatus()
-			if err != nil {
-				log.Error("获取项目 %s 状态失败 %v", p.Name, err)
+			log.Debug("正在检查项目 %s 的状态", p.Name)
+			if pm != nil {
+				pm.StartTask(p.Name)
+			}
+			results[i] = collectProjectStatus(p)
+			if pm != nil {
+				pm.FinishTask(p.Name, results[i].err == nil)
+			}
+			if results[i].err != nil {
+				log.Error("获取项目 %s 状态失败: %v", p.Name, results[i].err)
 				stats.increment(false)
 			} else {
 				stats.increment(true)
-				log.Debug("项目 %s 状态检查完成", p.Name)
 			}
+		}(i, p)
+	}
+	wg.Wait()
+	if pm != nil {
+		pm.Finish()
+	}
 
-			results <- statusResult{
-				Project: p,
-				Status:  status,
-				Err:     err,
-			}
-		}()
+	var orphans []string
+	if opts.Orphans {
+		allProjects, err := manager.GetProjectsInGroups(nil)
+		if err != nil {
+			return fmt.Errorf("failed to get projects: %w", err)
+		}
+		var projectPaths []string
+		for _, p := range allProjects {
+			projectPaths = append(projectPaths, projectRelPath(p))
+		}
+		if orphans, err = findOrphans(".", projectPaths); err != nil {
+			return fmt.Errorf("failed to find orphans: %w", err)
+		}
+	}
+
+	if opts.Format == "json" {
+		if err := printStatusJSON(results, orphans, opts.Orphans); err != nil {
+			return err
+		}
+	} else {
+		printStatusText(results, orphans, opts.Orphans)
 	}
 
-	// 启动一个goroutine 来关闭结果通道
-	go func() {
-		wg.Wait()
-		close(results)
-	}()
+	log.Debug("状态检查操作完成，总计: %d，成功 %d，失败 %d", stats.total, stats.success, stats.failed)
 
-	// 处理结果
+	// 如果有错误，返回汇总错误
 	var errs []error
-	for res := range results {
-		if res.Err != nil {
-			errs = append(errs, fmt.Errorf("项目 %s: %w", res.Project.Name, res.Err))
+	for _, r := range results {
+		if r.err != nil {
+			errs = append(errs, fmt.Errorf("项目 %s: %w", r.project.Name, r.err))
+		}
+	}
+	if len(errs) > 0 {
+		log.Error("有 %d 个项目状态检查失败", len(errs))
+		return fmt.Errorf("%d projects failed: %w", len(errs), errors.Join(errs...))
+	}
+
+	return nil
+}
+
+// projectStatus 保存单个项目的状态
+type projectStatus struct {
+	project *project.Project
+	path    string
+	missing bool
+	branch  string // 当前分支，分离HEAD时为空
+	ahead   int
+	behind  int
+	entries []git.StatusEntry
+	err     error
+}
+
+// collectProjectStatus 获取项目的分支、领先/落后提交数以及文件状态
+func collectProjectStatus(p *project.Project) *projectStatus {
+	st := &projectStatus{project: p, path: projectRelPath(p)}
+
+	if _, err := os.Stat(p.Worktree); os.IsNotExist(err) {
+		st.missing = true
+		return st
+	}
+
+	if st.entries, st.err = p.GitRepo.StatusEntries(); st.err != nil {
+		return st
+	}
+
+	if branch, err := p.GitRepo.CurrentBranch(); err == nil && !strings.HasPrefix(branch, "HEAD detached at ") {
+		st.branch = branch
+	}
+
+	// 清单修订版本尚未获取时不显示领先/落后信息
+	if ref := p.RevisionRef(); ref != "" {
+		if ahead, behind, err := p.GitRepo.AheadBehind(ref, "HEAD"); err == nil {
+			st.ahead, st.behind = ahead, behind
+		}
+	}
+	return st
+}
+
+// statusCodes 将porcelain状态转换为两字符的状态码：暂存区大写，工作区小写，未修改为 -
+func statusCodes(e git.StatusEntry) (string, string) {
+	code := func(c byte) string {
+		if c == ' ' || c == '?' || c == '!' {
+			return "-"
+		}
+		return string(c)
+	}
+	return strings.ToUpper(code(e.Index)), strings.ToLower(code(e.WorkTree))
+}
+
+// printStatusText 以原生repo的格式输出状态，省略干净的项目
+func printStatusText(results []*projectStatus, orphans []string, showOrphans bool) {
+	clean := true
+	for _, r := range results {
+		if r.err != nil {
 			continue
 		}
+		if r.missing {
+			clean = false
+			fmt.Printf("project %-40s missing (run \"repo sync\")\n", r.path+"/")
+			continue
+		}
+		if len(r.entries) == 0 {
+			continue
+		}
+		clean = false
+
+		branch := "(*** NO BRANCH ***)"
+		if r.branch != "" {
+			branch = "branch " + r.branch
+		}
+		var counts []string
+		if r.ahead > 0 {
+			counts = append(counts, fmt.Sprintf("ahead %d", r.ahead))
+		}
+		if r.behind > 0 {
+			counts = append(counts, fmt.Sprintf("behind %d", r.behind))
+		}
+		if len(counts) > 0 {
+			branch += " [" + strings.Join(counts, ", ") + "]"
+		}
+		fmt.Printf("project %-40s%s\n", r.path+"/", branch)
 
-		log.Info("项目 %s: %s", res.Project.Name, res.Status)
+		for _, e := range r.entries {
+			index, worktree := statusCodes(e)
+			if e.OrigPath != "" {
+				fmt.Printf(" %s%s\t%s => %s\n", index, worktree, e.OrigPath, e.Path)
+			} else {
+				fmt.Printf(" %s%s\t%s\n", index, worktree, e.Path)
+			}
+		}
 	}
 
-	// 显示统计信息
-	log.Info("状态检查操作完成，总计: %d，成功 %d，失败 %d", stats.total, stats.success, stats.failed)
+	if clean {
+		fmt.Println("nothing to commit (working directory clean)")
+	}
 
-	// 如果有错误，返回汇总错误
-	if len(errs) > 0 {
-		log.Error("有 %d 个项目状态检查失败", len(errs))
-		return fmt.Errorf("%d projects failed: %v", len(errs), errors.Join(errs...))
+	if showOrphans {
+		if len(orphans) == 0 {
+			fmt.Println("No orphan files or directories")
+			return
+		}
+		fmt.Println("Objects not within a project (orphans)")
+		for _, orphan := range orphans {
+			fmt.Printf(" --\t%s\n", orphan)
+		}
 	}
+}
+
+// statusJSONFile JSON输出中的文件状态
+type statusJSONFile struct {
+	Index    string `json:"index"`
+	WorkTree string `json:"worktree"`
+	Path     string `json:"path"`
+	OrigPath string `json:"orig_path,omitempty"`
+}
 
+// statusJSONProject JSON输出中的项目状态
+type statusJSONProject struct {
+	Name     string           `json:"name"`
+	Path     string           `json:"path"`
+	Revision string           `json:"revision"`
+	Branch   string           `json:"branch,omitempty"`
+	Ahead    int              `json:"ahead"`
+	Behind   int              `json:"behind"`
+	Clean    bool             `json:"clean"`
+	Missing  bool             `json:"missing,omitempty"`
+	Error    string           `json:"error,omitempty"`
+	Files    []statusJSONFile `json:"files"`
+}
+
+// printStatusJSON 以JSON格式输出所有项目的状态（包括干净的项目）
+func printStatusJSON(results []*projectStatus, orphans []string, showOrphans bool) error {
+	output := struct {
+		Projects []statusJSONProject `json:"projects"`
+		Orphans  []string            `json:"orphans,omitempty"`
+	}{Projects: []statusJSONProject{}}
+
+	for _, r := range results {
+		item := statusJSONProject{
+			Name:     r.project.Name,
+			Path:     r.path,
+			Revision: r.project.Revision,
+			Branch:   r.branch,
+			Ahead:    r.ahead,
+			Behind:   r.behind,
+			Clean:    r.err == nil && !r.missing && len(r.entries) == 0,
+			Missing:  r.missing,
+			Files:    []statusJSONFile{},
+		}
+		if r.err != nil {
+			item.Error = r.err.Error()
+		}
+		for _, e := range r.entries {
+			index, worktree := statusCodes(e)
+			item.Files = append(item.Files, statusJSONFile{Index: index, WorkTree: worktree, Path: e.Path, OrigPath: e.OrigPath})
+		}
+		output.Projects = append(output.Projects, item)
+	}
+	if showOrphans {
+		output.Orphans = append([]string{}, orphans...)
+	}
+
+	data, err := json.MarshalIndent(output, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode status: %w", err)
+	}
+	fmt.Println(string(data))
 	return nil
 }
+
+// findOrphans 列出root下不属于任何项目的文件和目录，目录以 / 结尾
+// 只会进入作为项目父目录的目录，.repo 及 .repo_* 被忽略
+func findOrphans(root string, projectPaths []string) ([]string, error) {
+	projects := make(map[string]bool)
+	parents := make(map[string]bool)
+	for _, p := range projectPaths {
+		p = filepath.ToSlash(filepath.Clean(p))
+		projects[p] = true
+		for dir := filepath.ToSlash(filepath.Dir(p)); dir != "." && dir != "/"; dir = filepath.ToSlash(filepath.Dir(dir)) {
+			parents[dir] = true
+		}
+	}
+
+	var orphans []string
+	var walk func(dir string) error
+	walk = func(dir string) error {
+		entries, err := os.ReadDir(filepath.Join(root, filepath.FromSlash(dir)))
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			name := entry.Name()
+			rel := name
+			if dir != "" {
+				rel = dir + "/" + name
+			} else if name == ".repo" || strings.HasPrefix(name, ".repo_") {
+				continue
+			}
+
+			switch {
+			case projects[rel]:
+				// 项目本身，其中嵌套的项目由各自的git状态负责
+			case parents[rel]:
+				if err := walk(rel); err != nil {
+					return err
+				}
+			case entry.IsDir():
+				orphans = append(orphans, rel+"/")
+			default:
+				orphans = append(orphans, rel)
+			}
+		}
+		return nil
+	}
+
+	if err := walk(""); err != nil {
+		return nil, err
+	}
+	sort.Strings(orphans)
+	return orphans, nil
+}
@@ -0,0 +1,77 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/leopardxu/repo-go/internal/git"
+)
+
+func TestStatusCodes(t *testing.T) {
+	tests := []struct {
+		entry           git.StatusEntry
+		index, worktree string
+	}{
+		{git.StatusEntry{Index: 'M', WorkTree: ' '}, "M", "-"},
+		{git.StatusEntry{Index: ' ', WorkTree: 'M'}, "-", "m"},
+		{git.StatusEntry{Index: 'A', WorkTree: 'M'}, "A", "m"},
+		{git.StatusEntry{Index: 'R', WorkTree: 'D'}, "R", "d"},
+		{git.StatusEntry{Index: '?', WorkTree: '?'}, "-", "-"},
+		{git.StatusEntry{Index: '!', WorkTree: '!'}, "-", "-"},
+		{git.StatusEntry{Index: 'U', WorkTree: 'U'}, "U", "u"},
+	}
+	for _, tt := range tests {
+		index, worktree := statusCodes(tt.entry)
+		if index != tt.index || worktree != tt.worktree {
+			t.Errorf("statusCodes(%q%q) = %q, %q, want %q, %q",
+				tt.entry.Index, tt.entry.WorkTree, index, worktree, tt.index, tt.worktree)
+		}
+	}
+}
+
+func TestFindOrphans(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{
+		".repo/manifests",
+		".repo_backup",
+		"build/make",
+		"build/tools",
+		"device/vendor/board",
+		"device/vendor/board/nested",
+		"device/vendor/board/nested/inner",
+		"device/other",
+		"stray",
+	} {
+		if err := os.MkdirAll(filepath.Join(root, filepath.FromSlash(dir)), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, file := range []string{"Makefile", "build/notes.txt", "device/vendor/README"} {
+		if err := os.WriteFile(filepath.Join(root, filepath.FromSlash(file)), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// device/vendor/board/nested 嵌套在项目 device/vendor/board 中，由该项目自己的状态负责
+	got, err := findOrphans(root, []string{
+		"build/make",
+		"device/vendor/board",
+		"device/vendor/board/nested",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"Makefile",
+		"build/notes.txt",
+		"build/tools/",
+		"device/other/",
+		"device/vendor/README",
+		"stray/",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("findOrphans() = %v, want %v", got, want)
+	}
+}
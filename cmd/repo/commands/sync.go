@@ -1,6 +1,7 @@
 package commands
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -21,10 +22,12 @@ type SyncOptions struct {
 	Jobs                   int
 	JobsNetwork            int
 	JobsCheckout           int
+	JobsPerHost            int
 	CurrentBranch          bool
 	NoCurrentBranch        bool
 	Detach                 bool
 	ForceSync              bool
+	NoRebase               bool // 有本地提交的分支不变基
 	ForceRemoveDirty       bool
 	ForceOverwrite         bool
 	ForceBroken            bool // 继续同步即使项目已损坏
@@ -94,9 +97,16 @@ func SyncCmd() *cobra.Command {
 	}
 
 	cmd := &cobra.Command{
-		Use:   "sync",
-		Short: "Update working tree to the latest revision",
-		Long:  `Synchronize the local repository with the remote repositories.`,
+		Use:               "sync",
+		Short:             "Update working tree to the latest revision",
+		ValidArgsFunction: completeProjects,
+		Long: `Synchronize the local repository with the remote repositories.
+
+After fetching, each project's working tree is updated to its manifest revision:
+a detached HEAD is moved to the new revision, a topic branch without local
+commits is fast-forwarded, and a topic branch with unpublished commits is
+rebased onto the new revision (left alone with a warning under --no-rebase).
+Projects with uncommitted changes are skipped unless --force-sync is given.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			// 创建日志记录器
 			log := logger.NewDefaultLogger()
@@ -118,7 +128,7 @@ func SyncCmd() *cobra.Command {
 				}
 			}
 
-			return runSync(opts, args, log)
+			return runSync(cmd.Context(), opts, args, log)
 		},
 	}
 
@@ -126,10 +136,12 @@ func SyncCmd() *cobra.Command {
 	cmd.Flags().IntVarP(&opts.Jobs, "jobs", "j", opts.Jobs, "number of parallel jobs (default: based on number of CPU cores)")
 	cmd.Flags().IntVar(&opts.JobsNetwork, "jobs-network", opts.Jobs, "number of network jobs to run in parallel")
 	cmd.Flags().IntVar(&opts.JobsCheckout, "jobs-checkout", opts.Jobs, "number of local checkout jobs to run in parallel")
+	cmd.Flags().IntVar(&opts.JobsPerHost, "jobs-per-host", 0, "maximum number of parallel fetches per remote host, halved while the server throttles (default: remote sync-j or jobs-network)")
 	cmd.Flags().BoolVarP(&opts.CurrentBranch, "current-branch", "c", true, "fetch only current branch")
 	cmd.Flags().BoolVar(&opts.NoCurrentBranch, "no-current-branch", false, "fetch all branches from server")
 	cmd.Flags().BoolVarP(&opts.Detach, "detach", "d", false, "detach projects back to manifest revision")
 	cmd.Flags().BoolVarP(&opts.ForceSync, "force-sync", "f", false, "overwrite local changes")
+	cmd.Flags().BoolVar(&opts.NoRebase, "no-rebase", false, "do not rebase local commits onto the new manifest revision")
 	cmd.Flags().BoolVar(&opts.ForceRemoveDirty, "force-remove-dirty", false, "force remove projects with uncommitted modifications")
 	cmd.Flags().BoolVar(&opts.ForceOverwrite, "force-overwrite", false, "force cleanup local uncommitted changes")
 	cmd.Flags().BoolVar(&opts.ForceBroken, "force-broken", false, "continue syncing other projects if a project sync fails")
@@ -149,6 +161,7 @@ func SyncCmd() *cobra.Command {
 	cmd.Flags().BoolVar(&opts.OptimizedFetch, "optimized-fetch", false, "only fetch projects fixed to sha1 if revision does not exist locally")
 	cmd.Flags().IntVar(&opts.RetryFetches, "retry-fetches", opts.RetryFetches, "number of times to retry fetches")
 	cmd.Flags().StringVarP(&opts.Groups, "groups", "g", "", "restrict to projects matching the specified groups")
+	cmd.RegisterFlagCompletionFunc("groups", completeGroups)
 	cmd.Flags().BoolVar(&opts.FailFast, "fail-fast", false, "stop syncing after first error is hit")
 	cmd.Flags().BoolVar(&opts.UseSuperproject, "use-superproject", false, "use the manifest superproject to sync projects")
 	cmd.Flags().BoolVar(&opts.NoUseSuperproject, "no-use-superproject", false, "disable use of manifest superprojects")
@@ -172,7 +185,7 @@ func SyncCmd() *cobra.Command {
 }
 
 // runSync 执行sync命令
-func runSync(opts *SyncOptions, args []string, log logger.Logger) error {
+func runSync(ctx context.Context, opts *SyncOptions, args []string, log logger.Logger) error {
 	// 确保在repo根目录下执行
 	originalDir, err := EnsureRepoRoot(log)
 	if err != nil {
@@ -207,7 +220,7 @@ func runSync(opts *SyncOptions, args []string, log logger.Logger) error {
 	if !opts.NoManifestUpdate {
 		log.Info("正在更新 manifest 仓库...")
 		// 创建临时引擎用于更新 manifest 仓库
-		tempEngine := repo_sync.NewEngine(&repo_sync.Options{
+		tempEngine := repo_sync.NewEngineWithContext(ctx, &repo_sync.Options{
 			NoManifestUpdate: opts.NoManifestUpdate,
 			Config:           cfg,
 			Quiet:            opts.Quiet,
@@ -331,13 +344,15 @@ func runSync(opts *SyncOptions, args []string, log logger.Logger) error {
 		log.Info("未指定组过滤，将同步所有项目")
 	}
 
-	engine := repo_sync.NewEngine(&repo_sync.Options{
+	engine := repo_sync.NewEngineWithContext(ctx, &repo_sync.Options{
 		Jobs:                   opts.Jobs,
 		JobsNetwork:            opts.JobsNetwork,
 		JobsCheckout:           opts.JobsCheckout,
+		HostJobs:               opts.JobsPerHost,
 		CurrentBranch:          opts.CurrentBranch && !opts.NoCurrentBranch,
 		Detach:                 opts.Detach,
 		ForceSync:              opts.ForceSync,
+		NoRebase:               opts.NoRebase,
 		ForceRemoveDirty:       opts.ForceRemoveDirty,
 		ForceOverwrite:         opts.ForceOverwrite,
 		ForceBroken:            opts.ForceBroken,
@@ -1,17 +1,23 @@
 package commands
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"path/filepath"
 	"strings"
 	"sync"
 
 	"runtime"
 
 	"github.com/leopardxu/repo-go/internal/config"
+	"github.com/leopardxu/repo-go/internal/git"
 	"github.com/leopardxu/repo-go/internal/logger"
 	"github.com/leopardxu/repo-go/internal/manifest"
+	"github.com/leopardxu/repo-go/internal/progress"
 	"github.com/leopardxu/repo-go/internal/project"
+	"github.com/leopardxu/repo-go/internal/repo_sync"
+	"github.com/leopardxu/repo-go/internal/review"
 	"github.com/spf13/cobra"
 )
 
@@ -45,6 +51,7 @@ type UploadOptions struct {
 	OuterManifest    bool
 	NoOuterManifest  bool
 	ThisManifestOnly bool
+	AtomicTopic      string // 原子主题：所有项目检查通过后以同一主题推送
 	// 添加配置字段，避免重复加载
 	Config *config.Config
 }
@@ -74,23 +81,47 @@ func UploadCmd() *cobra.Command {
 	opts := &UploadOptions{}
 
 	cmd := &cobra.Command{
-		Use:   "upload [--re --cc] [<project>...]",
-		Short: "Upload changes for code review",
-		Long: `Upload changes to Gerrit code review system.
+		Use:               "upload [--re --cc] [<project>...]",
+		Short:             "Upload changes for code review",
+		ValidArgsFunction: completeProjects,
+		Long: `Upload changes for code review.
 
 By default, changes are uploaded as WIP (Work In Progress) status.
 The upload pushes to refs/for/<branch> for Gerrit review, not directly to the branch.
 
+The review backend is chosen per remote: the review-type attribute of
+<remote> (gerrit, gitlab or github) wins, otherwise a review url on a
+gitlab or github host selects that backend, and Gerrit is the default.
+GitLab and GitHub backends push the topic (or local) branch and open or
+update a merge/pull request using GITLAB_TOKEN or GITHUB_TOKEN. The target
+branch is the dest-branch, otherwise the manifest revision; a branch is
+never uploaded into itself, and only --topic branches are force-pushed.
+--draft and --wip create drafts, --label and --hashtag become labels and
+--reviewers requests reviews. --private and --cc only apply to Gerrit.
+
+After a successful push the uploaded commit is recorded in
+refs/published/<branch>; repo sync does not rebase those commits until
+they are merged, and repo branches and repo prune report the branch as
+published.
+
 Use --draft for draft changes or --private for private changes.
-Specify reviewers with -r and CC with --cc.`,
+Specify reviewers with -r and CC with --cc.
+
+Use --atomic-topic <name> for changes that span several projects: every
+project is checked first (Change-Id present, destination branch resolvable,
+not behind upstream) and only then are all of them pushed with the same topic.
+If a push fails, the changes that already landed are listed so they can be
+abandoned.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runUpload(opts, args)
+			return runUpload(cmd.Context(), opts, args)
 		},
 	}
 
 	// 添加命令行选项
 	cmd.Flags().StringVarP(&opts.Branch, "branch", "b", "", "上传指定分支")
+	cmd.RegisterFlagCompletionFunc("branch", completeBranches)
 	cmd.Flags().BoolVarP(&opts.CurrentBranch, "current-branch", "c", false, "仅上传当前分支")
+	cmd.Flags().BoolVar(&opts.CurrentBranch, "cbr", false, "--current-branch 的简写")
 	cmd.Flags().BoolVarP(&opts.Draft, "draft", "d", false, "上传为草稿状态（覆盖默认的 WIP 状态）")
 	cmd.Flags().BoolVarP(&opts.Force, "force", "f", false, "强制上传，即使没有变更")
 	cmd.Flags().BoolVarP(&opts.DryRun, "dry-run", "n", false, "不实际上传，仅显示将要上传的内容")
@@ -117,12 +148,13 @@ Specify reviewers with -r and CC with --cc.`,
 	cmd.Flags().BoolVar(&opts.OuterManifest, "outer-manifest", false, "从最外层清单开始操作")
 	cmd.Flags().BoolVar(&opts.NoOuterManifest, "no-outer-manifest", false, "不操作外层清单")
 	cmd.Flags().BoolVar(&opts.ThisManifestOnly, "this-manifest-only", false, "仅操作此（子）清单")
+	cmd.Flags().StringVar(&opts.AtomicTopic, "atomic-topic", "", "先检查所有项目，再以同一主题推送全部变更")
 
 	return cmd
 }
 
 // runUpload 执行upload命令
-func runUpload(opts *UploadOptions, args []string) error {
+func runUpload(ctx context.Context, opts *UploadOptions, args []string) error {
 	// 创建日志记录
 	log := logger.NewDefaultLogger()
 	if opts.Verbose {
@@ -151,6 +183,9 @@ func runUpload(opts *UploadOptions, args []string) error {
 			return fmt.Errorf("加载配置失败: %w", err)
 		}
 	}
+	if err := requireGitClient(opts.Config, "upload"); err != nil {
+		return err
+	}
 
 	// 加载清单
 	parser := manifest.NewParser()
@@ -185,23 +220,13 @@ func runUpload(opts *UploadOptions, args []string) error {
 
 	log.Info("共有 %d 个项目需要处理", len(projects))
 
-	// 创建manifest项目名到项目的映射，用于访问dest-branch等信息
-	type ManifestProjectInfo struct {
-		DestBranch string
-		Upstream   string
-	}
-	manifestProjectInfo := make(map[string]ManifestProjectInfo)
-	for _, proj := range manifest.Projects {
-		manifestProjectInfo[proj.Name] = ManifestProjectInfo{
-			DestBranch: proj.DestBranch,
-			Upstream:   proj.Upstream,
-		}
+	if opts.Jobs < 1 {
+		opts.Jobs = 1
 	}
 
-	// 默认为 WIP 状态，除非用户明确指定其他选项
-	if !opts.Draft && !opts.Wip && !opts.Private {
-		opts.Wip = true
-		log.Debug("默认启用 WIP 状态")
+	// 原子主题上传：先检查所有项目，再统一推送
+	if opts.AtomicTopic != "" {
+		return runAtomicTopicUpload(ctx, opts, manifest, projects, log)
 	}
 
 	// 创建统计对象
@@ -214,6 +239,12 @@ func runUpload(opts *UploadOptions, args []string) error {
 
 	log.Info("开始并行处理项目，并发 %d", opts.Jobs)
 
+	var pm progress.Reporter
+	if !opts.Quiet {
+		pm = progress.NewConsoleReporterWithTitle("Uploading")
+		pm.Start(len(projects))
+	}
+
 	// 并发上传每个项目
 	for _, p := range projects {
 		p := p
@@ -223,210 +254,494 @@ func runUpload(opts *UploadOptions, args []string) error {
 			sem <- struct{}{}
 			defer func() { <-sem }()
 
-			log.Debug("处理项目: %s", p.Name)
-
-			// 确定目标分支（dest-branch）
-			destBranch := opts.Destination
-			if destBranch == "" && opts.Branch == "" {
-				// 如果没有指定，从 manifest 获取
-				if projInfo, exists := manifestProjectInfo[p.Name]; exists && projInfo.DestBranch != "" {
-					destBranch = projInfo.DestBranch
-				} else if manifest.Default.DestBranch != "" {
-					// 否则使用 default 的 dest-branch
-					destBranch = manifest.Default.DestBranch
-				}
+			ok := true
+			if pm != nil {
+				pm.StartTask(p.Name)
+				defer func() { pm.FinishTask(p.Name, ok) }()
 			}
 
-			log.Debug("项目 %s 的目标分支: %s", p.Name, destBranch)
-
-			// 如果指定-current-branch，检查当前分
-			if opts.CurrentBranch {
-				currentBranch, err := p.GitRepo.CurrentBranch()
-				if err != nil {
-					errMsg := fmt.Sprintf("获取项目 %s 的当前分支失 %v", p.Name, err)
-					log.Error(errMsg)
-					errChan <- fmt.Errorf(errMsg)
-					stats.increment(false)
-					return
-				}
-
-				// 如果当前分支是清单中指定的分支，跳过
-				if currentBranch == p.Revision {
-					log.Info("跳过项目 %s (当前分支是清单分", p.Name)
-					stats.increment(true) // 视为成功，因为这是预期行
-					return
-				}
-			}
-
-			// 获取项目的远程名称
-			remoteName := p.RemoteName
-			if remoteName == "" {
-				remoteName = "origin" // 默认值
-			}
-			log.Debug("项目 %s 的远程名称: %s", p.Name, remoteName)
-
-			// 检查是否有更改
-			hasChanges, err := p.GitRepo.HasChangesToPush(remoteName)
-			if err != nil {
-				errMsg := fmt.Sprintf("检查项目 %s 是否有变更失败: %v", p.Name, err)
-				log.Error(errMsg)
-				log.Debug("请确保项目已正确配置远程仓库，并且当前在有效的分支上")
-				errChan <- fmt.Errorf(errMsg)
+			if err := uploadProject(ctx, opts, manifest, p, log); err != nil {
+				errChan <- err
 				stats.increment(false)
+				ok = false
 				return
 			}
+			// 没有变更而跳过的项目也视为成功，这是预期行为
+			stats.increment(true)
+		}()
+	}
 
-			if !hasChanges && !opts.Force {
-				log.Info("跳过项目 %s (没有变更需要上", p.Name)
-				stats.increment(true) // 视为成功，因为这是预期行
-				return
-			}
+	// 等待所有goroutine完成
+	log.Debug("等待所有上传任务完成")
+	wg.Wait()
+	if pm != nil {
+		pm.Finish()
+	}
+	close(errChan)
 
-			// 获取当前分支
-			currentBranch, err := p.GitRepo.CurrentBranch()
-			if err != nil {
-				errMsg := fmt.Sprintf("获取项目 %s 的当前分支失败: %v", p.Name, err)
-				log.Error(errMsg)
-				errChan <- fmt.Errorf(errMsg)
-				stats.increment(false)
-				return
-			}
+	// 收集错误
+	var errs []error
+	for err := range errChan {
+		errs = append(errs, err)
+	}
 
-			// 确定目标分支（用于 Gerrit 审查）
-			targetBranch := destBranch
-			if targetBranch == "" {
-				// 如果没有指定目标分支，使用当前分支
-				targetBranch = currentBranch
-			}
+	// 输出统计信息
+	log.Info("上传完成，总计: %d, 成功: %d, 失败: %d", stats.total, stats.success, stats.failed)
 
-			// 构建 Gerrit 推送引用: refs/for/<branch>
-			gerritRef := fmt.Sprintf("refs/for/%s", targetBranch)
+	if len(errs) > 0 {
+		log.Error("上传过程中遇到 %d 个错误", len(errs))
+		return errors.Join(errs...)
+	}
 
-			// 构建推送命令参数
-			pushArgs := []string{"push"}
+	log.Info("所有项目上传成功完成")
+	return nil
+}
 
-			// 添加其他选项
-			if opts.NoVerify {
-				pushArgs = append(pushArgs, "--no-verify")
-			}
+// uploadProject 上传单个项目的当前分支，没有需要上传的变更时跳过
+func uploadProject(ctx context.Context, opts *UploadOptions, m *manifest.Manifest, p *project.Project, log logger.Logger) error {
+	log.Debug("处理项目: %s", p.Name)
 
-			// 构建 Gerrit push options（使用 % 分隔符附加到 refspec）
-			var gerritOptions []string
+	// 获取当前分支
+	currentBranch, err := p.GitRepo.CurrentBranch()
+	if err != nil {
+		log.Error("获取项目 %s 的当前分支失败: %v", p.Name, err)
+		return fmt.Errorf("获取项目 %s 的当前分支失败: %w", p.Name, err)
+	}
 
-			// WIP 状态
-			if opts.Wip {
-				gerritOptions = append(gerritOptions, "wip")
-			}
+	// 如果指定 --current-branch 且当前分支是清单中指定的分支，跳过
+	if opts.CurrentBranch && currentBranch == p.Revision {
+		log.Info("跳过项目 %s (当前分支是清单分支)", p.Name)
+		return nil
+	}
 
-			// Draft 状态
-			if opts.Draft {
-				gerritOptions = append(gerritOptions, "draft")
-			}
+	// 获取项目的远程名称
+	remoteName := p.RemoteName
+	if remoteName == "" {
+		remoteName = "origin" // 默认值
+	}
+	log.Debug("项目 %s 的远程名称: %s", p.Name, remoteName)
 
-			// Private 状态
-			if opts.Private {
-				gerritOptions = append(gerritOptions, "private")
-			}
+	// 检查是否有更改
+	hasChanges, err := p.GitRepo.HasChangesToPush(remoteName)
+	if err != nil {
+		log.Error("检查项目 %s 是否有变更失败: %v", p.Name, err)
+		log.Debug("请确保项目已正确配置远程仓库，并且当前在有效的分支上")
+		return fmt.Errorf("检查项目 %s 是否有变更失败: %w", p.Name, err)
+	}
+	if !hasChanges && !opts.Force {
+		log.Info("跳过项目 %s (没有变更需要上传)", p.Name)
+		return nil
+	}
 
-			// Topic
-			if opts.Topic != "" {
-				gerritOptions = append(gerritOptions, "topic="+opts.Topic)
-			}
+	// 确定目标分支
+	targetBranch := resolveUploadDestBranch(opts, m, p, currentBranch)
+	log.Debug("项目 %s 的目标分支: %s", p.Name, targetBranch)
 
-			// Hashtags
-			if opts.Hashtags != "" {
-				for _, tag := range strings.Split(opts.Hashtags, ",") {
-					gerritOptions = append(gerritOptions, "hashtag="+strings.TrimSpace(tag))
-				}
-			}
+	remote := findManifestRemote(m, remoteName)
+	if err := checkAutoUpload(opts.Config, remote); err != nil {
+		log.Error("项目 %s: %v", p.Name, err)
+		return fmt.Errorf("项目 %s: %w", p.Name, err)
+	}
+	backend, err := review.NewBackend(remote, review.Options{NoCertChecks: opts.NoCertChecks})
+	if err != nil {
+		log.Error("项目 %s: %v", p.Name, err)
+		return fmt.Errorf("项目 %s: %w", p.Name, err)
+	}
+	req := newUploadReviewRequest(opts, p, backend, remoteName, currentBranch, targetBranch, opts.Topic)
+	if err := review.Validate(backend, req); err != nil {
+		log.Error("项目 %s: %v", p.Name, err)
+		return fmt.Errorf("项目 %s: %w", p.Name, err)
+	}
+	pushArgs := backend.PushArgs(req)
 
-			// Labels
-			if opts.Labels != "" {
-				for _, label := range strings.Split(opts.Labels, ",") {
-					gerritOptions = append(gerritOptions, "label="+strings.TrimSpace(label))
-				}
-			}
+	log.Info("正在上传项目 %s 的变更到 %s 审查系统 (%s -> %s)", p.Name, backend.Type(), remoteName, targetBranch)
+	if req.Wip {
+		log.Info("将创建 WIP (进行中) 状态的审查")
+	}
 
-			// Reviewers
-			if opts.Reviewers != "" {
-				for _, reviewer := range strings.Split(opts.Reviewers, ",") {
-					gerritOptions = append(gerritOptions, "r="+strings.TrimSpace(reviewer))
-				}
-			}
+	// 如果是模拟运行，不实际上传
+	if opts.DryRun {
+		log.Info("模拟运行: 将上传项目 %s 的变更，命令: git %s", p.Name, strings.Join(pushArgs, " "))
+		return nil
+	}
 
-			// CC
-			if opts.CC != "" {
-				for _, cc := range strings.Split(opts.CC, ",") {
-					gerritOptions = append(gerritOptions, "cc="+strings.TrimSpace(cc))
-				}
-			}
+	// 执行上传命令
+	head, err := p.GitRepo.RevParse("HEAD")
+	if err != nil {
+		log.Error("解析项目 %s 的 HEAD 失败: %v", p.Name, err)
+		return fmt.Errorf("解析项目 %s 的 HEAD 失败: %w", p.Name, err)
+	}
+	outputBytes, err := p.GitRepo.RunCommandContext(ctx, pushArgs...)
+	if err != nil {
+		log.Error("上传项目 %s 的变更失败: %v\n%s", p.Name, err, string(outputBytes))
+		return fmt.Errorf("上传项目 %s 的变更失败: %w\n%s", p.Name, err, string(outputBytes))
+	}
 
-			// 自定义 push-option
-			if opts.PushOption != "" {
-				gerritOptions = append(gerritOptions, opts.PushOption)
-			}
+	log.Info("成功上传项目 %s 的变更", p.Name)
+	output := strings.TrimSpace(string(outputBytes))
+	if output != "" {
+		log.Info("上传输出:\n%s", output)
+	}
+	recordPublished(ctx, p, currentBranch, head, log)
 
-			// 构建完整的 refspec，将选项附加到 refs 后面
-			// 格式: HEAD:refs/for/<branch>%option1%option2%option3
-			refspec := fmt.Sprintf("HEAD:%s", gerritRef)
-			if len(gerritOptions) > 0 {
-				refspec = refspec + "%" + strings.Join(gerritOptions, ",")
-			}
+	return publishReview(ctx, backend, req, p, log)
+}
 
-			// 添加远程和引用
-			// 格式: git push <remote> HEAD:refs/for/<branch>%wip%topic=xxx
-			pushArgs = append(pushArgs, remoteName, refspec)
+// recordPublished 推送成功后把上传的提交记录到 refs/published/<branch>，
+// 同步时不会变基改写这些提交，repo branches 和 repo prune 据此判断分支是否已上传
+func recordPublished(ctx context.Context, p *project.Project, branch, commit string, log logger.Logger) {
+	if err := p.GitRepo.SetPublished(ctx, branch, commit); err != nil {
+		log.Warn("项目 %s: 记录分支 %s 的上传状态失败: %v", p.Name, branch, err)
+	}
+}
 
-			log.Info("正在上传项目 %s 的变更到 Gerrit 审查系统 (%s -> %s)", p.Name, remoteName, gerritRef)
-			if opts.Wip {
-				log.Info("将创建 WIP (进行中) 状态的审查")
-			}
+// findManifestProject 在清单中查找项目定义
+// 同一名称的项目可以检出到多个路径，按名称和路径一起匹配
+func findManifestProject(m *manifest.Manifest, p *project.Project) *manifest.Project {
+	path := projectRelPath(p)
+	for i := range m.Projects {
+		mp := &m.Projects[i]
+		mpPath := mp.Path
+		if mpPath == "" {
+			mpPath = mp.Name
+		}
+		if mp.Name == p.Name && filepath.ToSlash(filepath.Clean(mpPath)) == path {
+			return mp
+		}
+	}
+	return nil
+}
 
-			// 如果是模拟运行，不实际上传
-			if opts.DryRun {
-				log.Info("模拟运行: 将上传项目 %s 的变更，命令: git %s", p.Name, strings.Join(pushArgs, " "))
-				stats.increment(true)
-				return
-			}
+// resolveUploadDestBranch 确定项目上传审查的目标分支
+// 优先级: --destination > 项目 dest-branch > default dest-branch > 清单修订版本（非不可变时）> 当前分支，
+// 指定 --branch 时不使用清单中的 dest-branch
+func resolveUploadDestBranch(opts *UploadOptions, m *manifest.Manifest, p *project.Project, currentBranch string) string {
+	if dest := manifestUploadDestBranch(opts, m, p); dest != "" {
+		return dest
+	}
+	if p.Revision != "" && !git.IsImmutable(p.Revision) {
+		return strings.TrimPrefix(p.Revision, "refs/heads/")
+	}
+	return currentBranch
+}
 
-			// 执行上传命令
-			outputBytes, err := p.GitRepo.RunCommand(pushArgs...)
-			if err != nil {
-				errMsg := fmt.Sprintf("上传项目 %s 的变更失败: %v\n%s", p.Name, err, string(outputBytes))
-				log.Error(errMsg)
-				errChan <- fmt.Errorf(errMsg)
-				stats.increment(false)
-				return
-			}
+// manifestUploadDestBranch 返回 --destination 或清单中的 dest-branch，都没有时返回空字符串
+func manifestUploadDestBranch(opts *UploadOptions, m *manifest.Manifest, p *project.Project) string {
+	if opts.Destination != "" {
+		return opts.Destination
+	}
+	if opts.Branch != "" {
+		return ""
+	}
+	if mp := findManifestProject(m, p); mp != nil && mp.DestBranch != "" {
+		return mp.DestBranch
+	}
+	return m.Default.DestBranch
+}
 
-			log.Info("成功上传项目 %s 的变更", p.Name)
-			output := strings.TrimSpace(string(outputBytes))
-			if output != "" {
-				log.Info("上传输出:\n%s", output)
-			}
-			stats.increment(true)
-		}()
+// findManifestRemote 在清单中按名称查找远程定义
+func findManifestRemote(m *manifest.Manifest, name string) *manifest.Remote {
+	for i := range m.Remotes {
+		if m.Remotes[i].Name == name {
+			return &m.Remotes[i]
+		}
 	}
+	return nil
+}
 
-	// 等待所有goroutine完成
-	log.Debug("等待所有上传任务完成")
-	wg.Wait()
-	close(errChan)
+// checkAutoUpload 检查 review.<url>.autoupload 设置，设置为 false 时不允许上传到该评审服务器
+func checkAutoUpload(cfg *config.Config, remote *manifest.Remote) error {
+	if cfg == nil || remote == nil || remote.Review == "" {
+		return nil
+	}
+	key := "review." + remote.Review + ".autoupload"
+	if allowed, ok := cfg.Settings().GetBool(key); ok && !allowed {
+		return fmt.Errorf("upload to %s is disabled by %s=false", remote.Review, key)
+	}
+	return nil
+}
 
-	// 收集错误
-	var errs []error
-	for err := range errChan {
-		errs = append(errs, err)
+// newUploadReviewRequest 根据命令行选项构建评审请求
+func newUploadReviewRequest(opts *UploadOptions, p *project.Project, backend review.Backend, remoteName, branch, destBranch, topic string) *review.Request {
+	req := &review.Request{
+		Project:    p.Name,
+		Remote:     remoteName,
+		Branch:     branch,
+		DestBranch: destBranch,
+		Topic:      topic,
+		Reviewers:  splitUploadList(opts.Reviewers),
+		CC:         splitUploadList(opts.CC),
+		Labels:     splitUploadList(opts.Labels),
+		Hashtags:   splitUploadList(opts.Hashtags),
+		Draft:      opts.Draft,
+		Wip:        opts.Wip,
+		Private:    opts.Private,
+		NoVerify:   opts.NoVerify,
+	}
+	// Gerrit 默认为 WIP 状态，除非用户明确指定其他选项；merge/pull request 默认不是草稿
+	if backend.Type() == review.TypeGerrit && !opts.Draft && !opts.Wip && !opts.Private {
+		req.Wip = true
+	}
+	if opts.HashtagBranch && branch != "" {
+		req.Hashtags = append(req.Hashtags, branch)
+	}
+	if opts.PushOption != "" {
+		req.PushOptions = []string{opts.PushOption}
 	}
 
-	// 输出统计信息
-	log.Info("上传完成，总计: %d, 成功: %d, 失败: %d", stats.total, stats.success, stats.failed)
+	// merge/pull request 需要标题和描述，取自HEAD提交
+	if backend.Type() != review.TypeGerrit {
+		if message, err := p.GitRepo.CommitMessage("HEAD"); err == nil {
+			subject, body, _ := strings.Cut(strings.TrimSpace(message), "\n")
+			req.Title = subject
+			req.Description = strings.TrimSpace(body)
+		}
+	}
+	return req
+}
+
+// splitUploadList 拆分逗号分隔的选项值，忽略空项
+func splitUploadList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// publishReview 推送完成后由评审后端创建或更新评审
+func publishReview(ctx context.Context, backend review.Backend, req *review.Request, p *project.Project, log logger.Logger) error {
+	result, err := backend.Publish(ctx, req)
+	if err != nil {
+		log.Error("项目 %s 创建评审失败: %v", p.Name, err)
+		return fmt.Errorf("项目 %s 创建评审失败: %w", p.Name, err)
+	}
+	if result == nil {
+		return nil
+	}
+	if result.Created {
+		log.Info("项目 %s: 已创建评审 #%d %s", p.Name, result.Number, result.URL)
+	} else {
+		log.Info("项目 %s: 已更新评审 #%d %s", p.Name, result.Number, result.URL)
+	}
+	return nil
+}
+
+// atomicUploadCandidate 表示原子主题上传中通过检查、等待推送的项目
+type atomicUploadCandidate struct {
+	project    *project.Project
+	remoteName string
+	branch     string
+	destBranch string
+	backend    review.Backend
+	head       string // 推送的提交
+	commits    []string
+	changeIDs  []string
+}
+
+// checkAtomicUploadProject 检查项目是否满足原子主题上传的条件
+// 没有需要上传的提交时返回 nil, nil
+func checkAtomicUploadProject(opts *UploadOptions, m *manifest.Manifest, p *project.Project) (*atomicUploadCandidate, error) {
+	remoteName := p.RemoteName
+	if remoteName == "" {
+		remoteName = "origin"
+	}
+
+	currentBranch, err := p.GitRepo.CurrentBranch()
+	if err != nil {
+		return nil, fmt.Errorf("获取当前分支失败: %w", err)
+	}
+	if strings.HasPrefix(currentBranch, "HEAD detached at ") {
+		return nil, fmt.Errorf("处于分离HEAD状态，请先使用 repo start 创建主题分支")
+	}
+
+	// 与普通上传一样，--cbr 时跳过当前分支就是清单分支的项目
+	if opts.CurrentBranch && currentBranch == p.Revision {
+		return nil, nil
+	}
+
+	destBranch := resolveUploadDestBranch(opts, m, p, currentBranch)
+	upstreamRef := fmt.Sprintf("refs/remotes/%s/%s", remoteName, destBranch)
+	if ok, _ := p.GitRepo.HasRevision(upstreamRef); !ok {
+		return nil, fmt.Errorf("无法解析目标分支 %s/%s，请先执行 repo sync", remoteName, destBranch)
+	}
+
+	commits, err := p.GitRepo.CommitsBetween(upstreamRef, "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	if len(commits) == 0 {
+		return nil, nil
+	}
+	head, err := p.GitRepo.RevParse("HEAD")
+	if err != nil {
+		return nil, err
+	}
+
+	behind, err := p.GitRepo.CommitsBetween("HEAD", upstreamRef)
+	if err != nil {
+		return nil, err
+	}
+	if len(behind) > 0 {
+		return nil, fmt.Errorf("落后于 %s/%s %d 个提交，请先执行 repo rebase", remoteName, destBranch, len(behind))
+	}
+
+	remote := findManifestRemote(m, remoteName)
+	if err := checkAutoUpload(opts.Config, remote); err != nil {
+		return nil, err
+	}
+	backend, err := review.NewBackend(remote, review.Options{NoCertChecks: opts.NoCertChecks})
+	if err != nil {
+		return nil, err
+	}
+	req := &review.Request{Project: p.Name, Branch: currentBranch, DestBranch: destBranch, Topic: opts.AtomicTopic}
+	if err := review.Validate(backend, req); err != nil {
+		return nil, err
+	}
+
+	candidate := &atomicUploadCandidate{
+		project:    p,
+		remoteName: remoteName,
+		branch:     currentBranch,
+		destBranch: destBranch,
+		backend:    backend,
+		head:       head,
+		commits:    commits,
+	}
+	for _, commit := range commits {
+		message, err := p.GitRepo.CommitMessage(commit)
+		if err != nil {
+			return nil, err
+		}
+		// 只有 Gerrit 需要 Change-Id 关联变更，merge/pull request 按分支关联
+		changeID := git.ParseChangeID(message)
+		if changeID == "" && backend.Type() == review.TypeGerrit {
+			return nil, fmt.Errorf("提交 %s 缺少 Change-Id", shortCommit(commit))
+		}
+		candidate.changeIDs = append(candidate.changeIDs, changeID)
+	}
+
+	return candidate, nil
+}
+
+// runAtomicTopicUpload 以原子主题方式上传跨项目变更
+// 所有项目检查通过后才会推送；推送失败时列出已经落到 Gerrit 上的变更
+func runAtomicTopicUpload(ctx context.Context, opts *UploadOptions, m *manifest.Manifest, projects []*project.Project, log logger.Logger) error {
+	log.Info("原子主题 %s: 正在检查 %d 个项目", opts.AtomicTopic, len(projects))
+
+	// 第一阶段：并发检查，结果按清单顺序保存
+	candidates := make([]*atomicUploadCandidate, len(projects))
+	checkErrs := make([]error, len(projects))
+	sem := make(chan struct{}, opts.Jobs)
+	var wg sync.WaitGroup
+	for i, p := range projects {
+		wg.Add(1)
+		go func(i int, p *project.Project) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			candidates[i], checkErrs[i] = checkAtomicUploadProject(opts, m, p)
+		}(i, p)
+	}
+	wg.Wait()
 
+	var errs []error
+	for i, err := range checkErrs {
+		if err != nil {
+			log.Error("项目 %s 未通过检查: %v", projects[i].Name, err)
+			errs = append(errs, fmt.Errorf("项目 %s: %w", projects[i].Name, err))
+		}
+	}
 	if len(errs) > 0 {
-		log.Error("上传过程中遇到 %d 个错误", len(errs))
+		log.Error("原子主题 %s: %d 个项目未通过检查，未推送任何变更", opts.AtomicTopic, len(errs))
 		return errors.Join(errs...)
 	}
 
-	log.Info("所有项目上传成功完成")
+	var ready []*atomicUploadCandidate
+	for _, c := range candidates {
+		if c != nil {
+			ready = append(ready, c)
+		}
+	}
+	if len(ready) == 0 {
+		log.Info("原子主题 %s: 没有需要上传的变更", opts.AtomicTopic)
+		return nil
+	}
+
+	// 第二阶段：按清单顺序逐个推送，遇到失败立即停止
+	var landed []*atomicUploadCandidate
+	for i, c := range ready {
+		req := newUploadReviewRequest(opts, c.project, c.backend, c.remoteName, c.branch, c.destBranch, opts.AtomicTopic)
+		pushArgs := c.backend.PushArgs(req)
+		if opts.DryRun {
+			log.Info("模拟运行: 项目 %s (%d 个提交)，命令: git %s", c.project.Name, len(c.commits), strings.Join(pushArgs, " "))
+			continue
+		}
+
+		log.Info("原子主题 %s: 推送项目 %s (%d 个提交) -> %s %s", opts.AtomicTopic, c.project.Name, len(c.commits), c.backend.Type(), c.destBranch)
+		err := repo_sync.RetryWithBackoff(ctx, repo_sync.DefaultRetryOptions(), func(attempt int) error {
+			if attempt > 0 {
+				log.Warn("重试推送项目 %s (第 %d 次)", c.project.Name, attempt)
+			}
+			_, err := c.project.GitRepo.RunCommandContext(ctx, pushArgs...)
+			return withGitStderr(err)
+		})
+		if err != nil {
+			log.Error("推送项目 %s 失败: %v", c.project.Name, err)
+			reportAtomicUploadFailure(opts.AtomicTopic, landed, ready[i+1:], log)
+			return fmt.Errorf("原子主题 %s 上传失败，项目 %s: %w", opts.AtomicTopic, c.project.Name, err)
+		}
+		landed = append(landed, c)
+		recordPublished(ctx, c.project, c.branch, c.head, log)
+
+		if err := publishReview(ctx, c.backend, req, c.project, log); err != nil {
+			reportAtomicUploadFailure(opts.AtomicTopic, landed, ready[i+1:], log)
+			return fmt.Errorf("原子主题 %s 上传失败: %w", opts.AtomicTopic, err)
+		}
+	}
+
+	log.Info("原子主题 %s: 已上传 %d 个项目的变更", opts.AtomicTopic, len(ready))
 	return nil
 }
+
+// reportAtomicUploadFailure 列出原子主题上传失败时已经推送和尚未推送的变更
+func reportAtomicUploadFailure(topic string, landed, pending []*atomicUploadCandidate, log logger.Logger) {
+	if len(landed) == 0 {
+		log.Error("原子主题 %s: 没有变更被推送到服务器", topic)
+	} else {
+		log.Error("原子主题 %s: 以下变更已经推送到服务器，如需回滚请手动放弃 (abandon):", topic)
+		for _, c := range landed {
+			for j, commit := range c.commits {
+				log.Error("  %s (%s): %s %s", c.project.Name, c.destBranch, shortCommit(commit), c.changeIDs[j])
+			}
+		}
+	}
+	if len(pending) > 0 {
+		log.Error("原子主题 %s: 以下项目尚未推送:", topic)
+		for _, c := range pending {
+			log.Error("  %s (%s)", c.project.Name, c.destBranch)
+		}
+	}
+}
+
+// withGitStderr 将 git 命令的标准错误附加到错误信息中，便于判断是否可重试
+func withGitStderr(err error) error {
+	if err == nil {
+		return nil
+	}
+	var gitErr *git.GitCommandError
+	if errors.As(err, &gitErr) && strings.TrimSpace(gitErr.Stderr) != "" {
+		return fmt.Errorf("%w\n%s", err, strings.TrimSpace(gitErr.Stderr))
+	}
+	return err
+}
+
+// shortCommit 返回提交哈希的短格式
+func shortCommit(commit string) string {
+	if len(commit) > 12 {
+		return commit[:12]
+	}
+	return commit
+}
@@ -0,0 +1,224 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/leopardxu/repo-go/internal/logger"
+	"github.com/leopardxu/repo-go/internal/manifest"
+	"github.com/leopardxu/repo-go/internal/project"
+	"github.com/leopardxu/repo-go/internal/review"
+	"github.com/leopardxu/repo-go/internal/testutil"
+)
+
+// recordLogger 记录错误和警告日志，用于检查输出的报告
+type recordLogger struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (l *recordLogger) record(format string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lines = append(l.lines, fmt.Sprintf(format, args...))
+}
+
+func (l *recordLogger) Error(format string, args ...interface{}) { l.record(format, args...) }
+func (l *recordLogger) Warn(format string, args ...interface{})  { l.record(format, args...) }
+func (l *recordLogger) Info(format string, args ...interface{})  {}
+func (l *recordLogger) Debug(format string, args ...interface{}) {}
+func (l *recordLogger) Trace(format string, args ...interface{}) {}
+func (l *recordLogger) SetLevel(level logger.LogLevel)           {}
+
+// newUploadFixture 创建一个在主题分支 topic 上有一个带 Change-Id 的提交的项目，
+// 远程 origin 指向仓库自身，推送到 refs/for/main 会在仓库中创建对应的引用
+func newUploadFixture(t *testing.T, name string) *project.Project {
+	t.Helper()
+	dir := testutil.NewRepo(t)
+	testutil.Git(t, dir, "checkout", "--quiet", "-b", "topic", "--track", "origin/main")
+	testutil.Commit(t, dir, name, name+"\n", fmt.Sprintf("change %s\n\nChange-Id: I%040d", name, len(name)))
+	p := testutil.NewProject(dir, name)
+	p.GitRepo.Runner.SetMaxRetries(0)
+	return p
+}
+
+// uploadedRefs 返回推送到项目的 refs/for/ 引用
+func uploadedRefs(t *testing.T, p *project.Project) string {
+	return testutil.Git(t, p.Worktree, "for-each-ref", "--format=%(refname)", "refs/for/")
+}
+
+// uploadCurrentBranch 像 repo upload 一样把 dir 的当前分支上传到 Gerrit 的 refs/for/main（这里是仓库自身）
+// 每次使用新的项目，避免仓库缓存切换前的当前分支
+func uploadCurrentBranch(t *testing.T, dir string) {
+	t.Helper()
+	p := testutil.NewProject(dir, "p")
+	// Gerrit 每次推送到 refs/for/main 都创建新的变更，这里先删除上次推送的引用
+	for _, ref := range strings.Fields(uploadedRefs(t, p)) {
+		testutil.Git(t, dir, "update-ref", "-d", ref)
+	}
+	if err := uploadProject(context.Background(), &UploadOptions{}, &manifest.Manifest{}, p, &recordLogger{}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAtomicTopicUploadFailure(t *testing.T) {
+	a := newUploadFixture(t, "a")
+	b := newUploadFixture(t, "b")
+	c := newUploadFixture(t, "c")
+	// b 的推送失败：远程不存在
+	testutil.Git(t, b.Worktree, "remote", "set-url", "origin", filepath.Join(t.TempDir(), "missing"))
+
+	log := &recordLogger{}
+	opts := &UploadOptions{AtomicTopic: "feature", Jobs: 2}
+	err := runAtomicTopicUpload(context.Background(), opts, &manifest.Manifest{}, []*project.Project{a, b, c}, log)
+	if err == nil || !strings.Contains(err.Error(), "项目 b") {
+		t.Fatalf("runAtomicTopicUpload() error = %v, want a push failure of b", err)
+	}
+
+	if uploadedRefs(t, a) == "" {
+		t.Error("a was checked and should have been pushed before b failed")
+	}
+	if refs := uploadedRefs(t, c); refs != "" {
+		t.Errorf("c was pushed after b failed: %s", refs)
+	}
+	// 只有推送成功的 a 记录了上传状态
+	for p, want := range map[*project.Project]bool{a: true, b: false, c: false} {
+		if published, err := p.GitRepo.IsPublished("topic"); err != nil || published != want {
+			t.Errorf("%s IsPublished() = %v, %v, want %v", p.Name, published, err, want)
+		}
+	}
+
+	// 报告列出已推送的 a 的提交和 Change-Id，以及尚未推送的 c
+	report := strings.Join(log.lines, "\n")
+	landed, pending, ok := strings.Cut(report, "以下项目尚未推送")
+	if !ok {
+		t.Fatalf("report has no pending section:\n%s", report)
+	}
+	commit := testutil.Git(t, a.Worktree, "rev-parse", "HEAD")
+	for _, want := range []string{"以下变更已经推送到服务器", "a (main): " + shortCommit(commit), fmt.Sprintf("I%040d", 1)} {
+		if !strings.Contains(landed, want) {
+			t.Errorf("landed report missing %q:\n%s", want, landed)
+		}
+	}
+	if strings.Contains(landed, "  b (main)") || strings.Contains(landed, "  c (main)") {
+		t.Errorf("landed report lists projects that were not pushed:\n%s", landed)
+	}
+	if !strings.Contains(pending, "  c (main)") || strings.Contains(pending, "  a (main)") {
+		t.Errorf("pending report = %q, want only c", pending)
+	}
+}
+
+func TestAtomicTopicUploadCanceled(t *testing.T) {
+	a := newUploadFixture(t, "a")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	opts := &UploadOptions{AtomicTopic: "feature", Jobs: 1}
+	err := runAtomicTopicUpload(ctx, opts, &manifest.Manifest{}, []*project.Project{a}, &recordLogger{})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("runAtomicTopicUpload() error = %v, want context.Canceled", err)
+	}
+	if refs := uploadedRefs(t, a); refs != "" {
+		t.Errorf("a was pushed after cancellation: %s", refs)
+	}
+}
+
+func TestUploadDestBranch(t *testing.T) {
+	p := newUploadFixture(t, "p")
+	m := &manifest.Manifest{
+		Remotes: []manifest.Remote{{Name: "origin", Review: "https://gitlab.example.com", CustomAttrs: map[string]string{"review-type": "gitlab"}}},
+	}
+	backend, err := review.NewBackend(findManifestRemote(m, "origin"), review.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 没有 --topic 和 dest-branch 时，主题分支合入清单修订版本，推送不强制更新
+	dest := resolveUploadDestBranch(&UploadOptions{}, m, p, "topic")
+	if dest != "main" {
+		t.Fatalf("resolveUploadDestBranch() = %q, want the manifest revision main", dest)
+	}
+	req := newUploadReviewRequest(&UploadOptions{}, p, backend, "origin", "topic", dest, "")
+	if err := review.Validate(backend, req); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+	if got, want := backend.PushArgs(req), []string{"push", "origin", "HEAD:refs/heads/topic"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("PushArgs() = %v, want %v", got, want)
+	}
+	if req.Title != "change p" {
+		t.Errorf("request title = %q", req.Title)
+	}
+
+	// 在本地 main 上上传会把 main 推送到自身，拒绝
+	dest = resolveUploadDestBranch(&UploadOptions{}, m, p, "main")
+	req = newUploadReviewRequest(&UploadOptions{}, p, backend, "origin", "main", dest, "")
+	if err := review.Validate(backend, req); err == nil {
+		t.Errorf("Validate() accepted uploading main into %s", dest)
+	}
+
+	tests := []struct {
+		name     string
+		opts     UploadOptions
+		revision string
+		dest     string
+		want     string
+	}{
+		{"destination", UploadOptions{Destination: "release"}, "main", "stable", "release"},
+		{"dest-branch", UploadOptions{}, "main", "stable", "stable"},
+		{"--branch ignores dest-branch", UploadOptions{Branch: "topic"}, "refs/heads/main", "stable", "main"},
+		{"pinned revision", UploadOptions{}, "refs/tags/v1.0", "", "topic"},
+	}
+	for _, tt := range tests {
+		p.Revision = tt.revision
+		m.Default.DestBranch = tt.dest
+		if got := resolveUploadDestBranch(&tt.opts, m, p, "topic"); got != tt.want {
+			t.Errorf("%s: resolveUploadDestBranch() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestUploadProjectRecordsPublished(t *testing.T) {
+	p := newUploadFixture(t, "p")
+	head := testutil.Git(t, p.Worktree, "rev-parse", "HEAD")
+
+	if err := uploadProject(context.Background(), &UploadOptions{}, &manifest.Manifest{}, p, &recordLogger{}); err != nil {
+		t.Fatalf("uploadProject() error = %v", err)
+	}
+	if refs := uploadedRefs(t, p); !strings.HasPrefix(refs, "refs/for/main%") {
+		t.Errorf("pushed refs = %q, want refs/for/main", refs)
+	}
+	// 推送到 Gerrit 不产生远程跟踪分支，上传状态记录在 refs/published/<branch>
+	if refs := testutil.Git(t, p.Worktree, "for-each-ref", "--format=%(refname)", "refs/remotes/"); refs != "refs/remotes/origin/main" {
+		t.Errorf("remote-tracking refs = %q", refs)
+	}
+	if got := testutil.Git(t, p.Worktree, "rev-parse", "refs/published/topic"); got != head {
+		t.Errorf("refs/published/topic = %s, want %s", got, head)
+	}
+	if published, err := p.GitRepo.IsPublished("topic"); err != nil || !published {
+		t.Errorf("IsPublished() = %v, %v after upload", published, err)
+	}
+	if unpublished, err := p.GitRepo.UnpublishedCommits("topic", "origin"); err != nil || len(unpublished) != 0 {
+		t.Errorf("UnpublishedCommits() = %v, %v after upload", unpublished, err)
+	}
+
+	// 上传之后的新提交尚未上传
+	testutil.Commit(t, p.Worktree, "more", "more\n", "more")
+	if published, err := p.GitRepo.IsPublished("topic"); err != nil || published {
+		t.Errorf("IsPublished() = %v, %v with a new commit", published, err)
+	}
+
+	// 模拟运行不推送也不记录
+	testutil.Git(t, p.Worktree, "update-ref", "-d", "refs/published/topic")
+	if err := uploadProject(context.Background(), &UploadOptions{DryRun: true}, &manifest.Manifest{}, p, &recordLogger{}); err != nil {
+		t.Fatalf("uploadProject(--dry-run) error = %v", err)
+	}
+	if published, err := p.GitRepo.PublishedCommit("topic"); err != nil || published != "" {
+		t.Errorf("PublishedCommit() = %q, %v after --dry-run", published, err)
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/leopardxu/repo-go/cmd/repo/commands"
+	"github.com/leopardxu/repo-go/internal/config"
+	"github.com/leopardxu/repo-go/internal/external"
+	"github.com/leopardxu/repo-go/internal/logger"
+	"github.com/spf13/cobra"
+)
+
+// dispatchExternal 处理不是内置命令的子命令，顺序与 git 一致：
+// 先查找 PATH 中的 repo-<name>，再展开 alias.<name> 别名，别名可以指向内置命令、外部命令或其他别名
+// handled 为 true 时外部命令已经执行，exitCode 是它的退出码；否则交给 cobra 执行（别名展开后的）参数
+func dispatchExternal(root *cobra.Command, args []string, sess *session, log logger.Logger) (handled bool, exitCode int) {
+	i := commandIndex(root, args)
+	if i < 0 || builtinCommand(root, args[i]) {
+		return false, 0
+	}
+
+	clientRoot := findRepoRoot()
+	aliases := loadAliases(clientRoot, log)
+	seen := make(map[string]bool)
+	for {
+		name := args[i]
+		if builtinCommand(root, name) {
+			break
+		}
+		if path, err := external.Find(name); err == nil {
+			// 外部命令中的 git 同样复用 SSH 连接
+			sess.startSSHProxy()
+			code, err := commands.RunExternal(path, clientRoot, args[i+1:], log)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			}
+			return true, code
+		}
+
+		value, ok := aliases[name]
+		if !ok {
+			break
+		}
+		if seen[name] {
+			fmt.Fprintf(os.Stderr, "Error: alias loop detected: %q expands to itself\n", name)
+			return true, 1
+		}
+		seen[name] = true
+		expanded, err := external.SplitArgs(value)
+		if err == nil && len(expanded) == 0 {
+			err = fmt.Errorf("alias %q is empty", name)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: bad alias.%s: %v\n", name, err)
+			return true, 1
+		}
+		log.Debug("展开别名 %s: %s", name, value)
+		args = slices.Concat(args[:i], expanded, args[i+1:])
+	}
+
+	if len(seen) > 0 {
+		root.SetArgs(args)
+	}
+	return false, 0
+}
+
+// commandIndex 返回参数中子命令名称的位置，跳过全局选项及其值，没有子命令时返回 -1
+func commandIndex(root *cobra.Command, args []string) int {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			return -1
+		}
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			return i
+		}
+		if strings.HasPrefix(arg, "--") && !strings.Contains(arg, "=") {
+			if f := root.PersistentFlags().Lookup(arg[2:]); f != nil && f.Value.Type() != "bool" {
+				i++
+			}
+		}
+	}
+	return -1
+}
+
+// builtinCommand 判断 name 是否是内置命令，包括 cobra 自动添加的 help 和补全命令
+func builtinCommand(root *cobra.Command, name string) bool {
+	switch name {
+	case "help", "completion", cobra.ShellCompRequestCmd, cobra.ShellCompNoDescRequestCmd:
+		return true
+	}
+	for _, c := range root.Commands() {
+		if c.Name() == name || c.HasAlias(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// loadAliases 读取全局和客户端设置中的 alias.<name> 别名
+func loadAliases(clientRoot string, log logger.Logger) map[string]string {
+	settings, err := config.LoadSettings(clientRoot)
+	if err != nil {
+		log.Warn("加载设置失败，命令别名不可用: %v", err)
+	}
+	return settings.Aliases()
+}
+
+// helpCmd 返回 help 命令，除内置命令外还能显示别名的定义和外部命令的帮助
+func helpCmd(root *cobra.Command, log logger.Logger) *cobra.Command {
+	return &cobra.Command{
+		Use:   "help [command]",
+		Short: "Help about any command",
+		Long: `Help provides help for any command in the application.
+For an external command, runs repo-<command> --help.`,
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) > 0 {
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+			candidates, directive := completeExternal(log)(cmd, args, toComplete)
+			for _, c := range root.Commands() {
+				if c.IsAvailableCommand() && strings.HasPrefix(c.Name(), toComplete) {
+					candidates = append(candidates, c.Name()+"\t"+c.Short)
+				}
+			}
+			return candidates, directive
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			if len(args) == 0 {
+				root.Help()
+				return
+			}
+			if target, _, err := root.Find(args); err == nil && target != root {
+				target.Help()
+				return
+			}
+
+			name := args[0]
+			if path, err := external.Find(name); err == nil {
+				if _, err := commands.RunExternal(path, findRepoRoot(), []string{"--help"}, log); err != nil {
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				}
+				return
+			}
+			if value, ok := loadAliases(findRepoRoot(), log)[name]; ok {
+				fmt.Fprintf(cmd.OutOrStdout(), "'%s' is an alias for '%s'\n", name, value)
+				return
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Unknown help topic %q\n", args)
+			root.Usage()
+		},
+	}
+}
+
+// setupHelp 替换 help 命令，并在根命令的帮助中列出外部命令和别名
+func setupHelp(root *cobra.Command, log logger.Logger) {
+	root.SetHelpCommand(helpCmd(root, log))
+	defaultHelp := root.HelpFunc()
+	root.SetHelpFunc(func(cmd *cobra.Command, args []string) {
+		defaultHelp(cmd, args)
+		if cmd == root {
+			commands.WriteExternalHelp(cmd.OutOrStdout(), loadAliases(findRepoRoot(), log))
+		}
+	})
+}
+
+// completeExternal 补全外部命令和别名的名称，内置命令由 cobra 补全
+func completeExternal(log logger.Logger) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		var candidates []string
+		for _, c := range external.List() {
+			if strings.HasPrefix(c.Name, toComplete) {
+				candidates = append(candidates, c.Name+"\t"+c.Description)
+			}
+		}
+		for name, value := range loadAliases(findRepoRoot(), log) {
+			if strings.HasPrefix(name, toComplete) {
+				candidates = append(candidates, name+"\talias for '"+value+"'")
+			}
+		}
+		return candidates, cobra.ShellCompDirectiveNoFileComp
+	}
+}
@@ -1,8 +1,12 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 
 	"github.com/leopardxu/repo-go/cmd/repo/commands"
 	"github.com/leopardxu/repo-go/internal/logger"
@@ -26,6 +30,7 @@ func main() {
 		}
 	}
 	logger.SetGlobalLogger(log)
+	sess := newSession(log)
 
 	// 创建根命令
 	rootCmd := &cobra.Command{
@@ -47,13 +52,19 @@ Options:
   --event-log=EVENT_LOG filename of event log to append timeline to
   --git-trace2-event-log=GIT_TRACE2_EVENT_LOG directory to write git trace2 event log to
   --submanifest-path=REL_PATH submanifest path
+  --wait=DURATION       maximum time to wait for other repo commands to release
+                        the client lock, e.g. 30s (default: wait indefinitely)
+
+Commands that are not built in run the executable repo-<command> found on PATH,
+or expand the alias.<command> setting (see 'repo config'). External commands
+receive REPO_CLIENT_ROOT, REPO_MANIFEST and REPO_PROJECTS in the environment.
         `,
 		Version: fmt.Sprintf("%s (commit: %s, built at: %s)",
 			version, commit, date),
 	}
 
-	// 设置PersistentPreRun钩子函数处理全局标志
-	rootCmd.PersistentPreRun = func(cmd *cobra.Command, args []string) {
+	// 设置PersistentPreRunE钩子函数处理全局标志
+	rootCmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
 		// 处理--trace标志
 		trace, _ := cmd.Flags().GetBool("trace")
 		if trace {
@@ -73,19 +84,20 @@ Options:
 		}
 
 		// 处理--color标志
-		color, _ := cmd.Flags().GetBool("color")
-		if !color {
+		colorMode, _ := cmd.Flags().GetString("color")
+		switch strings.ToLower(colorMode) {
+		case "never":
 			// 禁用颜色输出
 			os.Setenv("NO_COLOR", "1")
+		case "always":
+			os.Unsetenv("NO_COLOR")
 		}
 
-		// 处理--git-trace2-event-log标志
-		eventLog, _ := cmd.Flags().GetString("git-trace2-event-log")
-		if eventLog != "" {
-			// 设置Git Trace2事件日志
-			os.Setenv("GIT_TRACE2_EVENT", eventLog)
-			log.Trace("Git Trace2事件日志已设置为: %s", eventLog)
-		}
+		// 处理--event-log、--time、--git-trace2-event-log和--paginate标志
+		sess.begin(cmd)
+
+		// 启动 SSH 连接复用，处理--wait标志并获取客户端锁，避免与其他 repo 命令同时修改客户端
+		return sess.prepare(cmd)
 	}
 
 	// 全局选项
@@ -99,6 +111,7 @@ Options:
 	rootCmd.PersistentFlags().String("event-log", "", "filename of event log to append timeline to")
 	rootCmd.PersistentFlags().String("git-trace2-event-log", "", "directory to write git trace2 event log to")
 	rootCmd.PersistentFlags().String("submanifest-path", "", "submanifest path")
+	rootCmd.PersistentFlags().Duration("wait", 0, "maximum time to wait for other repo commands to release the client lock (0 waits indefinitely)")
 
 	// 添加子命令
 	rootCmd.AddCommand(commands.InitCmd())
@@ -121,9 +134,44 @@ Options:
 	rootCmd.AddCommand(commands.RebaseCmd())
 	rootCmd.AddCommand(commands.SmartSyncCmd())
 	rootCmd.AddCommand(commands.StageCmd())
+	rootCmd.AddCommand(commands.HooksCmd())
+	rootCmd.AddCommand(commands.ConfigCmd())
+	rootCmd.AddCommand(commands.CompletionCmd())
+	rootCmd.CompletionOptions.DisableDefaultCmd = true
+	setupHelp(rootCmd, log)
+	rootCmd.ValidArgsFunction = completeExternal(log)
+
+	// Ctrl-C 或 SIGTERM 取消命令的 context，正在运行的 git 进程随之终止
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		// 再次按 Ctrl-C 立即退出，退出前关闭 SSH 主连接
+		again := make(chan os.Signal, 1)
+		signal.Notify(again, os.Interrupt, syscall.SIGTERM)
+		<-again
+		sess.closeSSHProxy()
+		os.Exit(130)
+	}()
+
+	// SSH 连接复用只为访问客户端的命令启动，帮助和补全不需要；
+	// panic 时同样关闭 SSH 主连接，正常退出由 sess.end 关闭
+	defer sess.closeSSHProxy()
 
 	// 执行命令
-	if err := rootCmd.Execute(); err != nil {
-		os.Exit(1)
+	// 不是内置命令时运行 PATH 中的 repo-<name> 或展开 alias.<name> 别名
+	handled, exitCode := dispatchExternal(rootCmd, os.Args[1:], sess, log)
+	if !handled {
+		if err := rootCmd.ExecuteContext(ctx); err != nil {
+			exitCode = 1
+			if ctx.Err() != nil {
+				exitCode = 130
+			}
+		}
+	}
+	sess.end(exitCode)
+	if exitCode == 130 {
+		fmt.Fprintln(os.Stderr, "aborted by user")
 	}
+	os.Exit(exitCode)
 }
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/leopardxu/repo-go/internal/event"
+	"github.com/leopardxu/repo-go/internal/git"
+	"github.com/leopardxu/repo-go/internal/lock"
+	"github.com/leopardxu/repo-go/internal/logger"
+	"github.com/leopardxu/repo-go/internal/pager"
+	"github.com/leopardxu/repo-go/internal/ssh"
+	"github.com/spf13/cobra"
+)
+
+// exclusiveCommands 是修改整个客户端的命令，执行时独占客户端锁，其他命令共享客户端锁
+var exclusiveCommands = map[string]bool{
+	"init":      true,
+	"sync":      true,
+	"smartsync": true,
+	"prune":     true,
+}
+
+// noLockCommands 是不访问客户端的命令，不获取客户端锁，补全在 sync 运行时也不会阻塞
+var noLockCommands = map[string]bool{
+	"help":                          true,
+	"completion":                    true,
+	cobra.ShellCompRequestCmd:       true,
+	cobra.ShellCompNoDescRequestCmd: true,
+}
+
+// session 处理与单次命令执行相关的全局选项：--event-log、--time、--git-trace2-event-log、--paginate 和 --wait
+type session struct {
+	log       logger.Logger
+	start     time.Time
+	showTime  bool
+	eventLog  string // --event-log 的绝对路径
+	cmdEvent  *event.Event
+	trace2    *event.Trace2Log
+	trace2Dst string
+	pager     *pager.Pager
+	lock      *lock.Lock
+	sshProxy  *ssh.Proxy
+}
+
+// newSession 创建会话并记录命令开始时间
+func newSession(log logger.Logger) *session {
+	return &session{log: log, start: time.Now()}
+}
+
+// startSSHProxy 让所有 git 网络操作按主机复用 SSH ControlMaster 连接，主连接在首次访问主机时启动
+// 主连接由 closeSSHProxy 关闭，end 会调用它；可以重复调用
+func (s *session) startSSHProxy() {
+	if s.sshProxy != nil {
+		return
+	}
+	proxy, err := ssh.NewProxy()
+	if err != nil {
+		s.log.Debug("SSH 连接复用不可用: %v", err)
+		return
+	}
+	s.sshProxy = proxy
+	if proxy.Install() {
+		git.SetPreconnect(proxy.Preconnect)
+	}
+}
+
+// closeSSHProxy 关闭所有 ControlMaster 连接并删除控制套接字目录，可以重复调用
+func (s *session) closeSSHProxy() {
+	if s.sshProxy != nil {
+		git.SetPreconnect(nil)
+		s.sshProxy.Close()
+	}
+}
+
+// begin 在子命令执行前根据全局选项开启事件记录和分页器
+func (s *session) begin(cmd *cobra.Command) {
+	name := strings.TrimPrefix(cmd.CommandPath(), cmd.Root().Name()+" ")
+
+	// 处理--event-log和--time标志，--time 的分项耗时同样来自事件日志
+	s.showTime, _ = cmd.Flags().GetBool("time")
+	if path, _ := cmd.Flags().GetString("event-log"); path != "" {
+		if abs, err := filepath.Abs(path); err == nil {
+			s.eventLog = abs
+		} else {
+			s.eventLog = path
+		}
+	}
+	if s.eventLog != "" || s.showTime {
+		l := event.Enable()
+		s.cmdEvent = l.Add(name, event.TaskCommand, s.start)
+		l.SetParent(s.cmdEvent)
+	}
+
+	// 处理--git-trace2-event-log标志：repo 自身的事件和 git 子进程的事件写入同一目标，
+	// git 子进程通过 GIT_TRACE2_PARENT_SID 关联到 repo 的会话
+	if dst, _ := cmd.Flags().GetString("git-trace2-event-log"); dst != "" {
+		if abs, err := filepath.Abs(dst); err == nil {
+			dst = abs
+		}
+		s.trace2Dst = dst
+		s.trace2 = event.NewTrace2Log(version)
+		s.trace2.CommandEvent(name)
+		os.Setenv("GIT_TRACE2_EVENT", dst)
+		s.log.Trace("Git Trace2事件日志已设置为: %s", dst)
+	}
+
+	// 处理--paginate标志，--no-pager 优先
+	paginate, _ := cmd.Flags().GetBool("paginate")
+	noPager, _ := cmd.Flags().GetBool("no-pager")
+	if paginate && !noPager {
+		p, err := pager.Start()
+		if err != nil {
+			s.log.Warn("无法启动分页器: %v", err)
+		}
+		s.pager = p
+	}
+}
+
+// topCommand 返回 cmd 所属的顶层子命令，cmd 是根命令时返回根命令
+func topCommand(cmd *cobra.Command) *cobra.Command {
+	top := cmd
+	for top.HasParent() && top.Parent() != cmd.Root() {
+		top = top.Parent()
+	}
+	return top
+}
+
+// accessesClient 判断命令是否访问客户端：帮助、补全等命令既不加锁也不需要 SSH 连接复用
+func accessesClient(cmd *cobra.Command) bool {
+	top := topCommand(cmd)
+	return top.HasParent() && !noLockCommands[top.Name()]
+}
+
+// prepare 在访问客户端的命令执行前启动 SSH 连接复用并获取客户端锁
+func (s *session) prepare(cmd *cobra.Command) error {
+	if !accessesClient(cmd) {
+		return nil
+	}
+	s.startSSHProxy()
+	return s.acquireLock(cmd)
+}
+
+// acquireLock 在客户端中执行命令前获取客户端锁，不在客户端中时不加锁
+// sync、init 和 prune 独占客户端锁，其他命令共享；--wait 限制等待时间，同时用于项目锁
+func (s *session) acquireLock(cmd *cobra.Command) error {
+	top := topCommand(cmd)
+
+	wait, _ := cmd.Flags().GetDuration("wait")
+	lock.Configure(cmd.CommandPath(), wait)
+
+	repoRoot := findRepoRoot()
+	if repoRoot == "" {
+		return nil
+	}
+	mode := lock.Shared
+	if exclusiveCommands[top.Name()] {
+		mode = lock.Exclusive
+	}
+	l, err := lock.Acquire(cmd.Context(), lock.ClientPath(repoRoot), mode)
+	if err != nil {
+		return err
+	}
+	s.lock = l
+	return nil
+}
+
+// findRepoRoot 从当前目录向上查找包含 .repo 目录的客户端根目录，找不到时返回空字符串
+func findRepoRoot() string {
+	dir, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+	for {
+		if info, err := os.Stat(filepath.Join(dir, ".repo")); err == nil && info.IsDir() {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// end 在命令结束后释放客户端锁、关闭分页器，输出耗时并写出事件日志
+func (s *session) end(exitCode int) {
+	elapsed := time.Since(s.start)
+	s.cmdEvent.Finish(exitCode == 0)
+
+	if err := s.lock.Release(); err != nil {
+		fmt.Fprintf(os.Stderr, "警告: %v\n", err)
+	}
+
+	// 先等待用户退出分页器，耗时输出到终端
+	s.pager.Wait()
+
+	if s.showTime {
+		event.WriteTimings(os.Stderr, elapsed, event.Default())
+	}
+
+	if s.eventLog != "" {
+		if err := event.Default().Write(s.eventLog); err != nil {
+			fmt.Fprintf(os.Stderr, "警告: 无法写入事件日志 %s: %v\n", s.eventLog, err)
+		}
+	}
+
+	if s.trace2 != nil {
+		s.trace2.ExitEvent(exitCode)
+		if err := s.trace2.Write(s.trace2Dst); err != nil {
+			fmt.Fprintf(os.Stderr, "警告: 无法写入 Git Trace2 事件日志 %s: %v\n", s.trace2Dst, err)
+		}
+	}
+
+	s.closeSSHProxy()
+}
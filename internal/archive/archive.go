@@ -0,0 +1,109 @@
+package archive
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrNotExported 表示项目还没有被导出到归档检出中
+var ErrNotExported = errors.New("project has not been exported, run repo sync first")
+
+// State 记录归档检出中一个项目最近一次导出的内容
+type State struct {
+	Name     string    `json:"name"`
+	Path     string    `json:"path"`     // 相对于客户端根目录的路径，使用/分隔
+	Revision string    `json:"revision"` // 清单中的修订版本
+	Commit   string    `json:"commit"`   // 导出的提交
+	Remote   string    `json:"remote"`
+	Method   string    `json:"method"` // 导出方式：remote（git archive --remote）或 fetch（浅获取后本地导出）
+	Time     time.Time `json:"time"`
+}
+
+// stateDir 返回保存导出状态的目录
+func stateDir(repoRoot string) string {
+	return filepath.Join(repoRoot, ".repo", "project-archives")
+}
+
+// statePath 返回项目导出状态文件的路径，path 是清单中的项目路径，files 为 true 时返回导出文件列表的路径
+func statePath(repoRoot, path string, files bool) string {
+	name := url.PathEscape(filepath.ToSlash(path))
+	if files {
+		return filepath.Join(stateDir(repoRoot), name+".files")
+	}
+	return filepath.Join(stateDir(repoRoot), name+".json")
+}
+
+// LoadState 读取项目的导出状态，项目没有导出过时返回 ErrNotExported
+func LoadState(repoRoot, path string) (*State, error) {
+	data, err := os.ReadFile(statePath(repoRoot, path, false))
+	if os.IsNotExist(err) {
+		return nil, ErrNotExported
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive state of %s: %w", path, err)
+	}
+	var s State
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("failed to parse archive state of %s: %w", path, err)
+	}
+	return &s, nil
+}
+
+// SaveState 保存项目的导出状态和导出的文件列表，先写临时文件再重命名，中断时不会留下不完整的状态
+func SaveState(repoRoot string, s *State, files []string) error {
+	if err := os.MkdirAll(stateDir(repoRoot), 0755); err != nil {
+		return fmt.Errorf("failed to create archive state directory: %w", err)
+	}
+	if err := writeFileAtomic(statePath(repoRoot, s.Path, true), []byte(strings.Join(files, "\n"))); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode archive state: %w", err)
+	}
+	return writeFileAtomic(statePath(repoRoot, s.Path, false), data)
+}
+
+// LoadFiles 读取项目上次导出的文件列表，没有导出过时返回空列表
+func LoadFiles(repoRoot, path string) ([]string, error) {
+	f, err := os.Open(statePath(repoRoot, path, true))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read exported files of %s: %w", path, err)
+	}
+	defer f.Close()
+
+	var files []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			files = append(files, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read exported files of %s: %w", path, err)
+	}
+	return files, nil
+}
+
+// writeFileAtomic 通过临时文件和重命名写入文件
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", tmp, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
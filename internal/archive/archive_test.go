@@ -0,0 +1,224 @@
+package archive
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// gitRun 在 dir 中执行 git 命令并返回去掉空白的输出
+func gitRun(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=t", "GIT_AUTHOR_EMAIL=t@example.com",
+		"GIT_COMMITTER_NAME=t", "GIT_COMMITTER_EMAIL=t@example.com")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExport(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	upstream := t.TempDir()
+	gitRun(t, upstream, "init", "--quiet", "-b", "main")
+	writeFile(t, filepath.Join(upstream, "a.txt"), "a1")
+	writeFile(t, filepath.Join(upstream, "old", "b.txt"), "b")
+	gitRun(t, upstream, "add", "-A")
+	gitRun(t, upstream, "commit", "--quiet", "-m", "first")
+	first := gitRun(t, upstream, "rev-parse", "HEAD")
+
+	root := t.TempDir()
+	worktree := filepath.Join(root, "p")
+	tmpDir := filepath.Join(root, ".repo", "archive-tmp")
+	ctx := context.Background()
+
+	commit, err := RemoteCommit(ctx, upstream, "main")
+	if err != nil || commit != first {
+		t.Fatalf("RemoteCommit = %q, %v; want %q", commit, err, first)
+	}
+
+	res, err := Export(ctx, upstream, "main", worktree, tmpDir, nil)
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	if res.Commit != first {
+		t.Errorf("Commit = %q, want %q", res.Commit, first)
+	}
+	sort.Strings(res.Files)
+	if want := []string{"a.txt", "old/b.txt"}; !reflect.DeepEqual(res.Files, want) {
+		t.Errorf("Files = %q, want %q", res.Files, want)
+	}
+	if _, err := os.Stat(filepath.Join(worktree, ".git")); !os.IsNotExist(err) {
+		t.Errorf("worktree has .git: %v", err)
+	}
+
+	// 第二次导出删除不再包含的文件，保留不属于导出内容的文件
+	writeFile(t, filepath.Join(upstream, "a.txt"), "a2")
+	gitRun(t, upstream, "rm", "--quiet", "-r", "old")
+	writeFile(t, filepath.Join(upstream, "new", "c.txt"), "c")
+	gitRun(t, upstream, "add", "-A")
+	gitRun(t, upstream, "commit", "--quiet", "-m", "second")
+	second := gitRun(t, upstream, "rev-parse", "HEAD")
+	writeFile(t, filepath.Join(worktree, "nested", "keep.txt"), "keep")
+
+	res, err = Export(ctx, upstream, "main", worktree, tmpDir, []string{"a.txt", "old/b.txt"})
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	if res.Commit != second {
+		t.Errorf("Commit = %q, want %q", res.Commit, second)
+	}
+	if data, _ := os.ReadFile(filepath.Join(worktree, "a.txt")); string(data) != "a2" {
+		t.Errorf("a.txt = %q, want a2", data)
+	}
+	if _, err := os.Stat(filepath.Join(worktree, "old")); !os.IsNotExist(err) {
+		t.Errorf("stale directory old still exists: %v", err)
+	}
+	for _, name := range []string{"new/c.txt", "nested/keep.txt"} {
+		if _, err := os.Stat(filepath.Join(worktree, name)); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+
+	// 服务器拒绝导出提交 ID 时改为浅获取后本地导出
+	res, err = Export(ctx, upstream, first, worktree, tmpDir, res.Files)
+	if err != nil {
+		t.Fatalf("Export %s: %v", first, err)
+	}
+	if res.Commit != first || res.Method != MethodFetch {
+		t.Errorf("Export %s = %s via %s, want %s via %s", first, res.Commit, res.Method, first, MethodFetch)
+	}
+	if _, err := os.Stat(filepath.Join(worktree, "new")); !os.IsNotExist(err) {
+		t.Errorf("stale directory new still exists: %v", err)
+	}
+	if entries, _ := os.ReadDir(tmpDir); len(entries) != 0 {
+		t.Errorf("temporary directory not cleaned: %v", entries)
+	}
+}
+
+func TestExtractRejectsUnsafePaths(t *testing.T) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	tw.WriteHeader(&tar.Header{Name: "../escape.txt", Mode: 0644, Size: 1, Typeflag: tar.TypeReg})
+	tw.Write([]byte("x"))
+	tw.Close()
+
+	dir := t.TempDir()
+	if _, _, err := extract(&buf, dir); err == nil {
+		t.Fatal("extract accepted a path outside the directory")
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(dir), "escape.txt")); !os.IsNotExist(err) {
+		t.Errorf("file written outside the directory: %v", err)
+	}
+}
+
+func TestExtractRejectsSymlinkEscape(t *testing.T) {
+	type entry struct {
+		name, link, body string
+		dir              bool
+	}
+	tests := []struct {
+		name    string
+		entries []entry
+		wantErr bool
+	}{
+		{"write through symlink", []entry{{name: "a", link: "OUTSIDE"}, {name: "a/x", body: "x"}}, true},
+		{"nested symlink", []entry{{name: "d/", dir: true}, {name: "d/a", link: "OUTSIDE"}, {name: "d/a/b/x", body: "x"}}, true},
+		{"directory over symlink", []entry{{name: "a", link: "OUTSIDE"}, {name: "a/", dir: true}}, true},
+		{"file replaces symlink", []entry{{name: "x", link: "OUTSIDE/x"}, {name: "x", body: "x"}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outside := t.TempDir()
+			dir := t.TempDir()
+
+			var buf bytes.Buffer
+			tw := tar.NewWriter(&buf)
+			for _, e := range tt.entries {
+				hdr := &tar.Header{Name: e.name, Mode: 0644, Typeflag: tar.TypeReg, Size: int64(len(e.body))}
+				switch {
+				case e.dir:
+					hdr = &tar.Header{Name: e.name, Mode: 0755, Typeflag: tar.TypeDir}
+				case e.link != "":
+					hdr = &tar.Header{Name: e.name, Typeflag: tar.TypeSymlink, Linkname: strings.ReplaceAll(e.link, "OUTSIDE", outside)}
+				}
+				tw.WriteHeader(hdr)
+				tw.Write([]byte(e.body))
+			}
+			tw.Close()
+
+			_, _, err := extract(&buf, dir)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("extract() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if entries, _ := os.ReadDir(outside); len(entries) != 0 {
+				t.Errorf("files written outside the directory: %v", entries)
+			}
+		})
+	}
+}
+
+func TestInstallRejectsSymlinkParent(t *testing.T) {
+	outside := t.TempDir()
+	staging := t.TempDir()
+	worktree := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(staging, "a"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(staging, "a", "x"), []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// 工作树中已有指向外部的符号链接 a
+	if err := os.Symlink(outside, filepath.Join(worktree, "a")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := install(staging, worktree, []string{"a/x"}, nil); err == nil {
+		t.Fatal("install wrote through a symlink")
+	}
+	if entries, _ := os.ReadDir(outside); len(entries) != 0 {
+		t.Errorf("files written outside the worktree: %v", entries)
+	}
+}
+
+func TestState(t *testing.T) {
+	root := t.TempDir()
+	if _, err := LoadState(root, "sub/p"); !errors.Is(err, ErrNotExported) {
+		t.Fatalf("LoadState = %v, want ErrNotExported", err)
+	}
+	s := &State{Name: "p", Path: "sub/p", Revision: "main", Commit: "abc", Method: MethodRemote}
+	if err := SaveState(root, s, []string{"a", "b/c"}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := LoadState(root, "sub/p")
+	if err != nil || got.Commit != "abc" || got.Name != "p" {
+		t.Fatalf("LoadState = %+v, %v", got, err)
+	}
+	files, err := LoadFiles(root, "sub/p")
+	if err != nil || !reflect.DeepEqual(files, []string{"a", "b/c"}) {
+		t.Errorf("LoadFiles = %q, %v", files, err)
+	}
+}
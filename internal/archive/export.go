@@ -0,0 +1,405 @@
+package archive
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/leopardxu/repo-go/internal/git"
+)
+
+// 导出方式
+const (
+	MethodRemote = "remote" // 服务器端 git archive --remote
+	MethodFetch  = "fetch"  // 浅获取到临时仓库后在本地 git archive
+)
+
+// Result 是一次导出的结果
+type Result struct {
+	Commit string
+	Method string
+	Files  []string // 导出的文件和符号链接，相对于工作树，使用/分隔
+}
+
+// Export 把 remoteURL 上 revision 的内容导出到 worktree，不创建 .git
+// 优先使用 git archive --remote，服务器不支持（如 HTTP 远程）时在 tmpDir 中浅获取后本地导出。
+// old 是上次导出的文件列表，这次不再包含的文件会被删除，工作树中的其他文件（如嵌套项目）保持不变。
+// 内容先解包到 tmpDir 中，全部成功后才移入工作树，tmpDir 应与工作树在同一文件系统上
+func Export(ctx context.Context, remoteURL, revision, worktree, tmpDir string, old []string) (*Result, error) {
+	if revision == "" {
+		revision = "HEAD"
+	}
+	if err := os.MkdirAll(tmpDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", tmpDir, err)
+	}
+
+	staging, err := os.MkdirTemp(tmpDir, "export-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	defer os.RemoveAll(staging)
+
+	result := &Result{Method: MethodRemote}
+	result.Commit, result.Files, err = archiveTo(ctx, "", staging, "archive", "--remote="+remoteURL, "--format=tar", revision)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		remoteErr := err
+		if err := resetDir(staging); err != nil {
+			return nil, err
+		}
+		result.Method = MethodFetch
+		result.Commit, result.Files, err = exportFetch(ctx, remoteURL, revision, staging, tmpDir)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, fmt.Errorf("git archive --remote failed: %v; shallow fetch failed: %w", remoteErr, err)
+		}
+	}
+
+	if err := install(staging, worktree, result.Files, old); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// exportFetch 浅获取 revision 到 tmpDir 中的临时裸仓库，再从中导出到 staging
+func exportFetch(ctx context.Context, remoteURL, revision, staging, tmpDir string) (string, []string, error) {
+	repo, err := os.MkdirTemp(tmpDir, "fetch-")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create temporary repository: %w", err)
+	}
+	defer os.RemoveAll(repo)
+
+	if err := runGit(ctx, "", "init", "--bare", "--quiet", repo); err != nil {
+		return "", nil, err
+	}
+	if err := runGit(ctx, "", "-C", repo, "fetch", "--quiet", "--depth", "1", "--no-tags", remoteURL, revision); err != nil {
+		return "", nil, err
+	}
+	commit, files, err := archiveTo(ctx, repo, staging, "archive", "--format=tar", "FETCH_HEAD")
+	if err != nil {
+		return "", nil, err
+	}
+	if commit == "" {
+		cmd := git.Command(ctx, repo, "rev-parse", "FETCH_HEAD^{commit}")
+		out, err := cmd.Output()
+		if err != nil {
+			return "", nil, fmt.Errorf("git rev-parse FETCH_HEAD: %w", err)
+		}
+		commit = strings.TrimSpace(string(out))
+	}
+	return commit, files, nil
+}
+
+// RemoteCommit 返回远程仓库中 revision 当前指向的提交，revision 是完整的提交 ID 时直接返回
+func RemoteCommit(ctx context.Context, remoteURL, revision string) (string, error) {
+	if isCommitID(revision) {
+		return revision, nil
+	}
+	if revision == "" {
+		revision = "HEAD"
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := git.Command(ctx, "", "ls-remote", remoteURL, revision)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := git.Run(cmd); err != nil {
+		return "", gitError("ls-remote", err, &stderr)
+	}
+
+	refs := make(map[string]string)
+	for _, line := range strings.Split(stdout.String(), "\n") {
+		sha, ref, ok := strings.Cut(strings.TrimSpace(line), "\t")
+		if ok {
+			refs[ref] = sha
+		}
+	}
+	candidates := []string{revision}
+	if !strings.HasPrefix(revision, "refs/") && revision != "HEAD" {
+		candidates = []string{"refs/heads/" + revision, "refs/tags/" + revision}
+	}
+	for _, ref := range candidates {
+		// 附注标签取其指向的提交
+		if sha, ok := refs[ref+"^{}"]; ok {
+			return sha, nil
+		}
+		if sha, ok := refs[ref]; ok {
+			return sha, nil
+		}
+	}
+	return "", fmt.Errorf("revision %s not found in %s", revision, remoteURL)
+}
+
+// archiveTo 执行输出 tar 的 git 命令，并把内容解包到 dir，返回归档中记录的提交和文件列表
+func archiveTo(ctx context.Context, gitDir, dir string, args ...string) (string, []string, error) {
+	pr, pw := io.Pipe()
+	var stderr bytes.Buffer
+	cmd := git.Command(ctx, gitDir, args...)
+	cmd.Stdout = pw
+	cmd.Stderr = &stderr
+
+	done := make(chan error, 1)
+	go func() {
+		err := git.Run(cmd)
+		pw.CloseWithError(err)
+		done <- err
+	}()
+
+	commit, files, extractErr := extract(pr, dir)
+	if extractErr == nil {
+		// tar 的结束块之后可能还有填充，读完以免 git 阻塞在写入上
+		_, extractErr = io.Copy(io.Discard, pr)
+	}
+	// 解包失败时关闭管道，git 写入失败后退出
+	pr.CloseWithError(extractErr)
+	runErr := <-done
+
+	if runErr != nil {
+		return "", nil, gitError(args[0], runErr, &stderr)
+	}
+	if extractErr != nil {
+		return "", nil, fmt.Errorf("failed to unpack archive: %w", extractErr)
+	}
+	return commit, files, nil
+}
+
+// extract 把 tar 解包到 dir，返回 git archive 在全局扩展头中记录的提交和解包的文件列表
+func extract(r io.Reader, dir string) (string, []string, error) {
+	var commit string
+	var files []string
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return commit, files, nil
+		}
+		if err != nil {
+			return "", nil, err
+		}
+		if hdr.Typeflag == tar.TypeXGlobalHeader {
+			commit = hdr.PAXRecords["comment"]
+			continue
+		}
+
+		name := strings.TrimSuffix(hdr.Name, "/")
+		if name == "" || name == "." {
+			continue
+		}
+		if !filepath.IsLocal(filepath.FromSlash(name)) {
+			return "", nil, fmt.Errorf("unsafe path %q in archive", hdr.Name)
+		}
+		// 先创建的符号链接可能指向 dir 之外，不能通过它写入后面的条目
+		if err := checkNoSymlink(dir, name); err != nil {
+			return "", nil, err
+		}
+		target := filepath.Join(dir, filepath.FromSlash(name))
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if info, err := os.Lstat(target); err == nil && info.Mode()&os.ModeSymlink != 0 {
+				return "", nil, fmt.Errorf("directory %q in archive replaces a symlink", name)
+			}
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return "", nil, err
+			}
+		case tar.TypeReg:
+			if err := prepareTarget(target); err != nil {
+				return "", nil, err
+			}
+			f, err := os.OpenFile(target, os.O_CREATE|os.O_EXCL|os.O_WRONLY, hdr.FileInfo().Mode().Perm())
+			if err != nil {
+				return "", nil, err
+			}
+			_, err = io.Copy(f, tr)
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				return "", nil, err
+			}
+			files = append(files, name)
+		case tar.TypeSymlink:
+			if err := prepareTarget(target); err != nil {
+				return "", nil, err
+			}
+			if err := os.Symlink(hdr.Linkname, target); err != nil {
+				return "", nil, err
+			}
+			files = append(files, name)
+		}
+	}
+}
+
+// checkNoSymlink 检查 root 下 name 的各级父目录都不是符号链接
+func checkNoSymlink(root, name string) error {
+	dir := root
+	parents := strings.Split(name, "/")
+	for _, elem := range parents[:len(parents)-1] {
+		dir = filepath.Join(dir, elem)
+		info, err := os.Lstat(dir)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("path %q goes through symlink %s", name, dir)
+		}
+	}
+	return nil
+}
+
+// prepareTarget 创建 target 的父目录并删除已存在的同名文件或符号链接，
+// 之后以 O_EXCL 创建文件，不会通过同名的符号链接写到其他位置
+func prepareTarget(target string) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	info, err := os.Lstat(target)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("cannot replace directory %s with a file", target)
+	}
+	return os.Remove(target)
+}
+
+// install 把 staging 中解包的文件移入工作树，并删除上次导出、这次不再包含的文件
+func install(staging, worktree string, files, old []string) error {
+	if err := os.MkdirAll(worktree, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", worktree, err)
+	}
+
+	// 先删除不再包含的文件，文件变为目录时才能创建目录
+	current := make(map[string]bool, len(files))
+	for _, name := range files {
+		current[name] = true
+	}
+	dirs := make(map[string]bool)
+	for _, name := range old {
+		if current[name] || !filepath.IsLocal(filepath.FromSlash(name)) || checkNoSymlink(worktree, name) != nil {
+			continue
+		}
+		target := filepath.Join(worktree, filepath.FromSlash(name))
+		if err := os.Remove(target); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s: %w", target, err)
+		}
+		for dir := filepath.Dir(target); dir != worktree && strings.HasPrefix(dir, worktree); dir = filepath.Dir(dir) {
+			dirs[dir] = true
+		}
+	}
+	removeEmptyDirs(dirs)
+
+	for _, name := range files {
+		src := filepath.Join(staging, filepath.FromSlash(name))
+		dst := filepath.Join(worktree, filepath.FromSlash(name))
+		// 工作树中已有的符号链接可能指向项目之外
+		if err := checkNoSymlink(worktree, name); err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return fmt.Errorf("failed to create directory for %s: %w", dst, err)
+		}
+		if info, err := os.Lstat(dst); err == nil {
+			if info.IsDir() {
+				return fmt.Errorf("cannot replace directory %s with a file", dst)
+			}
+			if err := os.Remove(dst); err != nil {
+				return fmt.Errorf("failed to replace %s: %w", dst, err)
+			}
+		}
+		if err := os.Rename(src, dst); err != nil {
+			return fmt.Errorf("failed to install %s: %w", dst, err)
+		}
+	}
+	return nil
+}
+
+// removeEmptyDirs 从深到浅删除已经为空的目录，非空目录保持不变
+func removeEmptyDirs(dirs map[string]bool) {
+	list := make([]string, 0, len(dirs))
+	for dir := range dirs {
+		list = append(list, dir)
+	}
+	sort.Slice(list, func(i, j int) bool { return len(list[i]) > len(list[j]) })
+	for _, dir := range list {
+		os.Remove(dir)
+	}
+}
+
+// resetDir 清空目录
+func resetDir(dir string) error {
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("failed to clean %s: %w", dir, err)
+	}
+	return os.MkdirAll(dir, 0755)
+}
+
+// runGit 执行 git 命令，失败时错误中包含 git 的错误输出
+func runGit(ctx context.Context, dir string, args ...string) error {
+	var stderr bytes.Buffer
+	cmd := git.Command(ctx, dir, args...)
+	cmd.Stderr = &stderr
+	if err := git.Run(cmd); err != nil {
+		sub := args[0]
+		if sub == "-C" && len(args) > 2 {
+			sub = args[2]
+		}
+		return gitError(sub, err, &stderr)
+	}
+	return nil
+}
+
+// gitError 使用 git 的错误输出描述错误
+func gitError(sub string, err error, stderr *bytes.Buffer) error {
+	if msg := strings.TrimSpace(stderr.String()); msg != "" {
+		return fmt.Errorf("git %s: %w: %s", sub, err, msg)
+	}
+	return fmt.Errorf("git %s: %w", sub, err)
+}
+
+// isCommitID 判断 revision 是否是完整的提交 ID
+func isCommitID(revision string) bool {
+	if len(revision) != 40 && len(revision) != 64 {
+		return false
+	}
+	for _, c := range revision {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// staleAfter 是临时目录被视为中断的导出遗留的时间
+const staleAfter = 24 * time.Hour
+
+// CleanTemp 删除 tmpDir 中被中断的导出遗留的临时目录
+func CleanTemp(tmpDir string) {
+	entries, err := os.ReadDir(tmpDir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err == nil && time.Since(info.ModTime()) > staleAfter {
+			os.RemoveAll(filepath.Join(tmpDir, entry.Name()))
+		}
+	}
+}
@@ -175,6 +175,10 @@ func ShouldUseColor(colorOption string) bool {
 	case "never":
 		return false
 	case "auto":
+		// 遵循 NO_COLOR 约定
+		if os.Getenv("NO_COLOR") != "" {
+			return false
+		}
 		// 检测是否是终端
 		if fileInfo, _ := os.Stdout.Stat(); (fileInfo.Mode() & os.ModeCharDevice) != 0 {
 			return true
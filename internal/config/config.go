@@ -7,7 +7,6 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
-	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -97,8 +96,14 @@ type Config struct {
 	NoGitLFS            bool   `json:"no_git_lfs"`            // 不使用Git LFS
 	OuterManifest       bool   `json:"outer_manifest"`        // 外部清单
 	NoOuterManifest     bool   `json:"no_outer_manifest"`     // 不使用外部清单
+
+	settings *Settings // 分层合并后的设置，由 Load 填充
 }
 
+// currentConfigVersion 是当前的配置版本
+// 从版本2开始，color.ui、repo.*、manifest.* 等设置以分层设置为准，config.json 中的对应字段只在迁移时读取
+const currentConfigVersion = 2
+
 // Load 加载配置
 func Load() (*Config, error) {
 	// 先定位顶层 repo 根目录，支持在任意子目录执行
@@ -167,10 +172,32 @@ func Load() (*Config, error) {
 	}
 
 	// 迁移配置
-	if err := migrateConfig(&config); err != nil {
+	migrated, err := migrateConfig(&config, repoRoot)
+	if err != nil {
 		log.Error("迁移配置失败: %v", err)
 		return nil, &ConfigError{Op: "migrate", Path: configPath, Err: err}
 	}
+	if migrated {
+		if data, err := json.MarshalIndent(&config, "", "  "); err == nil {
+			if err := os.WriteFile(configPath, data, 0644); err != nil {
+				log.Warn("保存迁移后的配置失败: %v", err)
+			} else if info, err := os.Stat(configPath); err == nil {
+				fileInfo = info
+			}
+		}
+		log.Debug("配置已迁移到版本 %d", config.Version)
+	}
+
+	// 依次应用默认值、全局设置、客户端设置和环境变量中的设置
+	settings, err := LoadSettings(repoRoot)
+	if err != nil {
+		log.Error("加载设置失败: %v", err)
+		return nil, &ConfigError{Op: "settings", Err: err}
+	}
+	if err := settings.Apply(&config); err != nil {
+		log.Warn("%v", err)
+	}
+	config.settings = settings
 
 	// 应用环境变量
 	config.ApplyEnvironment()
@@ -191,6 +218,41 @@ func Load() (*Config, error) {
 	return configCache, nil
 }
 
+// LoadReadOnly 读取客户端 repoRoot 的配置，不迁移、不写入任何文件，也不更新缓存
+// 用于补全等不应修改客户端的场景；未迁移的版本1配置按迁移后的结果合并设置
+func LoadReadOnly(repoRoot string) (*Config, error) {
+	configPath := filepath.Join(repoRoot, ".repo", "config.json")
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, &ConfigError{Op: "read", Path: configPath, Err: err}
+	}
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, &ConfigError{Op: "parse", Path: configPath, Err: err}
+	}
+	if config.Version == 0 {
+		config.Version = 1
+	}
+	if config.Version > currentConfigVersion {
+		return nil, &ConfigError{Op: "load", Path: configPath, Err: fmt.Errorf("unsupported config version: %d", config.Version)}
+	}
+
+	settings, err := LoadSettings(repoRoot)
+	if err != nil {
+		return nil, &ConfigError{Op: "settings", Err: err}
+	}
+	if config.Version < currentConfigVersion {
+		settings.overlayLegacy(&config)
+	}
+	if err := settings.Apply(&config); err != nil {
+		log.Debug("%v", err)
+	}
+	config.settings = settings
+	config.ApplyEnvironment()
+	config.RepoRoot = repoRoot
+	return &config, nil
+}
+
 // Save 保存配置
 func (c *Config) Save() error {
 	log.Debug("保存配置")
@@ -528,6 +590,9 @@ func (c *Config) Validate() error {
 	if c.Mirror && c.Archive {
 		errs = append(errs, "mirror and archive options are mutually exclusive")
 	}
+	if c.Worktree && (c.Mirror || c.Archive) {
+		errs = append(errs, "worktree option cannot be used with mirror or archive")
+	}
 
 	if c.CurrentBranch && c.NoCurrentBranch {
 		errs = append(errs, "current_branch and no_current_branch options are mutually exclusive")
@@ -564,6 +629,11 @@ func (c *Config) Validate() error {
 	return nil
 }
 
+// Settings 返回加载配置时合并的分层设置
+func (c *Config) Settings() *Settings {
+	return c.settings
+}
+
 // GetJobs 获取并发任务数，如果没有设置则返回默认值
 func (c *Config) GetJobs() int {
 	if c.Jobs > 0 {
@@ -677,42 +747,7 @@ func (c *Config) ApplyEnvironment() {
 		c.ManifestName = manifestName
 	}
 
-	if groups := os.Getenv("GOGO_GROUPS"); groups != "" {
-		log.Debug("从环境变量设GROUPS: %s", groups)
-		c.Groups = groups
-	}
-
-	if platform := os.Getenv("GOGO_PLATFORM"); platform != "" {
-		log.Debug("从环境变量设PLATFORM: %s", platform)
-		c.Platform = platform
-	}
-
-	// 布尔值环境变量
-	if mirror := os.Getenv("GOGO_MIRROR"); mirror == "true" {
-		log.Debug("从环境变量设MIRROR: true")
-		c.Mirror = true
-	} else if mirror == "false" {
-		log.Debug("从环境变量设MIRROR: false")
-		c.Mirror = false
-	}
-
-	if archive := os.Getenv("GOGO_ARCHIVE"); archive == "true" {
-		log.Debug("从环境变量设ARCHIVE: true")
-		c.Archive = true
-	} else if archive == "false" {
-		log.Debug("从环境变量设ARCHIVE: false")
-		c.Archive = false
-	}
-
-	// 整数值环境变量
-	if depthStr := os.Getenv("GOGO_DEPTH"); depthStr != "" {
-		if depth, err := strconv.Atoi(depthStr); err == nil {
-			log.Debug("从环境变量设DEPTH: %d", depth)
-			c.Depth = depth
-		} else {
-			log.Warn("无效的DEPTH环境变量 %s", depthStr)
-		}
-	}
+	// GOGO_GROUPS、GOGO_PLATFORM、GOGO_MIRROR、GOGO_ARCHIVE 和 GOGO_DEPTH 是设置的环境变量层，由 LoadSettings 处理
 
 	// 日志级别环境变量
 	if verbose := os.Getenv("GOGO_VERBOSE"); verbose == "true" {
@@ -732,8 +767,8 @@ func (c *Config) ApplyEnvironment() {
 	}
 }
 
-// migrateConfig 根据版本号迁移配置
-func migrateConfig(config *Config) error {
+// migrateConfig 根据版本号迁移配置，返回配置是否被修改
+func migrateConfig(config *Config, repoRoot string) (bool, error) {
 	// 如果没有版本号，假设为版本1
 	if config.Version == 0 {
 		config.Version = 1
@@ -742,9 +777,32 @@ func migrateConfig(config *Config) error {
 	// 根据版本号进行迁移
 	switch config.Version {
 	case 1:
+		// 版本1的设置只保存在 config.json 中（repo init 写入的也是版本1），移到客户端设置
+		if err := migrateSettings(config, ClientSettingsPath(repoRoot)); err != nil {
+			return false, err
+		}
+		config.Version = currentConfigVersion
+		return true, nil
+	case currentConfigVersion:
 		// 当前版本，无需迁移
-		return nil
+		return false, nil
 	default:
-		return fmt.Errorf("unsupported config version: %d", config.Version)
+		return false, fmt.Errorf("unsupported config version: %d", config.Version)
 	}
 }
+
+// migrateSettings 将 config.json 中不同于默认值的设置写入客户端设置
+// 与 repo init 一致，未指定的选项保留已有的设置
+func migrateSettings(config *Config, path string) error {
+	for _, f := range configSettings {
+		value := f.get(config)
+		if value == f.def || value == "" {
+			continue
+		}
+		if err := SetSetting(path, f.key, value); err != nil {
+			return err
+		}
+		log.Debug("迁移设置 %s=%s", f.key, value)
+	}
+	return nil
+}
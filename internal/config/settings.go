@@ -0,0 +1,399 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Scope 表示设置所在的层，后面的层覆盖前面的层
+type Scope int
+
+const (
+	ScopeDefault Scope = iota // 内置默认值
+	ScopeGlobal               // ~/.repoconfig/config
+	ScopeClient               // 客户端清单仓库的 git 配置
+	ScopeEnv                  // GOGO_* 环境变量
+)
+
+// String 返回层的名称
+func (s Scope) String() string {
+	switch s {
+	case ScopeGlobal:
+		return "global"
+	case ScopeClient:
+		return "client"
+	case ScopeEnv:
+		return "env"
+	default:
+		return "default"
+	}
+}
+
+// Setting 表示一个设置项及其来源
+type Setting struct {
+	Key   string
+	Value string
+	Scope Scope
+}
+
+// Settings 是按层合并后的设置
+type Settings struct {
+	values map[string]Setting
+}
+
+// settingSections 是允许的设置分节，客户端设置与清单仓库的 git 配置共用一个文件，
+// 限制分节避免误改 core.*、remote.* 等 git 自身的配置
+var settingSections = map[string]bool{
+	"alias":    true,
+	"color":    true,
+	"manifest": true,
+	"repo":     true,
+	"review":   true,
+}
+
+// settingField 将设置键映射到 Config 的字段
+type settingField struct {
+	key string
+	def string
+	get func(c *Config) string
+	set func(c *Config, value string) error
+}
+
+// configSettings 是映射到 Config 字段的设置，键名与上游 repo 一致
+var configSettings = []settingField{
+	stringSetting("color.ui", "auto", func(c *Config) *string { return &c.Color }),
+	stringSetting("manifest.groups", "", func(c *Config) *string { return &c.Groups }),
+	stringSetting("manifest.platform", "", func(c *Config) *string { return &c.Platform }),
+	boolSetting("repo.archive", false, func(c *Config) *bool { return &c.Archive }),
+	boolSetting("repo.clonebundle", true, func(c *Config) *bool { return &c.CloneBundle }),
+	stringSetting("repo.clonefilter", "", func(c *Config) *string { return &c.CloneFilter }),
+	intSetting("repo.depth", 0, func(c *Config) *int { return &c.Depth }),
+	boolSetting("repo.dissociate", false, func(c *Config) *bool { return &c.Dissociate }),
+	boolSetting("repo.git-lfs", false, func(c *Config) *bool { return &c.GitLFS }),
+	boolSetting("repo.mirror", false, func(c *Config) *bool { return &c.Mirror }),
+	boolSetting("repo.partialclone", false, func(c *Config) *bool { return &c.PartialClone }),
+	stringSetting("repo.partialcloneexclude", "", func(c *Config) *string { return &c.PartialCloneExclude }),
+	stringSetting("repo.reference", "", func(c *Config) *string { return &c.Reference }),
+	boolSetting("repo.submodules", false, func(c *Config) *bool { return &c.Submodules }),
+	boolSetting("repo.superproject", false, func(c *Config) *bool { return &c.UseSuperproject }),
+	boolSetting("repo.worktree", false, func(c *Config) *bool { return &c.Worktree }),
+}
+
+// envSettings 将环境变量映射到设置键
+var envSettings = []struct {
+	env string
+	key string
+}{
+	{"GOGO_GROUPS", "manifest.groups"},
+	{"GOGO_PLATFORM", "manifest.platform"},
+	{"GOGO_MIRROR", "repo.mirror"},
+	{"GOGO_ARCHIVE", "repo.archive"},
+	{"GOGO_DEPTH", "repo.depth"},
+}
+
+func stringSetting(key, def string, field func(*Config) *string) settingField {
+	return settingField{
+		key: key,
+		def: def,
+		get: func(c *Config) string { return *field(c) },
+		set: func(c *Config, value string) error {
+			*field(c) = value
+			return nil
+		},
+	}
+}
+
+func boolSetting(key string, def bool, field func(*Config) *bool) settingField {
+	return settingField{
+		key: key,
+		def: strconv.FormatBool(def),
+		get: func(c *Config) string { return strconv.FormatBool(*field(c)) },
+		set: func(c *Config, value string) error {
+			b, err := ParseBool(value)
+			if err != nil {
+				return err
+			}
+			*field(c) = b
+			return nil
+		},
+	}
+}
+
+func intSetting(key string, def int, field func(*Config) *int) settingField {
+	return settingField{
+		key: key,
+		def: strconv.Itoa(def),
+		get: func(c *Config) string { return strconv.Itoa(*field(c)) },
+		set: func(c *Config, value string) error {
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("invalid integer %q", value)
+			}
+			*field(c) = n
+			return nil
+		},
+	}
+}
+
+// LoadSettings 按 默认值、全局设置、客户端设置、环境变量 的顺序加载设置
+// repoRoot 为空时不加载客户端设置
+func LoadSettings(repoRoot string) (*Settings, error) {
+	s := &Settings{values: make(map[string]Setting)}
+	for _, f := range configSettings {
+		s.values[f.key] = Setting{Key: f.key, Value: f.def, Scope: ScopeDefault}
+	}
+
+	globalPath, err := GlobalSettingsPath()
+	if err == nil {
+		if err := s.loadFile(globalPath, ScopeGlobal); err != nil {
+			return nil, err
+		}
+	}
+	if repoRoot != "" {
+		if err := s.loadFile(ClientSettingsPath(repoRoot), ScopeClient); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, e := range envSettings {
+		if value := os.Getenv(e.env); value != "" {
+			s.values[e.key] = Setting{Key: e.key, Value: value, Scope: ScopeEnv}
+		}
+	}
+	return s, nil
+}
+
+// loadFile 读取设置文件中允许分节的设置，文件不存在时忽略
+func (s *Settings) loadFile(path string, scope Scope) error {
+	values, err := ReadSettingsFile(path)
+	if err != nil {
+		return err
+	}
+	for key, value := range values {
+		if settingSections[section(key)] {
+			s.values[key] = Setting{Key: key, Value: value, Scope: scope}
+		}
+	}
+	return nil
+}
+
+// Get 返回设置的值
+func (s *Settings) Get(key string) (Setting, bool) {
+	if s == nil {
+		return Setting{}, false
+	}
+	setting, ok := s.values[CanonicalKey(key)]
+	return setting, ok
+}
+
+// GetBool 返回布尔设置，未设置或值无效时 ok 为 false
+func (s *Settings) GetBool(key string) (value, ok bool) {
+	setting, found := s.Get(key)
+	if !found {
+		return false, false
+	}
+	b, err := ParseBool(setting.Value)
+	if err != nil {
+		return false, false
+	}
+	return b, true
+}
+
+// Aliases 返回 alias.<name> 设置的命令别名，键为别名名称
+func (s *Settings) Aliases() map[string]string {
+	aliases := make(map[string]string)
+	if s == nil {
+		return aliases
+	}
+	for key, setting := range s.values {
+		name, ok := strings.CutPrefix(key, "alias.")
+		if ok && name != "" && !strings.Contains(name, ".") {
+			aliases[name] = setting.Value
+		}
+	}
+	return aliases
+}
+
+// All 返回按键名排序的所有设置
+func (s *Settings) All() []Setting {
+	all := make([]Setting, 0, len(s.values))
+	for _, setting := range s.values {
+		all = append(all, setting)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Key < all[j].Key })
+	return all
+}
+
+// overlayLegacy 把未迁移的配置中不同于默认值的设置作为客户端设置，与 migrateSettings 写入后再加载的结果一致
+func (s *Settings) overlayLegacy(c *Config) {
+	for _, f := range configSettings {
+		value := f.get(c)
+		if value == f.def || value == "" || s.values[f.key].Scope == ScopeEnv {
+			continue
+		}
+		s.values[f.key] = Setting{Key: f.key, Value: value, Scope: ScopeClient}
+	}
+}
+
+// Apply 将设置写入 Config 的对应字段，无效的值保留字段原值并返回错误
+func (s *Settings) Apply(c *Config) error {
+	var errs []string
+	for _, f := range configSettings {
+		setting, ok := s.values[f.key]
+		if !ok {
+			continue
+		}
+		if err := f.set(c, setting.Value); err != nil {
+			errs = append(errs, fmt.Sprintf("%s (%s): %v", f.key, setting.Scope, err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid settings: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// ValidateSetting 检查设置键是否允许，以及映射到 Config 字段的设置值是否有效
+func ValidateSetting(key, value string) error {
+	key = CanonicalKey(key)
+	if strings.Count(key, ".") < 1 || strings.HasPrefix(key, ".") || strings.HasSuffix(key, ".") {
+		return fmt.Errorf("invalid key %q, expected <section>.<name>", key)
+	}
+	if !settingSections[section(key)] {
+		return fmt.Errorf("unsupported key %q, settings must be in one of the sections: alias, color, manifest, repo, review", key)
+	}
+	for _, f := range configSettings {
+		if f.key == key {
+			if err := f.set(&Config{}, value); err != nil {
+				return fmt.Errorf("invalid value for %s: %w", key, err)
+			}
+		}
+	}
+	return nil
+}
+
+// GlobalSettingsPath 返回全局设置文件 ~/.repoconfig/config 的路径
+func GlobalSettingsPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(home, ".repoconfig", "config"), nil
+}
+
+// ClientSettingsPath 返回客户端设置文件的路径，即清单仓库的 git 配置
+// 优先使用 .repo/manifests.git，兼容清单仓库直接检出在 .repo/manifests 中的客户端
+func ClientSettingsPath(repoRoot string) string {
+	bare := filepath.Join(repoRoot, ".repo", "manifests.git")
+	if info, err := os.Stat(bare); err == nil && info.IsDir() {
+		return filepath.Join(bare, "config")
+	}
+	gitDir := filepath.Join(repoRoot, ".repo", "manifests", ".git")
+	if info, err := os.Stat(gitDir); err == nil && info.IsDir() {
+		return filepath.Join(gitDir, "config")
+	}
+	return filepath.Join(bare, "config")
+}
+
+// ReadSettingsFile 读取 git 配置格式的文件，文件不存在时返回空结果
+// 同一个键出现多次时使用最后一个值
+func ReadSettingsFile(path string) (map[string]string, error) {
+	values := make(map[string]string)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return values, nil
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.Command("git", "config", "--file", path, "--null", "--list")
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, &ConfigError{Op: "read_settings", Path: path, Err: gitConfigError(err, &stderr)}
+	}
+	for _, entry := range strings.Split(string(out), "\x00") {
+		if entry == "" {
+			continue
+		}
+		key, value, _ := strings.Cut(entry, "\n")
+		values[key] = value
+	}
+	return values, nil
+}
+
+// SetSetting 在设置文件中设置键值，文件不存在时创建
+func SetSetting(path, key, value string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return &ConfigError{Op: "set_setting", Path: path, Err: err}
+	}
+	var stderr bytes.Buffer
+	cmd := exec.Command("git", "config", "--file", path, key, value)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return &ConfigError{Op: "set_setting", Path: path, Err: gitConfigError(err, &stderr)}
+	}
+	return nil
+}
+
+// ErrSettingNotSet 表示要删除的设置不存在
+var ErrSettingNotSet = errors.New("setting is not set")
+
+// UnsetSetting 从设置文件中删除键的所有值
+func UnsetSetting(path, key string) error {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return &ConfigError{Op: "unset_setting", Path: path, Err: ErrSettingNotSet}
+	}
+	var stderr bytes.Buffer
+	cmd := exec.Command("git", "config", "--file", path, "--unset-all", key)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		// git config 在键不存在时返回5
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 5 {
+			return &ConfigError{Op: "unset_setting", Path: path, Err: ErrSettingNotSet}
+		}
+		return &ConfigError{Op: "unset_setting", Path: path, Err: gitConfigError(err, &stderr)}
+	}
+	return nil
+}
+
+// CanonicalKey 返回 git 规范形式的键名：分节名和变量名小写，子分节保持原样
+func CanonicalKey(key string) string {
+	first := strings.Index(key, ".")
+	last := strings.LastIndex(key, ".")
+	if first < 0 {
+		return strings.ToLower(key)
+	}
+	return strings.ToLower(key[:first]) + key[first:last] + strings.ToLower(key[last:])
+}
+
+// ParseBool 按 git 的规则解析布尔值
+func ParseBool(value string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "true", "yes", "on", "1":
+		return true, nil
+	case "false", "no", "off", "0", "":
+		return false, nil
+	}
+	return false, fmt.Errorf("invalid boolean %q", value)
+}
+
+// section 返回键的分节名
+func section(key string) string {
+	name, _, _ := strings.Cut(key, ".")
+	return name
+}
+
+// gitConfigError 使用 git config 的错误输出描述错误
+func gitConfigError(err error, stderr *bytes.Buffer) error {
+	if msg := strings.TrimSpace(stderr.String()); msg != "" {
+		return errors.New(msg)
+	}
+	return err
+}
@@ -0,0 +1,252 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCanonicalKey(t *testing.T) {
+	tests := map[string]string{
+		"repo.partialClone": "repo.partialclone",
+		"Color.UI":          "color.ui",
+		"review.https://Gerrit.Example.com/.AutoUpload": "review.https://Gerrit.Example.com/.autoupload",
+		"core": "core",
+	}
+	for key, want := range tests {
+		if got := CanonicalKey(key); got != want {
+			t.Errorf("CanonicalKey(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestValidateSetting(t *testing.T) {
+	valid := [][2]string{
+		{"repo.depth", "1"},
+		{"repo.partialclone", "yes"},
+		{"review.https://review.example.com/.autoupload", "true"},
+		{"color.ui", "never"},
+	}
+	for _, kv := range valid {
+		if err := ValidateSetting(kv[0], kv[1]); err != nil {
+			t.Errorf("ValidateSetting(%q, %q) = %v", kv[0], kv[1], err)
+		}
+	}
+
+	invalid := [][2]string{
+		{"repo.depth", "shallow"},
+		{"repo.mirror", "maybe"},
+		{"core.bare", "true"},
+		{"depth", "1"},
+	}
+	for _, kv := range invalid {
+		if err := ValidateSetting(kv[0], kv[1]); err == nil {
+			t.Errorf("ValidateSetting(%q, %q) succeeded", kv[0], kv[1])
+		}
+	}
+}
+
+// newTestClient 创建带清单仓库 git 配置的客户端，并把 HOME 指向临时目录
+func newTestClient(t *testing.T) (repoRoot, globalPath string) {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	for _, env := range envSettings {
+		t.Setenv(env.env, "")
+	}
+
+	repoRoot = t.TempDir()
+	if err := os.MkdirAll(filepath.Join(repoRoot, ".repo", "manifests.git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	globalPath, err := GlobalSettingsPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return repoRoot, globalPath
+}
+
+func TestLoadSettingsLayers(t *testing.T) {
+	repoRoot, globalPath := newTestClient(t)
+	clientPath := ClientSettingsPath(repoRoot)
+	if clientPath != filepath.Join(repoRoot, ".repo", "manifests.git", "config") {
+		t.Fatalf("ClientSettingsPath() = %q", clientPath)
+	}
+
+	for _, kv := range [][3]string{
+		{globalPath, "repo.depth", "1"},
+		{globalPath, "color.ui", "never"},
+		{globalPath, "review.https://review.example.com/.autoupload", "false"},
+		{clientPath, "repo.depth", "2"},
+		{clientPath, "repo.partialclone", "true"},
+		{clientPath, "core.bare", "false"},
+	} {
+		if err := SetSetting(kv[0], kv[1], kv[2]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("GOGO_GROUPS", "default,tools")
+
+	settings, err := LoadSettings(repoRoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := map[string]Setting{
+		"repo.depth":        {Value: "2", Scope: ScopeClient},
+		"color.ui":          {Value: "never", Scope: ScopeGlobal},
+		"repo.partialclone": {Value: "true", Scope: ScopeClient},
+		"repo.clonebundle":  {Value: "true", Scope: ScopeDefault},
+		"manifest.groups":   {Value: "default,tools", Scope: ScopeEnv},
+	}
+	for key, want := range expect {
+		got, ok := settings.Get(key)
+		if !ok || got.Value != want.Value || got.Scope != want.Scope {
+			t.Errorf("Get(%q) = %+v, %v; want %+v", key, got, ok, want)
+		}
+	}
+	if _, ok := settings.Get("core.bare"); ok {
+		t.Errorf("git settings of the manifest repository should not be loaded")
+	}
+	if allowed, ok := settings.GetBool("review.https://review.example.com/.autoUpload"); !ok || allowed {
+		t.Errorf("GetBool(autoupload) = %v, %v", allowed, ok)
+	}
+
+	var cfg Config
+	if err := settings.Apply(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Depth != 2 || !cfg.PartialClone || cfg.Color != "never" || cfg.Groups != "default,tools" || !cfg.CloneBundle {
+		t.Errorf("Apply() = %+v", cfg)
+	}
+
+	if err := UnsetSetting(clientPath, "repo.depth"); err != nil {
+		t.Fatal(err)
+	}
+	if err := UnsetSetting(clientPath, "repo.depth"); !errors.Is(err, ErrSettingNotSet) {
+		t.Errorf("second UnsetSetting() = %v, want ErrSettingNotSet", err)
+	}
+	settings, err = LoadSettings(repoRoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := settings.Get("repo.depth"); got.Value != "1" || got.Scope != ScopeGlobal {
+		t.Errorf("after unset Get(repo.depth) = %+v", got)
+	}
+}
+
+func TestMigrateConfig(t *testing.T) {
+	repoRoot, _ := newTestClient(t)
+	clientPath := ClientSettingsPath(repoRoot)
+	if err := SetSetting(clientPath, "repo.depth", "5"); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetSetting(clientPath, "repo.submodules", "true"); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &Config{Depth: 1, PartialClone: true, Groups: "default", CloneBundle: true}
+	migrated, err := migrateConfig(cfg, repoRoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !migrated || cfg.Version != currentConfigVersion {
+		t.Fatalf("migrateConfig() = %v, version %d", migrated, cfg.Version)
+	}
+
+	values, err := ReadSettingsFile(clientPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"repo.depth":        "1",
+		"repo.partialclone": "true",
+		"manifest.groups":   "default",
+		"repo.submodules":   "true", // 未在 config.json 中指定的设置保持不变
+	}
+	for key, value := range want {
+		if values[key] != value {
+			t.Errorf("%s = %q, want %q", key, values[key], value)
+		}
+	}
+	// 与默认值相同的设置不写入
+	for _, key := range []string{"repo.clonebundle", "repo.mirror", "color.ui"} {
+		if _, ok := values[key]; ok {
+			t.Errorf("%s should not be migrated", key)
+		}
+	}
+
+	if migrated, err := migrateConfig(cfg, repoRoot); err != nil || migrated {
+		t.Errorf("second migrateConfig() = %v, %v", migrated, err)
+	}
+	if _, err := migrateConfig(&Config{Version: 99}, repoRoot); err == nil {
+		t.Errorf("migrateConfig() accepted an unknown version")
+	}
+}
+
+func TestAliases(t *testing.T) {
+	repoRoot, globalPath := newTestClient(t)
+	clientPath := ClientSettingsPath(repoRoot)
+	for _, kv := range [][3]string{
+		{globalPath, "alias.up", "upload --cbr --yes"},
+		{globalPath, "alias.st", "status"},
+		{clientPath, "alias.st", "status -o"},
+		{clientPath, "alias.x.y", "ignored"},
+	} {
+		if err := SetSetting(kv[0], kv[1], kv[2]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	settings, err := LoadSettings(repoRoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	aliases := settings.Aliases()
+	if len(aliases) != 2 || aliases["up"] != "upload --cbr --yes" || aliases["st"] != "status -o" {
+		t.Errorf("Aliases() = %v", aliases)
+	}
+	if err := ValidateSetting("alias.up", "upload"); err != nil {
+		t.Errorf("ValidateSetting(alias.up) = %v", err)
+	}
+}
+
+func TestLoadReadOnly(t *testing.T) {
+	repoRoot, _ := newTestClient(t)
+	clientPath := ClientSettingsPath(repoRoot)
+	if err := SetSetting(clientPath, "repo.submodules", "true"); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GOGO_GROUPS", "tools")
+	configPath := filepath.Join(repoRoot, ".repo", "config.json")
+	data := []byte(`{"version": 1, "manifest_name": "default.xml", "groups": "default,docs", "depth": 1}`)
+	if err := os.WriteFile(configPath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	settings, err := os.ReadFile(clientPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 未迁移的配置按迁移后的结果合并，环境变量仍然优先
+	cfg, err := LoadReadOnly(repoRoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Groups != "tools" || cfg.Depth != 1 || !cfg.Submodules || cfg.ManifestName != "default.xml" || cfg.RepoRoot != repoRoot {
+		t.Errorf("LoadReadOnly() = groups %q, depth %d, submodules %v, manifest %q, root %q",
+			cfg.Groups, cfg.Depth, cfg.Submodules, cfg.ManifestName, cfg.RepoRoot)
+	}
+	t.Setenv("GOGO_GROUPS", "")
+	if cfg, err := LoadReadOnly(repoRoot); err != nil || cfg.Groups != "default,docs" {
+		t.Errorf("LoadReadOnly() groups = %q, %v, want the config.json value", cfg.Groups, err)
+	}
+
+	// 不迁移也不写入任何文件
+	if got, _ := os.ReadFile(configPath); string(got) != string(data) {
+		t.Errorf("config.json was rewritten: %s", got)
+	}
+	if got, _ := os.ReadFile(clientPath); string(got) != string(settings) {
+		t.Errorf("client settings were rewritten:\n%s", got)
+	}
+}
@@ -0,0 +1,172 @@
+package event
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// 任务名称，与上游 repo 事件日志中的 task_name 一致
+const (
+	TaskCommand     = "command"
+	TaskSyncNetwork = "sync-network"
+	TaskSyncLocal   = "sync-local"
+	TaskGit         = "git" // git 子进程
+)
+
+// eventKind 是事件 ID 中的类型部分
+const eventKind = "RepoOp"
+
+// Log 记录一次命令执行中的事件，写出格式与上游 repo 的 --event-log 相同
+type Log struct {
+	mu     sync.Mutex
+	events []*Event
+	nextID int
+	parent *Event
+}
+
+// Event 表示事件日志中的一个事件
+type Event struct {
+	log    *Log
+	task   string
+	name   string
+	start  time.Time
+	finish time.Time
+	fields map[string]interface{}
+}
+
+// NewLog 创建一个空的事件日志
+func NewLog() *Log {
+	return &Log{nextID: 1}
+}
+
+// Add 添加一个在 start 开始的事件，之后添加的事件以当前父事件为 parent
+func (l *Log) Add(name, taskName string, start time.Time) *Event {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	ev := &Event{
+		log:   l,
+		task:  taskName,
+		name:  name,
+		start: start,
+		fields: map[string]interface{}{
+			"id":         []interface{}{eventKind, l.nextID},
+			"name":       name,
+			"task_name":  taskName,
+			"start_time": unixSeconds(start),
+			"try":        1,
+		},
+	}
+	l.nextID++
+	if l.parent != nil {
+		ev.fields["parent"] = l.parent.fields["id"]
+	}
+	l.events = append(l.events, ev)
+	return ev
+}
+
+// SetParent 设置之后添加的事件的父事件
+func (l *Log) SetParent(ev *Event) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.parent = ev
+}
+
+// Set 设置事件的附加字段，ev 为 nil 时不做任何事
+func (ev *Event) Set(key string, value interface{}) *Event {
+	if ev == nil {
+		return nil
+	}
+	ev.log.mu.Lock()
+	defer ev.log.mu.Unlock()
+	ev.fields[key] = value
+	return ev
+}
+
+// Finish 以当前时间结束事件，ev 为 nil 时不做任何事
+func (ev *Event) Finish(success bool) {
+	if ev == nil {
+		return
+	}
+	ev.log.mu.Lock()
+	defer ev.log.mu.Unlock()
+
+	ev.finish = time.Now()
+	status := "fail"
+	if success {
+		status = "pass"
+	}
+	ev.fields["finish_time"] = unixSeconds(ev.finish)
+	ev.fields["success"] = success
+	ev.fields["status"] = status
+}
+
+// Write 将事件追加到文件，每行一个按键名排序的 JSON 对象
+// 多次执行的 repo 命令可以使用同一个事件日志文件，每次执行的事件一次写入，不会与其他进程的事件交错
+func (l *Log) Write(path string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, ev := range l.events {
+		if err := enc.Encode(ev.fields); err != nil {
+			return fmt.Errorf("failed to encode event log: %w", err)
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create event log directory: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open event log: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to write event log: %w", err)
+	}
+	return f.Close()
+}
+
+// unixSeconds 返回以秒为单位的 Unix 时间戳，与上游的 time.time() 一致
+func unixSeconds(t time.Time) float64 {
+	return float64(t.UnixNano()) / float64(time.Second)
+}
+
+var (
+	defaultMu  sync.RWMutex
+	defaultLog *Log
+)
+
+// Enable 为当前进程开启事件记录并返回事件日志
+func Enable() *Log {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	if defaultLog == nil {
+		defaultLog = NewLog()
+	}
+	return defaultLog
+}
+
+// Default 返回当前进程的事件日志，未开启时返回 nil
+func Default() *Log {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultLog
+}
+
+// Add 在当前进程的事件日志中添加事件，未开启事件记录时返回 nil
+func Add(name, taskName string, start time.Time) *Event {
+	l := Default()
+	if l == nil {
+		return nil
+	}
+	return l.Add(name, taskName, start)
+}
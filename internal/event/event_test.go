@@ -0,0 +1,166 @@
+package event
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func readEvents(t *testing.T, path string) []map[string]interface{} {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var events []map[string]interface{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var ev map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &ev); err != nil {
+			t.Fatalf("invalid event line %q: %v", scanner.Text(), err)
+		}
+		events = append(events, ev)
+	}
+	return events
+}
+
+func TestLogWrite(t *testing.T) {
+	l := NewLog()
+	start := time.Now()
+	cmd := l.Add("sync", TaskCommand, start)
+	l.SetParent(cmd)
+	l.Add("platform/build", TaskSyncNetwork, start).Set("project", "platform/build").Finish(true)
+	l.Add("git fetch", TaskGit, start).Set("exit_code", 128).Finish(false)
+	cmd.Finish(true)
+
+	path := filepath.Join(t.TempDir(), "log", "events.json")
+	if err := l.Write(path); err != nil {
+		t.Fatal(err)
+	}
+	events := readEvents(t, path)
+	if len(events) != 3 {
+		t.Fatalf("got %d events, want 3", len(events))
+	}
+
+	if id := events[0]["id"].([]interface{}); id[0] != "RepoOp" || id[1] != float64(1) {
+		t.Errorf("command id = %v", id)
+	}
+	if _, ok := events[0]["parent"]; ok {
+		t.Errorf("command event has a parent")
+	}
+	if events[0]["task_name"] != "command" || events[0]["status"] != "pass" {
+		t.Errorf("command event = %v", events[0])
+	}
+
+	sync := events[1]
+	if parent := sync["parent"].([]interface{}); parent[1] != float64(1) {
+		t.Errorf("sync parent = %v", parent)
+	}
+	if sync["name"] != "platform/build" || sync["project"] != "platform/build" || sync["success"] != true {
+		t.Errorf("sync event = %v", sync)
+	}
+	if sync["finish_time"].(float64) < sync["start_time"].(float64) {
+		t.Errorf("finish_time before start_time: %v", sync)
+	}
+
+	if git := events[2]; git["status"] != "fail" || git["success"] != false || git["exit_code"] != float64(128) {
+		t.Errorf("git event = %v", git)
+	}
+
+	// 另一次执行的事件追加到同一个文件
+	other := NewLog()
+	other.Add("status", TaskCommand, time.Now()).Finish(false)
+	if err := other.Write(path); err != nil {
+		t.Fatal(err)
+	}
+	events = readEvents(t, path)
+	if len(events) != 4 {
+		t.Fatalf("appended log has %d events, want 4", len(events))
+	}
+	if events[0]["name"] != "sync" || events[3]["name"] != "status" || events[3]["status"] != "fail" {
+		t.Errorf("appended events = %v, %v", events[0], events[3])
+	}
+}
+
+func TestNilEvent(t *testing.T) {
+	var ev *Event
+	ev.Set("project", "a").Finish(true)
+	if Default() == nil && Add("a", TaskGit, time.Now()) != nil {
+		t.Errorf("Add returned an event while recording is disabled")
+	}
+}
+
+func TestWriteTimings(t *testing.T) {
+	l := NewLog()
+	now := time.Now()
+	l.Add("sync", TaskCommand, now.Add(-5*time.Second)).Finish(true)
+	l.Add("a", TaskSyncNetwork, now.Add(-2*time.Second)).Finish(true)
+	l.Add("b", TaskSyncNetwork, now.Add(-3*time.Second)).Finish(false)
+	l.Add("a", TaskSyncLocal, now.Add(-time.Second)).Finish(true)
+	l.Add("c", TaskSyncLocal, now) // 未结束的事件不计入
+
+	timings := l.Timings()
+	if len(timings) != 2 || timings[0].Task != TaskSyncNetwork || timings[1].Task != TaskSyncLocal {
+		t.Fatalf("timings = %+v", timings)
+	}
+	if timings[0].Count != 2 || timings[0].Slowest != "b" {
+		t.Errorf("network timing = %+v", timings[0])
+	}
+
+	var buf bytes.Buffer
+	WriteTimings(&buf, 62*time.Second+345*time.Millisecond, l)
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if lines[0] != "real\t1m2.345s" {
+		t.Errorf("real line = %q", lines[0])
+	}
+	if len(lines) != 3 || !strings.HasPrefix(lines[1], "sync-network") {
+		t.Errorf("timings output:\n%s", buf.String())
+	}
+
+	if got := formatReal(time.Hour + 2*time.Minute + 3500*time.Millisecond); got != "1h2m3.500s" {
+		t.Errorf("formatReal() = %q", got)
+	}
+}
+
+func TestTrace2Log(t *testing.T) {
+	t.Setenv(trace2ParentSIDEnv, "parent-sid")
+	tr := NewTrace2Log("v1.0")
+	if !strings.HasPrefix(tr.SID(), "parent-sid/repo-") {
+		t.Errorf("SID() = %q", tr.SID())
+	}
+	if got := os.Getenv(trace2ParentSIDEnv); got != tr.SID() {
+		t.Errorf("%s = %q, want %q", trace2ParentSIDEnv, got, tr.SID())
+	}
+	tr.CommandEvent("sync")
+	tr.ExitEvent(1)
+
+	dir := t.TempDir()
+	if err := tr.Write(dir); err != nil {
+		t.Fatal(err)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "repo-*"))
+	if len(files) != 1 {
+		t.Fatalf("got trace2 files %v", files)
+	}
+	events := readEvents(t, files[0])
+	var names []string
+	for _, ev := range events {
+		names = append(names, ev["event"].(string))
+		if ev["sid"] != tr.SID() {
+			t.Errorf("event sid = %v", ev["sid"])
+		}
+	}
+	if got := strings.Join(names, ","); got != "version,start,cmd_name,exit" {
+		t.Errorf("events = %s", got)
+	}
+	if events[3]["code"] != float64(1) {
+		t.Errorf("exit event = %v", events[3])
+	}
+}
@@ -0,0 +1,82 @@
+package event
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// TaskTiming 汇总同一类任务的耗时
+type TaskTiming struct {
+	Task    string
+	Count   int
+	Total   time.Duration // 各任务耗时之和，并发执行时可能超过实际耗时
+	Longest time.Duration
+	Slowest string // 耗时最长的任务名称
+}
+
+// Timings 按任务类型汇总已结束事件的耗时，不包括命令本身，按总耗时降序排列
+func (l *Log) Timings() []TaskTiming {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	byTask := make(map[string]*TaskTiming)
+	var order []string
+	for _, ev := range l.events {
+		if ev.task == TaskCommand || ev.finish.IsZero() {
+			continue
+		}
+		t, ok := byTask[ev.task]
+		if !ok {
+			t = &TaskTiming{Task: ev.task}
+			byTask[ev.task] = t
+			order = append(order, ev.task)
+		}
+		d := ev.finish.Sub(ev.start)
+		t.Count++
+		t.Total += d
+		if d > t.Longest {
+			t.Longest = d
+			t.Slowest = ev.name
+		}
+	}
+
+	timings := make([]TaskTiming, 0, len(order))
+	for _, task := range order {
+		timings = append(timings, *byTask[task])
+	}
+	sort.SliceStable(timings, func(i, j int) bool {
+		return timings[i].Total > timings[j].Total
+	})
+	return timings
+}
+
+// WriteTimings 输出命令的实际耗时和各类任务的耗时，用于 --time
+// 第一行与上游 repo 相同；l 为 nil 时只输出实际耗时
+func WriteTimings(w io.Writer, elapsed time.Duration, l *Log) {
+	fmt.Fprintf(w, "real\t%s\n", formatReal(elapsed))
+	if l == nil {
+		return
+	}
+	for _, t := range l.Timings() {
+		fmt.Fprintf(w, "%-14s %5d  total %9s  longest %9s  %s\n",
+			t.Task, t.Count, formatSeconds(t.Total), formatSeconds(t.Longest), t.Slowest)
+	}
+}
+
+// formatReal 按 time(1) 的格式输出耗时，如 1m2.345s、1h2m3.456s
+func formatReal(d time.Duration) string {
+	hours := int(d / time.Hour)
+	minutes := int(d % time.Hour / time.Minute)
+	seconds := float64(d%time.Minute) / float64(time.Second)
+	if hours == 0 {
+		return fmt.Sprintf("%dm%.3fs", minutes, seconds)
+	}
+	return fmt.Sprintf("%dh%dm%.3fs", hours, minutes, seconds)
+}
+
+// formatSeconds 以秒为单位输出耗时
+func formatSeconds(d time.Duration) string {
+	return fmt.Sprintf("%.3fs", d.Seconds())
+}
@@ -0,0 +1,111 @@
+package event
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// trace2ParentSIDEnv 是 git 用来关联父进程 trace2 会话的环境变量
+const trace2ParentSIDEnv = "GIT_TRACE2_PARENT_SID"
+
+// Trace2Log 以 git trace2 事件格式记录 repo 自身的事件
+// 创建时设置 GIT_TRACE2_PARENT_SID，之后启动的 git 子进程的会话 ID 都以本会话为前缀
+type Trace2Log struct {
+	mu      sync.Mutex
+	sid     string // 本进程的会话 ID 组件，也用作日志文件名前缀
+	fullSID string
+	start   time.Time
+	events  []map[string]interface{}
+}
+
+// NewTrace2Log 创建 trace2 事件日志，并记录 version 和 start 事件
+func NewTrace2Log(version string) *Trace2Log {
+	start := time.Now().UTC()
+	t := &Trace2Log{
+		sid:   fmt.Sprintf("repo-%s-P%08x", start.Format("20060102T150405Z"), os.Getpid()),
+		start: start,
+	}
+	t.fullSID = t.sid
+	if parent := os.Getenv(trace2ParentSIDEnv); parent != "" {
+		t.fullSID = parent + "/" + t.sid
+	}
+	os.Setenv(trace2ParentSIDEnv, t.fullSID)
+
+	ev := t.newEvent("version")
+	ev["evt"] = "2"
+	ev["exe"] = version
+	t.add(ev)
+
+	ev = t.newEvent("start")
+	ev["argv"] = os.Args
+	t.add(ev)
+	return t
+}
+
+// SID 返回完整的会话 ID
+func (t *Trace2Log) SID() string {
+	return t.fullSID
+}
+
+// CommandEvent 记录执行的 repo 子命令
+func (t *Trace2Log) CommandEvent(name string) {
+	ev := t.newEvent("cmd_name")
+	ev["name"] = name
+	ev["hierarchy"] = name
+	t.add(ev)
+}
+
+// ExitEvent 记录进程退出码和总耗时
+func (t *Trace2Log) ExitEvent(code int) {
+	ev := t.newEvent("exit")
+	ev["t_abs"] = time.Since(t.start).Seconds()
+	ev["code"] = code
+	t.add(ev)
+}
+
+// Write 将事件写入 path
+// path 是目录时在其中创建以会话 ID 为前缀的新文件，与 git 对目录目标的处理一致；否则追加到该文件
+func (t *Trace2Log) Write(path string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var f *os.File
+	var err error
+	if info, statErr := os.Stat(path); statErr == nil && info.IsDir() {
+		f, err = os.CreateTemp(path, t.sid+"-*")
+	} else {
+		f, err = os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open trace2 event log: %w", err)
+	}
+	defer f.Close()
+
+	enc := json.NewEncoder(f)
+	for _, ev := range t.events {
+		if err := enc.Encode(ev); err != nil {
+			return fmt.Errorf("failed to write trace2 event log: %w", err)
+		}
+	}
+	return f.Close()
+}
+
+// newEvent 创建包含公共字段的 trace2 事件
+func (t *Trace2Log) newEvent(name string) map[string]interface{} {
+	return map[string]interface{}{
+		"event":  name,
+		"sid":    t.fullSID,
+		"thread": "main",
+		"time":   time.Now().UTC().Format("2006-01-02T15:04:05.000000Z"),
+	}
+}
+
+// add 追加事件
+func (t *Trace2Log) add(ev map[string]interface{}) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.events = append(t.events, ev)
+}
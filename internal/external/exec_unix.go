@@ -0,0 +1,16 @@
+//go:build !windows
+
+package external
+
+import (
+	"os"
+	"strings"
+)
+
+// commandName 返回可执行文件对应的子命令名称，文件不可执行时 ok 为 false
+func commandName(file string, info os.FileInfo) (name string, ok bool) {
+	if info.Mode().Perm()&0111 == 0 {
+		return "", false
+	}
+	return strings.TrimPrefix(file, Prefix), true
+}
@@ -0,0 +1,27 @@
+//go:build windows
+
+package external
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// commandName 返回可执行文件对应的子命令名称，扩展名不在 PATHEXT 中时 ok 为 false
+func commandName(file string, info os.FileInfo) (name string, ok bool) {
+	ext := strings.ToLower(filepath.Ext(file))
+	if ext == "" {
+		return "", false
+	}
+	pathext := os.Getenv("PATHEXT")
+	if pathext == "" {
+		pathext = ".com;.exe;.bat;.cmd"
+	}
+	for _, e := range filepath.SplitList(strings.ToLower(pathext)) {
+		if e == ext {
+			return strings.TrimPrefix(strings.TrimSuffix(file, file[len(file)-len(ext):]), Prefix), true
+		}
+	}
+	return "", false
+}
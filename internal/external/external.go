@@ -0,0 +1,207 @@
+package external
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Prefix 是外部子命令可执行文件名的前缀，repo foo 运行 PATH 中的 repo-foo
+const Prefix = "repo-"
+
+// descriptionMarker 标记外部命令的描述，写在脚本开头的注释中，例如：
+//
+//	# repo-description: show build information of the client
+const descriptionMarker = "repo-description:"
+
+// descriptionScanLimit 是查找描述时读取的最大字节数
+const descriptionScanLimit = 4096
+
+// 传给外部命令的环境变量
+const (
+	EnvClientRoot   = "REPO_CLIENT_ROOT"   // 客户端根目录
+	EnvManifest     = "REPO_MANIFEST"      // 当前清单文件的路径
+	EnvProjects     = "REPO_PROJECTS"      // 项目路径列表，每行一个
+	EnvProjectsFile = "REPO_PROJECTS_FILE" // 项目列表过长时改为写入的文件
+)
+
+// maxProjectsEnv 是 REPO_PROJECTS 的最大长度，超过时项目列表写入临时文件，
+// 避免超出操作系统对单个环境变量的长度限制
+const maxProjectsEnv = 64 * 1024
+
+// Command 表示 PATH 中找到的外部命令
+type Command struct {
+	Name        string // 子命令名称，不含前缀
+	Path        string // 可执行文件的路径
+	Description string // 脚本中 repo-description 注释给出的描述
+}
+
+// Find 在 PATH 中查找子命令对应的可执行文件
+func Find(name string) (string, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) {
+		return "", exec.ErrNotFound
+	}
+	return exec.LookPath(Prefix + name)
+}
+
+// List 返回 PATH 中所有外部命令，按名称排序，同名的命令只保留 PATH 中靠前的一个
+func List() []Command {
+	seen := make(map[string]bool)
+	var cmds []Command
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		if dir == "" {
+			dir = "."
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if !strings.HasPrefix(entry.Name(), Prefix) || entry.IsDir() {
+				continue
+			}
+			path := filepath.Join(dir, entry.Name())
+			info, err := os.Stat(path)
+			if err != nil || info.IsDir() {
+				continue
+			}
+			name, ok := commandName(entry.Name(), info)
+			if !ok || name == "" || seen[name] {
+				continue
+			}
+			seen[name] = true
+			cmds = append(cmds, Command{Name: name, Path: path, Description: Description(path)})
+		}
+	}
+	sort.Slice(cmds, func(i, j int) bool { return cmds[i].Name < cmds[j].Name })
+	return cmds
+}
+
+// Description 返回可执行文件开头 repo-description 注释中的描述，没有时返回空字符串
+func Description(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(io.LimitReader(f, descriptionScanLimit))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, descriptionMarker); i >= 0 {
+			return strings.TrimSpace(line[i+len(descriptionMarker):])
+		}
+	}
+	return ""
+}
+
+// Env 返回运行外部命令时追加的环境变量，clientRoot 为空表示不在客户端中
+// 返回的 cleanup 删除项目列表过长时写入的临时文件
+func Env(clientRoot, manifestPath string, projects []string) (env []string, cleanup func(), err error) {
+	cleanup = func() {}
+	if clientRoot == "" {
+		return nil, cleanup, nil
+	}
+	env = append(env, EnvClientRoot+"="+clientRoot)
+	if manifestPath != "" {
+		env = append(env, EnvManifest+"="+manifestPath)
+	}
+
+	list := strings.Join(projects, "\n")
+	if len(list) <= maxProjectsEnv {
+		return append(env, EnvProjects+"="+list), cleanup, nil
+	}
+
+	f, err := os.CreateTemp("", "repo-projects-*")
+	if err != nil {
+		return nil, cleanup, fmt.Errorf("failed to create project list file: %w", err)
+	}
+	_, err = f.WriteString(list + "\n")
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return nil, cleanup, fmt.Errorf("failed to write project list file: %w", err)
+	}
+	return append(env, EnvProjectsFile+"="+f.Name()), func() { os.Remove(f.Name()) }, nil
+}
+
+// Run 运行外部命令并返回其退出码，标准输入输出直接连接到终端
+// 命令无法启动时返回错误
+func Run(path string, args, env []string) (int, error) {
+	cmd := exec.Command(path, args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(), env...)
+
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if code := exitErr.ExitCode(); code >= 0 {
+			return code, nil
+		}
+		return 1, nil
+	}
+	if err != nil {
+		return 1, fmt.Errorf("failed to run %s: %w", path, err)
+	}
+	return 0, nil
+}
+
+// SplitArgs 按 shell 的规则拆分别名的值，支持单引号、双引号和反斜杠转义
+func SplitArgs(s string) ([]string, error) {
+	var args []string
+	var cur strings.Builder
+	inArg := false
+	var quote rune
+	escaped := false
+	for _, r := range s {
+		switch {
+		case escaped:
+			cur.WriteRune(r)
+			escaped = false
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case r == '\\':
+			escaped = true
+			inArg = true
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, cur.String())
+				cur.Reset()
+				inArg = false
+			}
+		default:
+			cur.WriteRune(r)
+			inArg = true
+		}
+	}
+	if escaped || quote != 0 {
+		return nil, fmt.Errorf("unterminated quote or escape in %q", s)
+	}
+	if inArg {
+		args = append(args, cur.String())
+	}
+	return args, nil
+}
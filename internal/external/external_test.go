@@ -0,0 +1,103 @@
+package external
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestSplitArgs(t *testing.T) {
+	tests := map[string][]string{
+		"upload --cbr --yes":        {"upload", "--cbr", "--yes"},
+		`forall -c "git log -1"`:    {"forall", "-c", "git log -1"},
+		`grep -e 'a "b"' x\ y ""`:   {"grep", "-e", `a "b"`, "x y", ""},
+		"  status\t-o \n":           {"status", "-o"},
+		`start "topic \"x\"" --all`: {"start", `topic "x"`, "--all"},
+	}
+	for in, want := range tests {
+		got, err := SplitArgs(in)
+		if err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("SplitArgs(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	for _, in := range []string{`upload "x`, `status 'a`, `list \`} {
+		if _, err := SplitArgs(in); err == nil {
+			t.Errorf("SplitArgs(%q) succeeded", in)
+		}
+	}
+}
+
+func TestList(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses shell scripts")
+	}
+	dir1, dir2 := t.TempDir(), t.TempDir()
+	write := func(dir, name, content string, mode os.FileMode) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(dir1, "repo-owners", "#!/bin/sh\n# repo-description: list project owners\necho owners\n", 0755)
+	write(dir1, "repo-notes", "not executable\n", 0644)
+	write(dir2, "repo-owners", "#!/bin/sh\n# repo-description: shadowed\n", 0755)
+	write(dir2, "repo-buildinfo", "#!/bin/sh\necho buildinfo\n", 0755)
+	write(dir2, "git-repo", "#!/bin/sh\n", 0755)
+	t.Setenv("PATH", dir1+string(os.PathListSeparator)+dir2)
+
+	want := []Command{
+		{Name: "buildinfo", Path: filepath.Join(dir2, "repo-buildinfo")},
+		{Name: "owners", Path: filepath.Join(dir1, "repo-owners"), Description: "list project owners"},
+	}
+	if got := List(); !reflect.DeepEqual(got, want) {
+		t.Errorf("List() = %+v, want %+v", got, want)
+	}
+
+	if path, err := Find("owners"); err != nil || path != filepath.Join(dir1, "repo-owners") {
+		t.Errorf("Find(owners) = %q, %v", path, err)
+	}
+	for _, name := range []string{"notes", "missing", "../owners"} {
+		if _, err := Find(name); err == nil {
+			t.Errorf("Find(%q) succeeded", name)
+		}
+	}
+}
+
+func TestEnv(t *testing.T) {
+	env, cleanup, err := Env("", "", []string{"a"})
+	cleanup()
+	if err != nil || len(env) != 0 {
+		t.Errorf("Env() outside a client = %q, %v", env, err)
+	}
+
+	env, cleanup, err = Env("/src", "/src/.repo/manifest.xml", []string{"build", "art"})
+	cleanup()
+	want := []string{"REPO_CLIENT_ROOT=/src", "REPO_MANIFEST=/src/.repo/manifest.xml", "REPO_PROJECTS=build\nart"}
+	if err != nil || !reflect.DeepEqual(env, want) {
+		t.Errorf("Env() = %q, %v; want %q", env, err, want)
+	}
+
+	// 项目列表过长时写入临时文件
+	projects := make([]string, 0, 10000)
+	for i := 0; i < 10000; i++ {
+		projects = append(projects, "platform/external/project")
+	}
+	env, cleanup, err = Env("/src", "", projects)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, ok := strings.CutPrefix(env[len(env)-1], EnvProjectsFile+"=")
+	if !ok {
+		t.Fatalf("Env() = %q, want %s", env, EnvProjectsFile)
+	}
+	data, err := os.ReadFile(file)
+	if err != nil || strings.Count(string(data), "\n") != len(projects) {
+		t.Errorf("project list file has %d lines, %v", strings.Count(string(data), "\n"), err)
+	}
+	cleanup()
+	if _, err := os.Stat(file); !os.IsNotExist(err) {
+		t.Errorf("cleanup did not remove %s", file)
+	}
+}
@@ -0,0 +1,300 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/leopardxu/repo-go/internal/event"
+)
+
+// commandWaitDelay 是取消命令后等待 git 自行退出（并删除自己的锁文件）的时间，超时后强制结束
+const commandWaitDelay = 5 * time.Second
+
+// Command 创建受 ctx 控制的 git 命令
+// ctx 可以被取消时，命令在独立的进程组中运行，取消后整个进程组（包括 ssh、git-remote-https 等子进程）都会被终止
+func Command(ctx context.Context, dir string, args ...string) *exec.Cmd {
+	beforeNetworkCommand(dir, args)
+	return newCommand(ctx, ctx.Done() != nil, dir, args...)
+}
+
+// InteractiveCommand 创建连接到终端的 git 命令，用于 rebase -i、add --interactive 等需要用户输入的命令
+// 命令与 repo 在同一个进程组中，以便读取终端输入并直接收到 Ctrl-C；ctx 被取消时命令被终止
+func InteractiveCommand(ctx context.Context, dir string, args ...string) *exec.Cmd {
+	beforeNetworkCommand(dir, args)
+	cmd := newCommand(ctx, false, dir, args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd
+}
+
+// Run 执行由 Command 或 InteractiveCommand 创建的命令，并在事件日志中记录该 git 子进程
+func Run(cmd *exec.Cmd) error {
+	started := time.Now()
+	err := cmd.Run()
+	recordProcess(cmd.Dir, cmd.Args[1:], started, err)
+	return err
+}
+
+// recordProcess 在事件日志中记录一个已结束的 git 子进程，未开启事件记录时不做任何事
+func recordProcess(dir string, args []string, started time.Time, err error) {
+	if event.Default() == nil {
+		return
+	}
+	dir, args = splitGlobalOptions(dir, args)
+	name := "git"
+	if len(args) > 0 {
+		name += " " + args[0]
+	}
+
+	exitCode := 0
+	if err != nil {
+		exitCode = -1
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			exitCode = exitErr.ExitCode()
+		}
+	}
+	event.Add(name, event.TaskGit, started).
+		Set("dir", dir).
+		Set("exit_code", exitCode).
+		Finish(err == nil)
+}
+
+// newCommand 创建 git 命令，group 为 true 时在独立的进程组中运行
+func newCommand(ctx context.Context, group bool, dir string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	if dir != "" {
+		cmd.Dir = dir
+	}
+	if group {
+		SetProcessGroup(cmd)
+	}
+	return cmd
+}
+
+// SetProcessGroup 让由 exec.CommandContext 创建的命令在独立的进程组中运行，ctx 取消后终止整个进程组
+// forall 等执行非 git 命令时使用与 Command 相同的设置
+func SetProcessGroup(cmd *exec.Cmd) {
+	setProcessGroup(cmd)
+	cmd.WaitDelay = commandWaitDelay
+}
+
+// RemoveStaleLocks 删除被中止的 git 命令 args 遗留的锁文件，返回被删除的文件
+// 只处理该命令自己可能持有的锁：工作区的 index.lock、HEAD.lock，以及 fetch 等命令会更新的引用的锁；
+// 其他 worktree 和无关引用的锁、早于 since 的锁可能属于其他仍在运行的 git 进程，不做处理
+func RemoveStaleLocks(dir string, args []string, since time.Time) []string {
+	dir, args = splitGlobalOptions(dir, args)
+	if len(args) == 0 {
+		return nil
+	}
+	if dir == "" {
+		dir = "."
+	}
+	gitDir := resolveGitDir(dir)
+	if gitDir == "" {
+		return nil
+	}
+
+	// 文件系统时间戳精度可能只有1秒
+	since = since.Add(-time.Second)
+
+	var removed []string
+	for _, path := range commandLocks(gitDir, args) {
+		info, err := os.Stat(path)
+		if err != nil || info.ModTime().Before(since) {
+			continue
+		}
+		if os.Remove(path) == nil {
+			removed = append(removed, path)
+		}
+	}
+	return removed
+}
+
+// indexCommands 会写入工作区索引的子命令
+var indexCommands = map[string]bool{
+	"add": true, "am": true, "checkout": true, "cherry-pick": true, "commit": true,
+	"merge": true, "mv": true, "pull": true, "read-tree": true, "rebase": true,
+	"reset": true, "restore": true, "revert": true, "rm": true, "stash": true,
+	"switch": true, "update-index": true,
+}
+
+// headCommands 会移动 HEAD 和当前分支的子命令
+var headCommands = map[string]bool{
+	"am": true, "checkout": true, "cherry-pick": true, "commit": true, "merge": true,
+	"pull": true, "rebase": true, "reset": true, "revert": true, "switch": true,
+}
+
+// fetchValueOptions 是 fetch 中带单独参数值的选项
+var fetchValueOptions = map[string]bool{
+	"--depth": true, "--deepen": true, "--shallow-since": true, "--shallow-exclude": true,
+	"-j": true, "--jobs": true, "--upload-pack": true, "--refmap": true,
+	"-o": true, "--server-option": true, "--negotiation-tip": true,
+}
+
+// commandLocks 返回 git 命令 args 在 gitDir 所在仓库中可能持有的锁文件
+func commandLocks(gitDir string, args []string) []string {
+	commonDir := resolveCommonDir(gitDir)
+	sub, rest := args[0], args[1:]
+
+	var locks []string
+	if indexCommands[sub] {
+		locks = append(locks, filepath.Join(gitDir, "index.lock"))
+	}
+	if headCommands[sub] {
+		locks = append(locks, filepath.Join(gitDir, "HEAD.lock"), filepath.Join(gitDir, "ORIG_HEAD.lock"))
+		if ref := headRef(gitDir); ref != "" {
+			locks = append(locks, refLock(gitDir, commonDir, ref))
+		}
+	}
+
+	switch sub {
+	case "fetch", "pull":
+		locks = append(locks,
+			filepath.Join(gitDir, "FETCH_HEAD.lock"),
+			filepath.Join(commonDir, "shallow.lock"),
+			filepath.Join(commonDir, "packed-refs.lock"))
+		locks = append(locks, fetchRefLocks(gitDir, commonDir, rest)...)
+	case "update-ref":
+		for _, arg := range rest {
+			if !strings.HasPrefix(arg, "-") {
+				locks = append(locks, refLock(gitDir, commonDir, arg), filepath.Join(commonDir, "packed-refs.lock"))
+				break
+			}
+		}
+	case "checkout", "switch":
+		// 新建分支：checkout -b/-B <name>、switch -c/-C <name>
+		for i, arg := range rest {
+			if (arg == "-b" || arg == "-B" || arg == "-c" || arg == "-C") && i+1 < len(rest) {
+				locks = append(locks, refLock(gitDir, commonDir, "refs/heads/"+rest[i+1]))
+			}
+		}
+	case "gc", "pack-refs":
+		locks = append(locks, filepath.Join(commonDir, "packed-refs.lock"))
+	case "config":
+		locks = append(locks, filepath.Join(commonDir, "config.lock"))
+	}
+	return locks
+}
+
+// fetchRefLocks 返回 fetch 更新的引用的锁文件
+// 未指定 refspec 时按默认 refspec 处理远程跟踪分支 refs/remotes/<remote>/ 和标签
+func fetchRefLocks(gitDir, commonDir string, args []string) []string {
+	var positional []string
+	for i := 0; i < len(args); i++ {
+		if fetchValueOptions[args[i]] {
+			i++
+			continue
+		}
+		if !strings.HasPrefix(args[i], "-") {
+			positional = append(positional, args[i])
+		}
+	}
+
+	var dirs, locks []string
+	if len(positional) <= 1 {
+		remote := "origin"
+		if len(positional) == 1 {
+			remote = positional[0]
+		}
+		if !looksLikeURL(remote) && !filepath.IsAbs(remote) {
+			dirs = append(dirs, filepath.Join(commonDir, "refs", "remotes", filepath.FromSlash(remote)))
+		}
+		dirs = append(dirs, filepath.Join(commonDir, "refs", "tags"))
+	} else {
+		for _, spec := range positional[1:] {
+			_, dst, ok := strings.Cut(strings.TrimPrefix(spec, "+"), ":")
+			if !ok || dst == "" {
+				continue
+			}
+			if prefix, _, ok := strings.Cut(dst, "*"); ok {
+				dirs = append(dirs, filepath.Join(commonDir, filepath.FromSlash(strings.TrimSuffix(prefix, "/"))))
+			} else {
+				locks = append(locks, refLock(gitDir, commonDir, dst))
+			}
+		}
+	}
+
+	for _, dir := range dirs {
+		filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err == nil && !d.IsDir() && strings.HasSuffix(d.Name(), ".lock") {
+				locks = append(locks, path)
+			}
+			return nil
+		})
+	}
+	return locks
+}
+
+// refLock 返回引用 ref 的锁文件，refs/ 下的引用在共享的 git 目录中，HEAD 等伪引用属于工作区
+func refLock(gitDir, commonDir, ref string) string {
+	if strings.HasPrefix(ref, "refs/") {
+		return filepath.Join(commonDir, filepath.FromSlash(ref)+".lock")
+	}
+	return filepath.Join(gitDir, filepath.FromSlash(ref)+".lock")
+}
+
+// headRef 返回 gitDir 中 HEAD 指向的分支引用，分离头指针时返回空字符串
+func headRef(gitDir string) string {
+	data, err := os.ReadFile(filepath.Join(gitDir, "HEAD"))
+	if err != nil {
+		return ""
+	}
+	ref, _ := strings.CutPrefix(strings.TrimSpace(string(data)), "ref: ")
+	if !strings.HasPrefix(ref, "refs/") {
+		return ""
+	}
+	return ref
+}
+
+// resolveCommonDir 返回 gitDir 共享的 git 目录，linked worktree 的 git 目录中 commondir 指向主仓库
+func resolveCommonDir(gitDir string) string {
+	data, err := os.ReadFile(filepath.Join(gitDir, "commondir"))
+	if err != nil {
+		return gitDir
+	}
+	dir := strings.TrimSpace(string(data))
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(gitDir, dir)
+	}
+	return filepath.Clean(dir)
+}
+
+// resolveGitDir 返回工作区或裸仓库 dir 对应的 git 目录，无法识别时返回空字符串
+func resolveGitDir(dir string) string {
+	dotGit := filepath.Join(dir, ".git")
+	info, err := os.Stat(dotGit)
+	if err == nil && info.IsDir() {
+		return dotGit
+	}
+	if err == nil {
+		// 子模块和 worktree 中 .git 是指向实际 git 目录的文件
+		data, err := os.ReadFile(dotGit)
+		if err != nil {
+			return ""
+		}
+		target, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "gitdir:")
+		if !ok {
+			return ""
+		}
+		target = strings.TrimSpace(target)
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(dir, target)
+		}
+		return target
+	}
+	// 裸仓库
+	if _, err := os.Stat(filepath.Join(dir, "HEAD")); err == nil {
+		if _, err := os.Stat(filepath.Join(dir, "objects")); err == nil {
+			return dir
+		}
+	}
+	return ""
+}
@@ -0,0 +1,140 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRemoveStaleLocks(t *testing.T) {
+	started := time.Now()
+
+	// newRepo 创建一个当前分支为 topic 的仓库和它的 linked worktree wt
+	newRepo := func(t *testing.T) (dir, worktree string) {
+		dir = t.TempDir()
+		gitDir := filepath.Join(dir, ".git")
+		wtGitDir := filepath.Join(gitDir, "worktrees", "wt")
+		for _, d := range []string{"refs/heads", "refs/tags", "refs/remotes/origin", "refs/remotes/mirror", "objects", "worktrees/wt"} {
+			if err := os.MkdirAll(filepath.Join(gitDir, filepath.FromSlash(d)), 0755); err != nil {
+				t.Fatal(err)
+			}
+		}
+		worktree = filepath.Join(dir, "wt")
+		if err := os.MkdirAll(worktree, 0755); err != nil {
+			t.Fatal(err)
+		}
+		files := map[string]string{
+			filepath.Join(gitDir, "HEAD"):        "ref: refs/heads/topic\n",
+			filepath.Join(wtGitDir, "HEAD"):      "ref: refs/heads/wt\n",
+			filepath.Join(wtGitDir, "commondir"): "../..\n",
+			filepath.Join(worktree, ".git"):      "gitdir: " + wtGitDir + "\n",
+		}
+		for path, content := range files {
+			if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return dir, worktree
+	}
+	// lock 创建锁文件，old 为 true 时锁文件早于命令开始时间
+	lock := func(t *testing.T, dir, name string, old bool) {
+		path := filepath.Join(dir, ".git", filepath.FromSlash(name))
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+		mtime := started
+		if old {
+			mtime = started.Add(-time.Hour)
+		}
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		worktree bool
+		args     []string
+		removed  []string
+		kept     []string
+		old      []string
+	}{
+		{
+			name:    "fetch",
+			args:    []string{"fetch", "--depth", "1", "origin"},
+			removed: []string{"FETCH_HEAD.lock", "shallow.lock", "refs/remotes/origin/main.lock", "refs/tags/v1.lock"},
+			kept:    []string{"index.lock", "HEAD.lock", "refs/heads/topic.lock", "refs/remotes/mirror/main.lock", "worktrees/wt/index.lock"},
+		},
+		{
+			name:    "fetch refspec",
+			args:    []string{"fetch", "origin", "+refs/heads/*:refs/remotes/mirror/*", "refs/heads/a:refs/heads/a"},
+			removed: []string{"refs/remotes/mirror/main.lock", "refs/heads/a.lock"},
+			kept:    []string{"refs/remotes/origin/main.lock", "refs/tags/v1.lock", "refs/heads/topic.lock"},
+		},
+		{
+			name:    "checkout",
+			args:    []string{"checkout", "-b", "new"},
+			removed: []string{"index.lock", "HEAD.lock", "refs/heads/topic.lock", "refs/heads/new.lock"},
+			kept:    []string{"FETCH_HEAD.lock", "refs/remotes/origin/main.lock", "worktrees/wt/index.lock", "worktrees/wt/HEAD.lock"},
+			old:     []string{"ORIG_HEAD.lock"},
+		},
+		{
+			name:     "linked worktree",
+			worktree: true,
+			args:     []string{"reset", "--hard"},
+			removed:  []string{"worktrees/wt/index.lock", "worktrees/wt/HEAD.lock", "refs/heads/wt.lock"},
+			kept:     []string{"index.lock", "HEAD.lock", "refs/heads/topic.lock"},
+		},
+		{
+			name: "read-only command",
+			args: []string{"status"},
+			kept: []string{"index.lock", "HEAD.lock"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, worktree := newRepo(t)
+			for _, name := range append(append([]string{}, tt.removed...), tt.kept...) {
+				lock(t, dir, name, false)
+			}
+			for _, name := range tt.old {
+				lock(t, dir, name, true)
+			}
+			cwd := dir
+			if tt.worktree {
+				cwd = worktree
+			}
+
+			// 通过 -C 指定目录与直接指定 dir 等价
+			removed := RemoveStaleLocks("", append([]string{"-C", cwd}, tt.args...), started)
+			if len(removed) != len(tt.removed) {
+				t.Errorf("RemoveStaleLocks() removed %v, want %v", removed, tt.removed)
+			}
+			for _, name := range tt.removed {
+				if _, err := os.Stat(filepath.Join(dir, ".git", filepath.FromSlash(name))); !os.IsNotExist(err) {
+					t.Errorf("%s should have been removed", name)
+				}
+			}
+			for _, name := range append(append([]string{}, tt.kept...), tt.old...) {
+				if _, err := os.Stat(filepath.Join(dir, ".git", filepath.FromSlash(name))); err != nil {
+					t.Errorf("%s should have been kept: %v", name, err)
+				}
+			}
+		})
+	}
+}
+
+func TestRunInDirContextCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	runner := NewRunner()
+	runner.SetMaxRetries(0)
+	_, err := runner.RunInDirContext(ctx, t.TempDir(), "version")
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("RunInDirContext() error = %v, want context.Canceled", err)
+	}
+}
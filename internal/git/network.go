@@ -0,0 +1,139 @@
+package git
+
+import (
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// networkCommands 需要访问远程仓库的 git 子命令
+var networkCommands = map[string]bool{
+	"archive":   true,
+	"clone":     true,
+	"fetch":     true,
+	"ls-remote": true,
+	"pull":      true,
+	"push":      true,
+}
+
+var (
+	preconnectMu sync.RWMutex
+	preconnect   func(url string)
+
+	// remoteURLs 缓存远程名称或地址展开后的地址，键为 目录 + "\x00" + 远程
+	remoteURLs sync.Map
+)
+
+// SetPreconnect 设置执行网络命令（clone、fetch、ls-remote、pull、push、archive --remote）前调用的函数
+// fn 接收命令访问的远程地址，用于提前建立共享的 SSH 连接
+func SetPreconnect(fn func(url string)) {
+	preconnectMu.Lock()
+	defer preconnectMu.Unlock()
+	preconnect = fn
+}
+
+// beforeNetworkCommand 如果 args 是网络命令，在执行前调用 preconnect
+func beforeNetworkCommand(dir string, args []string) {
+	preconnectMu.RLock()
+	fn := preconnect
+	preconnectMu.RUnlock()
+	if fn == nil {
+		return
+	}
+	if url := commandRemoteURL(dir, args); url != "" {
+		fn(url)
+	}
+}
+
+// commandRemoteURL 返回网络命令访问的远程地址，不是网络命令或无法确定时返回空字符串
+func commandRemoteURL(dir string, args []string) string {
+	dir, args = splitGlobalOptions(dir, args)
+	if len(args) == 0 || !networkCommands[args[0]] {
+		return ""
+	}
+	sub := args[0]
+
+	// archive 只有带 --remote 时才访问远程仓库
+	if sub == "archive" {
+		for _, arg := range args[1:] {
+			if remote, ok := strings.CutPrefix(arg, "--remote="); ok {
+				return remoteURL(dir, remote)
+			}
+		}
+		return ""
+	}
+
+	var positional []string
+	for _, arg := range args[1:] {
+		if !strings.HasPrefix(arg, "-") {
+			positional = append(positional, arg)
+		}
+	}
+
+	// clone 的选项值和目标目录也是位置参数，取第一个像远程地址的参数
+	if sub == "clone" {
+		for _, arg := range positional {
+			if looksLikeURL(arg) {
+				return remoteURL("", arg)
+			}
+		}
+		return ""
+	}
+
+	remote := "origin"
+	if len(positional) > 0 {
+		remote = positional[0]
+	}
+	return remoteURL(dir, remote)
+}
+
+// remoteURL 返回 dir 中远程名称或地址 remote 实际访问的地址，按 url.<base>.insteadOf 展开，无法确定时返回空字符串
+// 结果按目录和远程缓存，同一项目的多次网络命令只查询一次
+func remoteURL(dir, remote string) string {
+	key := dir + "\x00" + remote
+	if url, ok := remoteURLs.Load(key); ok {
+		return url.(string)
+	}
+
+	cmd := exec.Command("git", "ls-remote", "--get-url", remote)
+	cmd.Dir = dir
+	url := ""
+	if out, err := cmd.Output(); err == nil {
+		url = strings.TrimSpace(string(out))
+	}
+	// 未配置的远程名称原样返回，不是可以连接的地址
+	if url == remote && !looksLikeURL(url) {
+		url = ""
+	}
+	remoteURLs.Store(key, url)
+	return url
+}
+
+// splitGlobalOptions 跳过 -C、-c 等全局选项，返回命令实际的工作目录和从子命令开始的参数
+func splitGlobalOptions(dir string, args []string) (string, []string) {
+	i := 0
+	for i < len(args) && strings.HasPrefix(args[i], "-") {
+		if (args[i] == "-C" || args[i] == "-c") && i+1 < len(args) {
+			if args[i] == "-C" {
+				if filepath.IsAbs(args[i+1]) || dir == "" {
+					dir = args[i+1]
+				} else {
+					dir = filepath.Join(dir, args[i+1])
+				}
+			}
+			i++
+		}
+		i++
+	}
+	return dir, args[i:]
+}
+
+// looksLikeURL 判断参数是否为远程地址（URL 或 scp 形式的 host:path）
+func looksLikeURL(s string) bool {
+	if strings.Contains(s, "://") {
+		return true
+	}
+	host, _, ok := strings.Cut(s, ":")
+	return ok && len(host) > 1 && !strings.Contains(host, "/")
+}
@@ -0,0 +1,85 @@
+package git
+
+import (
+	"os/exec"
+	"testing"
+)
+
+func TestCommandRemoteURL(t *testing.T) {
+	dir := t.TempDir()
+	for _, args := range [][]string{
+		{"init", "--quiet"},
+		{"remote", "add", "origin", "ssh://review.example.com:29418/a"},
+		{"remote", "add", "mirror", "git@mirror.example.com:a.git"},
+		{"config", "url.ssh://review.example.com:29418/.insteadOf", "review:"},
+		{"remote", "add", "short", "review:b"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"clone", []string{"clone", "--depth", "1", "ssh://host/a.git", "a"}, "ssh://host/a.git"},
+		{"clone scp", []string{"clone", "--mirror", "git@host:a.git", "/tmp/a"}, "git@host:a.git"},
+		{"fetch default", []string{"fetch", "--prune"}, "ssh://review.example.com:29418/a"},
+		{"fetch remote", []string{"fetch", "mirror", "main"}, "git@mirror.example.com:a.git"},
+		{"fetch url", []string{"fetch", "ssh://other/a", "main"}, "ssh://other/a"},
+		{"global options", []string{"-c", "core.askPass=true", "-C", dir, "ls-remote", "origin"}, "ssh://review.example.com:29418/a"},
+		{"push", []string{"push", "origin", "HEAD:refs/for/main"}, "ssh://review.example.com:29418/a"},
+		{"archive remote", []string{"archive", "--remote=ssh://host/a.git", "--format=tar", "main"}, "ssh://host/a.git"},
+		{"archive local", []string{"archive", "--format=tar", "HEAD"}, ""},
+		{"insteadOf remote", []string{"fetch", "short"}, "ssh://review.example.com:29418/b"},
+		{"insteadOf url", []string{"fetch", "review:c", "main"}, "ssh://review.example.com:29418/c"},
+		{"unknown remote", []string{"fetch", "missing"}, ""},
+		{"local command", []string{"status", "--porcelain"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := commandRemoteURL(dir, tt.args); got != tt.want {
+				t.Errorf("commandRemoteURL(%v) = %q, want %q", tt.args, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRemoteURLCached(t *testing.T) {
+	dir := t.TempDir()
+	run := func(args ...string) {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	run("init", "--quiet")
+	run("remote", "add", "origin", "ssh://host/a.git")
+
+	if got := remoteURL(dir, "origin"); got != "ssh://host/a.git" {
+		t.Fatalf("remoteURL() = %q", got)
+	}
+	// 同一项目的后续命令使用缓存的地址，不再查询 git
+	run("remote", "set-url", "origin", "ssh://other/a.git")
+	if got := remoteURL(dir, "origin"); got != "ssh://host/a.git" {
+		t.Errorf("remoteURL() = %q, want the cached ssh://host/a.git", got)
+	}
+}
+
+func TestBeforeNetworkCommand(t *testing.T) {
+	var urls []string
+	SetPreconnect(func(url string) { urls = append(urls, url) })
+	defer SetPreconnect(nil)
+
+	beforeNetworkCommand("", []string{"clone", "ssh://host/a.git"})
+	beforeNetworkCommand("", []string{"log", "-1"})
+	if len(urls) != 1 || urls[0] != "ssh://host/a.git" {
+		t.Errorf("preconnect called with %v", urls)
+	}
+}
@@ -0,0 +1,8 @@
+//go:build windows
+
+package git
+
+import "os/exec"
+
+// setProcessGroup 在 Windows 上使用默认行为，取消时结束 git 进程
+func setProcessGroup(cmd *exec.Cmd) {}
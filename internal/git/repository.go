@@ -1,10 +1,12 @@
 package git
 
 import (
+	"context"
 	"fmt"
 	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"sync"
 	"time"
@@ -92,9 +94,14 @@ func (r *Repository) ClearCache() {
 
 // RunCommand 执行Git命令并返回结
 func (r *Repository) RunCommand(args ...string) ([]byte, error) {
+	return r.RunCommandContext(context.Background(), args...)
+}
+
+// RunCommandContext 执行Git命令并返回结果，ctx 取消时终止命令
+func (r *Repository) RunCommandContext(ctx context.Context, args ...string) ([]byte, error) {
 	repoLog.Debug("在仓'%s' 执行命令: git %s", r.Path, strings.Join(args, " "))
 
-	output, err := r.Runner.RunInDir(r.Path, args...)
+	output, err := r.Runner.RunInDirContext(ctx, r.Path, args...)
 	if err != nil {
 		repoLog.Error("命令执行失败: git %s: %v", strings.Join(args, " "), err)
 		return nil, &RepositoryError{
@@ -614,3 +621,357 @@ func IsImmutable(revisionExpr string) bool {
 	// HEAD, master, main 等分支名是可变的
 	return false
 }
+
+// changeIDPattern 匹配提交信息中的 Gerrit Change-Id 尾注
+var changeIDPattern = regexp.MustCompile(`(?m)^Change-Id:\s*(I[0-9a-fA-F]{40})\s*$`)
+
+// ParseChangeID 从提交信息中提取 Change-Id，不存在时返回空字符串
+// 如果存在多个 Change-Id，以最后一个为准（与 Gerrit 行为一致）
+func ParseChangeID(message string) string {
+	matches := changeIDPattern.FindAllStringSubmatch(message, -1)
+	if len(matches) == 0 {
+		return ""
+	}
+	return matches[len(matches)-1][1]
+}
+
+// changeIDValue 匹配单独的 Change-Id 值
+var changeIDValue = regexp.MustCompile(`^I[0-9a-fA-F]{40}$`)
+
+// IsChangeID 检查字符串是否为 Gerrit Change-Id
+func IsChangeID(s string) bool {
+	return changeIDValue.MatchString(s)
+}
+
+// CommitsBetween 返回 base..head 范围内的提交，按从旧到新的顺序排列
+func (r *Repository) CommitsBetween(base, head string) ([]string, error) {
+	output, err := r.Runner.RunInDir(r.Path, "rev-list", "--reverse", base+".."+head)
+	if err != nil {
+		return nil, &RepositoryError{
+			Op:      "commits_between",
+			Path:    r.Path,
+			Command: fmt.Sprintf("git rev-list --reverse %s..%s", base, head),
+			Err:     err,
+		}
+	}
+
+	return splitLines(output), nil
+}
+
+// CommitMessage 获取指定提交的完整提交信息
+func (r *Repository) CommitMessage(commit string) (string, error) {
+	output, err := r.Runner.RunInDir(r.Path, "log", "-1", "--format=%B", commit)
+	if err != nil {
+		return "", &RepositoryError{
+			Op:      "commit_message",
+			Path:    r.Path,
+			Command: fmt.Sprintf("git log -1 --format=%%B %s", commit),
+			Err:     err,
+		}
+	}
+	return string(output), nil
+}
+
+// LocalBranches 返回所有本地分支名
+func (r *Repository) LocalBranches() ([]string, error) {
+	output, err := r.Runner.RunInDir(r.Path, "for-each-ref", "--format=%(refname:short)", "refs/heads/")
+	if err != nil {
+		return nil, &RepositoryError{
+			Op:      "local_branches",
+			Path:    r.Path,
+			Command: "git for-each-ref refs/heads/",
+			Err:     err,
+		}
+	}
+	return splitLines(output), nil
+}
+
+// UnpublishedCommits 返回分支上尚未发布的提交，按从旧到新排列
+// 出现在指定远程任何引用中或 refs/published/<branch> 记录的上次上传中的提交都视为已发布
+func (r *Repository) UnpublishedCommits(branch, remote string) ([]string, error) {
+	args := []string{"rev-list", "--reverse", branch, "--not", "--remotes=" + remote}
+	published, err := r.PublishedCommit(branch)
+	if err != nil {
+		return nil, err
+	}
+	if published != "" {
+		args = append(args, published)
+	}
+	output, err := r.Runner.RunInDir(r.Path, args...)
+	if err != nil {
+		return nil, &RepositoryError{
+			Op:      "unpublished_commits",
+			Path:    r.Path,
+			Command: "git " + strings.Join(args, " "),
+			Err:     err,
+		}
+	}
+	return splitLines(output), nil
+}
+
+// PublishedRef 返回记录分支上次上传的提交的引用
+// 上传到 Gerrit 的 refs/for/<branch> 不会产生远程跟踪分支，只能通过这个引用判断分支是否已上传
+func PublishedRef(branch string) string {
+	return "refs/published/" + branch
+}
+
+// PublishedCommit 返回分支上次上传的提交，从未上传时返回空字符串
+func (r *Repository) PublishedCommit(branch string) (string, error) {
+	ref := PublishedRef(branch)
+	output, err := r.Runner.RunInDir(r.Path, "for-each-ref", "--format=%(refname) %(objectname)", ref)
+	if err != nil {
+		return "", &RepositoryError{
+			Op:      "published_commit",
+			Path:    r.Path,
+			Command: "git for-each-ref " + ref,
+			Err:     err,
+		}
+	}
+	// for-each-ref 按路径前缀匹配，只取完全相同的引用
+	for _, line := range splitLines(output) {
+		if name, commit, ok := strings.Cut(line, " "); ok && name == ref {
+			return commit, nil
+		}
+	}
+	return "", nil
+}
+
+// IsPublished 判断分支上的提交是否都已上传：上次上传的提交之后没有新的提交
+func (r *Repository) IsPublished(branch string) (bool, error) {
+	published, err := r.PublishedCommit(branch)
+	if err != nil || published == "" {
+		return false, err
+	}
+	ahead, _, err := r.AheadBehind(published, "refs/heads/"+branch)
+	if err != nil {
+		return false, err
+	}
+	return ahead == 0, nil
+}
+
+// SetPublished 记录分支上传的提交
+func (r *Repository) SetPublished(ctx context.Context, branch, commit string) error {
+	if _, err := r.Runner.RunInDirContext(ctx, r.Path, "update-ref", PublishedRef(branch), commit); err != nil {
+		return &RepositoryError{
+			Op:      "set_published",
+			Path:    r.Path,
+			Command: fmt.Sprintf("git update-ref %s %s", PublishedRef(branch), commit),
+			Err:     err,
+		}
+	}
+	return nil
+}
+
+// DeletePublished 删除分支的上传记录，分支被删除时调用
+func (r *Repository) DeletePublished(ctx context.Context, branch string) error {
+	published, err := r.PublishedCommit(branch)
+	if err != nil || published == "" {
+		return err
+	}
+	if _, err := r.Runner.RunInDirContext(ctx, r.Path, "update-ref", "-d", PublishedRef(branch)); err != nil {
+		return &RepositoryError{
+			Op:      "delete_published",
+			Path:    r.Path,
+			Command: "git update-ref -d " + PublishedRef(branch),
+			Err:     err,
+		}
+	}
+	return nil
+}
+
+// splitLines 将命令输出按行拆分，忽略空行
+func splitLines(output []byte) []string {
+	var lines []string
+	for _, line := range strings.Split(string(output), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// StatusEntry 表示 git status --porcelain 输出中的一个文件
+type StatusEntry struct {
+	Index    byte   // 暂存区状态，' ' 表示未修改，'?' 表示未跟踪
+	WorkTree byte   // 工作区状态
+	Path     string // 文件路径
+	OrigPath string // 重命名或复制前的路径
+}
+
+// StatusEntries 获取工作区中每个文件的状态
+func (r *Repository) StatusEntries() ([]StatusEntry, error) {
+	output, err := r.Runner.RunInDir(r.Path, "status", "--porcelain", "-z")
+	if err != nil {
+		return nil, &RepositoryError{
+			Op:      "status_entries",
+			Path:    r.Path,
+			Command: "git status --porcelain -z",
+			Err:     err,
+		}
+	}
+	return ParseStatusEntries(output), nil
+}
+
+// ParseStatusEntries 解析 git status --porcelain -z 的输出
+func ParseStatusEntries(output []byte) []StatusEntry {
+	var entries []StatusEntry
+	fields := strings.Split(string(output), "\x00")
+	for i := 0; i < len(fields); i++ {
+		field := fields[i]
+		if len(field) < 4 {
+			continue
+		}
+		entry := StatusEntry{Index: field[0], WorkTree: field[1], Path: field[3:]}
+		// 重命名和复制的原路径紧跟在后面的字段中
+		if (entry.Index == 'R' || entry.Index == 'C') && i+1 < len(fields) {
+			entry.OrigPath = fields[i+1]
+			i++
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// AheadBehind 返回 head 相对 base 领先和落后的提交数
+func (r *Repository) AheadBehind(base, head string) (int, int, error) {
+	output, err := r.Runner.RunInDir(r.Path, "rev-list", "--left-right", "--count", base+"..."+head)
+	if err != nil {
+		return 0, 0, &RepositoryError{
+			Op:      "ahead_behind",
+			Path:    r.Path,
+			Command: fmt.Sprintf("git rev-list --left-right --count %s...%s", base, head),
+			Err:     err,
+		}
+	}
+
+	var behind, ahead int
+	if _, err := fmt.Sscanf(strings.TrimSpace(string(output)), "%d\t%d", &behind, &ahead); err != nil {
+		return 0, 0, fmt.Errorf("unexpected rev-list output %q: %w", output, err)
+	}
+	return ahead, behind, nil
+}
+
+// RevParse 将修订版本解析为完整的提交哈希
+func (r *Repository) RevParse(revision string) (string, error) {
+	output, err := r.Runner.RunInDir(r.Path, "rev-parse", "--verify", "--quiet", revision+"^{commit}")
+	if err != nil {
+		return "", &RepositoryError{
+			Op:      "rev_parse",
+			Path:    r.Path,
+			Command: fmt.Sprintf("git rev-parse --verify %s", revision),
+			Err:     err,
+		}
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// UnmergedCommits 返回分支上尚未以等价补丁形式进入 upstream 的提交
+// 与 git cherry 一致：被 cherry-pick 或变基后合入的提交视为已合并
+func (r *Repository) UnmergedCommits(upstream, branch string) ([]string, error) {
+	output, err := r.Runner.RunInDir(r.Path, "cherry", upstream, branch)
+	if err != nil {
+		return nil, &RepositoryError{
+			Op:      "unmerged_commits",
+			Path:    r.Path,
+			Command: fmt.Sprintf("git cherry %s %s", upstream, branch),
+			Err:     err,
+		}
+	}
+
+	var commits []string
+	for _, line := range splitLines(output) {
+		if strings.HasPrefix(line, "+ ") {
+			commits = append(commits, strings.TrimPrefix(line, "+ "))
+		}
+	}
+	return commits, nil
+}
+
+// TrackingBranch 返回分支跟踪的上游引用（由 branch.<name>.remote/merge 决定），未设置时返回空字符串
+func (r *Repository) TrackingBranch(branch string) (string, error) {
+	output, err := r.Runner.RunInDir(r.Path, "for-each-ref", "--format=%(upstream)", "refs/heads/"+branch)
+	if err != nil {
+		return "", &RepositoryError{
+			Op:      "tracking_branch",
+			Path:    r.Path,
+			Command: fmt.Sprintf("git for-each-ref --format=%%(upstream) refs/heads/%s", branch),
+			Err:     err,
+		}
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// RebaseInProgress 检查工作区是否处于未完成的 rebase 中
+func (r *Repository) RebaseInProgress() bool {
+	return r.gitPathExists("rebase-merge") || r.gitPathExists("rebase-apply")
+}
+
+// CherryPickInProgress 检查工作区是否处于未完成的 cherry-pick 中
+func (r *Repository) CherryPickInProgress() bool {
+	return r.gitPathExists("CHERRY_PICK_HEAD") || r.gitPathExists("sequencer")
+}
+
+// gitPathExists 检查 git 目录中的文件是否存在，兼容工作树和子模块的 .git 文件
+func (r *Repository) gitPathExists(name string) bool {
+	output, err := r.Runner.RunInDir(r.Path, "rev-parse", "--git-path", name)
+	if err != nil {
+		return false
+	}
+	path := strings.TrimSpace(string(output))
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(r.Path, path)
+	}
+	_, err = os.Stat(path)
+	return err == nil
+}
+
+// ConflictedFiles 返回存在合并冲突的文件
+func (r *Repository) ConflictedFiles() ([]string, error) {
+	output, err := r.Runner.RunInDir(r.Path, "diff", "--name-only", "--diff-filter=U")
+	if err != nil {
+		return nil, &RepositoryError{
+			Op:      "conflicted_files",
+			Path:    r.Path,
+			Command: "git diff --name-only --diff-filter=U",
+			Err:     err,
+		}
+	}
+	return splitLines(output), nil
+}
+
+// CommitSummaries 返回 base..head 范围内提交的 "<短哈希> <标题>"，按从新到旧排列
+func (r *Repository) CommitSummaries(base, head string) ([]string, error) {
+	output, err := r.Runner.RunInDir(r.Path, "log", "--format=%h %s", base+".."+head)
+	if err != nil {
+		return nil, &RepositoryError{
+			Op:      "commit_summaries",
+			Path:    r.Path,
+			Command: fmt.Sprintf("git log --format=%%h %%s %s..%s", base, head),
+			Err:     err,
+		}
+	}
+	return splitLines(output), nil
+}
+
+// FindChangeID 返回 revisions 中提交信息带有指定 Change-Id 的提交，按从新到旧排列
+// revisions 为空时搜索所有引用
+func (r *Repository) FindChangeID(changeID string, revisions ...string) ([]string, error) {
+	args := []string{"log", "--format=%H", "--grep=^Change-Id: " + changeID + "$"}
+	if len(revisions) == 0 {
+		args = append(args, "--all")
+	} else {
+		args = append(args, revisions...)
+	}
+	args = append(args, "--")
+
+	output, err := r.Runner.RunInDir(r.Path, args...)
+	if err != nil {
+		return nil, &RepositoryError{
+			Op:      "find_change_id",
+			Path:    r.Path,
+			Command: "git " + strings.Join(args, " "),
+			Err:     err,
+		}
+	}
+	return splitLines(output), nil
+}
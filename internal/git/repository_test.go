@@ -0,0 +1,68 @@
+package git
+
+import "testing"
+
+func TestParseChangeID(t *testing.T) {
+	id := "I" + "0123456789abcdef0123456789abcdef01234567"
+	other := "I" + "fedcba9876543210fedcba9876543210fedcba98"
+
+	tests := []struct {
+		name    string
+		message string
+		want    string
+	}{
+		{"no trailer", "Fix bug\n\nSome body\n", ""},
+		{"single trailer", "Fix bug\n\nChange-Id: " + id + "\n", id},
+		{"last trailer wins", "Fix bug\n\nChange-Id: " + other + "\nChange-Id: " + id + "\n", id},
+		{"not at line start", "Fix bug\n\nSee Change-Id: " + id + "\n", ""},
+		{"short id", "Fix bug\n\nChange-Id: I1234\n", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseChangeID(tt.message); got != tt.want {
+				t.Errorf("ParseChangeID() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsChangeID(t *testing.T) {
+	tests := []struct {
+		in   string
+		want bool
+	}{
+		{"I0123456789abcdef0123456789abcdef01234567", true},
+		{"I0123456789ABCDEF0123456789ABCDEF01234567", true},
+		{"0123456789abcdef0123456789abcdef01234567", false},
+		{"I0123456789abcdef", false},
+		{"Ig123456789abcdef0123456789abcdef01234567", false},
+		{"HEAD~1..HEAD", false},
+	}
+
+	for _, tt := range tests {
+		if got := IsChangeID(tt.in); got != tt.want {
+			t.Errorf("IsChangeID(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseStatusEntries(t *testing.T) {
+	output := []byte(" M modified.go\x00A  added.go\x00R  new.go\x00old.go\x00?? dir/\x00")
+	want := []StatusEntry{
+		{Index: ' ', WorkTree: 'M', Path: "modified.go"},
+		{Index: 'A', WorkTree: ' ', Path: "added.go"},
+		{Index: 'R', WorkTree: ' ', Path: "new.go", OrigPath: "old.go"},
+		{Index: '?', WorkTree: '?', Path: "dir/"},
+	}
+
+	got := ParseStatusEntries(output)
+	if len(got) != len(want) {
+		t.Fatalf("ParseStatusEntries() returned %d entries, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("entry %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
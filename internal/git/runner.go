@@ -126,26 +126,36 @@ func (r *defaultRunner) GetEnvironment() map[string]string {
 
 // Run 执行Git命令
 func (r *defaultRunner) Run(args ...string) ([]byte, error) {
-	return r.runGitCommand("", 0, args...)
+	return r.runGitCommand(context.Background(), "", 0, args...)
 }
 
 // RunInDir 在指定目录执行Git命令
 func (r *defaultRunner) RunInDir(dir string, args ...string) ([]byte, error) {
-	return r.runGitCommand(dir, 0, args...)
+	return r.runGitCommand(context.Background(), dir, 0, args...)
+}
+
+// RunContext 执行Git命令，ctx 取消时终止命令
+func (r *defaultRunner) RunContext(ctx context.Context, args ...string) ([]byte, error) {
+	return r.runGitCommand(ctx, "", 0, args...)
+}
+
+// RunInDirContext 在指定目录执行Git命令，ctx 取消时终止命令
+func (r *defaultRunner) RunInDirContext(ctx context.Context, dir string, args ...string) ([]byte, error) {
+	return r.runGitCommand(ctx, dir, 0, args...)
 }
 
 // RunWithTimeout 在指定目录执行Git命令并设置超
 func (r *defaultRunner) RunWithTimeout(timeout time.Duration, args ...string) ([]byte, error) {
-	return r.runGitCommand("", timeout, args...)
+	return r.runGitCommand(context.Background(), "", timeout, args...)
 }
 
 // RunInDirWithTimeout 在指定目录执行Git命令并设置超
 func (r *defaultRunner) RunInDirWithTimeout(dir string, timeout time.Duration, args ...string) ([]byte, error) {
-	return r.runGitCommand(dir, timeout, args...)
+	return r.runGitCommand(context.Background(), dir, timeout, args...)
 }
 
 // runGitCommand 是执git 命令的内部辅助函
-func (r *defaultRunner) runGitCommand(dir string, timeout time.Duration, args ...string) ([]byte, error) {
+func (r *defaultRunner) runGitCommand(parent context.Context, dir string, timeout time.Duration, args ...string) ([]byte, error) {
 	// 获取并发控制信号
 	r.mutex.RLock()
 	semaphore := r.semaphore
@@ -171,8 +181,12 @@ func (r *defaultRunner) runGitCommand(dir string, timeout time.Duration, args ..
 
 	// 如果设置了并发控
 	if semaphore != nil {
-		semaphore <- struct{}{}
-		defer func() { <-semaphore }()
+		select {
+		case semaphore <- struct{}{}:
+			defer func() { <-semaphore }()
+		case <-parent.Done():
+			return nil, parent.Err()
+		}
 	}
 
 	cmdArgs := append([]string{}, args...)
@@ -184,6 +198,9 @@ func (r *defaultRunner) runGitCommand(dir string, timeout time.Duration, args ..
 		log.Debug("执行: %s 在目'%s'", cmdStr, dir)
 	}
 
+	// 网络命令执行前建立共享的 SSH 连接
+	beforeNetworkCommand(dir, cmdArgs)
+
 	// 执行命令，支持重
 	var lastErr error
 	var stdoutBytes []byte
@@ -193,11 +210,15 @@ func (r *defaultRunner) runGitCommand(dir string, timeout time.Duration, args ..
 	for attempt := 0; attempt <= maxRetries; attempt++ {
 		if attempt > 0 {
 			log.Debug("重试Git命令 (尝试 %d/%d): %s", attempt, maxRetries, cmdStr)
-			time.Sleep(retryDelay)
+			select {
+			case <-time.After(retryDelay):
+			case <-parent.Done():
+				return stdoutBytes, lastErr
+			}
 		}
 
 		// 准备命令
-		ctx := context.Background()
+		ctx := parent
 		var cancel context.CancelFunc
 
 		if timeout > 0 {
@@ -205,11 +226,7 @@ func (r *defaultRunner) runGitCommand(dir string, timeout time.Duration, args ..
 			// 注意：不能在这里defer cancel()，因为我们需要在下面手动调用
 		}
 
-		cmd := exec.CommandContext(ctx, "git", cmdArgs...)
-
-		if dir != "" {
-			cmd.Dir = dir
-		}
+		cmd := newCommand(ctx, parent.Done() != nil, dir, cmdArgs...)
 
 		// 设置环境变量
 		if env != nil {
@@ -228,7 +245,9 @@ func (r *defaultRunner) runGitCommand(dir string, timeout time.Duration, args ..
 		cmd.Stderr = &stderr
 
 		// 执行命令
+		started := time.Now()
 		err := cmd.Run()
+		recordProcess(dir, cmdArgs, started, err)
 		stdoutBytes = stdout.Bytes()
 		stderrBytes = stderr.Bytes()
 
@@ -237,6 +256,20 @@ func (r *defaultRunner) runGitCommand(dir string, timeout time.Duration, args ..
 			cancel()
 		}
 
+		// 命令被取消时不再重试，并清理被中止的命令遗留的锁文件
+		if err != nil && parent.Err() != nil {
+			for _, lock := range RemoveStaleLocks(dir, cmdArgs, started) {
+				log.Warn("已删除中止的命令遗留的锁文件: %s", lock)
+			}
+			return stdoutBytes, &GitCommandError{
+				Command: cmdStr,
+				Dir:     dir,
+				Err:     parent.Err(),
+				Stdout:  string(stdoutBytes),
+				Stderr:  string(stderrBytes),
+			}
+		}
+
 		// 处理输出
 		if (verbose || repoTrace) && len(stdoutBytes) > 0 {
 			if repoTrace {
@@ -366,6 +399,8 @@ func NewCommandRunnerWithConfig(cfg *config.Config) (Runner, error) {
 type Runner interface {
 	Run(args ...string) ([]byte, error)
 	RunInDir(dir string, args ...string) ([]byte, error)
+	RunContext(ctx context.Context, args ...string) ([]byte, error)
+	RunInDirContext(ctx context.Context, dir string, args ...string) ([]byte, error)
 	RunWithTimeout(timeout time.Duration, args ...string) ([]byte, error)
 	RunInDirWithTimeout(dir string, timeout time.Duration, args ...string) ([]byte, error)
 	SetVerbose(verbose bool)
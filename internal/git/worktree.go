@@ -0,0 +1,160 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// CommonDir 返回工作区 dir 的公共 git 目录，即存放对象、引用、配置和钩子的目录
+// git worktree 创建的工作区中 .git 是指向 <公共目录>/worktrees/<id> 的文件，公共目录由其中的 commondir 指定；
+// 普通工作区返回 .git 目录，无法识别时返回空字符串
+func CommonDir(dir string) string {
+	gitDir := resolveGitDir(dir)
+	if gitDir == "" {
+		return ""
+	}
+	data, err := os.ReadFile(filepath.Join(gitDir, "commondir"))
+	if err != nil {
+		return gitDir
+	}
+	common := strings.TrimSpace(string(data))
+	if !filepath.IsAbs(common) {
+		common = filepath.Join(gitDir, common)
+	}
+	return filepath.Clean(common)
+}
+
+// Worktree 描述 git worktree list 列出的一个工作区
+type Worktree struct {
+	Path     string
+	Head     string
+	Branch   string // 检出的分支，分离头指针时为空
+	Bare     bool   // 公共仓库本身
+	Prunable bool   // 工作区目录已不存在
+}
+
+// AddWorktree 在共享仓库 gitDir 中创建分离头指针在 commit 的工作区 path
+// path 已经存在且不为空时（如嵌套项目先于父项目创建），先在临时目录中创建工作区，
+// 再把 .git 文件移入 path 并修复链接，path 中已有的其他文件保持不变
+func AddWorktree(ctx context.Context, gitDir, path, commit string) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	if empty, err := isEmptyDir(abs); err != nil || empty {
+		if err := runGitIn(ctx, gitDir, "worktree", "add", "--quiet", "--detach", abs, commit); err != nil {
+			return err
+		}
+		return nil
+	}
+
+	tmp, err := os.MkdirTemp(filepath.Dir(abs), "."+filepath.Base(abs)+".worktree-")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary worktree: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+	if err := runGitIn(ctx, gitDir, "worktree", "add", "--quiet", "--detach", "--no-checkout", tmp, commit); err != nil {
+		return err
+	}
+	if err := os.Rename(filepath.Join(tmp, ".git"), filepath.Join(abs, ".git")); err != nil {
+		runGitIn(ctx, gitDir, "worktree", "remove", "--force", tmp)
+		return fmt.Errorf("failed to move worktree to %s: %w", abs, err)
+	}
+	if err := runGitIn(ctx, gitDir, "worktree", "repair", abs); err != nil {
+		return err
+	}
+	// --no-checkout 创建的工作区索引为空，重置后索引和文件与 commit 一致
+	return runGitIn(ctx, abs, "reset", "--quiet", "--hard", commit)
+}
+
+// ListWorktrees 列出共享仓库 gitDir 的所有工作区
+func ListWorktrees(ctx context.Context, gitDir string) ([]Worktree, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := Command(ctx, gitDir, "worktree", "list", "--porcelain")
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := Run(cmd); err != nil {
+		return nil, commandError("worktree list", err, &stderr)
+	}
+	return parseWorktreeList(stdout.String()), nil
+}
+
+// parseWorktreeList 解析 git worktree list --porcelain 的输出，每个工作区之间以空行分隔
+func parseWorktreeList(output string) []Worktree {
+	var worktrees []Worktree
+	var cur *Worktree
+	for _, line := range strings.Split(output, "\n") {
+		key, value, _ := strings.Cut(strings.TrimRight(line, "\r"), " ")
+		switch key {
+		case "worktree":
+			worktrees = append(worktrees, Worktree{Path: filepath.FromSlash(value)})
+			cur = &worktrees[len(worktrees)-1]
+		case "HEAD":
+			if cur != nil {
+				cur.Head = value
+			}
+		case "branch":
+			if cur != nil {
+				cur.Branch = strings.TrimPrefix(value, "refs/heads/")
+			}
+		case "bare":
+			if cur != nil {
+				cur.Bare = true
+			}
+		case "prunable":
+			if cur != nil {
+				cur.Prunable = true
+			}
+		}
+	}
+	return worktrees
+}
+
+// RemoveWorktree 删除共享仓库 gitDir 的工作区 path，force 为 false 时工作区有修改或未跟踪文件会失败
+func RemoveWorktree(ctx context.Context, gitDir, path string, force bool) error {
+	args := []string{"worktree", "remove"}
+	if force {
+		args = append(args, "--force")
+	}
+	return runGitIn(ctx, gitDir, append(args, path)...)
+}
+
+// PruneWorktrees 清除共享仓库 gitDir 中目录已被删除或移动的工作区记录
+func PruneWorktrees(ctx context.Context, gitDir string) error {
+	return runGitIn(ctx, gitDir, "worktree", "prune")
+}
+
+// runGitIn 在 dir 中执行 git 命令，失败时错误中包含 git 的错误输出
+func runGitIn(ctx context.Context, dir string, args ...string) error {
+	var stderr bytes.Buffer
+	cmd := Command(ctx, dir, args...)
+	cmd.Stderr = &stderr
+	if err := Run(cmd); err != nil {
+		return commandError(strings.Join(args[:min(2, len(args))], " "), err, &stderr)
+	}
+	return nil
+}
+
+// commandError 使用 git 的错误输出描述命令失败
+func commandError(name string, err error, stderr *bytes.Buffer) error {
+	if msg := strings.TrimSpace(stderr.String()); msg != "" {
+		return fmt.Errorf("git %s: %w: %s", name, err, msg)
+	}
+	return fmt.Errorf("git %s: %w", name, err)
+}
+
+// isEmptyDir 判断 dir 是否不存在或为空目录
+func isEmptyDir(dir string) (bool, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return len(entries) == 0, nil
+}
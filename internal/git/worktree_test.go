@@ -0,0 +1,106 @@
+package git
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// gitIn 在 dir 中执行 git 命令并返回去掉空白的输出
+func gitIn(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=t", "GIT_AUTHOR_EMAIL=t@example.com",
+		"GIT_COMMITTER_NAME=t", "GIT_COMMITTER_EMAIL=t@example.com")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func TestWorktrees(t *testing.T) {
+	root := t.TempDir()
+	seed := filepath.Join(root, "seed")
+	gitIn(t, root, "init", "--quiet", seed)
+	if err := os.WriteFile(filepath.Join(seed, "f"), []byte("f"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	gitIn(t, seed, "add", "f")
+	gitIn(t, seed, "commit", "--quiet", "-m", "init")
+	commit := gitIn(t, seed, "rev-parse", "HEAD")
+
+	shared := filepath.Join(root, "shared.git")
+	gitIn(t, root, "clone", "--quiet", "--bare", seed, shared)
+	ctx := context.Background()
+
+	// 空目录直接创建，非空目录（如已有嵌套项目）通过临时目录创建
+	a := filepath.Join(root, "a")
+	b := filepath.Join(root, "b")
+	if err := os.MkdirAll(filepath.Join(b, "nested"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(b, "nested", "keep"), []byte("keep"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{a, b} {
+		if err := AddWorktree(ctx, shared, path, commit); err != nil {
+			t.Fatalf("AddWorktree(%s): %v", path, err)
+		}
+		if got := gitIn(t, path, "rev-parse", "HEAD"); got != commit {
+			t.Errorf("%s: HEAD = %s, want %s", path, got, commit)
+		}
+		if status := gitIn(t, path, "status", "--porcelain", "--untracked-files=no"); status != "" {
+			t.Errorf("%s: worktree not clean:\n%s", path, status)
+		}
+		if got, want := CommonDir(path), shared; got != want {
+			t.Errorf("CommonDir(%s) = %s, want %s", path, got, want)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(b, "nested", "keep")); err != nil {
+		t.Errorf("existing file removed: %v", err)
+	}
+	if got, want := CommonDir(seed), filepath.Join(seed, ".git"); got != want {
+		t.Errorf("CommonDir(%s) = %s, want %s", seed, got, want)
+	}
+
+	worktrees, err := ListWorktrees(ctx, shared)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(worktrees) != 3 || !worktrees[0].Bare || worktrees[1].Head != commit {
+		t.Fatalf("ListWorktrees() = %+v", worktrees)
+	}
+
+	// 有未跟踪文件的工作区不强制时不会被删除
+	if err := os.WriteFile(filepath.Join(a, "untracked"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := RemoveWorktree(ctx, shared, a, false); err == nil {
+		t.Error("RemoveWorktree() removed a worktree with untracked files")
+	}
+	if err := RemoveWorktree(ctx, shared, a, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(a); !os.IsNotExist(err) {
+		t.Errorf("worktree %s still exists", a)
+	}
+
+	// 目录被删除的工作区由 prune 清除
+	if err := os.RemoveAll(b); err != nil {
+		t.Fatal(err)
+	}
+	if worktrees, _ := ListWorktrees(ctx, shared); len(worktrees) != 2 || !worktrees[1].Prunable {
+		t.Fatalf("ListWorktrees() after removing %s = %+v", b, worktrees)
+	}
+	if err := PruneWorktrees(ctx, shared); err != nil {
+		t.Fatal(err)
+	}
+	if worktrees, _ := ListWorktrees(ctx, shared); len(worktrees) != 1 {
+		t.Errorf("ListWorktrees() after prune = %+v", worktrees)
+	}
+}
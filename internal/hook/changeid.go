@@ -0,0 +1,176 @@
+package hook
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/leopardxu/repo-go/internal/git"
+)
+
+// trailerLinePattern 匹配 "Key: value" 形式的提交信息尾注
+var trailerLinePattern = regexp.MustCompile(`^[A-Za-z0-9-]+:\s*\S`)
+
+// InsertChangeID 在提交信息末尾的尾注段中追加 Change-Id
+// 行为与 commit-msg hook 中的 git interpret-trailers 一致
+func InsertChangeID(message string, changeID string) string {
+	body := strings.TrimRight(message, "\n\t ")
+	trailer := "Change-Id: " + changeID
+
+	if idx := strings.LastIndex(body, "\n\n"); idx >= 0 && isTrailerBlock(body[idx+2:]) {
+		return body + "\n" + trailer + "\n"
+	}
+	return body + "\n\n" + trailer + "\n"
+}
+
+// isTrailerBlock 判断段落是否全部由尾注行（及其续行）组成
+func isTrailerBlock(paragraph string) bool {
+	lines := strings.Split(paragraph, "\n")
+	if len(lines) == 0 || !trailerLinePattern.MatchString(lines[0]) {
+		return false
+	}
+	for _, line := range lines[1:] {
+		if !trailerLinePattern.MatchString(line) && !strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "\t") {
+			return false
+		}
+	}
+	return true
+}
+
+// FixChangeIDs 为分支上缺少 Change-Id 的提交补充 Change-Id
+// commits 为分支上未发布的提交（从旧到新），其中最新的提交必须是分支当前指向的提交。
+// 从第一个缺少 Change-Id 的提交开始重写，保留作者、提交者和树对象，返回补充的数量。
+func FixChangeIDs(ctx context.Context, worktree string, branch string, commits []string) (int, error) {
+	if len(commits) == 0 {
+		return 0, nil
+	}
+
+	raws := make([][]byte, len(commits))
+	first := -1
+	for i, commit := range commits {
+		raw, err := runGit(ctx, worktree, "cat-file", "commit", commit)
+		if err != nil {
+			return 0, err
+		}
+		raws[i] = raw
+		if _, message := splitCommitObject(raw); first < 0 && git.ParseChangeID(message) == "" {
+			first = i
+		}
+	}
+	if first < 0 {
+		return 0, nil
+	}
+
+	oldTip := commits[len(commits)-1]
+	ref := "refs/heads/" + branch
+	tip, err := runGit(ctx, worktree, "rev-parse", "--verify", ref)
+	if err != nil {
+		return 0, err
+	}
+	if strings.TrimSpace(string(tip)) != oldTip {
+		return 0, &HookError{Op: "fix_change_ids", Path: worktree, Err: fmt.Errorf("branch %s does not point at %s", branch, oldTip)}
+	}
+
+	fixed := 0
+	newParent := ""
+	for i := first; i < len(commits); i++ {
+		header, message := splitCommitObject(raws[i])
+		if parents := commitParents(header); len(parents) > 1 {
+			return 0, &HookError{Op: "fix_change_ids", Path: worktree, Err: fmt.Errorf("cannot rewrite merge commit %s", commits[i])}
+		}
+		if newParent != "" {
+			header = strings.Replace(header, "\nparent "+commits[i-1]+"\n", "\nparent "+newParent+"\n", 1)
+		}
+		// 重写后原有签名不再有效
+		header = stripSignature(header)
+
+		if git.ParseChangeID(message) == "" {
+			message = InsertChangeID(message, fmt.Sprintf("I%x", sha1.Sum(raws[i])))
+			fixed++
+		}
+
+		object := header + "\n" + message
+		newCommit, err := hashCommit(ctx, worktree, object)
+		if err != nil {
+			return 0, err
+		}
+		newParent = strings.TrimSpace(string(newCommit))
+	}
+
+	if _, err := runGit(ctx, worktree, "update-ref", "-m", "repo hooks: add Change-Id", ref, newParent, oldTip); err != nil {
+		return 0, err
+	}
+	log.Debug("已为 %s 分支 %s 补充 %d 个 Change-Id", worktree, branch, fixed)
+	return fixed, nil
+}
+
+// splitCommitObject 将原始提交对象拆分为头部（以换行结尾）和提交信息
+func splitCommitObject(raw []byte) (string, string) {
+	content := string(raw)
+	idx := strings.Index(content, "\n\n")
+	if idx < 0 {
+		return content, ""
+	}
+	return content[:idx+1], content[idx+2:]
+}
+
+// commitParents 返回提交头部中的父提交列表
+func commitParents(header string) []string {
+	var parents []string
+	for _, line := range strings.Split(header, "\n") {
+		if strings.HasPrefix(line, "parent ") {
+			parents = append(parents, strings.TrimPrefix(line, "parent "))
+		}
+	}
+	return parents
+}
+
+// stripSignature 移除提交头部中的 gpgsig 签名及其续行
+func stripSignature(header string) string {
+	var kept []string
+	inSignature := false
+	for _, line := range strings.SplitAfter(header, "\n") {
+		if strings.HasPrefix(line, "gpgsig ") || strings.HasPrefix(line, "gpgsig-sha256 ") {
+			inSignature = true
+			continue
+		}
+		if inSignature && strings.HasPrefix(line, " ") {
+			continue
+		}
+		inSignature = false
+		kept = append(kept, line)
+	}
+	return strings.Join(kept, "")
+}
+
+// gitRunner 执行补充 Change-Id 时的 git 命令
+var gitRunner = git.NewRunner()
+
+// runGit 在worktree中执行git命令
+func runGit(ctx context.Context, worktree string, args ...string) ([]byte, error) {
+	output, err := gitRunner.RunInDirContext(ctx, worktree, args...)
+	if err != nil {
+		return nil, &HookError{Op: "git " + args[0], Path: worktree, Err: err}
+	}
+	return output, nil
+}
+
+// hashCommit 将提交对象写入worktree所在仓库的对象库，返回新提交的哈希
+func hashCommit(ctx context.Context, worktree string, object string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := git.Command(ctx, worktree, "hash-object", "-t", "commit", "-w", "--stdin")
+	cmd.Stdin = strings.NewReader(object)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := git.Run(cmd); err != nil {
+		return nil, &HookError{
+			Op:   "git hash-object",
+			Path: worktree,
+			Err:  fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String())),
+		}
+	}
+	return stdout.Bytes(), nil
+}
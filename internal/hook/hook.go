@@ -445,8 +445,13 @@ func LinkHooks(projectDir string, hooksDir string) error {
 		}
 	}
 
+	// 符号链接需要使用绝对路径，否则链接会相对于项目hooks目录解析
+	if absHooksDir, err := filepath.Abs(hooksDir); err == nil {
+		hooksDir = absHooksDir
+	}
+
 	// 确保项目git/hooks目录存在
-	projectHooksDir := filepath.Join(projectDir, ".git", "hooks")
+	projectHooksDir := projectHooksDir(projectDir)
 	if err := os.MkdirAll(projectHooksDir, 0755); err != nil {
 		log.Error("创建项目hooks目录失败: %v", err)
 		return &HookError{
@@ -490,8 +495,8 @@ func LinkHooks(projectDir string, hooksDir string) error {
 				return
 			}
 
-			// 如果目标文件已存在，先删
-			if fileExists(dstPath) {
+			// 如果目标文件已存在（包括失效的符号链接），先删
+			if linkExists(dstPath) {
 				if err := os.Remove(dstPath); err != nil {
 					errorCh <- &HookError{
 						Op:   "remove_existing_hook",
@@ -555,10 +560,16 @@ func fileExists(path string) bool {
 	return err == nil
 }
 
+// linkExists 检查路径是否存在，不跟随符号链接
+func linkExists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
 // trySymlink 尝试创建符号链接，如果不支持则返回false
 func trySymlink(src, dst string) bool {
-	// 如果目标文件已存在，先删
-	if fileExists(dst) {
+	// 如果目标文件已存在（包括失效的符号链接），先删
+	if linkExists(dst) {
 		if err := os.Remove(dst); err != nil {
 			return false
 		}
@@ -0,0 +1,152 @@
+package hook
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestInsertChangeID(t *testing.T) {
+	const id = "I0123456789abcdef0123456789abcdef01234567"
+	tests := []struct {
+		name    string
+		message string
+		want    string
+	}{
+		{
+			name:    "subject only",
+			message: "fix bug\n",
+			want:    "fix bug\n\nChange-Id: " + id + "\n",
+		},
+		{
+			name:    "body without trailers",
+			message: "fix bug\n\nlonger description\n\n",
+			want:    "fix bug\n\nlonger description\n\nChange-Id: " + id + "\n",
+		},
+		{
+			name:    "existing trailer block",
+			message: "fix bug\n\nSigned-off-by: a <a@b>\n",
+			want:    "fix bug\n\nSigned-off-by: a <a@b>\nChange-Id: " + id + "\n",
+		},
+		{
+			name:    "paragraph that only starts like a trailer",
+			message: "fix bug\n\nNote: this is prose\nand continues here\n",
+			want:    "fix bug\n\nNote: this is prose\nand continues here\n\nChange-Id: " + id + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := InsertChangeID(tt.message, id); got != tt.want {
+				t.Errorf("InsertChangeID() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckHooks(t *testing.T) {
+	root := t.TempDir()
+	hooksDir := filepath.Join(root, "hooks")
+	projectDir := filepath.Join(root, "project")
+	projectHooks := filepath.Join(projectDir, ".git", "hooks")
+	for _, dir := range []string{hooksDir, projectHooks} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"commit-msg", "pre-commit", "pre-auto-gc", "post-checkout"} {
+		if err := os.WriteFile(filepath.Join(hooksDir, name), []byte(name), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// commit-msg: 正确的符号链接；pre-commit: 内容一致的副本；
+	// pre-auto-gc: 指向其他位置的链接；post-checkout: 缺失
+	if err := os.Symlink(filepath.Join(hooksDir, "commit-msg"), filepath.Join(projectHooks, "commit-msg")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(projectHooks, "pre-commit"), []byte("pre-commit"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(root, "elsewhere"), filepath.Join(projectHooks, "pre-auto-gc")); err != nil {
+		t.Fatal(err)
+	}
+
+	statuses, err := CheckHooks(projectDir, hooksDir)
+	if err != nil {
+		t.Fatalf("CheckHooks() error = %v", err)
+	}
+	want := map[string]HookState{
+		"commit-msg":    HookOK,
+		"pre-commit":    HookOK,
+		"pre-auto-gc":   HookOutdated,
+		"post-checkout": HookMissing,
+	}
+	if len(statuses) != len(want) {
+		t.Fatalf("CheckHooks() returned %d statuses, want %d", len(statuses), len(want))
+	}
+	for _, s := range statuses {
+		if s.State != want[s.Name] {
+			t.Errorf("%s: state = %v, want %v", s.Name, s.State, want[s.Name])
+		}
+	}
+
+	// 失效的符号链接应被替换，而不是写穿到链接目标
+	if err := LinkHooks(projectDir, hooksDir); err != nil {
+		t.Fatalf("LinkHooks() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "elsewhere")); !os.IsNotExist(err) {
+		t.Errorf("LinkHooks() wrote through a dangling symlink")
+	}
+	statuses, err = CheckHooks(projectDir, hooksDir)
+	if err != nil {
+		t.Fatalf("CheckHooks() error = %v", err)
+	}
+	if !HooksUpToDate(statuses) {
+		t.Errorf("hooks not up to date after LinkHooks: %+v", statuses)
+	}
+}
+
+func TestFixChangeIDs(t *testing.T) {
+	dir := t.TempDir()
+	run := func(args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=t", "GIT_AUTHOR_EMAIL=t@example.com",
+			"GIT_COMMITTER_NAME=t", "GIT_COMMITTER_EMAIL=t@example.com")
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	run("init", "--quiet", "-b", "topic")
+	run("commit", "--quiet", "--allow-empty", "-m", "base")
+	run("commit", "--quiet", "--allow-empty", "-m", "has id\n\nChange-Id: I1111111111111111111111111111111111111111")
+	run("commit", "--quiet", "--allow-empty", "-m", "missing id")
+	commits := strings.Fields(run("rev-list", "--reverse", "HEAD~2..HEAD"))
+
+	fixed, err := FixChangeIDs(context.Background(), dir, "topic", commits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fixed != 1 {
+		t.Errorf("FixChangeIDs() fixed %d commits, want 1", fixed)
+	}
+	if msg := run("log", "-1", "--format=%B", "topic"); !strings.Contains(msg, "Change-Id: I") {
+		t.Errorf("tip message has no Change-Id:\n%s", msg)
+	}
+	// 已有 Change-Id 的提交保持不变
+	if got := run("rev-parse", "topic~1"); got != commits[0] {
+		t.Errorf("topic~1 = %s, want unchanged %s", got, commits[0])
+	}
+
+	// 分支不再指向传入的提交时拒绝重写
+	if _, err := FixChangeIDs(context.Background(), dir, "topic", commits); err == nil {
+		t.Error("FixChangeIDs() rewrote a branch that moved")
+	}
+}
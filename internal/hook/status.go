@@ -0,0 +1,134 @@
+package hook
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/leopardxu/repo-go/internal/git"
+)
+
+// HookState 表示项目中单个hook相对.repo/hooks的状态
+type HookState int
+
+const (
+	// HookOK hook已链接或内容与模板一致
+	HookOK HookState = iota
+	// HookMissing 项目中不存在该hook
+	HookMissing
+	// HookOutdated hook存在但指向其他位置或内容已过期
+	HookOutdated
+)
+
+func (s HookState) String() string {
+	switch s {
+	case HookOK:
+		return "ok"
+	case HookMissing:
+		return "missing"
+	case HookOutdated:
+		return "outdated"
+	default:
+		return "unknown"
+	}
+}
+
+// HookStatus 描述项目中单个hook的检查结果
+type HookStatus struct {
+	Name   string
+	State  HookState
+	Copied bool // 以复制方式安装（不支持符号链接时的回退）
+}
+
+// projectHooksDir 返回项目的 hooks 目录，git worktree 创建的工作区使用共享仓库中的 hooks
+func projectHooksDir(projectDir string) string {
+	gitDir := git.CommonDir(projectDir)
+	if gitDir == "" {
+		gitDir = filepath.Join(projectDir, ".git")
+	}
+	return filepath.Join(gitDir, "hooks")
+}
+
+// CheckHooks 将项目.git/hooks中的hook与hooksDir中的模板逐一比较
+func CheckHooks(projectDir string, hooksDir string) ([]HookStatus, error) {
+	entries, err := os.ReadDir(hooksDir)
+	if err != nil {
+		return nil, &HookError{
+			Op:   "read_hooks_dir",
+			Path: hooksDir,
+			Err:  err,
+		}
+	}
+
+	absHooksDir, err := filepath.Abs(hooksDir)
+	if err != nil {
+		return nil, &HookError{Op: "check_hooks", Path: hooksDir, Err: err}
+	}
+	projectHooksDir := projectHooksDir(projectDir)
+
+	var statuses []HookStatus
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		srcPath := filepath.Join(absHooksDir, entry.Name())
+		dstPath := filepath.Join(projectHooksDir, entry.Name())
+		statuses = append(statuses, checkHook(entry.Name(), srcPath, dstPath))
+	}
+
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses, nil
+}
+
+// checkHook 检查单个hook，符号链接需指向模板，复制的文件需内容一致且可执行
+func checkHook(name, srcPath, dstPath string) HookStatus {
+	status := HookStatus{Name: name}
+
+	info, err := os.Lstat(dstPath)
+	if err != nil {
+		status.State = HookMissing
+		return status
+	}
+
+	if info.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(dstPath)
+		if err != nil {
+			status.State = HookOutdated
+			return status
+		}
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(dstPath), target)
+		}
+		if filepath.Clean(target) == srcPath {
+			status.State = HookOK
+		} else {
+			status.State = HookOutdated
+		}
+		return status
+	}
+
+	status.Copied = true
+	srcContent, err := os.ReadFile(srcPath)
+	if err != nil {
+		status.State = HookOutdated
+		return status
+	}
+	dstContent, err := os.ReadFile(dstPath)
+	if err != nil || !bytes.Equal(srcContent, dstContent) || info.Mode()&0111 == 0 {
+		status.State = HookOutdated
+		return status
+	}
+	status.State = HookOK
+	return status
+}
+
+// HooksUpToDate 判断检查结果中是否所有hook都处于正常状态
+func HooksUpToDate(statuses []HookStatus) bool {
+	for _, s := range statuses {
+		if s.State != HookOK {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,302 @@
+package lock
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/leopardxu/repo-go/internal/logger"
+)
+
+// Mode 表示锁的模式
+type Mode int
+
+const (
+	// Shared 共享锁，可以与其他共享锁同时持有
+	Shared Mode = iota
+	// Exclusive 独占锁，与任何其他锁互斥
+	Exclusive
+)
+
+// String 返回模式名称，同时用作持有者文件名的前缀
+func (m Mode) String() string {
+	if m == Exclusive {
+		return "exclusive"
+	}
+	return "shared"
+}
+
+// ParentsEnv 是列出启动当前进程的 repo 进程的环境变量，用逗号分隔进程号
+// 这些进程持有的锁不会阻塞当前进程，避免 repo forall -c 'repo start ...' 等待 forall 自己持有的项目锁
+const ParentsEnv = "REPO_LOCK_PARENTS"
+
+// ChildEnv 返回传给子进程的 ParentsEnv 环境变量，包含当前进程和当前进程继承的父进程
+func ChildEnv() string {
+	pids := strconv.Itoa(os.Getpid())
+	if parents := os.Getenv(ParentsEnv); parents != "" {
+		pids = parents + "," + pids
+	}
+	return ParentsEnv + "=" + pids
+}
+
+// parentPIDs 返回 ParentsEnv 中列出的进程号
+func parentPIDs() map[int]bool {
+	pids := make(map[int]bool)
+	for _, field := range strings.Split(os.Getenv(ParentsEnv), ",") {
+		if pid, err := strconv.Atoi(strings.TrimSpace(field)); err == nil && pid > 0 {
+			pids[pid] = true
+		}
+	}
+	return pids
+}
+
+// ErrTimeout 表示在 --wait 指定的时间内未能获取锁
+var ErrTimeout = errors.New("timed out waiting for lock")
+
+// pollInterval 是等待锁时两次尝试之间的基本间隔，实际间隔带有随机抖动
+const pollInterval = 100 * time.Millisecond
+
+var (
+	mu      sync.RWMutex
+	command = "repo"
+	timeout time.Duration
+
+	seq atomic.Int64
+)
+
+// Configure 设置持有者信息中记录的命令名称和等待锁的最长时间，timeout 为 0 时一直等待
+func Configure(cmd string, wait time.Duration) {
+	mu.Lock()
+	defer mu.Unlock()
+	command = cmd
+	timeout = wait
+}
+
+// settings 返回当前的命令名称和等待时间
+func settings() (string, time.Duration) {
+	mu.RLock()
+	defer mu.RUnlock()
+	return command, timeout
+}
+
+// ClientPath 返回客户端锁的目录 .repo/repo.lock
+func ClientPath(repoRoot string) string {
+	return filepath.Join(repoRoot, ".repo", "repo.lock")
+}
+
+// ProjectPath 返回项目锁的目录 .repo/project-locks/<转义后的项目路径>
+func ProjectPath(repoRoot, projectPath string) string {
+	return filepath.Join(repoRoot, ".repo", "project-locks", url.PathEscape(filepath.ToSlash(projectPath)))
+}
+
+// Owner 描述锁的一个持有者
+type Owner struct {
+	Mode    Mode      `json:"-"`
+	PID     int       `json:"pid"`
+	Host    string    `json:"host"`
+	Command string    `json:"command"`
+	Since   time.Time `json:"since"`
+
+	file string
+}
+
+// String 返回用于提示信息的持有者描述
+func (o Owner) String() string {
+	desc := fmt.Sprintf("%s (pid %d", o.Command, o.PID)
+	if !o.Since.IsZero() {
+		desc += ", since " + o.Since.Format("15:04:05")
+	}
+	return desc + ")"
+}
+
+// Lock 表示已获取的锁
+// 锁是一个目录，每个持有者在其中创建一个以模式、PID 和序号命名的文件；
+// 创建文件后再检查其他持有者，发现冲突时删除自己的文件并稍后重试
+type Lock struct {
+	dir  string
+	file string
+}
+
+// Acquire 获取目录 dir 表示的锁，被其他持有者占用时等待，第一次等待时输出提示
+// 持有者进程已不存在时视为过期锁并删除；超过 Configure 设置的时间或 ctx 取消时返回错误
+func Acquire(ctx context.Context, dir string, mode Mode) (*Lock, error) {
+	cmd, wait := settings()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create lock directory %s: %w", dir, err)
+	}
+
+	host, _ := os.Hostname()
+	owner := Owner{Mode: mode, PID: os.Getpid(), Host: host, Command: cmd, Since: time.Now()}
+	start := time.Now()
+	reported := false
+	for {
+		l, blockers, err := tryAcquire(dir, owner)
+		if err != nil {
+			return nil, err
+		}
+		if l != nil {
+			if reported {
+				logger.Info("已获取锁 %s", dir)
+			}
+			return l, nil
+		}
+
+		if wait > 0 && time.Since(start) >= wait {
+			return nil, fmt.Errorf("%w %s after %s, held by %s", ErrTimeout, dir, wait, describe(blockers))
+		}
+		if !reported {
+			logger.Info("等待 %s 释放锁 %s ...", describe(blockers), dir)
+			reported = true
+		}
+
+		delay := pollInterval + time.Duration(rand.Int63n(int64(pollInterval)))
+		if wait > 0 {
+			if remaining := wait - time.Since(start); remaining < delay {
+				delay = max(remaining, time.Millisecond)
+			}
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// tryAcquire 尝试获取一次锁，失败时返回阻塞的持有者
+func tryAcquire(dir string, owner Owner) (*Lock, []Owner, error) {
+	name := fmt.Sprintf("%s-%d-%d", owner.Mode, owner.PID, seq.Add(1))
+	file := filepath.Join(dir, name)
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create lock file %s: %w", file, err)
+	}
+	data, _ := json.Marshal(owner)
+	_, werr := f.Write(data)
+	if cerr := f.Close(); werr == nil {
+		werr = cerr
+	}
+	if werr != nil {
+		os.Remove(file)
+		return nil, nil, fmt.Errorf("failed to write lock file %s: %w", file, werr)
+	}
+
+	holders, err := Holders(dir)
+	if err != nil {
+		os.Remove(file)
+		return nil, nil, err
+	}
+	host, _ := os.Hostname()
+	parents := parentPIDs()
+	var blockers []Owner
+	for _, h := range holders {
+		if h.file == name {
+			continue
+		}
+		if parents[h.PID] && (h.Host == "" || h.Host == host) {
+			continue
+		}
+		if owner.Mode == Exclusive || h.Mode == Exclusive {
+			blockers = append(blockers, h)
+		}
+	}
+	if len(blockers) > 0 {
+		os.Remove(file)
+		return nil, blockers, nil
+	}
+	return &Lock{dir: dir, file: file}, nil, nil
+}
+
+// Holders 返回锁目录中仍然有效的持有者，同时删除进程已不存在的过期持有者
+func Holders(dir string) ([]Owner, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read lock directory %s: %w", dir, err)
+	}
+
+	host, _ := os.Hostname()
+	var holders []Owner
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		owner, ok := parseOwner(dir, entry.Name())
+		if !ok {
+			continue
+		}
+		// 其他主机上的进程无法检查，始终视为有效
+		if (owner.Host == "" || owner.Host == host) && !processAlive(owner.PID) {
+			if err := os.Remove(filepath.Join(dir, owner.file)); err == nil {
+				logger.Warn("删除过期的锁 %s: %s 已不存在", filepath.Join(dir, owner.file), owner)
+			}
+			continue
+		}
+		holders = append(holders, owner)
+	}
+	return holders, nil
+}
+
+// parseOwner 根据持有者文件名和内容解析持有者，文件内容不完整时只使用文件名中的信息
+func parseOwner(dir, name string) (Owner, bool) {
+	parts := strings.Split(name, "-")
+	if len(parts) != 3 {
+		return Owner{}, false
+	}
+	var mode Mode
+	switch parts[0] {
+	case Shared.String():
+		mode = Shared
+	case Exclusive.String():
+		mode = Exclusive
+	default:
+		return Owner{}, false
+	}
+	pid, err := strconv.Atoi(parts[1])
+	if err != nil || pid <= 0 {
+		return Owner{}, false
+	}
+
+	owner := Owner{Command: "unknown command"}
+	if data, err := os.ReadFile(filepath.Join(dir, name)); err == nil {
+		json.Unmarshal(data, &owner)
+	}
+	owner.Mode = mode
+	owner.PID = pid
+	owner.file = name
+	return owner, true
+}
+
+// describe 返回持有者列表的描述
+func describe(owners []Owner) string {
+	descs := make([]string, len(owners))
+	for i, o := range owners {
+		descs[i] = o.String()
+	}
+	return strings.Join(descs, ", ")
+}
+
+// Release 释放锁，l 为 nil 时不做任何事
+func (l *Lock) Release() error {
+	if l == nil {
+		return nil
+	}
+	if err := os.Remove(l.file); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to release lock %s: %w", l.dir, err)
+	}
+	return nil
+}
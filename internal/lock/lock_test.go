@@ -0,0 +1,165 @@
+package lock
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSharedAndExclusive(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "repo.lock")
+	Configure("repo status", 50*time.Millisecond)
+	defer Configure("repo", 0)
+	ctx := context.Background()
+
+	a, err := Acquire(ctx, dir, Shared)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := Acquire(ctx, dir, Shared)
+	if err != nil {
+		t.Fatalf("second shared lock: %v", err)
+	}
+	if _, err := Acquire(ctx, dir, Exclusive); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("exclusive lock while shared held = %v, want ErrTimeout", err)
+	}
+
+	a.Release()
+	b.Release()
+	x, err := Acquire(ctx, dir, Exclusive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Acquire(ctx, dir, Shared); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("shared lock while exclusive held = %v, want ErrTimeout", err)
+	}
+
+	holders, err := Holders(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(holders) != 1 || holders[0].Mode != Exclusive || holders[0].PID != os.Getpid() || holders[0].Command != "repo status" {
+		t.Fatalf("Holders() = %+v", holders)
+	}
+	if err := x.Release(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWaitForRelease(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "repo.lock")
+	Configure("repo sync", 0)
+	ctx := context.Background()
+
+	x, err := Acquire(ctx, dir, Exclusive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		time.Sleep(150 * time.Millisecond)
+		x.Release()
+	}()
+	start := time.Now()
+	l, err := Acquire(ctx, dir, Exclusive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Release()
+	if time.Since(start) < 100*time.Millisecond {
+		t.Errorf("lock acquired before it was released")
+	}
+}
+
+func TestCancel(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "repo.lock")
+	Configure("repo sync", 0)
+
+	x, err := Acquire(context.Background(), dir, Exclusive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer x.Release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := Acquire(ctx, dir, Shared); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Acquire() = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestStaleLock(t *testing.T) {
+	// 已退出进程的 PID 不再有效
+	cmd := exec.Command("git", "--version")
+	if err := cmd.Run(); err != nil {
+		t.Skip("git not available")
+	}
+	pid := cmd.Process.Pid
+
+	dir := filepath.Join(t.TempDir(), "repo.lock")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	host, _ := os.Hostname()
+	data, _ := json.Marshal(Owner{PID: pid, Host: host, Command: "repo sync"})
+	stale := filepath.Join(dir, "exclusive-"+strconv.Itoa(pid)+"-1")
+	if err := os.WriteFile(stale, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	Configure("repo status", 50*time.Millisecond)
+	defer Configure("repo", 0)
+	l, err := Acquire(context.Background(), dir, Shared)
+	if err != nil {
+		t.Fatalf("Acquire() with stale lock: %v", err)
+	}
+	defer l.Release()
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Errorf("stale lock file was not removed")
+	}
+}
+
+func TestParentLock(t *testing.T) {
+	// 用测试进程的父进程模拟启动当前进程的 repo forall
+	ppid := os.Getppid()
+	dir := filepath.Join(t.TempDir(), "project.lock")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	host, _ := os.Hostname()
+	data, _ := json.Marshal(Owner{PID: ppid, Host: host, Command: "repo forall"})
+	if err := os.WriteFile(filepath.Join(dir, "exclusive-"+strconv.Itoa(ppid)+"-1"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	Configure("repo start", 50*time.Millisecond)
+	defer Configure("repo", 0)
+	t.Setenv(ParentsEnv, "")
+	if _, err := Acquire(context.Background(), dir, Exclusive); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("Acquire() while another process holds the lock = %v, want ErrTimeout", err)
+	}
+
+	t.Setenv(ParentsEnv, "1,"+strconv.Itoa(ppid))
+	l, err := Acquire(context.Background(), dir, Exclusive)
+	if err != nil {
+		t.Fatalf("Acquire() under the parent holding the lock: %v", err)
+	}
+	l.Release()
+
+	if got, want := ChildEnv(), ParentsEnv+"=1,"+strconv.Itoa(ppid)+","+strconv.Itoa(os.Getpid()); got != want {
+		t.Errorf("ChildEnv() = %q, want %q", got, want)
+	}
+}
+
+func TestProjectPath(t *testing.T) {
+	got := ProjectPath("/client", "platform/build")
+	want := filepath.Join("/client", ".repo", "project-locks", "platform%2Fbuild")
+	if got != want {
+		t.Errorf("ProjectPath() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,15 @@
+//go:build windows
+
+package lock
+
+import "os"
+
+// processAlive 检查进程是否仍在运行，Windows 上无法打开不存在的进程
+func processAlive(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	p.Release()
+	return true
+}
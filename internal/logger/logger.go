@@ -196,7 +196,11 @@ func (l *DefaultLogger) log(level LogLevel, format string, args ...interface{})
 
 	// 输出到控制台
 	if level <= currentLevel {
-		fmt.Fprint(outputWriter, output)
+		if guard := getOutputGuard(); guard != nil {
+			guard(func() { fmt.Fprint(outputWriter, output) })
+		} else {
+			fmt.Fprint(outputWriter, output)
+		}
 	}
 
 	// 输出到调试文件
@@ -269,6 +273,28 @@ func (fl *FieldLogger) formatWithFields(format string, args ...interface{}) stri
 // Global 全局日志记录器
 var Global Logger = NewDefaultLogger()
 
+var (
+	outputGuardMu sync.RWMutex
+	outputGuard   func(write func())
+)
+
+// SetOutputGuard 设置包装所有控制台日志输出的函数并返回之前的设置，guard 为 nil 时直接输出
+// 终端上的进度显示器用它在输出日志前清除进度行，输出后重新绘制
+func SetOutputGuard(guard func(write func())) func(write func()) {
+	outputGuardMu.Lock()
+	defer outputGuardMu.Unlock()
+	prev := outputGuard
+	outputGuard = guard
+	return prev
+}
+
+// getOutputGuard 返回当前的控制台输出包装函数
+func getOutputGuard() func(write func()) {
+	outputGuardMu.RLock()
+	defer outputGuardMu.RUnlock()
+	return outputGuard
+}
+
 // SetGlobalLogger 设置全局日志记录器
 func SetGlobalLogger(logger Logger) {
 	Global = logger
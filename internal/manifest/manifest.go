@@ -92,6 +92,7 @@ type Remote struct {
 	Revision    string            `xml:"revision,attr,omitempty"`
 	Alias       string            `xml:"alias,attr,omitempty"`
 	PushURL     string            `xml:"pushurl,attr,omitempty"` // 新增：推送URL
+	SyncJ       int               `xml:"sync-j,attr,omitempty"`  // 该远程所在主机的最大并发获取数
 	CustomAttrs map[string]string `xml:"-"`                      // 存储自定义属性
 }
 
@@ -1376,7 +1377,7 @@ func isKnownRemoteAttr(name string) bool {
 
 func isStandardRemoteAttr(name string) bool {
 	switch name {
-	case "name", "fetch", "review", "revision", "alias", "pushurl":
+	case "name", "fetch", "review", "revision", "alias", "pushurl", "sync-j":
 		return true
 	}
 	return false
@@ -1503,6 +1504,9 @@ func (m *Manifest) ToXML() (string, error) {
 		if r.PushURL != "" {
 			xml += fmt.Sprintf(` pushurl="%s"`, r.PushURL)
 		}
+		if r.SyncJ > 0 {
+			xml += fmt.Sprintf(` sync-j="%d"`, r.SyncJ)
+		}
 		// 添加远程仓库的自定义属性
 		for k, v := range r.CustomAttrs {
 			xml += fmt.Sprintf(` %s="%s"`, k, v)
@@ -1670,6 +1674,11 @@ func containsAll(groups []string) bool {
 	return false
 }
 
+// InGroups 检查项目是否属于指定的组，规则与解析清单时按组过滤相同
+func (p Project) InGroups(groups []string) bool {
+	return containsAll(groups) || shouldIncludeProject(p, groups)
+}
+
 // shouldIncludeProject 检查项目是否应该包含在指定的组中
 // 支持否定表达式（以"-"开头的组表示排除）
 func shouldIncludeProject(project Project, groups []string) bool {
@@ -0,0 +1,8 @@
+package pager
+
+import "syscall"
+
+// dup2 复制文件描述符，部分 linux 架构（如 arm64）没有 dup2 系统调用
+func dup2(oldfd, newfd int) error {
+	return syscall.Dup3(oldfd, newfd, 0)
+}
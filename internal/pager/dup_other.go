@@ -0,0 +1,10 @@
+//go:build !linux && !windows
+
+package pager
+
+import "syscall"
+
+// dup2 复制文件描述符
+func dup2(oldfd, newfd int) error {
+	return syscall.Dup2(oldfd, newfd)
+}
@@ -0,0 +1,110 @@
+package pager
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// Pager 表示正在运行的分页器进程
+type Pager struct {
+	cmd    *exec.Cmd
+	stdout int // 原标准输出的副本
+	stderr int // 原标准错误的副本
+}
+
+// Start 启动分页器，并将标准输出和标准错误重定向到分页器
+// 重定向作用于文件描述符，之前创建的日志记录器和之后启动的子进程的输出同样进入分页器
+// 标准输出不是终端、分页器为空或 cat、或在 Windows 上时不启动分页器，返回 nil
+func Start() (*Pager, error) {
+	if runtime.GOOS == "windows" || !isTerminal(os.Stdout) {
+		return nil, nil
+	}
+	command := selectPager(os.Getenv, corePager())
+	if command == "" || command == "cat" {
+		return nil, nil
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create pager pipe: %w", err)
+	}
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Stdin = r
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = os.Environ()
+	// 与 git 相同：内容不足一屏时直接退出，保留颜色，不清屏
+	if os.Getenv("LESS") == "" {
+		cmd.Env = append(cmd.Env, "LESS=FRX")
+	}
+	if os.Getenv("LV") == "" {
+		cmd.Env = append(cmd.Env, "LV=-c")
+	}
+	if err := cmd.Start(); err != nil {
+		r.Close()
+		w.Close()
+		return nil, fmt.Errorf("failed to start pager %q: %w", command, err)
+	}
+	r.Close()
+
+	p := &Pager{cmd: cmd, stdout: -1, stderr: -1}
+	if p.stdout, err = redirect(1, w); err == nil {
+		p.stderr, err = redirect(2, w)
+	}
+	// 标准输出和标准错误已持有管道的写端
+	w.Close()
+	if err != nil {
+		p.Wait()
+		return nil, fmt.Errorf("failed to redirect output to pager: %w", err)
+	}
+	return p, nil
+}
+
+// Wait 恢复标准输出和标准错误并等待用户退出分页器，p 为 nil 时不做任何事
+func (p *Pager) Wait() {
+	if p == nil {
+		return
+	}
+	// 恢复后管道的写端全部关闭，分页器读到文件结束
+	restore(1, p.stdout)
+	restore(2, p.stderr)
+	p.cmd.Wait()
+}
+
+// selectPager 按 git 的顺序选择分页器：GIT_PAGER、core.pager、PAGER，都未设置时使用 less
+func selectPager(getenv func(string) string, corePager string) string {
+	if pager, ok := lookup(getenv, "GIT_PAGER"); ok {
+		return pager
+	}
+	if corePager != "" {
+		return corePager
+	}
+	if pager, ok := lookup(getenv, "PAGER"); ok {
+		return pager
+	}
+	return "less"
+}
+
+// lookup 返回环境变量的值，变量为空时视为未设置
+func lookup(getenv func(string) string, key string) (string, bool) {
+	value := strings.TrimSpace(getenv(key))
+	return value, value != ""
+}
+
+// corePager 返回 git 配置中的 core.pager
+func corePager() string {
+	out, err := exec.Command("git", "config", "--get", "core.pager").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// isTerminal 检查文件是否为终端
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
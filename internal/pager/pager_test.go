@@ -0,0 +1,27 @@
+package pager
+
+import "testing"
+
+func TestSelectPager(t *testing.T) {
+	tests := []struct {
+		name      string
+		env       map[string]string
+		corePager string
+		want      string
+	}{
+		{"default", nil, "", "less"},
+		{"PAGER", map[string]string{"PAGER": "more"}, "", "more"},
+		{"core.pager over PAGER", map[string]string{"PAGER": "more"}, "less -S", "less -S"},
+		{"GIT_PAGER first", map[string]string{"GIT_PAGER": "cat", "PAGER": "more"}, "less -S", "cat"},
+		{"blank ignored", map[string]string{"GIT_PAGER": "  ", "PAGER": "most"}, "", "most"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			getenv := func(key string) string { return tt.env[key] }
+			if got := selectPager(getenv, tt.corePager); got != tt.want {
+				t.Errorf("selectPager() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,30 @@
+//go:build !windows
+
+package pager
+
+import (
+	"os"
+	"syscall"
+)
+
+// redirect 将文件描述符 fd 指向 f，返回原描述符的副本用于恢复
+func redirect(fd int, f *os.File) (int, error) {
+	saved, err := syscall.Dup(fd)
+	if err != nil {
+		return -1, err
+	}
+	if err := dup2(int(f.Fd()), fd); err != nil {
+		syscall.Close(saved)
+		return -1, err
+	}
+	return saved, nil
+}
+
+// restore 将文件描述符 fd 恢复为 redirect 保存的副本，saved 为 -1 时不做任何事
+func restore(fd, saved int) {
+	if saved < 0 {
+		return
+	}
+	dup2(saved, fd)
+	syscall.Close(saved)
+}
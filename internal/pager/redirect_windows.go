@@ -0,0 +1,14 @@
+package pager
+
+import (
+	"errors"
+	"os"
+)
+
+// redirect 在 Windows 上不支持，Start 不会启动分页器
+func redirect(fd int, f *os.File) (int, error) {
+	return -1, errors.New("pager is not supported on windows")
+}
+
+// restore 在 Windows 上不做任何事
+func restore(fd, saved int) {}
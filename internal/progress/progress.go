@@ -7,41 +7,149 @@ import (
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/leopardxu/repo-go/internal/logger"
 )
 
 // Reporter 进度报告器接口
+// 按任务报告时先调用 StartTask，任务结束后调用 FinishTask，完成数随之增加；
+// 只关心总体进度时可以直接用 Update 设置完成数
 type Reporter interface {
 	Start(total int)
 	Update(current int, msg string)
+	StartTask(name string)
+	FinishTask(name string, ok bool)
+	Suspend(fn func())
 	Finish()
 }
 
+const (
+	// defaultMaxActive 是终端上最多显示的进行中任务数
+	defaultMaxActive = 8
+	// defaultStallAfter 是任务运行多久后提示可能卡住
+	defaultStallAfter = 2 * time.Minute
+	// showDelay 是开始后第一次在终端上显示进度前的等待时间，很快完成的操作不显示进度
+	showDelay = 500 * time.Millisecond
+	// ttyRefresh 是终端上刷新进度的间隔
+	ttyRefresh = 200 * time.Millisecond
+	// plainInterval 是非终端输出时两次进度行之间的间隔
+	plainInterval = 10 * time.Second
+)
+
+// task 表示一个进行中的任务
+type task struct {
+	name    string
+	start   time.Time
+	stalled bool
+}
+
 // ConsoleReporter 控制台进度报告器
+// 标准错误是终端时在多行区域中显示总体进度条、速度、预计剩余时间和进行中的任务，
+// 否则定期输出一行纯文本进度
 type ConsoleReporter struct {
-	title     string
-	total     int
-	current   int
-	startTime time.Time
-	mu        sync.Mutex
-	writer    io.Writer
-	enabled   bool
+	title      string
+	total      int
+	current    int
+	failed     int
+	msg        string
+	active     []*task // 按开始时间排序
+	startTime  time.Time
+	mu         sync.Mutex
+	writer     io.Writer
+	enabled    bool
+	tty        bool
+	lines      int  // 终端上当前显示的行数
+	shown      bool // 是否已经显示过进度
+	delay      time.Duration
+	lastDraw   time.Time
+	maxActive  int
+	stallAfter time.Duration
+	interval   time.Duration
+	stop       chan struct{}
+	stopped    chan struct{}
+	prevGuard  func(write func())
 }
 
 // NewConsoleReporter 创建控制台进度报告器
 func NewConsoleReporter() Reporter {
+	return NewConsoleReporterWithTitle("")
+}
+
+// NewConsoleReporterWithTitle 创建带标题的控制台进度报告器，输出到标准错误
+func NewConsoleReporterWithTitle(title string) Reporter {
+	cr := newConsoleReporter(os.Stderr, ansiTerminal(os.Stderr))
+	cr.title = title
+	return cr
+}
+
+// newConsoleReporter 创建输出到 w 的进度报告器
+func newConsoleReporter(w io.Writer, tty bool) *ConsoleReporter {
+	interval := plainInterval
+	if tty {
+		interval = ttyRefresh
+	}
 	return &ConsoleReporter{
-		writer:  os.Stderr,
-		enabled: true,
+		writer:     w,
+		enabled:    true,
+		tty:        tty,
+		maxActive:  defaultMaxActive,
+		stallAfter: defaultStallAfter,
+		interval:   interval,
+		delay:      showDelay,
 	}
 }
 
-// Start 开始进度报告
+// SetStallThreshold 设置任务运行多久后提示可能卡住，0 表示不提示
+func (cr *ConsoleReporter) SetStallThreshold(d time.Duration) {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	cr.stallAfter = d
+}
+
+// Start 开始进度报告，启动定时刷新
 func (cr *ConsoleReporter) Start(total int) {
 	cr.mu.Lock()
 	defer cr.mu.Unlock()
 	cr.total = total
 	cr.current = 0
+	cr.failed = 0
+	cr.active = nil
+	cr.shown = false
 	cr.startTime = time.Now()
+	cr.lastDraw = cr.startTime
+	if !cr.enabled || cr.stop != nil {
+		return
+	}
+
+	if cr.tty {
+		// 日志输出前先清除进度区域，输出后重新绘制
+		cr.prevGuard = logger.SetOutputGuard(cr.Suspend)
+	}
+	cr.stop = make(chan struct{})
+	cr.stopped = make(chan struct{})
+	go cr.refresh(cr.stop, cr.stopped, cr.interval)
+}
+
+// refresh 定时检查卡住的任务并刷新进度
+func (cr *ConsoleReporter) refresh(stop, stopped chan struct{}, interval time.Duration) {
+	defer close(stopped)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			cr.mu.Lock()
+			cr.checkStalled()
+			if cr.tty {
+				cr.draw()
+			} else {
+				cr.printLine()
+			}
+			cr.mu.Unlock()
+		}
+	}
 }
 
 // Update 更新进度
@@ -53,7 +161,52 @@ func (cr *ConsoleReporter) Update(current int, msg string) {
 	cr.mu.Lock()
 	defer cr.mu.Unlock()
 	cr.current = current
-	cr.display(msg)
+	cr.msg = msg
+	cr.redraw()
+}
+
+// StartTask 记录开始执行的任务
+func (cr *ConsoleReporter) StartTask(name string) {
+	if !cr.enabled {
+		return
+	}
+
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	cr.active = append(cr.active, &task{name: name, start: time.Now()})
+	cr.redraw()
+}
+
+// FinishTask 记录任务结束并增加完成数，ok 为 false 时计为失败
+func (cr *ConsoleReporter) FinishTask(name string, ok bool) {
+	if !cr.enabled {
+		return
+	}
+
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	for i, t := range cr.active {
+		if t.name == name {
+			cr.active = append(cr.active[:i], cr.active[i+1:]...)
+			break
+		}
+	}
+	cr.current++
+	if !ok {
+		cr.failed++
+	}
+	cr.redraw()
+}
+
+// Suspend 清除终端上的进度区域后执行 fn，之后重新绘制，用于在进度显示期间输出其他内容
+func (cr *ConsoleReporter) Suspend(fn func()) {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	cr.clear()
+	fn()
+	if cr.stop != nil {
+		cr.draw()
+	}
 }
 
 // Finish 完成进度报告
@@ -62,24 +215,148 @@ func (cr *ConsoleReporter) Finish() {
 		return
 	}
 
+	cr.mu.Lock()
+	stop, stopped := cr.stop, cr.stopped
+	cr.stop, cr.stopped = nil, nil
+	cr.mu.Unlock()
+	if stop != nil {
+		close(stop)
+		<-stopped
+	}
+
 	cr.mu.Lock()
 	defer cr.mu.Unlock()
-	cr.current = cr.total
-	fmt.Fprintln(cr.writer) // 换行
+	if cr.tty {
+		logger.SetOutputGuard(cr.prevGuard)
+		cr.prevGuard = nil
+	}
+	if cr.total == 0 || !cr.shown {
+		return
+	}
+	cr.active = nil
+	if cr.tty {
+		cr.clear()
+		fmt.Fprintln(cr.writer, cr.truncate(cr.summary()))
+	} else {
+		cr.printLine()
+	}
 }
 
-// display 显示进度信息
-func (cr *ConsoleReporter) display(msg string) {
+// redraw 在终端上刷新进度，两次绘制至少间隔 50 毫秒；非终端输出由定时刷新负责
+func (cr *ConsoleReporter) redraw() {
+	if !cr.tty || cr.stop == nil || time.Since(cr.lastDraw) < 50*time.Millisecond {
+		return
+	}
+	cr.draw()
+}
+
+// checkStalled 对运行超过阈值的任务输出一次提示
+func (cr *ConsoleReporter) checkStalled() {
+	if cr.stallAfter <= 0 {
+		return
+	}
+	for _, t := range cr.active {
+		elapsed := time.Since(t.start)
+		if t.stalled || elapsed < cr.stallAfter {
+			continue
+		}
+		t.stalled = true
+		warning := fmt.Sprintf("warning: %s has been running for %s, it may be stalled", t.name, formatDuration(elapsed))
+		cr.clear()
+		fmt.Fprintln(cr.writer, warning)
+	}
+}
+
+// draw 在终端上重新绘制进度区域，调用者需持有锁
+func (cr *ConsoleReporter) draw() {
+	if cr.total == 0 && len(cr.active) == 0 {
+		return
+	}
+	if !cr.shown && time.Since(cr.startTime) < cr.delay {
+		return
+	}
+	lines := []string{cr.summary()}
+	for i, t := range cr.active {
+		if i == cr.maxActive {
+			lines = append(lines, fmt.Sprintf("  ... and %d more", len(cr.active)-cr.maxActive))
+			break
+		}
+		line := fmt.Sprintf("  %-40s %s", t.name, formatDuration(time.Since(t.start)))
+		if t.stalled {
+			line += " (stalled?)"
+		}
+		lines = append(lines, line)
+	}
+	for i := range lines {
+		lines[i] = cr.truncate(lines[i])
+	}
+
+	var b strings.Builder
+	cr.writeClear(&b)
+	b.WriteString(strings.Join(lines, "\n"))
+	fmt.Fprint(cr.writer, b.String())
+	cr.lines = len(lines)
+	cr.shown = true
+	cr.lastDraw = time.Now()
+}
+
+// clear 清除终端上的进度区域，光标回到区域的第一行行首
+func (cr *ConsoleReporter) clear() {
+	if !cr.tty || cr.lines == 0 {
+		return
+	}
+	var b strings.Builder
+	cr.writeClear(&b)
+	fmt.Fprint(cr.writer, b.String())
+	cr.lines = 0
+}
+
+// writeClear 写入清除进度区域的控制序列
+func (cr *ConsoleReporter) writeClear(b *strings.Builder) {
+	if cr.lines == 0 {
+		return
+	}
+	b.WriteString("\r")
+	if cr.lines > 1 {
+		fmt.Fprintf(b, "\x1b[%dA", cr.lines-1)
+	}
+	b.WriteString("\x1b[J")
+}
+
+// printLine 在非终端输出上输出一行进度
+func (cr *ConsoleReporter) printLine() {
 	if cr.total == 0 {
 		return
 	}
+	line := cr.summary()
+	if len(cr.active) > 0 {
+		names := make([]string, 0, cr.maxActive)
+		for i, t := range cr.active {
+			if i == cr.maxActive {
+				names = append(names, fmt.Sprintf("and %d more", len(cr.active)-cr.maxActive))
+				break
+			}
+			names = append(names, t.name)
+		}
+		line += " | active: " + strings.Join(names, ", ")
+	}
+	fmt.Fprintln(cr.writer, line)
+	cr.shown = true
+}
 
-	percentage := float64(cr.current) * 100 / float64(cr.total)
+// summary 返回总体进度行：进度条、完成数、速度、已用时间和预计剩余时间
+func (cr *ConsoleReporter) summary() string {
+	var percentage float64
+	if cr.total > 0 {
+		percentage = float64(cr.current) * 100 / float64(cr.total)
+	}
 	elapsed := time.Since(cr.startTime)
 
 	// 计算预估剩余时间
 	var eta string
-	if cr.current > 0 {
+	if cr.current >= cr.total {
+		eta = "0s"
+	} else if cr.current > 0 {
 		avgTime := elapsed / time.Duration(cr.current)
 		remaining := time.Duration(cr.total-cr.current) * avgTime
 		eta = formatDuration(remaining)
@@ -89,48 +366,76 @@ func (cr *ConsoleReporter) display(msg string) {
 
 	// 构建进度条
 	barWidth := 30
-	filled := int(float64(barWidth) * percentage / 100)
+	filled := min(max(int(float64(barWidth)*percentage/100), 0), barWidth)
 	bar := strings.Repeat("=", filled) + strings.Repeat("-", barWidth-filled)
 
-	// 清除当前行并显示进度
-	output := fmt.Sprintf("\r[%s] %d/%d (%.1f%%) | %s | ETA: %s",
-		bar, cr.current, cr.total, percentage, formatDuration(elapsed), eta)
-
-	if msg != "" {
-		output += " | " + msg
+	output := fmt.Sprintf("[%s] %d/%d (%.1f%%) | %.1f/s | %s | ETA: %s",
+		bar, cr.current, cr.total, percentage, rate(cr.current, elapsed), formatDuration(elapsed), eta)
+	if cr.title != "" {
+		output = cr.title + ": " + output
+	}
+	if cr.failed > 0 {
+		output += fmt.Sprintf(" | %d failed", cr.failed)
 	}
+	if cr.msg != "" && len(cr.active) == 0 {
+		output += " | " + cr.msg
+	}
+	return output
+}
 
-	// 确保输出不会太长
-	maxLen := 120
-	if len(output) > maxLen {
-		output = output[:maxLen-3] + "..."
+// truncate 按终端宽度截断一行，避免换行后无法正确清除
+func (cr *ConsoleReporter) truncate(line string) string {
+	width := 120
+	if cr.tty {
+		width = terminalWidth() - 1
+	}
+	runes := []rune(line)
+	if len(runes) <= width {
+		return line
 	}
+	if width <= 3 {
+		return string(runes[:width])
+	}
+	return string(runes[:width-3]) + "..."
+}
 
-	fmt.Fprint(cr.writer, output)
+// rate 返回每秒完成的任务数
+func rate(done int, elapsed time.Duration) float64 {
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(done) / elapsed.Seconds()
 }
 
 // Progress 表示进度显示器
 type Progress struct {
-	title     string
-	total     int
-	current   int
-	quiet     bool
-	startTime time.Time
-	mu        sync.Mutex
-	writer    io.Writer
-	enabled   bool
+	reporter *ConsoleReporter
+	total    int
+	current  int
+	quiet    bool
+	started  bool
+	mu       sync.Mutex
+	enabled  bool
 }
 
 // NewProgress 创建新的进度显示器
 func NewProgress(title string, total int, quiet bool) *Progress {
+	reporter := newConsoleReporter(os.Stderr, ansiTerminal(os.Stderr))
+	reporter.title = title
 	return &Progress{
-		title:     title,
-		total:     total,
-		current:   0,
-		quiet:     quiet,
-		startTime: time.Now(),
-		writer:    os.Stderr,
-		enabled:   !quiet && total > 0,
+		reporter: reporter,
+		total:    total,
+		current:  0,
+		quiet:    quiet,
+		enabled:  !quiet && total > 0,
+	}
+}
+
+// start 在第一次显示时开始进度报告，调用者需持有锁
+func (p *Progress) start() {
+	if !p.started {
+		p.started = true
+		p.reporter.Start(p.total)
 	}
 }
 
@@ -143,8 +448,9 @@ func (p *Progress) Update(msg string) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
+	p.start()
 	p.current++
-	p.display(msg)
+	p.reporter.Update(p.current, msg)
 }
 
 // UpdateMsg 更新进度消息（不增加计数）
@@ -156,7 +462,8 @@ func (p *Progress) UpdateMsg(msg string) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	p.display(msg)
+	p.start()
+	p.reporter.Update(p.current, msg)
 }
 
 // Finish 完成进度显示
@@ -168,50 +475,10 @@ func (p *Progress) Finish(msg string) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
+	p.start()
 	p.current = p.total
-	p.display(msg)
-	fmt.Fprintln(p.writer) // 换行
-}
-
-// display 显示进度信息
-func (p *Progress) display(msg string) {
-	if p.total == 0 {
-		return
-	}
-
-	percentage := float64(p.current) * 100 / float64(p.total)
-	elapsed := time.Since(p.startTime)
-
-	// 计算预估剩余时间
-	var eta string
-	if p.current > 0 {
-		avgTime := elapsed / time.Duration(p.current)
-		remaining := time.Duration(p.total-p.current) * avgTime
-		eta = formatDuration(remaining)
-	} else {
-		eta = "calculating..."
-	}
-
-	// 构建进度条
-	barWidth := 30
-	filled := int(float64(barWidth) * percentage / 100)
-	bar := strings.Repeat("=", filled) + strings.Repeat("-", barWidth-filled)
-
-	// 清除当前行并显示进度
-	output := fmt.Sprintf("\r%s: [%s] %d/%d (%.1f%%) | %s | ETA: %s",
-		p.title, bar, p.current, p.total, percentage, formatDuration(elapsed), eta)
-
-	if msg != "" {
-		output += " | " + msg
-	}
-
-	// 确保输出不会太长
-	maxLen := 120
-	if len(output) > maxLen {
-		output = output[:maxLen-3] + "..."
-	}
-
-	fmt.Fprint(p.writer, output)
+	p.reporter.Update(p.current, msg)
+	p.reporter.Finish()
 }
 
 // formatDuration 格式化时间duration
@@ -243,6 +510,9 @@ func (p *Progress) SetTotal(total int) {
 	defer p.mu.Unlock()
 	p.total = total
 	p.enabled = !p.quiet && total > 0
+	p.reporter.mu.Lock()
+	p.reporter.total = total
+	p.reporter.mu.Unlock()
 }
 
 // GetCurrent 获取当前进度
@@ -0,0 +1,137 @@
+package progress
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/leopardxu/repo-go/internal/logger"
+)
+
+// syncBuffer 是可以被刷新协程并发写入的缓冲区
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestPlainReporter(t *testing.T) {
+	var out syncBuffer
+	cr := newConsoleReporter(&out, false)
+	cr.title = "Syncing"
+	cr.interval = 10 * time.Millisecond
+	cr.SetStallThreshold(0)
+
+	cr.Start(3)
+	cr.StartTask("platform/build")
+	cr.StartTask("platform/art")
+	time.Sleep(50 * time.Millisecond)
+	cr.FinishTask("platform/build", true)
+	cr.FinishTask("platform/art", false)
+	cr.Update(3, "")
+	cr.Finish()
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) < 2 {
+		t.Fatalf("expected periodic progress lines, got %q", out.String())
+	}
+	if !strings.Contains(lines[0], "Syncing: [") || !strings.Contains(lines[0], "0/3") ||
+		!strings.Contains(lines[0], "active: platform/build, platform/art") {
+		t.Errorf("progress line = %q", lines[0])
+	}
+	last := lines[len(lines)-1]
+	if !strings.Contains(last, "3/3 (100.0%)") || !strings.Contains(last, "1 failed") || strings.Contains(last, "active:") {
+		t.Errorf("final line = %q", last)
+	}
+	if strings.Contains(out.String(), "\x1b[") || strings.Contains(out.String(), "\r") {
+		t.Errorf("plain output contains control sequences: %q", out.String())
+	}
+}
+
+func TestTerminalReporter(t *testing.T) {
+	var out syncBuffer
+	cr := newConsoleReporter(&out, true)
+	cr.interval = 10 * time.Millisecond
+	cr.delay = 0
+	cr.SetStallThreshold(20 * time.Millisecond)
+	cr.maxActive = 2
+
+	cr.Start(4)
+	if logger.SetOutputGuard(nil) == nil {
+		t.Errorf("log output guard was not installed")
+	}
+	logger.SetOutputGuard(cr.Suspend)
+
+	for _, name := range []string{"a", "b", "c"} {
+		cr.StartTask(name)
+	}
+	time.Sleep(60 * time.Millisecond)
+	cr.Suspend(func() { out.Write([]byte("log line\n")) })
+	cr.FinishTask("a", true)
+	cr.Finish()
+
+	s := out.String()
+	for _, want := range []string{
+		"warning: a has been running for",
+		"  a ",
+		"(stalled?)",
+		"  ... and 1 more",
+		"\r\x1b[3A\x1b[J",
+		"log line\n",
+	} {
+		if !strings.Contains(s, want) {
+			t.Errorf("output does not contain %q:\n%q", want, s)
+		}
+	}
+	if final := s[strings.LastIndex(s, "\x1b[J"):]; !strings.Contains(final, "1/4 (25.0%)") || !strings.HasSuffix(final, "\n") {
+		t.Errorf("final summary missing:\n%q", s)
+	}
+	if logger.SetOutputGuard(nil) != nil {
+		t.Errorf("log output guard was not restored")
+	}
+}
+
+func TestQuickOperationNotShown(t *testing.T) {
+	var out syncBuffer
+	cr := newConsoleReporter(&out, true)
+	cr.Start(2)
+	cr.StartTask("a")
+	cr.FinishTask("a", true)
+	cr.StartTask("b")
+	cr.FinishTask("b", true)
+	cr.Finish()
+	if out.String() != "" {
+		t.Errorf("quick operation printed progress: %q", out.String())
+	}
+}
+
+func TestProgressCounts(t *testing.T) {
+	p := NewProgress("Starting", 2, true)
+	p.Update("a")
+	if p.GetCurrent() != 0 {
+		t.Errorf("quiet progress should not count, got %d", p.GetCurrent())
+	}
+
+	p = NewProgress("Starting", 2, false)
+	p.reporter.writer = &syncBuffer{}
+	p.reporter.delay = 0
+	p.Update("a")
+	p.Update("b")
+	if p.GetCurrent() != 2 || p.GetTotal() != 2 {
+		t.Errorf("progress = %d/%d", p.GetCurrent(), p.GetTotal())
+	}
+	p.Finish("")
+}
@@ -0,0 +1,22 @@
+//go:build windows
+
+package progress
+
+import (
+	"os"
+	"strconv"
+)
+
+// ansiTerminal 检查文件是否为支持 ANSI 控制序列的终端
+// Windows 控制台默认不处理 ANSI 控制序列，使用纯文本进度
+func ansiTerminal(f *os.File) bool {
+	return false
+}
+
+// terminalWidth 返回终端的列数，无法获取时使用 COLUMNS 或 80
+func terminalWidth() int {
+	if n, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && n > 0 {
+		return n
+	}
+	return 80
+}
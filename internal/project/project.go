@@ -609,3 +609,16 @@ func (p *Project) SetRevision(revision string) {
 	p.Revision = revision
 	p.RevisionId = revision
 }
+
+// RevisionRef 返回清单修订版本在本地对应的引用
+// 提交哈希和标签原样返回，分支转换为远程跟踪分支 refs/remotes/<remote>/<branch>
+func (p *Project) RevisionRef() string {
+	if p.Revision == "" || git.IsImmutable(p.Revision) {
+		return p.Revision
+	}
+	remote := p.RemoteName
+	if remote == "" {
+		remote = "origin"
+	}
+	return fmt.Sprintf("refs/remotes/%s/%s", remote, strings.TrimPrefix(p.Revision, "refs/heads/"))
+}
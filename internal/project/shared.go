@@ -0,0 +1,30 @@
+package project
+
+import (
+	"io/fs"
+	"path/filepath"
+	"strings"
+)
+
+// SharedGitDir 返回 --worktree 客户端中项目 name 的共享裸仓库 .repo/worktrees/<name>.git
+// 清单中使用同一 name 的所有路径都是这个仓库的 git worktree
+func SharedGitDir(repoRoot, name string) string {
+	return filepath.Join(repoRoot, ".repo", "worktrees", filepath.FromSlash(name)+".git")
+}
+
+// SharedGitDirs 返回客户端中所有的共享裸仓库
+func SharedGitDirs(repoRoot string) []string {
+	var dirs []string
+	root := filepath.Join(repoRoot, ".repo", "worktrees")
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() || path == root {
+			return nil
+		}
+		if strings.HasSuffix(d.Name(), ".git") {
+			dirs = append(dirs, path)
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	return dirs
+}
@@ -49,7 +49,14 @@ func (e *Engine) AbandonTopics(projects []*project.Project, topic string) []Aban
 				}
 			}
 
-			err := proj.DeleteBranch(branch)
+			l, err := e.lockProject(proj, "abandon")
+			if err != nil {
+				resultsChan <- AbandonResult{Project: proj, Branch: branch, Success: false, Error: err}
+				return
+			}
+			defer l.Release()
+
+			err = proj.DeleteBranch(branch)
 			if err != nil {
 				if e.logger != nil {
 					e.logger.Error("删除项目 %s 的分%s 失败: %v", proj.Name, branch, err)
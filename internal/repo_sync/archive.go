@@ -0,0 +1,174 @@
+package repo_sync
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/leopardxu/repo-go/internal/archive"
+	"github.com/leopardxu/repo-go/internal/event"
+	"github.com/leopardxu/repo-go/internal/project"
+)
+
+// isArchive 判断客户端是否是归档检出，归档检出的项目只导出文件，不创建 git 仓库
+func (e *Engine) isArchive() bool {
+	return e.options.Config != nil && e.options.Config.Archive
+}
+
+// archiveProject 把项目的修订版本导出到工作树，导出的提交记录在 .repo/project-archives 中
+// 远程修订版本指向的提交与上次导出的相同时跳过导出，--force-sync 时总是重新导出
+func (e *Engine) archiveProject(p *project.Project) (err error) {
+	l, err := e.lockProject(p, "archive")
+	if err != nil {
+		return err
+	}
+	defer l.Release()
+
+	path := e.archivePath(p)
+	if !e.options.LocalOnly {
+		ev := startSyncEvent(p, event.TaskSyncNetwork)
+		commit, err := e.exportProject(p, path)
+		if ev != nil && commit != "" {
+			ev.Set("git_hash", commit)
+		}
+		finishSyncEvent(ev, p, err)
+		if err != nil {
+			return err
+		}
+	} else if _, err := archive.LoadState(e.repoRoot, path); err != nil {
+		return &SyncError{ProjectName: p.Name, Phase: "archive", Err: err, Timestamp: time.Now()}
+	}
+
+	if e.options.NetworkOnly {
+		return nil
+	}
+	if err := e.linkAndCopyFiles(p); err != nil {
+		return &SyncError{
+			ProjectName: p.Name,
+			Phase:       "link_copy_files_after_update",
+			Err:         err,
+			Timestamp:   time.Now(),
+		}
+	}
+	return nil
+}
+
+// archivePath 返回项目导出状态的键，即项目相对于客户端根目录的路径
+func (e *Engine) archivePath(p *project.Project) string {
+	path := p.Path
+	if path == "" {
+		path = p.Name
+	}
+	if filepath.IsAbs(path) {
+		if rel, err := filepath.Rel(e.repoRoot, path); err == nil {
+			path = rel
+		}
+	}
+	return filepath.ToSlash(filepath.Clean(path))
+}
+
+// projectRepoURL 返回项目仓库的地址，清单中远程的 fetch 地址加上项目名称
+// 相对的 fetch 地址在解析时已经拼接了项目名称
+func projectRepoURL(p *project.Project) string {
+	url := strings.TrimSuffix(p.RemoteURL, "/")
+	if strings.HasSuffix(url, "/"+p.Name) || strings.HasSuffix(url, "/"+p.Name+".git") {
+		return url
+	}
+	return url + "/" + p.Name
+}
+
+// exportProject 导出项目并保存导出状态，返回导出的提交
+func (e *Engine) exportProject(p *project.Project, path string) (string, error) {
+	p.RemoteURL = e.resolveRemoteURL(p)
+	remoteURL := projectRepoURL(p)
+
+	state, err := archive.LoadState(e.repoRoot, path)
+	if err != nil && !errors.Is(err, archive.ErrNotExported) {
+		return "", &SyncError{ProjectName: p.Name, Phase: "archive", Err: err, Timestamp: time.Now()}
+	}
+	oldFiles, err := archive.LoadFiles(e.repoRoot, path)
+	if err != nil {
+		return "", &SyncError{ProjectName: p.Name, Phase: "archive", Err: err, Timestamp: time.Now()}
+	}
+
+	if state != nil && !e.options.ForceSync {
+		if _, err := os.Stat(p.Worktree); err == nil {
+			slot, err := e.acquireHost(p, remoteURL)
+			if err != nil {
+				return "", &SyncError{ProjectName: p.Name, Phase: "archive", Err: err, Timestamp: time.Now()}
+			}
+			commit, err := archive.RemoteCommit(e.ctx, remoteURL, p.Revision)
+			slot.Release(isThrottled(err, ""))
+			if err == nil && commit == state.Commit {
+				e.logger.Debug("项目 %s 已导出 %s，跳过", p.Name, commit)
+				return commit, nil
+			}
+			if err != nil {
+				e.logger.Debug("查询项目 %s 的远程修订版本失败，重新导出: %v", p.Name, err)
+			}
+		}
+	}
+
+	if !e.options.Quiet {
+		e.logger.Info("导出项目: %s", p.Name)
+	}
+	tmpDir := filepath.Join(e.repoRoot, ".repo", "archive-tmp")
+	archive.CleanTemp(tmpDir)
+
+	// 添加重试机制
+	const maxRetries = 3
+	var result *archive.Result
+	for retryCount := 0; ; retryCount++ {
+		if retryCount > 0 {
+			retryDelay := time.Duration(retryCount) * 2 * time.Second
+			e.logger.Info("正在重试导出项目 %s (第%d 次尝试，将在%v 后重试)",
+				p.Name, retryCount, retryDelay)
+			if err := sleepContext(e.ctx, retryDelay); err != nil {
+				return "", &SyncError{ProjectName: p.Name, Phase: "archive", Err: err, Timestamp: time.Now()}
+			}
+		}
+
+		// 导出占用远程主机的一个并发名额
+		slot, err := e.acquireHost(p, remoteURL)
+		if err != nil {
+			return "", &SyncError{ProjectName: p.Name, Phase: "archive", Err: err, Timestamp: time.Now()}
+		}
+		result, err = archive.Export(e.ctx, remoteURL, p.Revision, p.Worktree, tmpDir, oldFiles)
+		slot.Release(isThrottled(err, ""))
+		if err == nil {
+			break
+		}
+		if e.ctx.Err() != nil {
+			return "", &SyncError{ProjectName: p.Name, Phase: "archive", Err: e.ctx.Err(), Timestamp: time.Now()}
+		}
+		if retryCount == maxRetries {
+			return "", &SyncError{
+				ProjectName: p.Name,
+				Phase:       "archive",
+				Err:         err,
+				Timestamp:   time.Now(),
+				RetryCount:  retryCount,
+			}
+		}
+	}
+
+	if result.Method == archive.MethodFetch {
+		e.logger.Debug("远程仓库不支持 git archive --remote，项目 %s 通过浅获取导出", p.Name)
+	}
+	err = archive.SaveState(e.repoRoot, &archive.State{
+		Name:     p.Name,
+		Path:     path,
+		Revision: p.Revision,
+		Commit:   result.Commit,
+		Remote:   remoteURL,
+		Method:   result.Method,
+		Time:     time.Now(),
+	}, result.Files)
+	if err != nil {
+		return "", &SyncError{ProjectName: p.Name, Phase: "archive", Err: fmt.Errorf("failed to save archive state: %w", err), Timestamp: time.Now()}
+	}
+	return result.Commit, nil
+}
@@ -8,6 +8,7 @@ import (
 	"strings"
 	"sync"
 
+	"github.com/leopardxu/repo-go/internal/git"
 	"github.com/leopardxu/repo-go/internal/logger"
 	"github.com/leopardxu/repo-go/internal/progress"
 	"github.com/leopardxu/repo-go/internal/project"
@@ -269,10 +270,17 @@ func (e *Engine) checkoutOneBranch(project *project.Project) CheckoutResult {
 		e.logger.Info("检出项%s 的分%s", project.Name, e.branchName)
 	}
 
+	l, err := e.lockProject(project, "checkout")
+	if err != nil {
+		e.logger.Error("项目 %s 获取项目锁失败: %v", project.Name, err)
+		return CheckoutResult{Success: false, Project: project}
+	}
+	defer l.Release()
+
 	// 如果是分离模式，检出项目的修订版本
 	if e.options.Detach {
 		e.logger.Debug("项目 %s 使用分离模式检出修订版%s", project.Name, project.Revision)
-		_, err := project.GitRepo.RunCommand("checkout", project.Revision)
+		_, err := project.GitRepo.RunCommandContext(e.ctx, "checkout", project.Revision)
 		if err != nil {
 			e.logger.Error("项目 %s 检出修订版本失 %v", project.Name, err)
 			return CheckoutResult{Success: false, Project: project}
@@ -282,7 +290,7 @@ func (e *Engine) checkoutOneBranch(project *project.Project) CheckoutResult {
 		e.logger.Debug("项目 %s 创建并检出分%s", project.Name, e.branchName)
 
 		// 先检查远程分支是否存在冲
-		output, _ := project.GitRepo.RunCommand("branch", "-r", "--list", fmt.Sprintf("*/%s", e.branchName))
+		output, _ := project.GitRepo.RunCommandContext(e.ctx, "branch", "-r", "--list", fmt.Sprintf("*/%s", e.branchName))
 		remoteBranches := strings.Split(strings.TrimSpace(string(output)), "\n")
 
 		if len(remoteBranches) > 1 {
@@ -302,7 +310,7 @@ func (e *Engine) checkoutOneBranch(project *project.Project) CheckoutResult {
 
 				if hasProjectRemoteBranch {
 					// 使用项目自身的远
-					_, err := project.GitRepo.RunCommand("checkout", "--track", fmt.Sprintf("%s/%s", project.RemoteName, e.branchName))
+					_, err := project.GitRepo.RunCommandContext(e.ctx, "checkout", "--track", fmt.Sprintf("%s/%s", project.RemoteName, e.branchName))
 					if err != nil {
 						e.logger.Error("项目 %s 检出远程分支失 %v", project.Name, err)
 						return CheckoutResult{Success: false, Project: project}
@@ -325,7 +333,7 @@ func (e *Engine) checkoutOneBranch(project *project.Project) CheckoutResult {
 
 				if hasDefaultRemoteBranch {
 					// 使用配置的默认远
-					_, err := project.GitRepo.RunCommand("checkout", "--track", fmt.Sprintf("%s/%s", e.options.DefaultRemote, e.branchName))
+					_, err := project.GitRepo.RunCommandContext(e.ctx, "checkout", "--track", fmt.Sprintf("%s/%s", e.options.DefaultRemote, e.branchName))
 					if err != nil {
 						e.logger.Error("项目 %s 检出远程分支失 %v", project.Name, err)
 						return CheckoutResult{Success: false, Project: project}
@@ -352,14 +360,14 @@ func (e *Engine) checkoutOneBranch(project *project.Project) CheckoutResult {
 		} else if len(remoteBranches) == 1 && remoteBranches[0] != "" {
 			// 只有一个远程分支匹配，直接检
 			remoteBranch := strings.TrimSpace(remoteBranches[0])
-			_, err := project.GitRepo.RunCommand("checkout", "--track", remoteBranch)
+			_, err := project.GitRepo.RunCommandContext(e.ctx, "checkout", "--track", remoteBranch)
 			if err != nil {
 				e.logger.Error("项目 %s 检出远程分支失 %v", project.Name, err)
 				return CheckoutResult{Success: false, Project: project}
 			}
 		} else {
 			// 没有远程分支匹配，创建新分支
-			_, err := project.GitRepo.RunCommand("checkout", "-B", e.branchName)
+			_, err := project.GitRepo.RunCommandContext(e.ctx, "checkout", "-B", e.branchName)
 			if err != nil {
 				e.logger.Error("项目 %s 创建并检出分支失 %v", project.Name, err)
 				return CheckoutResult{Success: false, Project: project}
@@ -369,7 +377,7 @@ func (e *Engine) checkoutOneBranch(project *project.Project) CheckoutResult {
 
 	// 如果检出成功，复制钩子脚本到项
 	repoHooksDir := filepath.Join(e.repoRoot, ".repo", "hooks")
-	projectGitDir := filepath.Join(project.Worktree, ".git")
+	projectGitDir := hooksGitDir(project)
 
 	if err := copyHooksToProject(repoHooksDir, projectGitDir); err != nil {
 		e.logger.Warn("无法复制钩子脚本到项%s: %v", project.Name, err)
@@ -402,7 +410,7 @@ func (e *Engine) checkoutOne(project *project.Project) CheckoutResult {
 		repoHooksDir := filepath.Join(e.repoRoot, ".repo", "hooks")
 
 		// 获取项目.git 目录路径
-		projectGitDir := filepath.Join(project.Worktree, ".git")
+		projectGitDir := hooksGitDir(project)
 
 		// 复制钩子脚本
 		if err := copyHooksToProject(repoHooksDir, projectGitDir); err != nil && !e.options.Quiet {
@@ -426,6 +434,14 @@ func (e *Engine) checkoutOne(project *project.Project) CheckoutResult {
 	}
 }
 
+// hooksGitDir 返回项目存放钩子的 git 目录，git worktree 创建的工作区使用共享仓库
+func hooksGitDir(p *project.Project) string {
+	if dir := git.CommonDir(p.Worktree); dir != "" {
+		return dir
+	}
+	return filepath.Join(p.Worktree, ".git")
+}
+
 // copyHooksToProject .repo/hooks 中的钩子复制到指定项目的 .git/hooks 目录
 func copyHooksToProject(repoHooksDir, projectGitDir string) error {
 	hooks, err := os.ReadDir(repoHooksDir)
@@ -2,8 +2,10 @@ package repo_sync
 
 import (
 	"fmt"
+	"strings"
 	"sync"
 
+	"github.com/leopardxu/repo-go/internal/git"
 	"github.com/leopardxu/repo-go/internal/logger"
 	"github.com/leopardxu/repo-go/internal/progress"
 	"github.com/leopardxu/repo-go/internal/project"
@@ -16,9 +18,21 @@ type cherryPickStats struct {
 	mu      sync.Mutex
 }
 
-// SetCommitHash 设置要cherry-pick的提交哈希
-func (e *Engine) SetCommitHash(commitHash string) {
-	e.commitHash = commitHash
+// CherryPickState 表示单个项目的cherry-pick结果
+type CherryPickState int
+
+const (
+	CherryPickApplied  CherryPickState = iota // 已应用
+	CherryPickPresent                         // 变更已存在于当前分支
+	CherryPickNotFound                        // 项目中找不到对应的提交
+	CherryPickConflict                        // 出现冲突，等待 --continue/--abort
+	CherryPickFailed                          // 执行失败
+)
+
+// SetCherryPickSpec 设置要cherry-pick的内容
+// 可以是 Change-Id、单个修订版本或 <rev1>..<rev2> 范围，在每个项目中分别解析
+func (e *Engine) SetCherryPickSpec(spec string) {
+	e.commitHash = spec
 }
 
 // GetCherryPickStats 获取cherry-pick操作的统计信息
@@ -26,8 +40,47 @@ func (e *Engine) GetCherryPickStats() (int, int) {
 	return e.cherryPickStats.Success, e.cherryPickStats.Failed
 }
 
+// CherryPickResults 返回按项目顺序排列的cherry-pick结果
+func (e *Engine) CherryPickResults() []CherryPickResult {
+	return e.pickResults
+}
+
 // CherryPickCommit 在指定项目中应用cherry-pick
 func (e *Engine) CherryPickCommit(projects []*project.Project) error {
+	if e.commitHash == "" {
+		return fmt.Errorf("cherry-pick revision is not specified")
+	}
+	changes, err := resolveCherryPickSpec(projects, e.commitHash)
+	if err != nil {
+		return err
+	}
+	return e.runCherryPick(projects, func(p *project.Project) CherryPickResult {
+		return e.cherryPickOne(p, changes)
+	})
+}
+
+// ResumeCherryPick 对处于cherry-pick中的项目执行 --continue 或 --abort
+func (e *Engine) ResumeCherryPick(projects []*project.Project, action string) error {
+	if action != "--continue" && action != "--abort" && action != "--skip" {
+		return fmt.Errorf("unsupported cherry-pick action %q", action)
+	}
+
+	var pending []*project.Project
+	for _, p := range projects {
+		if p.Worktree != "" && p.GitRepo.CherryPickInProgress() {
+			pending = append(pending, p)
+		}
+	}
+
+	return e.runCherryPick(pending, func(p *project.Project) CherryPickResult {
+		// 冲突解决后沿用原有提交信息
+		_, err := p.GitRepo.Runner.RunInDirContext(e.ctx, p.Worktree, "-c", "core.editor=true", "cherry-pick", action)
+		return cherryPickOutcome(p, nil, err)
+	})
+}
+
+// runCherryPick 并发地在每个项目中执行操作，结果按项目顺序保存
+func (e *Engine) runCherryPick(projects []*project.Project, apply func(*project.Project) CherryPickResult) error {
 	if e.logger == nil {
 		e.logger = logger.NewDefaultLogger()
 		if e.options.Verbose {
@@ -37,12 +90,6 @@ func (e *Engine) CherryPickCommit(projects []*project.Project) error {
 		}
 	}
 
-	if e.commitHash == "" {
-		return fmt.Errorf("commit hash is not specified")
-	}
-
-	e.logger.Info("开始在 %d 个项目中应用 cherry-pick '%s'", len(projects), e.commitHash)
-
 	// 初始化统计信息
 	e.cherryPickStats = &cherryPickStats{}
 
@@ -53,6 +100,11 @@ func (e *Engine) CherryPickCommit(projects []*project.Project) error {
 			worktreeProjects = append(worktreeProjects, project)
 		}
 	}
+	e.pickResults = make([]CherryPickResult, len(worktreeProjects))
+	if len(worktreeProjects) == 0 {
+		e.logger.Info("没有可应用的项目")
+		return nil
+	}
 
 	// 创建进度条
 	pm := progress.NewConsoleReporter()
@@ -60,67 +112,35 @@ func (e *Engine) CherryPickCommit(projects []*project.Project) error {
 		pm.Start(len(worktreeProjects))
 	}
 
-	// 执行cherry-pick
-	if len(worktreeProjects) == 0 {
-		e.logger.Info("没有可应用的项目")
-		return nil
+	jobs := e.options.Jobs
+	if jobs <= 0 {
+		jobs = 1
 	}
+	e.logger.Debug("应用cherry-pick，并发数: %d", jobs)
 
-	if e.options.Jobs == 1 {
-		// 单线程执行
-		e.logger.Debug("使用单线程模式应用cherry-pick")
-		for _, project := range worktreeProjects {
-			result := e.cherryPickOne(project)
-			e.processCherryPickResult(result, pm)
-		}
-	} else {
-		// 多线程执行
-		e.logger.Debug("使用多线程模式应用cherry-pick，并发数: %d", e.options.Jobs)
-
-		// 创建工作组
-		var wg sync.WaitGroup
-		resultsChan := make(chan CherryPickResult, len(worktreeProjects))
-
-		// 限制并发数
-		semaphore := make(chan struct{}, e.options.Jobs)
-
-		for _, p := range worktreeProjects {
-			wg.Add(1)
-			go func(proj *project.Project) {
-				defer wg.Done()
-
-				// 获取信号量
-				semaphore <- struct{}{}
-				defer func() { <-semaphore }()
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, jobs)
+	for i, p := range worktreeProjects {
+		wg.Add(1)
+		go func(i int, proj *project.Project) {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
 
-				// 执行cherry-pick
-				result := e.cherryPickOne(proj)
-				resultsChan <- result
-			}(p)
-		}
-
-		// 等待所有操作完成
-		go func() {
-			wg.Wait()
-			close(resultsChan)
-		}()
-
-		// 处理结果
-		for result := range resultsChan {
+			result := apply(proj)
+			e.pickResults[i] = result
 			e.processCherryPickResult(result, pm)
-		}
+		}(i, p)
 	}
+	wg.Wait()
 
 	if !e.options.Quiet {
 		pm.Finish()
 	}
 
-	e.logger.Info("Cherry-pick '%s' 完成: %d 成功, %d 失败", e.commitHash, e.cherryPickStats.Success, e.cherryPickStats.Failed)
-
 	if e.cherryPickStats.Failed > 0 {
-		return fmt.Errorf("Cherry-pick 失败: %d 个项目出错", e.cherryPickStats.Failed)
+		return fmt.Errorf("cherry-pick failed in %d projects", e.cherryPickStats.Failed)
 	}
-
 	return nil
 }
 
@@ -131,15 +151,13 @@ func (e *Engine) processCherryPickResult(result CherryPickResult, pm progress.Re
 
 	if result.Success {
 		e.cherryPickStats.Success++
-		if e.options.Verbose && !e.options.Quiet {
-			e.logger.Debug("项目 %s cherry-pick 成功", result.Project.Name)
-		}
+		e.logger.Debug("项目 %s cherry-pick 成功", result.Project.Name)
 	} else {
 		e.cherryPickStats.Failed++
+		e.errResultsMu.Lock()
 		e.errResults = append(e.errResults, result.Project.Path)
-		if !e.options.Quiet {
-			e.logger.Error("项目 %s cherry-pick 失败: %v", result.Project.Name, result.Error)
-		}
+		e.errResultsMu.Unlock()
+		e.logger.Debug("项目 %s cherry-pick 失败: %v", result.Project.Name, result.Error)
 	}
 
 	if !e.options.Quiet {
@@ -149,23 +167,144 @@ func (e *Engine) processCherryPickResult(result CherryPickResult, pm progress.Re
 
 // CherryPickResult 表示cherry-pick操作的结果
 type CherryPickResult struct {
-	Success bool
-	Project *project.Project
-	Error   error
+	Success   bool
+	Project   *project.Project
+	State     CherryPickState
+	Commits   []string // 应用的提交，按应用顺序排列
+	Conflicts []string // 冲突文件
+	Error     error
 }
 
-// cherryPickOne 在单个项目中应用cherry-pick
-func (e *Engine) cherryPickOne(project *project.Project) CherryPickResult {
-	if !e.options.Quiet {
-		e.logger.Info("在项目 %s 中应用 cherry-pick %s", project.Name, e.commitHash)
+// cherryPickOne 在单个项目中解析并依次应用提交
+func (e *Engine) cherryPickOne(project *project.Project, changes []cherryPickChange) CherryPickResult {
+	repo := project.GitRepo
+	if repo.RebaseInProgress() || repo.CherryPickInProgress() {
+		return CherryPickResult{Project: project, State: CherryPickFailed,
+			Error: fmt.Errorf("a rebase or cherry-pick is already in progress")}
 	}
 
-	// 执行git cherry-pick命令
-	_, err := project.GitRepo.RunCommand("cherry-pick", e.commitHash)
+	commits, present, err := resolveCherryPicks(repo, changes)
 	if err != nil {
-		e.logger.Error("项目 %s cherry-pick 失败: %v", project.Name, err)
-		return CherryPickResult{Success: false, Project: project, Error: err}
+		return CherryPickResult{Project: project, State: CherryPickFailed, Error: err}
 	}
+	if present {
+		return CherryPickResult{Success: true, Project: project, State: CherryPickPresent}
+	}
+	if len(commits) == 0 {
+		return CherryPickResult{Success: true, Project: project, State: CherryPickNotFound}
+	}
+
+	e.logger.Debug("在项目 %s 中应用 %d 个提交", project.Name, len(commits))
+
+	// -x 在提交信息末尾追加 (cherry picked from commit <sha>)
+	args := append([]string{"cherry-pick", "-x"}, commits...)
+	_, err = repo.Runner.RunInDirContext(e.ctx, project.Worktree, args...)
+	return cherryPickOutcome(project, commits, err)
+}
 
-	return CherryPickResult{Success: true, Project: project}
+// cherryPickOutcome 根据命令结果和仓库状态区分成功、冲突和失败
+func cherryPickOutcome(p *project.Project, commits []string, err error) CherryPickResult {
+	p.GitRepo.ClearCache()
+	result := CherryPickResult{Project: p, Commits: commits}
+	switch {
+	case err == nil:
+		result.Success = true
+		result.State = CherryPickApplied
+	case p.GitRepo.CherryPickInProgress():
+		result.State = CherryPickConflict
+		result.Conflicts, _ = p.GitRepo.ConflictedFiles()
+		result.Error = fmt.Errorf("cherry-pick stopped with conflicts")
+	default:
+		result.State = CherryPickFailed
+		result.Error = err
+	}
+	return result
+}
+
+// cherryPickChange 描述要应用的一个变更：带 Change-Id 的变更在每个项目中按 Change-Id 查找，
+// 没有 Change-Id 时按 commit 解析
+type cherryPickChange struct {
+	commit   string
+	changeID string
+}
+
+// resolveCherryPickSpec 把 Change-Id、修订版本或 <rev1>..<rev2> 范围转换为要应用的变更，按应用顺序排列
+// 范围只解析一次：在第一个包含两个端点的项目中列出提交并读取各自的 Change-Id
+func resolveCherryPickSpec(projects []*project.Project, spec string) ([]cherryPickChange, error) {
+	if git.IsChangeID(spec) {
+		return []cherryPickChange{{changeID: spec}}, nil
+	}
+	base, head, ok := strings.Cut(spec, "..")
+	if !ok || strings.HasPrefix(head, ".") {
+		// 单个修订版本在每个项目中分别解析
+		return []cherryPickChange{{commit: spec}}, nil
+	}
+	if base == "" || head == "" {
+		return nil, fmt.Errorf("invalid revision range %q", spec)
+	}
+
+	for _, p := range projects {
+		if p.Worktree == "" {
+			continue
+		}
+		repo := p.GitRepo
+		// 范围的端点在该项目中不存在时视为项目不涉及此变更
+		if _, err := repo.RevParse(base); err != nil {
+			continue
+		}
+		if _, err := repo.RevParse(head); err != nil {
+			continue
+		}
+		commits, err := repo.CommitsBetween(base, head)
+		if err != nil {
+			return nil, fmt.Errorf("project %s: %w", p.Name, err)
+		}
+		changes := make([]cherryPickChange, 0, len(commits))
+		for _, commit := range commits {
+			message, err := repo.CommitMessage(commit)
+			if err != nil {
+				return nil, fmt.Errorf("project %s: %w", p.Name, err)
+			}
+			changes = append(changes, cherryPickChange{commit: commit, changeID: git.ParseChangeID(message)})
+		}
+		return changes, nil
+	}
+	return nil, nil
+}
+
+// resolveCherryPicks 在项目中解析要应用的提交，按应用顺序返回
+// 已在当前分支上的 Change-Id 跳过，第二个返回值表示所有变更都已经在当前分支上
+func resolveCherryPicks(repo *git.Repository, changes []cherryPickChange) ([]string, bool, error) {
+	var commits []string
+	present := 0
+	for _, change := range changes {
+		if change.changeID != "" {
+			found, err := repo.FindChangeID(change.changeID, "HEAD")
+			if err != nil {
+				return nil, false, err
+			}
+			if len(found) > 0 {
+				present++
+				continue
+			}
+		}
+		// 范围中的提交本身存在于项目中时直接使用
+		if change.commit != "" {
+			if commit, err := repo.RevParse(change.commit); err == nil {
+				commits = append(commits, commit)
+				continue
+			}
+		}
+		if change.changeID != "" {
+			candidates, err := repo.FindChangeID(change.changeID)
+			if err != nil {
+				return nil, false, err
+			}
+			// 同一变更有多个补丁集时使用最新的提交
+			if len(candidates) > 0 {
+				commits = append(commits, candidates[0])
+			}
+		}
+	}
+	return commits, len(changes) > 0 && present == len(changes), nil
 }
@@ -0,0 +1,184 @@
+package repo_sync
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/leopardxu/repo-go/internal/logger"
+	"github.com/leopardxu/repo-go/internal/project"
+	"github.com/leopardxu/repo-go/internal/testutil"
+)
+
+// changeID 返回测试中第 n 个变更的 Change-Id
+func changeID(n int) string {
+	return fmt.Sprintf("I%040d", n)
+}
+
+// commitChange 在 dir 的当前分支上提交带 Change-Id 的变更
+func commitChange(t *testing.T, dir, name string, n int) string {
+	t.Helper()
+	return testutil.Commit(t, dir, name, name+"\n", fmt.Sprintf("%s\n\nChange-Id: %s", name, changeID(n)))
+}
+
+func newCherryPickEngine() *Engine {
+	log := logger.NewDefaultLogger()
+	log.SetLevel(logger.LogLevelError)
+	return &Engine{ctx: context.Background(), options: &Options{Quiet: true, Jobs: 2}, logger: log}
+}
+
+// cherryPickStates 返回每个项目的结果状态
+func cherryPickStates(results []CherryPickResult) map[string]CherryPickState {
+	states := make(map[string]CherryPickState)
+	for _, res := range results {
+		states[res.Project.Name] = res.State
+	}
+	return states
+}
+
+// subjects 返回 base..HEAD 的提交标题，按从旧到新排列
+func subjects(t *testing.T, dir, base string) []string {
+	return strings.Fields(testutil.Git(t, dir, "log", "--reverse", "--format=%s", base+"..HEAD"))
+}
+
+func TestCherryPickChangeID(t *testing.T) {
+	testutil.SetIdentity(t)
+	// a: 变更在另一个分支上，当前分支没有
+	a := testutil.NewRepo(t)
+	testutil.Git(t, a, "checkout", "--quiet", "-b", "change")
+	commitChange(t, a, "one", 1)
+	testutil.Git(t, a, "checkout", "--quiet", "main")
+	// b: 当前分支已经有这个变更
+	b := testutil.NewRepo(t)
+	commitChange(t, b, "one", 1)
+	// c: 没有这个变更
+	c := testutil.NewRepo(t)
+
+	projects := []*project.Project{testutil.NewProject(a, "a"), testutil.NewProject(b, "b"), testutil.NewProject(c, "c")}
+	e := newCherryPickEngine()
+	e.SetCherryPickSpec(changeID(1))
+	if err := e.CherryPickCommit(projects); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]CherryPickState{"a": CherryPickApplied, "b": CherryPickPresent, "c": CherryPickNotFound}
+	if got := cherryPickStates(e.CherryPickResults()); !reflect.DeepEqual(got, want) {
+		t.Errorf("states = %v, want %v", got, want)
+	}
+	if got := subjects(t, a, "origin/main"); !reflect.DeepEqual(got, []string{"one"}) {
+		t.Errorf("a picked %v", got)
+	}
+	if got := subjects(t, b, "origin/main"); !reflect.DeepEqual(got, []string{"one"}) {
+		t.Errorf("b picked the change again: %v", got)
+	}
+}
+
+func TestCherryPickRange(t *testing.T) {
+	testutil.SetIdentity(t)
+	// src: 范围 main..feature 所在的项目
+	src := testutil.NewRepo(t)
+	testutil.Git(t, src, "checkout", "--quiet", "-b", "feature")
+	commitChange(t, src, "one", 1)
+	commitChange(t, src, "two", 2)
+	testutil.Commit(t, src, "three", "three\n", "three")
+	testutil.Git(t, src, "checkout", "--quiet", "main")
+	// other: 没有 feature 分支，one 已在当前分支上，two 是 refs/changes/ 下的另一个提交
+	other := testutil.NewRepo(t)
+	commitChange(t, other, "one", 1)
+	testutil.Git(t, other, "checkout", "--quiet", "--detach", "origin/main")
+	commitChange(t, other, "two", 2)
+	testutil.Git(t, other, "update-ref", "refs/changes/02/2/1", "HEAD")
+	testutil.Git(t, other, "checkout", "--quiet", "main")
+	// none: 范围中的变更都不存在
+	none := testutil.NewRepo(t)
+
+	projects := []*project.Project{testutil.NewProject(src, "src"), testutil.NewProject(other, "other"), testutil.NewProject(none, "none")}
+	changes, err := resolveCherryPickSpec(projects, "main..feature")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, change := range changes {
+		ids = append(ids, change.changeID)
+	}
+	if want := []string{changeID(1), changeID(2), ""}; !reflect.DeepEqual(ids, want) {
+		t.Fatalf("range Change-Ids = %q, want %q", ids, want)
+	}
+
+	e := newCherryPickEngine()
+	e.SetCherryPickSpec("main..feature")
+	if err := e.CherryPickCommit(projects); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]CherryPickState{"src": CherryPickApplied, "other": CherryPickApplied, "none": CherryPickNotFound}
+	if got := cherryPickStates(e.CherryPickResults()); !reflect.DeepEqual(got, want) {
+		t.Errorf("states = %v, want %v", got, want)
+	}
+	if got := subjects(t, src, "origin/main"); !reflect.DeepEqual(got, []string{"one", "two", "three"}) {
+		t.Errorf("src picked %v, want the whole range oldest first", got)
+	}
+	// other 按 Change-Id 找到 two，one 已存在，没有 Change-Id 的 three 只在 src 中
+	if got := subjects(t, other, "origin/main"); !reflect.DeepEqual(got, []string{"one", "two"}) {
+		t.Errorf("other has %v, want one and the picked two", got)
+	}
+
+	// 再次应用时范围中的变更都已在当前分支上
+	e = newCherryPickEngine()
+	e.SetCherryPickSpec("main..feature~1")
+	if err := e.CherryPickCommit(projects); err != nil {
+		t.Fatal(err)
+	}
+	want = map[string]CherryPickState{"src": CherryPickPresent, "other": CherryPickPresent, "none": CherryPickNotFound}
+	if got := cherryPickStates(e.CherryPickResults()); !reflect.DeepEqual(got, want) {
+		t.Errorf("states = %v, want %v", got, want)
+	}
+}
+
+func TestCherryPickConflictContinue(t *testing.T) {
+	testutil.SetIdentity(t)
+	dir := testutil.NewRepo(t)
+	testutil.Git(t, dir, "checkout", "--quiet", "-b", "change")
+	testutil.Commit(t, dir, "f", "change\n", fmt.Sprintf("change f\n\nChange-Id: %s", changeID(1)))
+	testutil.Git(t, dir, "checkout", "--quiet", "main")
+	testutil.Commit(t, dir, "f", "local\n", "local f")
+
+	projects := []*project.Project{testutil.NewProject(dir, "p")}
+	e := newCherryPickEngine()
+	e.SetCherryPickSpec(changeID(1))
+	if err := e.CherryPickCommit(projects); err == nil {
+		t.Fatal("CherryPickCommit() succeeded, want the conflict reported as a failure")
+	}
+	res := e.CherryPickResults()
+	if len(res) != 1 || res[0].State != CherryPickConflict || !reflect.DeepEqual(res[0].Conflicts, []string{"f"}) {
+		t.Fatalf("results = %+v, want a conflict in f", res)
+	}
+
+	// 解决冲突后 --continue 完成提交，沿用原有提交信息
+	if err := os.WriteFile(filepath.Join(dir, "f"), []byte("resolved\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	testutil.Git(t, dir, "add", "f")
+	e = newCherryPickEngine()
+	if err := e.ResumeCherryPick(projects, "--continue"); err != nil {
+		t.Fatal(err)
+	}
+	if res := e.CherryPickResults(); len(res) != 1 || res[0].State != CherryPickApplied {
+		t.Fatalf("--continue results = %+v", res)
+	}
+	message := testutil.Git(t, dir, "log", "-1", "--format=%B")
+	if !strings.HasPrefix(message, "change f") || !strings.Contains(message, "(cherry picked from commit") {
+		t.Errorf("commit message = %q", message)
+	}
+	if projects[0].GitRepo.CherryPickInProgress() {
+		t.Error("cherry-pick still in progress after --continue")
+	}
+
+	// 没有进行中的 cherry-pick 时不处理任何项目
+	e = newCherryPickEngine()
+	if err := e.ResumeCherryPick(projects, "--continue"); err != nil || len(e.CherryPickResults()) != 0 {
+		t.Errorf("ResumeCherryPick() = %v, %+v with nothing in progress", err, e.CherryPickResults())
+	}
+}
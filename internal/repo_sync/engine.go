@@ -17,6 +17,7 @@ import (
 	"time"
 
 	"github.com/leopardxu/repo-go/internal/config"
+	"github.com/leopardxu/repo-go/internal/event"
 	"github.com/leopardxu/repo-go/internal/git"
 	"github.com/leopardxu/repo-go/internal/logger"
 	"github.com/leopardxu/repo-go/internal/manifest"
@@ -81,6 +82,7 @@ type Engine struct {
 	logger          logger.Logger
 	progressReport  progress.Reporter
 	workerPool      *workerpool.WorkerPool
+	hostLimiter     *workerpool.HostLimiter // 按主机限制获取并发数，遇到限流时自动降低
 	repoRoot        string
 	errors          []error
 	errorsMu        sync.Mutex
@@ -95,8 +97,12 @@ type Engine struct {
 	ctx             context.Context      // 添加 ctx 字段
 	branchName      string               // 要检出的分支名称
 	checkoutStats   *checkoutStats       // 检出操作的统计信息
-	commitHash      string               // 要cherry-pick的提交哈希
+	commitHash      string               // 要cherry-pick的 Change-Id、修订版本或范围
 	cherryPickStats *cherryPickStats     // cherry-pick操作的统计信息
+	pickResults     []CherryPickResult   // cherry-pick操作的结果，按项目顺序排列
+	localResults    []localSyncResult    // 本地更新的结果
+	localMu         sync.Mutex           // 保护 localResults 的互斥锁
+	sharedRepos     sync.Map             // 工作树模式下本次同步用到的共享仓库，仓库路径 -> *sharedRepo
 }
 
 // NewEngine 创建同步引擎
@@ -144,8 +150,10 @@ func NewEngineWithContext(ctx context.Context, options *Options, manifest *manif
 		logger:         log,
 		progressReport: progressReport,
 		workerPool:     workerpool.New(options.Jobs),
+		hostLimiter:    newHostLimiter(options, log),
 		repoRoot:       repoRoot,                   // 设置仓库根目录
 		errEvent:       make(chan error),           // 初始化errEvent 字段
+		sshProxy:       ssh.Active(),               // 进程级的 SSH 连接复用代理，未启用时为nil
 		fetchTimes:     make(map[string]time.Time), // 初始化fetchTimes 映射
 		ctx:            ctx,                        // 使用传入的 context
 	}
@@ -153,8 +161,8 @@ func NewEngineWithContext(ctx context.Context, options *Options, manifest *manif
 
 // Sync 执行同步
 func (e *Engine) Sync() error {
-	// 创建带取消功能的上下文
-	ctx, cancel := context.WithCancel(context.Background())
+	// 创建带取消功能的上下文，随引擎的 context 一起取消
+	ctx, cancel := context.WithCancel(e.ctx)
 	defer cancel() // 确保函数退出时取消上下文
 
 	totalProjects := len(e.projects)
@@ -168,12 +176,14 @@ func (e *Engine) Sync() error {
 
 	if !e.options.Quiet {
 		e.logger.Info("同步 %d 个项目，并发数 %d", totalProjects, e.options.Jobs)
+		if e.options.Verbose {
+			e.logger.Info("每个主机的初始并发上限 %d，遇到限流时减半", e.hostLimiter.DefaultLimit())
+		}
 		if e.progressReport != nil {
 			e.progressReport.Start(totalProjects)
 		}
 	}
 
-	var count int32
 	var successCount int32
 	var failCount int32
 
@@ -189,9 +199,12 @@ func (e *Engine) Sync() error {
 				// 继续执行
 			}
 
+			if !e.options.Quiet && e.progressReport != nil {
+				e.progressReport.StartTask(project.Name)
+			}
+
 			err := e.syncProject(project)
 
-			current := atomic.AddInt32(&count, 1)
 			if err != nil {
 				atomic.AddInt32(&failCount, 1)
 			} else {
@@ -199,26 +212,7 @@ func (e *Engine) Sync() error {
 			}
 
 			if !e.options.Quiet && e.progressReport != nil {
-				status := "完成"
-				if err != nil {
-					status = "失败"
-				}
-
-				// 计算预估完成时间
-				var etaStr string
-				if current > 0 && current < int32(totalProjects) {
-					elapsed := time.Since(startTime)
-					estimatedTotal := elapsed * time.Duration(totalProjects) / time.Duration(current)
-					estimatedRemaining := estimatedTotal - elapsed
-					if estimatedRemaining > 0 {
-						etaStr = fmt.Sprintf("，预计剩余时 %s", formatDuration(estimatedRemaining))
-					}
-				}
-
-				progressMsg := fmt.Sprintf("%s: %s (进度: %d/%d, 成功: %d, 失败: %d%s)",
-					project.Name, status, current, totalProjects,
-					successCount, failCount, etaStr)
-				e.progressReport.Update(int(current), progressMsg)
+				e.progressReport.FinishTask(project.Name, err == nil)
 			}
 
 			if err != nil {
@@ -239,6 +233,16 @@ func (e *Engine) Sync() error {
 	if !e.options.Quiet && e.progressReport != nil {
 		e.progressReport.Finish()
 	}
+	e.logHostLimits()
+	if e.isWorktree() && e.ctx.Err() == nil {
+		e.removeObsoleteWorktrees()
+	}
+	e.reportLocalSync()
+
+	if err := e.ctx.Err(); err != nil {
+		e.logger.Warn("同步已中断，已完成 %d/%d 个项目", successCount+failCount, totalProjects)
+		return err
+	}
 
 	// 计算总耗时
 	totalDuration := time.Since(startTime)
@@ -274,7 +278,7 @@ func (e *Engine) UpdateManifestRepo() error {
 	gitRunner := git.NewRunner()
 
 	// 获取更新前的 HEAD 提交哈希以检查是否有更新
-	oldHeadOutput, err := gitRunner.RunInDir(manifestProjectPath, "rev-parse", "HEAD")
+	oldHeadOutput, err := gitRunner.RunInDirContext(e.ctx, manifestProjectPath, "rev-parse", "HEAD")
 	oldHead := strings.TrimSpace(string(oldHeadOutput))
 	if err != nil {
 		e.logger.Warn("获取当前 HEAD 失败: %v", err)
@@ -282,18 +286,21 @@ func (e *Engine) UpdateManifestRepo() error {
 
 	// 执行 fetch 操作
 	e.logger.Debug("正在获取 manifest 仓库更新...")
-	_, err = gitRunner.RunInDir(manifestProjectPath, "fetch", "origin")
+	_, err = gitRunner.RunInDirContext(e.ctx, manifestProjectPath, "fetch", "origin")
+	if err != nil && e.ctx.Err() != nil {
+		return e.ctx.Err()
+	}
 	if err != nil {
 		e.logger.Warn("获取 manifest 仓库更新失败: %v", err)
 		// 尝试获取所有远程分支
-		_, fetchErr := gitRunner.RunInDir(manifestProjectPath, "fetch", "--all")
+		_, fetchErr := gitRunner.RunInDirContext(e.ctx, manifestProjectPath, "fetch", "--all")
 		if fetchErr != nil {
 			e.logger.Warn("获取所有远程分支也失败: %v", fetchErr)
 		}
 	}
 
 	// 获取当前分支
-	output, err := gitRunner.RunInDir(manifestProjectPath, "rev-parse", "--abbrev-ref", "HEAD")
+	output, err := gitRunner.RunInDirContext(e.ctx, manifestProjectPath, "rev-parse", "--abbrev-ref", "HEAD")
 	if err != nil {
 		return fmt.Errorf("获取当前分支失败: %w", err)
 	}
@@ -301,11 +308,14 @@ func (e *Engine) UpdateManifestRepo() error {
 
 	// 执行合并操作
 	e.logger.Debug("正在合并 manifest 仓库更新到分支 %s...", currentBranch)
-	_, err = gitRunner.RunInDir(manifestProjectPath, "merge", "origin/"+currentBranch)
+	_, err = gitRunner.RunInDirContext(e.ctx, manifestProjectPath, "merge", "origin/"+currentBranch)
+	if err != nil && e.ctx.Err() != nil {
+		return e.ctx.Err()
+	}
 	if err != nil {
 		e.logger.Warn("合并 manifest 仓库更新失败: %v", err)
 		// 尝试使用 pull 命令
-		_, pullErr := gitRunner.RunInDir(manifestProjectPath, "pull", "origin", currentBranch)
+		_, pullErr := gitRunner.RunInDirContext(e.ctx, manifestProjectPath, "pull", "origin", currentBranch)
 		if pullErr != nil {
 			e.logger.Warn("拉取 manifest 仓库更新也失败: %v", pullErr)
 			return fmt.Errorf("无法更新 manifest 仓库，fetch/merge/pull 均失败")
@@ -313,7 +323,7 @@ func (e *Engine) UpdateManifestRepo() error {
 	}
 
 	// 检查是否有更新
-	newHeadOutput, err := gitRunner.RunInDir(manifestProjectPath, "rev-parse", "HEAD")
+	newHeadOutput, err := gitRunner.RunInDirContext(e.ctx, manifestProjectPath, "rev-parse", "HEAD")
 	newHead := strings.TrimSpace(string(newHeadOutput))
 	if err != nil {
 		e.logger.Warn("获取更新后的 HEAD 失败: %v", err)
@@ -465,6 +475,15 @@ func formatDuration(d time.Duration) string {
 
 // syncProject 同步单个项目
 func (e *Engine) syncProject(p *project.Project) error {
+	// 归档检出只导出文件，不克隆或获取
+	if e.isArchive() {
+		return e.archiveProject(p)
+	}
+	// 工作树模式下同名项目共享一个裸仓库，每个路径是它的 git worktree
+	if e.isWorktree() {
+		return e.worktreeProject(p)
+	}
+
 	// 检查项目目录是否存在
 	exists, err := e.projectExists(p)
 	if err != nil {
@@ -742,7 +761,16 @@ func (e *Engine) resolveRemoteURL(p *project.Project) string {
 }
 
 // fetchProject 执行单个项目的网络同
-func (e *Engine) fetchProject(p *project.Project) error {
+func (e *Engine) fetchProject(p *project.Project) (err error) {
+	l, err := e.lockProject(p, "fetch")
+	if err != nil {
+		return err
+	}
+	defer l.Release()
+
+	ev := startSyncEvent(p, event.TaskSyncNetwork)
+	defer func() { finishSyncEvent(ev, p, err) }()
+
 	// 输出详细日志，显示实际使用的远程 URL
 	if e.options.Verbose {
 		e.logger.Debug("正在获取项目 %s，原始远URL: %s", p.Name, p.RemoteURL)
@@ -800,21 +828,32 @@ func (e *Engine) fetchProject(p *project.Project) error {
 			retryDelay := time.Duration(retryCount) * 2 * time.Second
 			e.logger.Info("正在重试获取项目 %s (第%d 次尝试，将在%v 后重试)",
 				p.Name, retryCount, retryDelay)
-			time.Sleep(retryDelay)
+			if err := sleepContext(e.ctx, retryDelay); err != nil {
+				return &SyncError{ProjectName: p.Name, Phase: "fetch", Err: err, Timestamp: time.Now()}
+			}
 
 			// 清空上一次的错误输出
 			stderr.Reset()
 		}
 
-		// 执行 git fetch
-		cmd := exec.Command("git", args...)
+		// 执行 git fetch，占用远程主机的一个并发名额
+		slot, err := e.acquireHost(p, remoteURL)
+		if err != nil {
+			return &SyncError{ProjectName: p.Name, Phase: "fetch", Err: err, Timestamp: time.Now()}
+		}
+		started := time.Now()
+		cmd := git.Command(e.ctx, "", args...)
 		cmd.Stderr = &stderr
-		lastErr = cmd.Run()
+		lastErr = git.Run(cmd)
+		slot.Release(isThrottled(lastErr, stderr.String()))
 
 		if lastErr == nil {
 			// 成功获取，跳出重试循
 			break
 		}
+		if err := e.interrupted(args, started); err != nil {
+			return &SyncError{ProjectName: p.Name, Phase: "fetch", Err: err, Timestamp: time.Now()}
+		}
 
 		// 如果已经达到最大重试次数，则返回错
 		if retryCount == maxRetries {
@@ -847,7 +886,16 @@ func (e *Engine) fetchProject(p *project.Project) error {
 }
 
 // cloneProject 克隆单个项目
-func (e *Engine) cloneProject(p *project.Project) error {
+func (e *Engine) cloneProject(p *project.Project) (err error) {
+	l, err := e.lockProject(p, "clone")
+	if err != nil {
+		return err
+	}
+	defer l.Release()
+
+	ev := startSyncEvent(p, event.TaskSyncNetwork)
+	defer func() { finishSyncEvent(ev, p, err) }()
+
 	// 解析远程URL
 	remoteURL := e.resolveRemoteURL(p)
 	// 更新项目RemoteURL 为解析后URL
@@ -964,7 +1012,9 @@ func (e *Engine) cloneProject(p *project.Project) error {
 			retryDelay := time.Duration(retryCount) * 3 * time.Second
 			e.logger.Info("正在重试克隆项目 %s (第%d 次尝试，将在%v 后重试)",
 				p.Name, retryCount, retryDelay)
-			time.Sleep(retryDelay)
+			if err := sleepContext(e.ctx, retryDelay); err != nil {
+				return &SyncError{ProjectName: p.Name, Phase: "clone", Err: err, Timestamp: time.Now()}
+			}
 
 			// 清空上一次的错误输出
 			stderr.Reset()
@@ -1061,15 +1111,24 @@ func (e *Engine) cloneProject(p *project.Project) error {
 			}
 		}
 
-		// 执行 clone 命令
-		cmd := exec.Command("git", args...)
+		// 执行 clone 命令，占用远程主机的一个并发名额
+		slot, err := e.acquireHost(p, remoteURL)
+		if err != nil {
+			return &SyncError{ProjectName: p.Name, Phase: "clone", Err: err, Timestamp: time.Now()}
+		}
+		cmd := git.Command(e.ctx, "", args...)
 		cmd.Stderr = &stderr
-		lastErr = cmd.Run()
+		lastErr = git.Run(cmd)
+		slot.Release(isThrottled(lastErr, stderr.String()))
 
 		if lastErr == nil {
 			// 成功克隆，跳出重试循环
 			break
 		}
+		if e.ctx.Err() != nil {
+			// 不完整的克隆目录在下次同步时清理
+			return &SyncError{ProjectName: p.Name, Phase: "clone", Err: e.ctx.Err(), Timestamp: time.Now()}
+		}
 
 		// 如果已经达到最大重试次数，则返回错误
 		if retryCount == maxRetries {
@@ -1100,7 +1159,7 @@ func (e *Engine) cloneProject(p *project.Project) error {
 		}
 
 		// 执行检出操作
-		if err := e.checkoutProject(p); err != nil {
+		if err := e.checkoutRevision(p); err != nil {
 			return &SyncError{
 				ProjectName: p.Name,
 				Phase:       "post_clone_checkout",
@@ -1127,7 +1186,7 @@ func (e *Engine) cloneProject(p *project.Project) error {
 	// 处理 linkfile 和 copyfile（仅在非NetworkOnly模式且非镜像模式下）
 	if !e.options.NetworkOnly && !isMirror {
 		e.logger.Info("开始处理项目 %s 的链接文件和复制文件", p.Name)
-		if err := e.processLinkAndCopyFiles(p); err != nil {
+		if err := e.linkAndCopyFiles(p); err != nil {
 			e.logger.Error("项目 %s 处理 linkfile 和 copyfile 失败: %v", p.Name, err)
 			return &SyncError{
 				ProjectName: p.Name,
@@ -1155,73 +1214,25 @@ func (e *Engine) cloneProject(p *project.Project) error {
 	return nil
 }
 
-// checkoutProject 检出项
-func (e *Engine) checkoutProject(p *project.Project) error {
-	// 执行 checkout 命令
-	args := []string{"-C", p.Worktree, "checkout"}
-	if e.options.Detach {
-		args = append(args, "--detach")
-	}
-	if strings.HasPrefix(p.Revision, "refs/heads/") {
-		p.Revision = strings.TrimPrefix(p.Revision, "refs/heads/")
+// interrupted 在引擎的 context 被取消时清理被中止的 git 命令 args 遗留的锁文件，并返回取消原因
+func (e *Engine) interrupted(args []string, started time.Time) error {
+	if e.ctx.Err() == nil {
+		return nil
 	}
-	if strings.HasPrefix(p.Revision, "refs/tags/") {
-		p.Revision = strings.TrimPrefix(p.Revision, "refs/tags/")
+	for _, lock := range git.RemoveStaleLocks("", args, started) {
+		e.logger.Warn("已删除中止的命令遗留的锁文件: %s", lock)
 	}
-	args = append(args, p.Revision)
-
-	// 添加重试机制
-	const maxRetries = 2 // 检出操作通常不需要太多重
-	var lastErr error
-	var stderr bytes.Buffer
-
-	for retryCount := 0; retryCount <= maxRetries; retryCount++ {
-		// 如果不是第一次尝试，则等待一段时间后重试
-		if retryCount > 0 {
-			retryDelay := time.Duration(retryCount) * time.Second
-			e.logger.Info("正在重试检出项目 %s 的 %s 分支 (第%d 次尝试，将在%v 后重试)",
-				p.Name, p.Revision, retryCount, retryDelay)
-			time.Sleep(retryDelay)
-
-			// 清空上一次的错误输出
-			stderr.Reset()
-
-			// 如果检出失败，可能是因为有未提交的更改，尝试强制检
-			if retryCount == maxRetries {
-				e.logger.Info("尝试强制检出项%s", p.Name)
-				// 添加 --force 参数
-				forceArgs := make([]string, len(args))
-				copy(forceArgs, args)
-				// checkout 后插--force
-				forceArgs = append(forceArgs[:3], append([]string{"--force"}, forceArgs[3:]...)...)
-				args = forceArgs
-			}
-		}
-
-		// 执行 checkout 命令
-		cmd := exec.Command("git", args...)
-		cmd.Stderr = &stderr
-		lastErr = cmd.Run()
-
-		if lastErr == nil {
-			// 成功检出，跳出重试循环
-			break
-		}
+	return e.ctx.Err()
+}
 
-		// 如果已经达到最大重试次数，则返回错
-		if retryCount == maxRetries {
-			return &SyncError{
-				ProjectName: p.Name,
-				Phase:       "checkout",
-				Err:         lastErr,
-				Output:      stderr.String(),
-				Timestamp:   time.Now(),
-				RetryCount:  retryCount,
-			}
-		}
+// sleepContext 等待 d，ctx 被取消时提前返回取消原因
+func sleepContext(ctx context.Context, d time.Duration) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d):
+		return nil
 	}
-
-	return nil
 }
 
 // projectExists 检查项目目录是否存
@@ -1240,7 +1251,7 @@ func (e *Engine) projectExists(p *project.Project) (bool, error) {
 // setupRemote 设置远程仓库
 func (e *Engine) setupRemote(p *project.Project, remoteURL string) error {
 	// 检查远程仓库是否已存在
-	cmd := exec.Command("git", "-C", p.Worktree, "remote")
+	cmd := git.Command(e.ctx, "", "-C", p.Worktree, "remote")
 	output, err := cmd.Output()
 	if err != nil {
 		return fmt.Errorf("获取远程仓库列表失败: %w", err)
@@ -1270,16 +1281,16 @@ func (e *Engine) setupRemote(p *project.Project, remoteURL string) error {
 		if e.options.Verbose {
 			e.logger.Debug("项目 %s 存在默认远程 'origin'，但项目指定的远程名称为 '%s'，将删除 'origin' 远程", p.Name, p.RemoteName)
 		}
-		cmd = exec.Command("git", "-C", p.Worktree, "remote", "remove", "origin")
-		if err := cmd.Run(); err != nil {
+		cmd = git.Command(e.ctx, "", "-C", p.Worktree, "remote", "remove", "origin")
+		if err := git.Run(cmd); err != nil {
 			e.logger.Warn("删除项目 %s 的 'origin' 远程失败: %v", p.Name, err)
 		}
 	}
 
 	// 如果项目指定的远程不存在，添加
 	if !remoteExists {
-		cmd = exec.Command("git", "-C", p.Worktree, "remote", "add", p.RemoteName, remoteURL)
-		if err := cmd.Run(); err != nil {
+		cmd = git.Command(e.ctx, "", "-C", p.Worktree, "remote", "add", p.RemoteName, remoteURL)
+		if err := git.Run(cmd); err != nil {
 			return fmt.Errorf("添加远程仓库失败: %w", err)
 		}
 		if e.options.Verbose {
@@ -1287,8 +1298,8 @@ func (e *Engine) setupRemote(p *project.Project, remoteURL string) error {
 		}
 	} else {
 		// 如果远程仓库已存在，更新URL
-		cmd = exec.Command("git", "-C", p.Worktree, "remote", "set-url", p.RemoteName, remoteURL)
-		if err := cmd.Run(); err != nil {
+		cmd = git.Command(e.ctx, "", "-C", p.Worktree, "remote", "set-url", p.RemoteName, remoteURL)
+		if err := git.Run(cmd); err != nil {
 			return fmt.Errorf("更新远程仓库URL失败: %w", err)
 		}
 		if e.options.Verbose {
@@ -1298,8 +1309,8 @@ func (e *Engine) setupRemote(p *project.Project, remoteURL string) error {
 
 	// 如果是镜像模式，设置mirror=true
 	if e.options.Config != nil && e.options.Config.Mirror {
-		cmd = exec.Command("git", "-C", p.Worktree, "config", "--add", fmt.Sprintf("remote.%s.mirror", p.RemoteName), "true")
-		if err := cmd.Run(); err != nil {
+		cmd = git.Command(e.ctx, "", "-C", p.Worktree, "config", "--add", fmt.Sprintf("remote.%s.mirror", p.RemoteName), "true")
+		if err := git.Run(cmd); err != nil {
 			return fmt.Errorf("设置远程仓库镜像模式失败: %w", err)
 		}
 		if e.options.Verbose {
@@ -1313,7 +1324,7 @@ func (e *Engine) setupRemote(p *project.Project, remoteURL string) error {
 // ensureRemoteExists 确保远程仓库存在
 func (e *Engine) ensureRemoteExists(p *project.Project, remoteURL string) error {
 	// 检查远程仓库是否已存在
-	cmd := exec.Command("git", "-C", p.Worktree, "remote")
+	cmd := git.Command(e.ctx, "", "-C", p.Worktree, "remote")
 	output, err := cmd.Output()
 	if err != nil {
 		return fmt.Errorf("获取远程仓库列表失败: %w", err)
@@ -1342,16 +1353,16 @@ func (e *Engine) ensureRemoteExists(p *project.Project, remoteURL string) error
 		if e.options.Verbose {
 			e.logger.Debug("项目 %s 存在默认远程 'origin'，但项目指定的远程名称为 '%s'，将删除 'origin' 远程", p.Name, p.RemoteName)
 		}
-		cmd = exec.Command("git", "-C", p.Worktree, "remote", "remove", "origin")
-		if err := cmd.Run(); err != nil {
+		cmd = git.Command(e.ctx, "", "-C", p.Worktree, "remote", "remove", "origin")
+		if err := git.Run(cmd); err != nil {
 			e.logger.Warn("删除项目 %s 的 'origin' 远程失败: %v", p.Name, err)
 		}
 	}
 
 	// 如果项目指定的远程不存在，添加
 	if !remoteExists {
-		cmd = exec.Command("git", "-C", p.Worktree, "remote", "add", p.RemoteName, remoteURL)
-		if err := cmd.Run(); err != nil {
+		cmd = git.Command(e.ctx, "", "-C", p.Worktree, "remote", "add", p.RemoteName, remoteURL)
+		if err := git.Run(cmd); err != nil {
 			return fmt.Errorf("添加远程仓库失败: %w", err)
 		}
 		if e.options.Verbose {
@@ -1359,7 +1370,7 @@ func (e *Engine) ensureRemoteExists(p *project.Project, remoteURL string) error
 		}
 	} else {
 		// 检查远程URL是否正确
-		cmd = exec.Command("git", "-C", p.Worktree, "remote", "get-url", p.RemoteName)
+		cmd = git.Command(e.ctx, "", "-C", p.Worktree, "remote", "get-url", p.RemoteName)
 		output, err := cmd.Output()
 		if err != nil {
 			return fmt.Errorf("获取远程仓库URL失败: %w", err)
@@ -1368,8 +1379,8 @@ func (e *Engine) ensureRemoteExists(p *project.Project, remoteURL string) error
 		currentURL := strings.TrimSpace(string(output))
 		if currentURL != remoteURL {
 			// 更新远程URL
-			cmd = exec.Command("git", "-C", p.Worktree, "remote", "set-url", p.RemoteName, remoteURL)
-			if err := cmd.Run(); err != nil {
+			cmd = git.Command(e.ctx, "", "-C", p.Worktree, "remote", "set-url", p.RemoteName, remoteURL)
+			if err := git.Run(cmd); err != nil {
 				return fmt.Errorf("更新远程仓库URL失败: %w", err)
 			}
 			if e.options.Verbose {
@@ -1381,8 +1392,8 @@ func (e *Engine) ensureRemoteExists(p *project.Project, remoteURL string) error
 	// 检查是否为镜像模式
 	if e.options.Config != nil && e.options.Config.Mirror {
 		// 为镜像仓库设置mirror=true配置
-		cmd = exec.Command("git", "-C", p.Worktree, "config", "--add", fmt.Sprintf("remote.%s.mirror", p.RemoteName), "true")
-		if err := cmd.Run(); err != nil {
+		cmd = git.Command(e.ctx, "", "-C", p.Worktree, "config", "--add", fmt.Sprintf("remote.%s.mirror", p.RemoteName), "true")
+		if err := git.Run(cmd); err != nil {
 			return fmt.Errorf("设置镜像仓库配置失败: %w", err)
 		}
 		if !e.options.Quiet && e.options.Verbose {
@@ -1402,7 +1413,7 @@ func (e *Engine) pullLFS(p *project.Project) error {
 	}
 
 	// 检查仓库是否使LFS
-	cmd := exec.Command("git", "-C", p.Worktree, "lfs", "ls-files")
+	cmd := git.Command(e.ctx, "", "-C", p.Worktree, "lfs", "ls-files")
 	output, err := cmd.Output()
 	if err != nil {
 		// 可能不是 LFS 仓库，跳
@@ -1411,8 +1422,8 @@ func (e *Engine) pullLFS(p *project.Project) error {
 
 	// 如果LFS 文件，执行拉
 	if len(output) > 0 {
-		cmd = exec.Command("git", "-C", p.Worktree, "lfs", "pull")
-		if err := cmd.Run(); err != nil {
+		cmd = git.Command(e.ctx, "", "-C", p.Worktree, "lfs", "pull")
+		if err := git.Run(cmd); err != nil {
 			return fmt.Errorf("LFS 拉取失败: %w", err)
 		}
 	}
@@ -1480,11 +1491,21 @@ func (e *Engine) checkoutParallel(projects []*project.Project, hyperSyncProjects
 	return g.Wait()
 }
 
-// processLinkAndCopyFiles 处理项目中的 linkfile copyfile
+// processLinkAndCopyFiles 持有项目锁处理项目中的 linkfile copyfile
 func (e *Engine) processLinkAndCopyFiles(p *project.Project) error {
 	if p == nil {
 		return fmt.Errorf("项目对象为空")
 	}
+	l, err := e.lockProject(p, "link_copy_files")
+	if err != nil {
+		return err
+	}
+	defer l.Release()
+	return e.linkAndCopyFiles(p)
+}
+
+// linkAndCopyFiles 处理项目中的 linkfile copyfile，调用者需持有项目锁
+func (e *Engine) linkAndCopyFiles(p *project.Project) error {
 
 	e.logger.Info("开始处理项目 %s 的 linkfile 和 copyfile", p.Name)
 
@@ -1955,11 +1976,11 @@ func (e *Engine) updateSubmodules(p *project.Project) error {
 		args = append(args, "--quiet")
 	}
 
-	cmd := exec.Command("git", args...)
+	cmd := git.Command(e.ctx, "", args...)
 	var stderr bytes.Buffer
 	cmd.Stderr = &stderr
 
-	if err := cmd.Run(); err != nil {
+	if err := git.Run(cmd); err != nil {
 		// submodule 更新失败
 		errorMsg := stderr.String()
 		if errorMsg == "" {
@@ -0,0 +1,31 @@
+package repo_sync
+
+import (
+	"time"
+
+	"github.com/leopardxu/repo-go/internal/event"
+	"github.com/leopardxu/repo-go/internal/project"
+)
+
+// startSyncEvent 在事件日志中开始项目的一个同步阶段，未开启事件记录时返回 nil
+func startSyncEvent(p *project.Project, task string) *event.Event {
+	return event.Add(p.Path, task, time.Now()).
+		Set("project", p.Name).
+		Set("revision", p.Revision)
+}
+
+// finishSyncEvent 结束项目同步阶段的事件，同时记录远程地址和当前提交
+func finishSyncEvent(ev *event.Event, p *project.Project, err error) {
+	if ev == nil {
+		return
+	}
+	if p.RemoteURL != "" {
+		ev.Set("remote_url", p.RemoteURL)
+	}
+	if p.GitRepo != nil {
+		if hash, err := p.GitRepo.RevParse("HEAD"); err == nil {
+			ev.Set("git_hash", hash)
+		}
+	}
+	ev.Finish(err == nil)
+}
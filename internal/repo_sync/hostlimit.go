@@ -0,0 +1,124 @@
+package repo_sync
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/leopardxu/repo-go/internal/logger"
+	"github.com/leopardxu/repo-go/internal/project"
+	"github.com/leopardxu/repo-go/internal/workerpool"
+)
+
+// newHostLimiter 创建按主机限制获取并发数的限制器
+// 初始上限依次取 --jobs-per-host、--jobs-network 和 --jobs，清单中远程的 sync-j 可以进一步降低上限
+func newHostLimiter(options *Options, log logger.Logger) *workerpool.HostLimiter {
+	limit := options.HostJobs
+	if limit <= 0 {
+		limit = options.JobsNetwork
+	}
+	if limit <= 0 {
+		limit = options.Jobs
+	}
+
+	limiter := workerpool.NewHostLimiter(limit)
+	limiter.OnChange = func(host string, oldLimit, newLimit int, throttled bool) {
+		if log == nil {
+			return
+		}
+		logf := log.Debug
+		if options.Verbose {
+			logf = log.Info
+		}
+		if throttled {
+			logf("主机 %s 限流，并发上限 %d -> %d", hostName(host), oldLimit, newLimit)
+		} else {
+			logf("主机 %s 并发上限恢复 %d -> %d", hostName(host), oldLimit, newLimit)
+		}
+	}
+	return limiter
+}
+
+// acquireHost 在项目远程所在的主机上占用一个请求名额，返回的名额需要在请求结束后释放
+func (e *Engine) acquireHost(p *project.Project, remoteURL string) (*workerpool.Slot, error) {
+	if e.hostLimiter == nil {
+		return nil, nil
+	}
+	host := remoteHost(remoteURL)
+	if syncJ := e.remoteSyncJ(p); syncJ > 0 {
+		e.hostLimiter.SetLimit(host, syncJ)
+	}
+	ctx := e.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return e.hostLimiter.Acquire(ctx, host)
+}
+
+// remoteSyncJ 返回清单中项目远程的 sync-j 属性，未设置时返回 0
+func (e *Engine) remoteSyncJ(p *project.Project) int {
+	if e.manifest == nil {
+		return 0
+	}
+	name := p.RemoteName
+	if name == "" {
+		name = e.options.DefaultRemote
+	}
+	if name == "" {
+		name = e.manifest.Default.Remote
+	}
+	for _, r := range e.manifest.Remotes {
+		if r.Name == name {
+			return r.SyncJ
+		}
+	}
+	return 0
+}
+
+// logHostLimits 在 --verbose 时输出每个主机当前的并发上限
+func (e *Engine) logHostLimits() {
+	if e.hostLimiter == nil || !e.options.Verbose || e.options.Quiet {
+		return
+	}
+	for _, l := range e.hostLimiter.Limits() {
+		e.logger.Info("主机 %s: 并发上限 %d/%d，进行中 %d", hostName(l.Host), l.Limit, l.Max, l.InFlight)
+	}
+}
+
+// isThrottled 判断 git 命令的错误和标准错误输出是否表示服务器限流
+func isThrottled(err error, stderr string) bool {
+	if err == nil {
+		return false
+	}
+	return IsThrottlingGitError(fmt.Errorf("%v: %s", err, stderr))
+}
+
+// remoteHost 返回远程地址的主机名（含端口），本地路径返回空字符串
+func remoteHost(remoteURL string) string {
+	if strings.Contains(remoteURL, "://") {
+		u, err := url.Parse(remoteURL)
+		if err != nil || u.Scheme == "file" {
+			return ""
+		}
+		return u.Host
+	}
+	// scp 风格的地址：[user@]host:path
+	colon := strings.Index(remoteURL, ":")
+	if colon <= 0 || strings.ContainsAny(remoteURL[:colon], `/\`) || colon == 1 {
+		return ""
+	}
+	host := remoteURL[:colon]
+	if at := strings.LastIndex(host, "@"); at >= 0 {
+		host = host[at+1:]
+	}
+	return host
+}
+
+// hostName 返回用于日志的主机名称
+func hostName(host string) string {
+	if host == "" {
+		return "(local)"
+	}
+	return host
+}
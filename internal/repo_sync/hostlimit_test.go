@@ -0,0 +1,21 @@
+package repo_sync
+
+import "testing"
+
+func TestRemoteHost(t *testing.T) {
+	tests := map[string]string{
+		"https://review.example.com/platform/build": "review.example.com",
+		"ssh://user@review.example.com:29418/a":     "review.example.com:29418",
+		"git@github.com:org/repo.git":               "github.com",
+		"review.example.com:platform/build":         "review.example.com",
+		"file:///srv/git/a.git":                     "",
+		"/srv/git/a.git":                            "",
+		"../a.git":                                  "",
+		`C:\repos\a.git`:                            "",
+	}
+	for url, want := range tests {
+		if got := remoteHost(url); got != want {
+			t.Errorf("remoteHost(%q) = %q, want %q", url, got, want)
+		}
+	}
+}
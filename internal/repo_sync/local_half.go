@@ -0,0 +1,272 @@
+package repo_sync
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/leopardxu/repo-go/internal/event"
+	"github.com/leopardxu/repo-go/internal/git"
+	"github.com/leopardxu/repo-go/internal/project"
+)
+
+// localSyncState 表示项目本地更新（local half）的结果
+type localSyncState int
+
+const (
+	localUpToDate    localSyncState = iota // 已是最新
+	localDetached                          // 分离头指针已移动到新的修订版本
+	localFastForward                       // 主题分支已快进
+	localRebased                           // 主题分支上的本地提交已变基
+	localNoRebase                          // 有未发布的提交，因 --no-rebase 保持不变
+	localPublished                         // 本地提交已发布但未合并，不改写，保持不变
+	localNoTracking                        // 分支未跟踪上游，保持不变
+	localDirty                             // 工作区有未提交的修改，已跳过
+	localFailed                            // 更新失败
+)
+
+// localSyncResult 记录单个项目本地更新的结果
+type localSyncResult struct {
+	project *project.Project
+	state   localSyncState
+	branch  string // 当前分支，分离头指针时为空
+	target  string // 清单修订版本的短提交号
+	commits int    // 本地未发布的提交数
+	err     error
+}
+
+// checkoutProject 将项目工作区更新到清单修订版本
+// 分离头指针直接移动；主题分支没有本地提交时快进，有本地提交时变基（--no-rebase 或提交已发布时保持不变）；
+// 工作区有未提交的修改时跳过，除非指定了 --force-sync
+func (e *Engine) checkoutProject(p *project.Project) error {
+	l, err := e.lockProject(p, "checkout")
+	if err != nil {
+		return err
+	}
+	defer l.Release()
+
+	ev := startSyncEvent(p, event.TaskSyncLocal)
+	res := e.syncLocalHalf(p)
+	finishSyncEvent(ev, p, res.err)
+
+	e.localMu.Lock()
+	e.localResults = append(e.localResults, res)
+	e.localMu.Unlock()
+
+	if res.state == localFailed {
+		return &SyncError{
+			ProjectName: p.Name,
+			Phase:       "checkout",
+			Err:         res.err,
+			Timestamp:   time.Now(),
+		}
+	}
+	return nil
+}
+
+// syncLocalHalf 执行单个项目的本地更新
+func (e *Engine) syncLocalHalf(p *project.Project) localSyncResult {
+	repo := p.GitRepo
+	repo.ClearCache()
+	res := localSyncResult{project: p}
+
+	if repo.RebaseInProgress() || repo.CherryPickInProgress() {
+		res.state = localFailed
+		res.err = fmt.Errorf("a rebase or cherry-pick is in progress, finish or abort it first")
+		return res
+	}
+
+	target, err := repo.RevParse(p.RevisionRef())
+	if err != nil {
+		// 标签等修订版本没有对应的远程跟踪引用
+		if target, err = repo.RevParse(p.Revision); err != nil {
+			res.state = localFailed
+			res.err = fmt.Errorf("manifest revision %s not found", p.Revision)
+			return res
+		}
+	}
+	res.target = shortCommit(target)
+
+	dirty, err := hasTrackedChanges(e.ctx, repo)
+	if err != nil {
+		res.state = localFailed
+		res.err = err
+		return res
+	}
+	force := dirty && e.options.ForceSync
+
+	head, _ := repo.RevParse("HEAD")
+	branch, _ := repo.CurrentBranch()
+	detached := branch == "" || branch == "HEAD" || strings.HasPrefix(branch, "HEAD detached")
+
+	if detached || e.options.Detach {
+		if detached && head == target {
+			res.state = localUpToDate
+			return res
+		}
+		if dirty && !force {
+			res.state = localDirty
+			return res
+		}
+		args := []string{"checkout", "--quiet", "--detach"}
+		if force {
+			args = append(args, "--force")
+		}
+		if _, err := repo.Runner.RunInDirContext(e.ctx, p.Worktree, append(args, target)...); err != nil {
+			res.state = localFailed
+			res.err = err
+			return res
+		}
+		res.state = localDetached
+		return res
+	}
+
+	res.branch = branch
+	if tracking, _ := repo.TrackingBranch(branch); tracking == "" {
+		res.state = localNoTracking
+		return res
+	}
+
+	local, behind, err := repo.AheadBehind(target, "HEAD")
+	if err != nil {
+		res.state = localFailed
+		res.err = err
+		return res
+	}
+	res.commits = local
+	if behind == 0 {
+		res.state = localUpToDate
+		return res
+	}
+	if local > 0 && e.options.NoRebase {
+		res.state = localNoRebase
+		return res
+	}
+	if local > 0 {
+		// 已上传待审核、尚未合并的提交不能变基改写；上传时记录在 refs/published/<branch>
+		published, err := repo.PublishedCommit(branch)
+		if err != nil {
+			res.state = localFailed
+			res.err = err
+			return res
+		}
+		if published != "" {
+			unmerged, err := repo.UnmergedCommits(target, published)
+			if err != nil {
+				res.state = localFailed
+				res.err = err
+				return res
+			}
+			if len(unmerged) > 0 {
+				res.state = localPublished
+				return res
+			}
+		}
+	}
+	if dirty && !force {
+		res.state = localDirty
+		return res
+	}
+	if force {
+		if _, err := repo.Runner.RunInDirContext(e.ctx, p.Worktree, "reset", "--quiet", "--hard"); err != nil {
+			res.state = localFailed
+			res.err = err
+			return res
+		}
+	}
+
+	if local == 0 {
+		if _, err := repo.Runner.RunInDirContext(e.ctx, p.Worktree, "merge", "--quiet", "--ff-only", target); err != nil {
+			res.state = localFailed
+			res.err = err
+			return res
+		}
+		res.state = localFastForward
+		return res
+	}
+
+	if _, err := repo.Runner.RunInDirContext(e.ctx, p.Worktree, "rebase", "--quiet", target); err != nil {
+		// 同步过程中不留下未完成的变基，分支恢复原状；同步被中断时同样需要还原
+		if repo.RebaseInProgress() {
+			repo.Runner.RunInDirContext(context.WithoutCancel(e.ctx), p.Worktree, "rebase", "--abort")
+		}
+		if e.ctx.Err() != nil {
+			res.state = localFailed
+			res.err = e.ctx.Err()
+			return res
+		}
+		res.state = localFailed
+		res.err = fmt.Errorf("rebasing %s onto %s failed, branch left unchanged; run 'repo rebase' to resolve", branch, res.target)
+		return res
+	}
+	res.state = localRebased
+	return res
+}
+
+// checkoutRevision 在新克隆的项目中检出清单修订版本
+func (e *Engine) checkoutRevision(p *project.Project) error {
+	revision := strings.TrimPrefix(strings.TrimPrefix(p.Revision, "refs/heads/"), "refs/tags/")
+	if _, err := p.GitRepo.Runner.RunInDirContext(e.ctx, p.Worktree, "checkout", "--quiet", revision); err != nil {
+		return err
+	}
+	return nil
+}
+
+// reportLocalSync 在同步摘要中输出各项目本地更新的结果
+func (e *Engine) reportLocalSync() {
+	e.localMu.Lock()
+	results := append([]localSyncResult(nil), e.localResults...)
+	e.localMu.Unlock()
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].project.Name < results[j].project.Name
+	})
+
+	// 失败的项目已在同步过程中报告，这里只计数
+	counts := make(map[localSyncState]int)
+	for _, res := range results {
+		counts[res.state]++
+		name := res.project.Name
+		switch res.state {
+		case localDetached:
+			e.logger.Info("项目 %s: 已分离到 %s", name, res.target)
+		case localFastForward:
+			e.logger.Info("项目 %s: 分支 %s 已快进到 %s", name, res.branch, res.target)
+		case localRebased:
+			e.logger.Info("项目 %s: 分支 %s 的 %d 个本地提交已变基到 %s", name, res.branch, res.commits, res.target)
+		case localNoRebase:
+			e.logger.Warn("项目 %s: 分支 %s 有 %d 个未发布的提交，因 --no-rebase 未更新", name, res.branch, res.commits)
+		case localPublished:
+			e.logger.Warn("项目 %s: 分支 %s 已发布但未合并，有 %d 个本地提交，保持不变 (请使用 repo rebase 手动变基)", name, res.branch, res.commits)
+		case localNoTracking:
+			e.logger.Warn("项目 %s: 分支 %s 未跟踪上游分支，保持不变", name, res.branch)
+		case localDirty:
+			e.logger.Warn("项目 %s: 工作区有未提交的修改，已跳过 (使用 --force-sync 覆盖本地修改)", name)
+		}
+	}
+
+	if len(results) > 0 {
+		e.logger.Info("本地更新: %d 个已是最新, %d 个分离, %d 个快进, %d 个变基, %d 个保持不变, %d 个跳过, %d 个失败",
+			counts[localUpToDate], counts[localDetached], counts[localFastForward], counts[localRebased],
+			counts[localNoRebase]+counts[localPublished]+counts[localNoTracking], counts[localDirty], counts[localFailed])
+	}
+}
+
+// hasTrackedChanges 检查工作区是否有已跟踪文件的未提交修改，未跟踪文件不影响更新
+func hasTrackedChanges(ctx context.Context, repo *git.Repository) (bool, error) {
+	output, err := repo.Runner.RunInDirContext(ctx, repo.Path, "status", "--porcelain", "--untracked-files=no")
+	if err != nil {
+		return false, fmt.Errorf("failed to check worktree status: %w", err)
+	}
+	return strings.TrimSpace(string(output)) != "", nil
+}
+
+// shortCommit 返回提交哈希的前12位
+func shortCommit(commit string) string {
+	if len(commit) > 12 {
+		return commit[:12]
+	}
+	return commit
+}
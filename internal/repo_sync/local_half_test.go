@@ -0,0 +1,148 @@
+package repo_sync
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/leopardxu/repo-go/internal/project"
+	"github.com/leopardxu/repo-go/internal/testutil"
+)
+
+// newLocalHalfFixture 创建一个检出在跟踪 origin/main 的分支 topic 上的项目，
+// 之后上游前进一个提交；local 为 true 时 topic 上有一个本地提交
+func newLocalHalfFixture(t *testing.T, local bool) *project.Project {
+	t.Helper()
+	dir := testutil.NewRepo(t)
+	testutil.Git(t, dir, "checkout", "--quiet", "-b", "topic", "--track", "origin/main")
+	if local {
+		testutil.Commit(t, dir, "local", "local\n", "local")
+	}
+
+	testutil.Git(t, dir, "checkout", "--quiet", "--detach", "origin/main")
+	testutil.Commit(t, dir, "upstream", "upstream\n", "upstream")
+	testutil.Git(t, dir, "update-ref", "refs/remotes/origin/main", "HEAD")
+	testutil.Git(t, dir, "checkout", "--quiet", "topic")
+
+	return testutil.NewProject(dir, "p")
+}
+
+func TestSyncLocalHalf(t *testing.T) {
+	testutil.SetIdentity(t)
+
+	tests := []struct {
+		name    string
+		local   bool
+		options Options
+		setup   func(t *testing.T, p *project.Project)
+		want    localSyncState
+		// wantHead 为 true 时 HEAD 应包含 origin/main，否则 topic 应保持不变
+		wantHead bool
+	}{
+		{
+			name: "detached",
+			setup: func(t *testing.T, p *project.Project) {
+				testutil.Git(t, p.Worktree, "checkout", "--quiet", "--detach", "topic")
+			},
+			want:     localDetached,
+			wantHead: true,
+		},
+		{name: "fast-forward", want: localFastForward, wantHead: true},
+		{name: "rebase", local: true, want: localRebased, wantHead: true},
+		{name: "no rebase", local: true, options: Options{NoRebase: true}, want: localNoRebase},
+		{
+			name:  "published commits",
+			local: true,
+			// 与 repo upload 一样推送到 Gerrit 的 refs/for/main 并记录上传的提交，推送不产生远程跟踪分支
+			setup: func(t *testing.T, p *project.Project) {
+				testutil.Git(t, p.Worktree, "push", "--quiet", "origin", "HEAD:refs/for/main")
+				if err := p.GitRepo.SetPublished(context.Background(), "topic", testutil.Git(t, p.Worktree, "rev-parse", "HEAD")); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: localPublished,
+		},
+		{
+			name:  "published commits merged",
+			local: true,
+			// 上次上传的提交已合入上游，之后的本地提交可以变基
+			setup: func(t *testing.T, p *project.Project) {
+				if err := p.GitRepo.SetPublished(context.Background(), "topic", testutil.Git(t, p.Worktree, "rev-parse", "topic~1")); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want:     localRebased,
+			wantHead: true,
+		},
+		{
+			name: "dirty worktree",
+			setup: func(t *testing.T, p *project.Project) {
+				os.WriteFile(filepath.Join(p.Worktree, "f"), []byte("dirty\n"), 0644)
+			},
+			want: localDirty,
+		},
+		{
+			name:    "dirty worktree with --force-sync",
+			options: Options{ForceSync: true},
+			setup: func(t *testing.T, p *project.Project) {
+				os.WriteFile(filepath.Join(p.Worktree, "f"), []byte("dirty\n"), 0644)
+			},
+			want:     localFastForward,
+			wantHead: true,
+		},
+		{
+			name:     "untracked files",
+			setup:    func(t *testing.T, p *project.Project) { os.WriteFile(filepath.Join(p.Worktree, "new"), nil, 0644) },
+			want:     localFastForward,
+			wantHead: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newLocalHalfFixture(t, tt.local)
+			dir := p.Worktree
+			if tt.setup != nil {
+				tt.setup(t, p)
+			}
+			topic := testutil.Git(t, dir, "rev-parse", "refs/heads/topic")
+
+			e := &Engine{ctx: context.Background(), options: &tt.options}
+			res := e.syncLocalHalf(p)
+			if res.state != tt.want || res.err != nil {
+				t.Fatalf("syncLocalHalf() = state %d, err %v, want state %d", res.state, res.err, tt.want)
+			}
+
+			upstream := testutil.Git(t, dir, "rev-parse", "origin/main")
+			if !tt.wantHead {
+				if got := testutil.Git(t, dir, "rev-parse", "refs/heads/topic"); got != topic {
+					t.Errorf("topic moved to %s, want it left at %s", got, topic)
+				}
+				return
+			}
+			if _, err := exec.Command("git", "-C", dir, "merge-base", "--is-ancestor", upstream, "HEAD").Output(); err != nil {
+				t.Errorf("HEAD does not contain origin/main %s", upstream)
+			}
+			if tt.local {
+				if got := testutil.Git(t, dir, "rev-parse", "HEAD~1"); got != upstream {
+					t.Errorf("HEAD~1 = %s, want local commit rebased onto %s", got, upstream)
+				}
+			}
+			if tt.options.ForceSync {
+				if got := testutil.Git(t, dir, "status", "--porcelain", "--untracked-files=no"); got != "" {
+					t.Errorf("worktree still dirty after --force-sync: %s", got)
+				}
+			}
+		})
+	}
+
+	t.Run("up to date", func(t *testing.T) {
+		p := newLocalHalfFixture(t, false)
+		testutil.Git(t, p.Worktree, "merge", "--quiet", "--ff-only", "origin/main")
+		e := &Engine{ctx: context.Background(), options: &Options{}}
+		if res := e.syncLocalHalf(p); res.state != localUpToDate {
+			t.Errorf("syncLocalHalf() = state %d, err %v, want up to date", res.state, res.err)
+		}
+	})
+}
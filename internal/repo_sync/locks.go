@@ -0,0 +1,39 @@
+package repo_sync
+
+import (
+	"context"
+	"time"
+
+	"github.com/leopardxu/repo-go/internal/lock"
+	"github.com/leopardxu/repo-go/internal/project"
+)
+
+// lockProject 获取项目锁，防止其他 repo 进程同时获取、检出或复制链接同一项目
+// 仓库根目录未知时不加锁，返回的锁为 nil
+func (e *Engine) lockProject(p *project.Project, phase string) (*lock.Lock, error) {
+	if e.repoRoot == "" {
+		return nil, nil
+	}
+	path := p.Path
+	if path == "" {
+		path = p.Name
+	}
+	l, err := lock.Acquire(e.lockContext(), lock.ProjectPath(e.repoRoot, path), lock.Exclusive)
+	if err != nil {
+		return nil, &SyncError{
+			ProjectName: p.Name,
+			Phase:       phase,
+			Err:         err,
+			Timestamp:   time.Now(),
+		}
+	}
+	return l, nil
+}
+
+// lockContext 返回等待锁时使用的 context，引擎没有设置 context 时一直等待
+func (e *Engine) lockContext() context.Context {
+	if e.ctx == nil {
+		return context.Background()
+	}
+	return e.ctx
+}
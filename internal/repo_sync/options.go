@@ -18,6 +18,7 @@ type Options struct {
 	Jobs                   int
 	JobsNetwork            int
 	JobsCheckout           int
+	HostJobs               int // 每个主机的最大并发获取数，0 表示不单独限制
 	SmartSync              bool
 	SmartTag               string
 	UseSuperproject        bool
@@ -27,6 +28,7 @@ type Options struct {
 	Tags                   bool
 	GitLFS                 bool // 添加 GitLFS 字段
 	ForceSync              bool
+	NoRebase               bool // 有本地提交的分支不变基
 	ForceOverwrite         bool
 	ForceRemoveDirty       bool // 添加 ForceRemoveDirty 字段
 	ForceBroken            bool // 继续同步即使项目已损坏
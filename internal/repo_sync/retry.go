@@ -103,6 +103,11 @@ func IsRetryableGitError(err error) bool {
 	}
 	errMsg := err.Error()
 
+	// 服务器限流 - 可重试，同时降低该主机的并发数
+	if IsThrottlingGitError(err) {
+		return true
+	}
+
 	// 网络错误 - 可重试
 	networkErrors := []string{
 		"fatal: unable to access",
@@ -152,3 +157,29 @@ func IsRetryableGitError(err error) bool {
 
 	return false
 }
+
+// throttlingErrors 是服务器因并发连接过多拒绝请求时的错误信息
+var throttlingErrors = []string{
+	"too many connections",
+	"Too many connections",
+	"Too Many Requests",
+	"returned error: 429",
+	"returned error: 503",
+	"HTTP 429",
+	"Connection closed by", // ssh: Connection closed by remote host / <addr> port <port>
+	"kex_exchange_identification",
+}
+
+// IsThrottlingGitError 判断 Git 错误是否表示服务器因并发连接过多而限流
+func IsThrottlingGitError(err error) bool {
+	if err == nil {
+		return false
+	}
+	errMsg := err.Error()
+	for _, te := range throttlingErrors {
+		if strings.Contains(errMsg, te) {
+			return true
+		}
+	}
+	return false
+}
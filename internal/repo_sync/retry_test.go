@@ -44,3 +44,30 @@ func TestRetryWithBackoff_ContextTimeout(t *testing.T) {
 		t.Error("Expected context error, got nil")
 	}
 }
+
+func TestIsThrottlingGitError(t *testing.T) {
+	throttled := []string{
+		"fatal: unable to access 'https://review.example.com/a/': The requested URL returned error: 429",
+		"remote: Too many connections, try again later",
+		"Connection closed by remote host\nfatal: Could not read from remote repository.",
+		"Connection closed by 10.0.0.1 port 29418",
+	}
+	for _, msg := range throttled {
+		err := errors.New(msg)
+		if !IsThrottlingGitError(err) {
+			t.Errorf("IsThrottlingGitError(%q) = false", msg)
+		}
+		if !IsRetryableGitError(err) {
+			t.Errorf("IsRetryableGitError(%q) = false", msg)
+		}
+	}
+
+	for _, msg := range []string{
+		"fatal: repository 'https://review.example.com/a/' not found",
+		"fatal: Could not resolve host: review.example.com",
+	} {
+		if IsThrottlingGitError(errors.New(msg)) {
+			t.Errorf("IsThrottlingGitError(%q) = true", msg)
+		}
+	}
+}
@@ -167,7 +167,7 @@ func (e *Engine) syncProjectImpl(p *project.Project) error {
 
 	// 阶段三：本地检出（checkout）
 	if !e.options.NetworkOnly {
-		if err := e.checkoutProjectWithRetry(p); err != nil {
+		if err := e.checkoutProject(p); err != nil {
 			return err
 		}
 	}
@@ -343,69 +343,6 @@ func (e *Engine) fetchProjectWithRetry(p *project.Project) error {
 	return nil
 }
 
-// checkoutProjectWithRetry 使用统一重试逻辑执行 checkout
-func (e *Engine) checkoutProjectWithRetry(p *project.Project) error {
-	// 检查是否有本地修改
-	clean, err := p.GitRepo.IsClean()
-	if err != nil {
-		return fmt.Errorf("检查项目 %s 工作区状态失败: %w", p.Name, err)
-	}
-
-	if !clean && !e.options.ForceSync {
-		return fmt.Errorf("项目 %s 工作区不干净，使用 --force-sync 覆盖本地修改", p.Name)
-	}
-
-	if e.options.Verbose {
-		e.logger.Debug("正在检出项目 %s 的版本 %s", p.Name, p.Revision)
-	}
-
-	if p.Revision == "" {
-		p.Revision = "HEAD"
-		if e.options.Verbose {
-			e.logger.Debug("项目 %s 的修订版本未设置，使用默认 'HEAD'", p.Name)
-		}
-	}
-
-	retryOpts := DefaultRetryOptions()
-	retryOpts.MaxRetries = e.options.RetryFetches
-	if retryOpts.MaxRetries <= 0 {
-		retryOpts.MaxRetries = 3
-	}
-	retryOpts.ShouldRetry = func(err error) bool {
-		// checkout 的本地修改冲突需要特殊处理
-		errMsg := err.Error()
-		if strings.Contains(errMsg, "local changes") || strings.Contains(errMsg, "would be overwritten") {
-			if e.options.ForceSync {
-				e.logger.Info("检出项目 %s 时发现本地修改，尝试强制重置...", p.Name)
-				_, resetErr := p.GitRepo.Runner.RunInDir(p.Worktree, "reset", "--hard")
-				return resetErr == nil // 重置成功才重试
-			}
-			return false
-		}
-		return IsRetryableGitError(err)
-	}
-
-	checkoutErr := RetryWithBackoff(e.ctx, retryOpts, func(attempt int) error {
-		if attempt > 0 {
-			e.logger.Info("检出项目 %s 第 %d 次重试...", p.Name, attempt)
-		}
-		return p.GitRepo.Checkout(p.Revision)
-	})
-
-	if checkoutErr != nil {
-		errorMsg := fmt.Sprintf("检出项目 %s 失败: %v", p.Name, checkoutErr)
-		if e.options.Verbose {
-			errorDetails := analyzeGitError(checkoutErr.Error())
-			errorMsg = fmt.Sprintf("检出项目 %s 的版本 %s 失败: %v\n错误详情: %s",
-				p.Name, p.Revision, checkoutErr, errorDetails)
-		}
-		e.recordError(errorMsg)
-		return fmt.Errorf("检出项目 %s 的版本 %s 失败: %w", p.Name, p.Revision, checkoutErr)
-	}
-
-	return nil
-}
-
 // recordError 线程安全地记录错误信息（使用正确的互斥锁）
 func (e *Engine) recordError(errorMsg string) {
 	e.errResultsMu.Lock()
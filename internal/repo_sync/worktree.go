@@ -0,0 +1,368 @@
+package repo_sync
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/leopardxu/repo-go/internal/event"
+	"github.com/leopardxu/repo-go/internal/git"
+	"github.com/leopardxu/repo-go/internal/lock"
+	"github.com/leopardxu/repo-go/internal/manifest"
+	"github.com/leopardxu/repo-go/internal/project"
+)
+
+// sharedRepo 记录本次同步中一个共享仓库的状态
+type sharedRepo struct {
+	mu      sync.Mutex      // 串行化本进程中对仓库的获取和 worktree 操作
+	fetched map[string]bool // 本次同步已获取过的远程，同名的其他路径不再重复获取
+}
+
+// isWorktree 判断客户端是否使用 git worktree 检出项目（repo init --worktree）
+func (e *Engine) isWorktree() bool {
+	c := e.options.Config
+	return c != nil && c.Worktree && !c.Mirror && !c.Archive
+}
+
+// worktreeProject 同步工作树模式下的项目
+// 同名项目的所有路径共享 .repo/worktrees/<name>.git，每次同步只获取一次；
+// 路径不存在时通过 git worktree add 创建，之后与普通项目一样进行本地更新
+func (e *Engine) worktreeProject(p *project.Project) error {
+	shared := project.SharedGitDir(e.repoRoot, p.Name)
+
+	exists, err := e.projectExists(p)
+	if err != nil {
+		return fmt.Errorf("检查项目 %s 失败: %w", p.Name, err)
+	}
+	if exists && !samePath(git.CommonDir(p.Worktree), shared) {
+		// 切换到工作树模式之前克隆的项目仍是独立仓库，按普通项目获取
+		e.logger.Debug("项目 %s 是独立的 git 仓库，不使用共享仓库", p.Name)
+		if !e.options.LocalOnly {
+			if err := e.fetchProject(p); err != nil {
+				return err
+			}
+		}
+	} else if err := e.syncSharedRepo(p, shared); err != nil {
+		return err
+	}
+
+	if e.options.NetworkOnly {
+		return nil
+	}
+	if !exists {
+		if err := e.addWorktree(p, shared); err != nil {
+			return err
+		}
+	}
+
+	if err := e.checkoutProject(p); err != nil {
+		return err
+	}
+	if err := e.processLinkAndCopyFiles(p); err != nil {
+		e.logger.Error("项目 %s 更新后处理 linkfile 和 copyfile 失败: %v", p.Name, err)
+		return &SyncError{
+			ProjectName: p.Name,
+			Phase:       "link_copy_files_after_update",
+			Err:         err,
+			Timestamp:   time.Now(),
+		}
+	}
+	if err := e.updateSubmodules(p); err != nil {
+		e.logger.Error("项目 %s 更新 submodule 失败: %v", p.Name, err)
+		// submodule 更新失败不阻断整个同步流程，只记录错误
+		if !e.options.Quiet {
+			e.logger.Warn("跳过项目 %s 的 submodule 更新", p.Name)
+		}
+	}
+	return nil
+}
+
+// lockShared 获取共享仓库的锁，先在本进程内互斥，再用文件锁防止其他 repo 进程同时操作
+func (e *Engine) lockShared(p *project.Project, shared string) (*sharedRepo, func(), error) {
+	v, _ := e.sharedRepos.LoadOrStore(shared, &sharedRepo{})
+	r := v.(*sharedRepo)
+	r.mu.Lock()
+
+	var l *lock.Lock
+	if e.repoRoot != "" {
+		rel, err := filepath.Rel(e.repoRoot, shared)
+		if err != nil {
+			rel = shared
+		}
+		l, err = lock.Acquire(e.lockContext(), lock.ProjectPath(e.repoRoot, rel), lock.Exclusive)
+		if err != nil {
+			r.mu.Unlock()
+			return nil, nil, &SyncError{ProjectName: p.Name, Phase: "worktree", Err: err, Timestamp: time.Now()}
+		}
+	}
+	return r, func() {
+		l.Release()
+		r.mu.Unlock()
+	}, nil
+}
+
+// syncSharedRepo 确保项目的共享仓库存在，并在本次同步中获取一次
+func (e *Engine) syncSharedRepo(p *project.Project, shared string) (err error) {
+	r, unlock, err := e.lockShared(p, shared)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	p.RemoteURL = e.resolveRemoteURL(p)
+	remoteURL := projectRepoURL(p)
+	if _, err := os.Stat(shared); os.IsNotExist(err) {
+		if e.options.LocalOnly {
+			return &SyncError{
+				ProjectName: p.Name,
+				Phase:       "worktree",
+				Err:         fmt.Errorf("shared repository %s does not exist, run repo sync without --local-only first", shared),
+				Timestamp:   time.Now(),
+			}
+		}
+		if err := e.initSharedRepo(p, shared); err != nil {
+			return err
+		}
+	}
+	if err := e.configureSharedRemote(p, shared, remoteURL); err != nil {
+		return err
+	}
+	remote := sharedRemote(p)
+	if e.options.LocalOnly || r.fetched[remote] {
+		return nil
+	}
+
+	ev := startSyncEvent(p, event.TaskSyncNetwork)
+	defer func() { finishSyncEvent(ev, p, err) }()
+	if err := e.fetchShared(p, shared, remoteURL); err != nil {
+		return err
+	}
+	if r.fetched == nil {
+		r.fetched = make(map[string]bool)
+	}
+	r.fetched[remote] = true
+	return nil
+}
+
+// sharedRemote 返回项目在共享仓库中使用的远程名称
+func sharedRemote(p *project.Project) string {
+	if p.RemoteName == "" {
+		return "origin"
+	}
+	return p.RemoteName
+}
+
+// initSharedRepo 创建共享裸仓库
+func (e *Engine) initSharedRepo(p *project.Project, shared string) error {
+	if !e.options.Quiet {
+		e.logger.Info("创建共享仓库: %s", p.Name)
+	}
+	if err := e.runShared(p, "", "init", "--quiet", "--bare", shared); err != nil {
+		os.RemoveAll(shared)
+		return err
+	}
+	return nil
+}
+
+// configureSharedRemote 在共享仓库中配置项目的远程，远程分支获取到 refs/remotes/<remote>/ 下，
+// 这样 repo start 等命令创建的本地分支不会与远程分支冲突
+// 同名项目的各个路径可以使用不同的远程，但同一远程名称必须指向同一地址
+func (e *Engine) configureSharedRemote(p *project.Project, shared, remoteURL string) error {
+	remote := sharedRemote(p)
+	cmd := git.Command(e.ctx, shared, "config", "--get", "remote."+remote+".url")
+	out, err := cmd.Output()
+	if err == nil {
+		if current := strings.TrimSpace(string(out)); current != remoteURL {
+			return &SyncError{
+				ProjectName: p.Name,
+				Phase:       "worktree",
+				Err: fmt.Errorf("remote %s of shared repository %s is %s, but project path %s uses %s",
+					remote, shared, current, p.Path, remoteURL),
+				Timestamp: time.Now(),
+			}
+		}
+		return nil
+	}
+	if e.options.LocalOnly {
+		return nil
+	}
+	if err := e.runShared(p, shared, "config", "remote."+remote+".url", remoteURL); err != nil {
+		return err
+	}
+	return e.runShared(p, shared, "config", "remote."+remote+".fetch", "+refs/heads/*:refs/remotes/"+remote+"/*")
+}
+
+// runShared 在 dir 中执行配置共享仓库的 git 命令
+func (e *Engine) runShared(p *project.Project, dir string, args ...string) error {
+	var stderr bytes.Buffer
+	cmd := git.Command(e.ctx, dir, args...)
+	cmd.Stderr = &stderr
+	if err := git.Run(cmd); err != nil {
+		return &SyncError{ProjectName: p.Name, Phase: "worktree", Err: err, Output: stderr.String(), Timestamp: time.Now()}
+	}
+	return nil
+}
+
+// fetchShared 获取共享仓库的远程，失败时重试
+func (e *Engine) fetchShared(p *project.Project, shared, remoteURL string) error {
+	remote := sharedRemote(p)
+	args := []string{"-C", shared, "fetch"}
+	if e.options.Tags {
+		args = append(args, "--tags")
+	}
+	if e.options.Config.Depth > 0 {
+		args = append(args, "--depth", strconv.Itoa(e.options.Config.Depth))
+	}
+	if e.options.Quiet {
+		args = append(args, "--quiet")
+	}
+	args = append(args, remote)
+
+	// 添加重试机制
+	const maxRetries = 3
+	var stderr bytes.Buffer
+	for retryCount := 0; ; retryCount++ {
+		if retryCount > 0 {
+			retryDelay := time.Duration(retryCount) * 2 * time.Second
+			e.logger.Info("正在重试获取项目 %s (第%d 次尝试，将在%v 后重试)",
+				p.Name, retryCount, retryDelay)
+			if err := sleepContext(e.ctx, retryDelay); err != nil {
+				return &SyncError{ProjectName: p.Name, Phase: "fetch", Err: err, Timestamp: time.Now()}
+			}
+			stderr.Reset()
+		}
+
+		// 执行 git fetch，占用远程主机的一个并发名额
+		slot, err := e.acquireHost(p, remoteURL)
+		if err != nil {
+			return &SyncError{ProjectName: p.Name, Phase: "fetch", Err: err, Timestamp: time.Now()}
+		}
+		started := time.Now()
+		cmd := git.Command(e.ctx, "", args...)
+		cmd.Stderr = &stderr
+		err = git.Run(cmd)
+		slot.Release(isThrottled(err, stderr.String()))
+		if err == nil {
+			return nil
+		}
+		if err := e.interrupted(args, started); err != nil {
+			return &SyncError{ProjectName: p.Name, Phase: "fetch", Err: err, Timestamp: time.Now()}
+		}
+		if retryCount == maxRetries {
+			return &SyncError{
+				ProjectName: p.Name,
+				Phase:       "fetch",
+				Err:         err,
+				Output:      stderr.String(),
+				Timestamp:   time.Now(),
+				RetryCount:  retryCount,
+			}
+		}
+	}
+}
+
+// addWorktree 在共享仓库中为项目路径创建工作区，分离头指针在清单修订版本
+func (e *Engine) addWorktree(p *project.Project, shared string) error {
+	_, unlock, err := e.lockShared(p, shared)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if !e.options.Quiet {
+		e.logger.Info("创建工作树: %s", p.Name)
+	}
+	// 目录被删除或移动后留下的记录会阻止在原路径重新创建工作区
+	if err := git.PruneWorktrees(e.ctx, shared); err != nil {
+		e.logger.Debug("清理共享仓库 %s 的工作树记录失败: %v", shared, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(p.Worktree), 0755); err != nil {
+		return fmt.Errorf("创建项目目录失败 %s: %w", p.Name, err)
+	}
+	if err := git.AddWorktree(e.ctx, shared, p.Worktree, p.RevisionRef()); err != nil {
+		return &SyncError{ProjectName: p.Name, Phase: "worktree", Err: err, Timestamp: time.Now()}
+	}
+	return nil
+}
+
+// removeObsoleteWorktrees 删除清单中已不存在的路径对应的工作区，并清除目录已被删除或移动的记录
+// 有本地修改或未跟踪文件的工作区只在 --force-remove-dirty 时删除
+func (e *Engine) removeObsoleteWorktrees() {
+	if e.manifest == nil || e.repoRoot == "" {
+		return
+	}
+	root, err := filepath.Abs(e.repoRoot)
+	if err != nil {
+		return
+	}
+	if resolved, err := filepath.EvalSymlinks(root); err == nil {
+		root = resolved
+	}
+	// 按组过滤的清单中没有其他组的项目，使用完整的清单判断路径是否已被删除
+	m := e.manifest
+	parser := manifest.NewParser()
+	parser.SetSilentMode(true)
+	if full, err := parser.ParseFromFile(filepath.Join(e.repoRoot, ".repo", "manifest.xml"), nil); err == nil {
+		m = full
+	}
+	paths := make(map[string]bool)
+	for _, p := range m.Projects {
+		path := p.Path
+		if path == "" {
+			path = p.Name
+		}
+		paths[filepath.Join(root, path)] = true
+	}
+
+	for _, shared := range project.SharedGitDirs(e.repoRoot) {
+		if err := git.PruneWorktrees(e.ctx, shared); err != nil {
+			e.logger.Warn("清理共享仓库 %s 的工作树记录失败: %v", shared, err)
+			continue
+		}
+		worktrees, err := git.ListWorktrees(e.ctx, shared)
+		if err != nil {
+			e.logger.Warn("列出共享仓库 %s 的工作树失败: %v", shared, err)
+			continue
+		}
+		for _, wt := range worktrees {
+			path := filepath.Clean(wt.Path)
+			if wt.Bare || paths[path] {
+				continue
+			}
+			// 只处理客户端中的工作区
+			if rel, err := filepath.Rel(root, path); err != nil || !filepath.IsLocal(rel) {
+				continue
+			}
+			if err := git.RemoveWorktree(e.ctx, shared, path, e.options.ForceRemoveDirty); err != nil {
+				e.logger.Warn("工作树 %s 已不在清单中，但无法删除（有本地修改时使用 --force-remove-dirty）: %v", path, err)
+				continue
+			}
+			e.logger.Info("已删除不在清单中的工作树: %s", path)
+		}
+	}
+}
+
+// samePath 判断两个路径是否指向同一位置
+func samePath(a, b string) bool {
+	if a == "" || b == "" {
+		return false
+	}
+	if ra, err := filepath.Abs(a); err == nil {
+		a = ra
+	}
+	if rb, err := filepath.Abs(b); err == nil {
+		b = rb
+	}
+	if ra, err := filepath.EvalSymlinks(a); err == nil {
+		a = ra
+	}
+	if rb, err := filepath.EvalSymlinks(b); err == nil {
+		b = rb
+	}
+	return a == b
+}
@@ -0,0 +1,177 @@
+package repo_sync
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/leopardxu/repo-go/internal/config"
+	"github.com/leopardxu/repo-go/internal/git"
+	"github.com/leopardxu/repo-go/internal/lock"
+	"github.com/leopardxu/repo-go/internal/logger"
+	"github.com/leopardxu/repo-go/internal/manifest"
+	"github.com/leopardxu/repo-go/internal/project"
+	"github.com/leopardxu/repo-go/internal/testutil"
+)
+
+// newUpstream 在 base/<name> 创建一个有一个提交的上游仓库，返回提交
+func newUpstream(t *testing.T, base, name string) string {
+	t.Helper()
+	dir := filepath.Join(base, name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	testutil.Git(t, dir, "init", "--quiet", "-b", "main")
+	return testutil.Commit(t, dir, "f", base+"\n", "base")
+}
+
+// newWorktreeEngine 创建工作树模式的同步引擎，客户端根目录为 root
+func newWorktreeEngine(t *testing.T, root string, paths ...string) *Engine {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Join(root, ".repo"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	log := logger.NewDefaultLogger()
+	log.SetLevel(logger.LogLevelError)
+	return &Engine{
+		ctx:      context.Background(),
+		options:  &Options{Quiet: true, Config: &config.Config{Worktree: true}},
+		logger:   log,
+		repoRoot: root,
+		manifest: worktreeManifest(paths...),
+	}
+}
+
+// worktreeManifest 返回 paths 都使用项目名 p 的清单
+func worktreeManifest(paths ...string) *manifest.Manifest {
+	m := &manifest.Manifest{}
+	for _, path := range paths {
+		m.Projects = append(m.Projects, manifest.Project{Name: "p", Path: path})
+	}
+	return m
+}
+
+func newWorktreeProject(root, path, remote, remoteURL string) *project.Project {
+	worktree := filepath.Join(root, path)
+	return &project.Project{
+		Name:       "p",
+		Path:       path,
+		Worktree:   worktree,
+		RemoteName: remote,
+		RemoteURL:  remoteURL,
+		Revision:   "main",
+		GitRepo:    git.NewRepository(worktree, git.NewRunner()),
+	}
+}
+
+func TestWorktreeProject(t *testing.T) {
+	testutil.SetIdentity(t)
+	remotes := t.TempDir()
+	commit := newUpstream(t, remotes, "p")
+	root := t.TempDir()
+	e := newWorktreeEngine(t, root, "a", "b")
+	shared := project.SharedGitDir(root, "p")
+
+	// 两个路径使用同名项目，共享同一个仓库
+	for _, path := range []string{"a", "b"} {
+		p := newWorktreeProject(root, path, "origin", remotes)
+		if err := e.worktreeProject(p); err != nil {
+			t.Fatalf("worktreeProject(%s): %v", path, err)
+		}
+		if !samePath(git.CommonDir(p.Worktree), shared) {
+			t.Errorf("%s uses %s, want the shared repository %s", path, git.CommonDir(p.Worktree), shared)
+		}
+		if got := testutil.Git(t, p.Worktree, "rev-parse", "HEAD"); got != commit {
+			t.Errorf("%s HEAD = %s, want %s", path, got, commit)
+		}
+	}
+	v, _ := e.sharedRepos.Load(shared)
+	if r := v.(*sharedRepo); len(r.fetched) != 1 || !r.fetched["origin"] {
+		t.Errorf("shared repository fetched remotes = %v, want origin once", r.fetched)
+	}
+
+	// 重新同步已存在的工作区
+	if err := e.worktreeProject(newWorktreeProject(root, "a", "origin", remotes)); err != nil {
+		t.Fatalf("worktreeProject(a) again: %v", err)
+	}
+
+	// 从清单中删除 b 后同步删除它的工作区
+	e.manifest = worktreeManifest("a")
+	e.removeObsoleteWorktrees()
+	if _, err := os.Stat(filepath.Join(root, "b")); !os.IsNotExist(err) {
+		t.Errorf("worktree b was not removed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "a", "f")); err != nil {
+		t.Errorf("worktree a was removed: %v", err)
+	}
+	worktrees, err := git.ListWorktrees(context.Background(), shared)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, wt := range worktrees {
+		if samePath(wt.Path, filepath.Join(root, "b")) {
+			t.Errorf("worktree b is still registered in %s", shared)
+		}
+	}
+}
+
+func TestWorktreeProjectRemotes(t *testing.T) {
+	testutil.SetIdentity(t)
+	remotes := t.TempDir()
+	newUpstream(t, remotes, "p")
+	mirror := t.TempDir()
+	mirrorCommit := newUpstream(t, mirror, "p")
+	root := t.TempDir()
+	e := newWorktreeEngine(t, root, "a", "b", "c")
+	shared := project.SharedGitDir(root, "p")
+
+	if err := e.worktreeProject(newWorktreeProject(root, "a", "origin", remotes)); err != nil {
+		t.Fatalf("worktreeProject(a): %v", err)
+	}
+
+	// 同名项目的另一个路径使用不同的远程，远程添加到共享仓库中
+	b := newWorktreeProject(root, "b", "mirror", mirror)
+	if err := e.worktreeProject(b); err != nil {
+		t.Fatalf("worktreeProject(b): %v", err)
+	}
+	if got := testutil.Git(t, shared, "config", "remote.mirror.url"); got != filepath.Join(mirror, "p") {
+		t.Errorf("remote.mirror.url = %s", got)
+	}
+	if got := testutil.Git(t, b.Worktree, "rev-parse", "HEAD"); got != mirrorCommit {
+		t.Errorf("b HEAD = %s, want %s from the mirror remote", got, mirrorCommit)
+	}
+
+	// 同一远程名称指向不同地址时报错
+	err := e.worktreeProject(newWorktreeProject(root, "c", "origin", mirror))
+	if err == nil || !strings.Contains(err.Error(), "remote origin of shared repository") {
+		t.Fatalf("worktreeProject(c) error = %v, want a remote mismatch", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "c")); !os.IsNotExist(err) {
+		t.Errorf("worktree c was created: %v", err)
+	}
+}
+
+func TestLockSharedWithoutContext(t *testing.T) {
+	t.Setenv(lock.ParentsEnv, "")
+	root := t.TempDir()
+	e := newWorktreeEngine(t, root, "a")
+	e.ctx = nil
+	shared := project.SharedGitDir(root, "p")
+	p := newWorktreeProject(root, "a", "origin", "")
+
+	// 另一个持有者释放锁之前需要等待，没有设置 context 的引擎与 lockProject 一样一直等待
+	held, err := lock.Acquire(context.Background(), lock.ProjectPath(root, filepath.Join(".repo", "worktrees", "p.git")), lock.Exclusive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.AfterFunc(100*time.Millisecond, func() { held.Release() })
+
+	_, unlock, err := e.lockShared(p, shared)
+	if err != nil {
+		t.Fatalf("lockShared() error = %v", err)
+	}
+	unlock()
+}
@@ -0,0 +1,64 @@
+package review
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Gerrit 通过推送到 refs/for/<branch> 创建评审
+type Gerrit struct{}
+
+// Type 返回后端类型
+func (g *Gerrit) Type() Type {
+	return TypeGerrit
+}
+
+// PushArgs 构建推送到 Gerrit 的 git push 参数
+// 格式: git push <remote> HEAD:refs/for/<branch>%wip,topic=xxx
+func (g *Gerrit) PushArgs(req *Request) []string {
+	pushArgs := []string{"push"}
+	if req.NoVerify {
+		pushArgs = append(pushArgs, "--no-verify")
+	}
+
+	// 构建 Gerrit push options（使用 % 分隔符附加到 refspec）
+	var gerritOptions []string
+	if req.Wip {
+		gerritOptions = append(gerritOptions, "wip")
+	}
+	if req.Draft {
+		gerritOptions = append(gerritOptions, "draft")
+	}
+	if req.Private {
+		gerritOptions = append(gerritOptions, "private")
+	}
+	if req.Topic != "" {
+		gerritOptions = append(gerritOptions, "topic="+req.Topic)
+	}
+	for _, tag := range req.Hashtags {
+		gerritOptions = append(gerritOptions, "hashtag="+tag)
+	}
+	for _, label := range req.Labels {
+		gerritOptions = append(gerritOptions, "label="+label)
+	}
+	for _, reviewer := range req.Reviewers {
+		gerritOptions = append(gerritOptions, "r="+reviewer)
+	}
+	for _, cc := range req.CC {
+		gerritOptions = append(gerritOptions, "cc="+cc)
+	}
+	gerritOptions = append(gerritOptions, req.PushOptions...)
+
+	refspec := fmt.Sprintf("HEAD:refs/for/%s", req.DestBranch)
+	if len(gerritOptions) > 0 {
+		refspec = refspec + "%" + strings.Join(gerritOptions, ",")
+	}
+
+	return append(pushArgs, req.Remote, refspec)
+}
+
+// Publish Gerrit 在推送时已创建评审，无需额外操作
+func (g *Gerrit) Publish(ctx context.Context, req *Request) (*Result, error) {
+	return nil, nil
+}
@@ -0,0 +1,116 @@
+package review
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// GitHub 推送源分支并通过 REST API 创建或更新 pull request
+//
+// 选项映射: --topic 作为源分支名，--draft/--wip 创建草稿 PR，
+// --label/--hashtag 转为 issue 标签，--reviewers 请求评审。
+// GitHub 没有私有变更和抄送的概念，--private 与 --cc 会被忽略。
+type GitHub struct {
+	api *apiClient
+}
+
+// githubPullRequest GitHub pull request 的必要字段
+type githubPullRequest struct {
+	Number  int    `json:"number"`
+	HTMLURL string `json:"html_url"`
+}
+
+// NewGitHub 创建 GitHub 后端，reviewURL 为 https://github.com 或 GitHub Enterprise 实例地址
+func NewGitHub(reviewURL, token string, client *http.Client) *GitHub {
+	baseURL := strings.TrimRight(reviewURL, "/") + "/api/v3"
+	if u, err := url.Parse(reviewURL); err == nil && strings.EqualFold(u.Host, "github.com") {
+		baseURL = "https://api.github.com"
+	}
+
+	headers := map[string]string{"Accept": "application/vnd.github+json"}
+	if token != "" {
+		headers["Authorization"] = "Bearer " + token
+	}
+	return &GitHub{api: &apiClient{baseURL: baseURL, headers: headers, client: client}}
+}
+
+// Type 返回后端类型
+func (g *GitHub) Type() Type {
+	return TypeGitHub
+}
+
+// PushArgs 推送HEAD到源分支
+func (g *GitHub) PushArgs(req *Request) []string {
+	return branchPushArgs(req)
+}
+
+// Publish 创建 pull request，源分支已有打开的 PR 时更新它
+func (g *GitHub) Publish(ctx context.Context, req *Request) (*Result, error) {
+	owner, repo, err := splitGitHubProject(req.Project)
+	if err != nil {
+		return nil, err
+	}
+	repoPath := fmt.Sprintf("/repos/%s/%s", owner, repo)
+
+	query := url.Values{}
+	query.Set("state", "open")
+	query.Set("head", owner+":"+req.SourceBranch())
+	query.Set("base", req.DestBranch)
+	var existing []githubPullRequest
+	if err := g.api.do(ctx, http.MethodGet, repoPath+"/pulls?"+query.Encode(), nil, &existing); err != nil {
+		return nil, err
+	}
+
+	var pr githubPullRequest
+	result := &Result{}
+	if len(existing) > 0 {
+		body := map[string]interface{}{"title": req.Title, "body": req.Description}
+		path := fmt.Sprintf("%s/pulls/%d", repoPath, existing[0].Number)
+		if err := g.api.do(ctx, http.MethodPatch, path, body, &pr); err != nil {
+			return nil, err
+		}
+	} else {
+		body := map[string]interface{}{
+			"title": req.Title,
+			"body":  req.Description,
+			"head":  req.SourceBranch(),
+			"base":  req.DestBranch,
+			"draft": req.Draft || req.Wip,
+		}
+		if err := g.api.do(ctx, http.MethodPost, repoPath+"/pulls", body, &pr); err != nil {
+			return nil, err
+		}
+		result.Created = true
+	}
+	result.Number = pr.Number
+	result.URL = pr.HTMLURL
+
+	if len(req.Reviewers) > 0 {
+		path := fmt.Sprintf("%s/pulls/%d/requested_reviewers", repoPath, pr.Number)
+		if err := g.api.do(ctx, http.MethodPost, path, map[string]interface{}{"reviewers": req.Reviewers}, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	labels := append(append([]string{}, req.Labels...), req.Hashtags...)
+	if len(labels) > 0 {
+		path := fmt.Sprintf("%s/issues/%d/labels", repoPath, pr.Number)
+		if err := g.api.do(ctx, http.MethodPost, path, map[string]interface{}{"labels": labels}, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// splitGitHubProject 将清单中的项目名拆分为 owner 和 repo
+func splitGitHubProject(name string) (string, string, error) {
+	parts := strings.Split(strings.TrimSuffix(strings.Trim(name, "/"), ".git"), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("github project name %q must be in the form owner/repo", name)
+	}
+	return parts[0], parts[1], nil
+}
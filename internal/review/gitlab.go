@@ -0,0 +1,124 @@
+package review
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// GitLab 推送源分支并通过 REST API 创建或更新 merge request
+//
+// 选项映射: --topic 作为源分支名，--draft/--wip 转为 "Draft:" 标题前缀，
+// --label/--hashtag 转为 MR 标签，--reviewers 按用户名解析为 reviewer。
+// GitLab 没有私有变更和抄送的概念，--private 与 --cc 会被忽略。
+type GitLab struct {
+	api *apiClient
+}
+
+// gitlabMergeRequest GitLab merge request 的必要字段
+type gitlabMergeRequest struct {
+	IID    int    `json:"iid"`
+	WebURL string `json:"web_url"`
+}
+
+// NewGitLab 创建 GitLab 后端，reviewURL 为实例地址，例如 https://gitlab.example.com
+func NewGitLab(reviewURL, token string, client *http.Client) *GitLab {
+	headers := map[string]string{}
+	if token != "" {
+		headers["PRIVATE-TOKEN"] = token
+	}
+	return &GitLab{api: &apiClient{
+		baseURL: strings.TrimRight(reviewURL, "/") + "/api/v4",
+		headers: headers,
+		client:  client,
+	}}
+}
+
+// Type 返回后端类型
+func (g *GitLab) Type() Type {
+	return TypeGitLab
+}
+
+// PushArgs 推送HEAD到源分支
+func (g *GitLab) PushArgs(req *Request) []string {
+	return branchPushArgs(req)
+}
+
+// Publish 创建 merge request，源分支已有打开的 MR 时更新它
+func (g *GitLab) Publish(ctx context.Context, req *Request) (*Result, error) {
+	projectID := url.PathEscape(req.Project)
+
+	title := req.Title
+	if req.Draft || req.Wip {
+		title = "Draft: " + title
+	}
+	labels := strings.Join(append(append([]string{}, req.Labels...), req.Hashtags...), ",")
+
+	reviewerIDs, err := g.resolveUsers(ctx, req.Reviewers)
+	if err != nil {
+		return nil, err
+	}
+
+	query := url.Values{}
+	query.Set("state", "opened")
+	query.Set("source_branch", req.SourceBranch())
+	query.Set("target_branch", req.DestBranch)
+	var existing []gitlabMergeRequest
+	if err := g.api.do(ctx, http.MethodGet, "/projects/"+projectID+"/merge_requests?"+query.Encode(), nil, &existing); err != nil {
+		return nil, err
+	}
+
+	body := map[string]interface{}{
+		"title":       title,
+		"description": req.Description,
+	}
+	if len(reviewerIDs) > 0 {
+		body["reviewer_ids"] = reviewerIDs
+	}
+
+	var mr gitlabMergeRequest
+	if len(existing) > 0 {
+		// 更新时追加标签，避免覆盖评审过程中添加的标签
+		if labels != "" {
+			body["add_labels"] = labels
+		}
+		path := fmt.Sprintf("/projects/%s/merge_requests/%d", projectID, existing[0].IID)
+		if err := g.api.do(ctx, http.MethodPut, path, body, &mr); err != nil {
+			return nil, err
+		}
+		return &Result{Number: mr.IID, URL: mr.WebURL}, nil
+	}
+
+	body["source_branch"] = req.SourceBranch()
+	body["target_branch"] = req.DestBranch
+	body["remove_source_branch"] = true
+	if labels != "" {
+		body["labels"] = labels
+	}
+	if err := g.api.do(ctx, http.MethodPost, "/projects/"+projectID+"/merge_requests", body, &mr); err != nil {
+		return nil, err
+	}
+	return &Result{Number: mr.IID, URL: mr.WebURL, Created: true}, nil
+}
+
+// resolveUsers 将用户名解析为 GitLab 用户ID
+func (g *GitLab) resolveUsers(ctx context.Context, usernames []string) ([]int, error) {
+	var ids []int
+	for _, name := range usernames {
+		var users []struct {
+			ID int `json:"id"`
+		}
+		query := url.Values{}
+		query.Set("username", strings.TrimPrefix(name, "@"))
+		if err := g.api.do(ctx, http.MethodGet, "/users?"+query.Encode(), nil, &users); err != nil {
+			return nil, err
+		}
+		if len(users) == 0 {
+			return nil, fmt.Errorf("gitlab user %q not found", name)
+		}
+		ids = append(ids, users[0].ID)
+	}
+	return ids, nil
+}
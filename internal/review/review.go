@@ -0,0 +1,237 @@
+package review
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/leopardxu/repo-go/internal/manifest"
+)
+
+// Type 评审后端类型
+type Type string
+
+const (
+	TypeGerrit Type = "gerrit"
+	TypeGitLab Type = "gitlab"
+	TypeGitHub Type = "github"
+)
+
+// Request 描述一次上传评审的内容，与具体后端无关
+type Request struct {
+	Project     string   // 服务端项目名（清单中的name）
+	Remote      string   // git远程名
+	Branch      string   // 本地分支名
+	DestBranch  string   // 目标分支
+	Topic       string   // 主题
+	Title       string   // 标题（取自HEAD提交的subject）
+	Description string   // 描述（取自HEAD提交的正文）
+	Reviewers   []string // 评审人
+	CC          []string // 抄送
+	Labels      []string // 标签
+	Hashtags    []string // hashtag
+	PushOptions []string // 附加的推送选项
+	Draft       bool
+	Wip         bool
+	Private     bool
+	NoVerify    bool
+}
+
+// SourceBranch 返回推送到服务端的源分支名，指定了主题时使用主题名
+func (r *Request) SourceBranch() string {
+	if r.Topic != "" {
+		return r.Topic
+	}
+	return r.Branch
+}
+
+// Result 表示创建或更新的评审
+type Result struct {
+	Number  int
+	URL     string
+	Created bool // true表示新建，false表示更新已有评审
+}
+
+// Backend 评审后端接口
+type Backend interface {
+	// Type 返回后端类型
+	Type() Type
+	// PushArgs 返回推送变更所需的git push参数
+	PushArgs(req *Request) []string
+	// Publish 在推送完成后创建或更新评审，Gerrit等通过推送即可创建评审的后端返回nil
+	Publish(ctx context.Context, req *Request) (*Result, error)
+}
+
+// Options 创建后端的选项
+type Options struct {
+	Token        string       // API访问令牌，为空时从环境变量读取
+	HTTPClient   *http.Client // 为空时使用默认客户端
+	NoCertChecks bool         // 跳过证书验证
+}
+
+// APIError 表示评审服务REST API返回的错误
+type APIError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("review api error: %s %s returned %d: %s", e.Method, e.URL, e.StatusCode, e.Body)
+}
+
+// Detect 确定远程使用的评审后端类型
+// 优先使用 review-type 自定义属性，否则根据 review URL 的主机名判断，默认为 Gerrit
+func Detect(remote *manifest.Remote) Type {
+	if remote == nil {
+		return TypeGerrit
+	}
+	if reviewType, ok := remote.GetCustomAttr("review-type"); ok && reviewType != "" {
+		return Type(strings.ToLower(strings.TrimSpace(reviewType)))
+	}
+
+	host := remote.Review
+	if u, err := url.Parse(remote.Review); err == nil && u.Host != "" {
+		host = u.Host
+	}
+	host = strings.ToLower(host)
+	switch {
+	case strings.Contains(host, "gitlab"):
+		return TypeGitLab
+	case strings.Contains(host, "github"):
+		return TypeGitHub
+	default:
+		return TypeGerrit
+	}
+}
+
+// NewBackend 根据远程配置创建评审后端
+func NewBackend(remote *manifest.Remote, opts Options) (Backend, error) {
+	reviewType := Detect(remote)
+	if reviewType == TypeGerrit {
+		return &Gerrit{}, nil
+	}
+
+	if remote.Review == "" {
+		return nil, fmt.Errorf("remote %s uses review-type %s but has no review url", remote.Name, reviewType)
+	}
+
+	client := opts.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 60 * time.Second}
+		if opts.NoCertChecks {
+			client.Transport = &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+		}
+	}
+
+	switch reviewType {
+	case TypeGitLab:
+		token := opts.Token
+		if token == "" {
+			token = os.Getenv("GITLAB_TOKEN")
+		}
+		return NewGitLab(remote.Review, token, client), nil
+	case TypeGitHub:
+		token := opts.Token
+		if token == "" {
+			token = os.Getenv("GITHUB_TOKEN")
+		}
+		return NewGitHub(remote.Review, token, client), nil
+	default:
+		return nil, fmt.Errorf("remote %s: unsupported review-type %q", remote.Name, reviewType)
+	}
+}
+
+// Validate 检查后端能否处理请求
+// merge/pull request 从源分支合入目标分支，两者相同时推送会直接更新目标分支而不经过评审
+func Validate(backend Backend, req *Request) error {
+	if backend.Type() == TypeGerrit {
+		return nil
+	}
+	if req.DestBranch == "" {
+		return fmt.Errorf("no destination branch for %s, set dest-branch in the manifest or use --destination", req.Project)
+	}
+	if req.SourceBranch() == req.DestBranch {
+		return fmt.Errorf("source branch %s is the destination branch of the %s request, use --topic or upload from a topic branch", req.DestBranch, backend.Type())
+	}
+	return nil
+}
+
+// branchPushArgs 构建将HEAD推送到源分支的参数
+// 只有按主题生成的评审分支由用户独占，允许强制更新；推送本地分支同名的远程分支时不覆盖其他提交
+func branchPushArgs(req *Request) []string {
+	args := []string{"push"}
+	if req.NoVerify {
+		args = append(args, "--no-verify")
+	}
+	for _, option := range req.PushOptions {
+		args = append(args, "--push-option="+option)
+	}
+	refspec := "HEAD:refs/heads/" + req.SourceBranch()
+	if req.Topic != "" {
+		refspec = "+" + refspec
+	}
+	return append(args, req.Remote, refspec)
+}
+
+// apiClient 封装JSON REST请求
+type apiClient struct {
+	baseURL string
+	headers map[string]string
+	client  *http.Client
+}
+
+// do 发送JSON请求，out不为nil时解析响应
+func (c *apiClient) do(ctx context.Context, method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	endpoint := c.baseURL + path
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, reader)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", "gogo-repo/1.0")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for key, value := range c.headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", method, endpoint, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 10<<20))
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &APIError{Method: method, URL: endpoint, StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(data))}
+	}
+	if out != nil && len(data) > 0 {
+		if err := json.Unmarshal(data, out); err != nil {
+			return fmt.Errorf("failed to decode response from %s: %w", endpoint, err)
+		}
+	}
+	return nil
+}
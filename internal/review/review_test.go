@@ -0,0 +1,284 @@
+package review
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/leopardxu/repo-go/internal/manifest"
+)
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name   string
+		remote *manifest.Remote
+		want   Type
+	}{
+		{"nil remote", nil, TypeGerrit},
+		{"no review url", &manifest.Remote{Name: "origin"}, TypeGerrit},
+		{"gerrit url", &manifest.Remote{Review: "https://review.example.com"}, TypeGerrit},
+		{"gitlab url", &manifest.Remote{Review: "https://gitlab.example.com"}, TypeGitLab},
+		{"github url", &manifest.Remote{Review: "https://github.com"}, TypeGitHub},
+		{
+			"attribute overrides url",
+			&manifest.Remote{Review: "https://code.example.com", CustomAttrs: map[string]string{"review-type": "GitLab"}},
+			TypeGitLab,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Detect(tt.remote); got != tt.want {
+				t.Errorf("Detect() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGerritPushArgs(t *testing.T) {
+	req := &Request{
+		Remote:     "origin",
+		DestBranch: "main",
+		Topic:      "feature",
+		Reviewers:  []string{"alice", "bob"},
+		Labels:     []string{"Verified+1"},
+		Wip:        true,
+		NoVerify:   true,
+	}
+	want := []string{"push", "--no-verify", "origin", "HEAD:refs/for/main%wip,topic=feature,label=Verified+1,r=alice,r=bob"}
+	if got := (&Gerrit{}).PushArgs(req); !reflect.DeepEqual(got, want) {
+		t.Errorf("PushArgs() = %v, want %v", got, want)
+	}
+}
+
+func TestBranchPushArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		req  *Request
+		want []string
+	}{
+		// 按主题生成的评审分支由用户独占，可以强制更新
+		{"topic", &Request{Remote: "origin", Branch: "local", DestBranch: "main", Topic: "feature"}, []string{"push", "origin", "+HEAD:refs/heads/feature"}},
+		{"local branch", &Request{Remote: "origin", Branch: "local", DestBranch: "main", NoVerify: true}, []string{"push", "--no-verify", "origin", "HEAD:refs/heads/local"}},
+	}
+	for _, tt := range tests {
+		if got := branchPushArgs(tt.req); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: branchPushArgs() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	gitlab := NewGitLab("https://gitlab.example.com", "", nil)
+	tests := []struct {
+		name    string
+		backend Backend
+		req     *Request
+		wantErr bool
+	}{
+		{"topic branch", gitlab, &Request{Branch: "topic", DestBranch: "main"}, false},
+		{"topic", gitlab, &Request{Branch: "main", DestBranch: "main", Topic: "feature"}, false},
+		{"same branch", gitlab, &Request{Branch: "main", DestBranch: "main"}, true},
+		{"topic is destination", NewGitHub("https://github.com", "", nil), &Request{Branch: "local", DestBranch: "main", Topic: "main"}, true},
+		{"no destination", gitlab, &Request{Branch: "topic"}, true},
+		// Gerrit 推送到 refs/for/<branch>，不会更新目标分支
+		{"gerrit", &Gerrit{}, &Request{Branch: "main", DestBranch: "main"}, false},
+	}
+	for _, tt := range tests {
+		if err := Validate(tt.backend, tt.req); (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+// fakeServer 记录请求并以预设的处理函数响应
+type fakeServer struct {
+	mu       sync.Mutex
+	requests []string
+	bodies   map[string]map[string]interface{}
+}
+
+func (f *fakeServer) record(r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	key := r.Method + " " + r.URL.Path
+	f.requests = append(f.requests, key)
+	if r.Body != nil {
+		var body map[string]interface{}
+		if json.NewDecoder(r.Body).Decode(&body) == nil {
+			if f.bodies == nil {
+				f.bodies = make(map[string]map[string]interface{})
+			}
+			f.bodies[key] = body
+		}
+	}
+}
+
+func TestGitLabPublish(t *testing.T) {
+	for _, existing := range []bool{false, true} {
+		fake := &fakeServer{}
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fake.record(r)
+			if r.Header.Get("PRIVATE-TOKEN") != "secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			switch {
+			case r.Method == http.MethodGet && r.URL.Path == "/api/v4/users":
+				w.Write([]byte(`[{"id": 42}]`))
+			case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/merge_requests"):
+				if r.URL.Query().Get("source_branch") != "feature" || r.URL.Query().Get("target_branch") != "main" {
+					t.Errorf("unexpected query: %s", r.URL.RawQuery)
+				}
+				if existing {
+					w.Write([]byte(`[{"iid": 7, "web_url": "http://mr/7"}]`))
+				} else {
+					w.Write([]byte(`[]`))
+				}
+			case r.Method == http.MethodPut:
+				w.Write([]byte(`{"iid": 7, "web_url": "http://mr/7"}`))
+			case r.Method == http.MethodPost:
+				w.Write([]byte(`{"iid": 8, "web_url": "http://mr/8"}`))
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+
+		remote := &manifest.Remote{Name: "origin", Review: srv.URL, CustomAttrs: map[string]string{"review-type": "gitlab"}}
+		backend, err := NewBackend(remote, Options{Token: "secret"})
+		if err != nil {
+			t.Fatalf("NewBackend() error = %v", err)
+		}
+		req := &Request{
+			Project:    "group/app",
+			Remote:     "origin",
+			Branch:     "local",
+			DestBranch: "main",
+			Topic:      "feature",
+			Title:      "Add feature",
+			Reviewers:  []string{"alice"},
+			Labels:     []string{"backend"},
+			Draft:      true,
+		}
+
+		if got := backend.PushArgs(req); !reflect.DeepEqual(got, []string{"push", "origin", "+HEAD:refs/heads/feature"}) {
+			t.Errorf("PushArgs() = %v", got)
+		}
+
+		result, err := backend.Publish(context.Background(), req)
+		srv.Close()
+		if err != nil {
+			t.Fatalf("Publish() error = %v", err)
+		}
+
+		if existing {
+			if result.Created || result.Number != 7 {
+				t.Errorf("Publish() = %+v, want update of !7", result)
+			}
+			body := fake.bodies["PUT /api/v4/projects/group/app/merge_requests/7"]
+			if body == nil || body["add_labels"] != "backend" {
+				t.Errorf("update body = %v, requests = %v", body, fake.requests)
+			}
+			continue
+		}
+
+		if !result.Created || result.Number != 8 || result.URL != "http://mr/8" {
+			t.Errorf("Publish() = %+v, want created !8", result)
+		}
+		body := fake.bodies["POST /api/v4/projects/group/app/merge_requests"]
+		if body == nil {
+			t.Fatalf("merge request not created, requests = %v", fake.requests)
+		}
+		if body["title"] != "Draft: Add feature" || body["source_branch"] != "feature" || body["labels"] != "backend" {
+			t.Errorf("create body = %v", body)
+		}
+		if ids, ok := body["reviewer_ids"].([]interface{}); !ok || len(ids) != 1 || ids[0] != float64(42) {
+			t.Errorf("reviewer_ids = %v", body["reviewer_ids"])
+		}
+	}
+}
+
+func TestGitHubPublish(t *testing.T) {
+	fake := &fakeServer{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fake.record(r)
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/api/v3/repos/owner/app/pulls":
+			if r.URL.Query().Get("head") != "owner:topic-branch" {
+				t.Errorf("unexpected head: %s", r.URL.Query().Get("head"))
+			}
+			w.Write([]byte(`[]`))
+		case r.Method == http.MethodPost && r.URL.Path == "/api/v3/repos/owner/app/pulls":
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"number": 3, "html_url": "http://pr/3"}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/api/v3/repos/owner/app/pulls/3/requested_reviewers":
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/api/v3/repos/owner/app/issues/3/labels":
+			w.Write([]byte(`[]`))
+		default:
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			w.Write([]byte(`{"message": "unexpected"}`))
+		}
+	}))
+	defer srv.Close()
+
+	remote := &manifest.Remote{Name: "gh", Review: srv.URL, CustomAttrs: map[string]string{"review-type": "github"}}
+	backend, err := NewBackend(remote, Options{Token: "secret"})
+	if err != nil {
+		t.Fatalf("NewBackend() error = %v", err)
+	}
+	req := &Request{
+		Project:    "owner/app.git",
+		Remote:     "gh",
+		Branch:     "topic-branch",
+		DestBranch: "main",
+		Title:      "Fix bug",
+		Reviewers:  []string{"carol"},
+		Hashtags:   []string{"urgent"},
+		Wip:        true,
+	}
+
+	result, err := backend.Publish(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	if !result.Created || result.Number != 3 {
+		t.Errorf("Publish() = %+v, want created #3", result)
+	}
+
+	create := fake.bodies["POST /api/v3/repos/owner/app/pulls"]
+	if create["draft"] != true || create["head"] != "topic-branch" || create["base"] != "main" {
+		t.Errorf("create body = %v", create)
+	}
+	if reviewers := fake.bodies["POST /api/v3/repos/owner/app/pulls/3/requested_reviewers"]; reviewers == nil {
+		t.Errorf("reviewers not requested, requests = %v", fake.requests)
+	}
+	if labels := fake.bodies["POST /api/v3/repos/owner/app/issues/3/labels"]; labels == nil {
+		t.Errorf("labels not added, requests = %v", fake.requests)
+	}
+}
+
+func TestGitHubPublishAPIError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"message": "forbidden"}`))
+	}))
+	defer srv.Close()
+
+	backend := NewGitHub(srv.URL, "", srv.Client())
+	_, err := backend.Publish(context.Background(), &Request{Project: "owner/app", Branch: "b", DestBranch: "main"})
+	apiErr, ok := err.(*APIError)
+	if !ok || apiErr.StatusCode != http.StatusForbidden {
+		t.Fatalf("Publish() error = %v, want APIError 403", err)
+	}
+}
@@ -2,24 +2,49 @@ package ssh
 
 import (
 	"fmt"
+	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
 	"strings"
 	"sync"
+	"time"
 )
 
+// masterStartTimeout 是等待 ControlMaster 连接就绪的最长时间
+const masterStartTimeout = 10 * time.Second
+
 // Proxy 表示SSH连接代理
+// 每个主机最多启动一个 ControlMaster 连接，git 通过 GIT_SSH_COMMAND 复用这些连接
 type Proxy struct {
 	controlMaster bool
-	controlPath   string
+	controlPath   string // 存放控制套接字的临时目录
 	sshDir        string
 	sshConfig     string
-	connections   map[string]*exec.Cmd
+	connections   map[string]*master
 	mu            sync.Mutex
 }
 
+// master 表示到单个主机的 ControlMaster 连接
+type master struct {
+	once sync.Once
+	cmd  *exec.Cmd
+	done chan struct{} // 主进程退出时关闭
+}
+
+var (
+	activeMu sync.RWMutex
+	active   *Proxy
+)
+
+// Active 返回已安装到当前进程的代理，未安装时返回nil
+func Active() *Proxy {
+	activeMu.RLock()
+	defer activeMu.RUnlock()
+	return active
+}
+
 // NewProxy 创建一个新的SSH代理
 func NewProxy() (*Proxy, error) {
 	// 获取用户主目录
@@ -27,82 +52,224 @@ func NewProxy() (*Proxy, error) {
 	if err != nil {
 		return nil, fmt.Errorf("无法获取用户主目录: %w", err)
 	}
-
-	// 创建SSH目录
 	sshDir := filepath.Join(homeDir, ".ssh")
-	if err := os.MkdirAll(sshDir, 0700); err != nil {
-		return nil, fmt.Errorf("无法创建SSH目录: %w", err)
-	}
-
-	// 创建控制路径目录
-	controlPath := filepath.Join(sshDir, "controlmasters")
-	if err := os.MkdirAll(controlPath, 0700); err != nil {
-		return nil, fmt.Errorf("无法创建SSH控制路径目录: %w", err)
-	}
 
 	// 检查SSH是否支持ControlMaster
 	controlMaster := checkControlMasterSupport()
 
+	// 控制套接字放在短路径的临时目录中，避免超过 unix 套接字路径长度限制，也避免与其他 repo 进程冲突
+	var controlPath string
+	if controlMaster {
+		controlPath, err = os.MkdirTemp("", "repo-ssh-")
+		if err != nil {
+			return nil, fmt.Errorf("无法创建SSH控制路径目录: %w", err)
+		}
+	}
+
 	return &Proxy{
 		controlMaster: controlMaster,
 		controlPath:   controlPath,
 		sshDir:        sshDir,
 		sshConfig:     filepath.Join(sshDir, "config"),
-		connections:   make(map[string]*exec.Cmd),
+		connections:   make(map[string]*master),
 	}, nil
 }
 
+// Install 为当前进程启动的所有 git 命令设置 GIT_SSH_COMMAND，使其复用 ControlMaster 连接
+// 用户已通过 GIT_SSH、GIT_SSH_COMMAND 或 core.sshCommand 指定 ssh 命令时不做修改，返回 false
+func (p *Proxy) Install() bool {
+	if !p.controlMaster {
+		return false
+	}
+	if os.Getenv("GIT_SSH") != "" || os.Getenv("GIT_SSH_COMMAND") != "" {
+		return false
+	}
+	if out, err := exec.Command("git", "config", "--get", "core.sshCommand").Output(); err == nil && strings.TrimSpace(string(out)) != "" {
+		return false
+	}
+
+	if err := os.Setenv("GIT_SSH_COMMAND", p.Command()); err != nil {
+		return false
+	}
+
+	activeMu.Lock()
+	active = p
+	activeMu.Unlock()
+	return true
+}
+
+// Command 返回复用 ControlMaster 连接的 ssh 命令行，用作 GIT_SSH_COMMAND
+// 主机还没有主连接时 ssh 会直接建立普通连接
+func (p *Proxy) Command() string {
+	return "ssh -o ControlMaster=no -o " + shellQuote("ControlPath="+p.socketPattern())
+}
+
+// Preconnect 在访问 rawURL 之前为其主机启动 ControlMaster 连接
+// 同一主机只启动一次，并发调用会等待连接就绪；非 SSH 地址直接返回
+func (p *Proxy) Preconnect(rawURL string) {
+	if !p.controlMaster {
+		return
+	}
+	target, ok := parseSSHURL(rawURL)
+	if !ok {
+		return
+	}
+
+	p.mu.Lock()
+	if p.connections == nil {
+		// 代理已关闭
+		p.mu.Unlock()
+		return
+	}
+	m, ok := p.connections[target.key()]
+	if !ok {
+		m = &master{}
+		p.connections[target.key()] = m
+	}
+	p.mu.Unlock()
+
+	m.once.Do(func() { p.startMaster(m, target) })
+}
+
+// startMaster 启动主连接并等待其就绪，失败时 git 回退为普通连接
+func (p *Proxy) startMaster(m *master, target sshTarget) {
+	// BatchMode 避免主连接在后台等待密码输入，需要交互认证时由 git 自己的 ssh 处理
+	args := append([]string{"-M", "-N", "-o", "BatchMode=yes", "-o", "ControlPath=" + p.socketPattern()}, target.args()...)
+	cmd := exec.Command("ssh", args...)
+	if err := cmd.Start(); err != nil {
+		return
+	}
+	m.cmd = cmd
+	m.done = make(chan struct{})
+	go func() {
+		cmd.Wait()
+		close(m.done)
+	}()
+
+	check := append([]string{"-O", "check", "-o", "ControlPath=" + p.socketPattern()}, target.args()...)
+	deadline := time.Now().Add(masterStartTimeout)
+	for time.Now().Before(deadline) {
+		select {
+		case <-m.done:
+			return
+		case <-time.After(100 * time.Millisecond):
+		}
+		if exec.Command("ssh", check...).Run() == nil {
+			return
+		}
+	}
+}
+
 // Close 关闭SSH代理
 func (p *Proxy) Close() {
 	p.mu.Lock()
-	defer p.mu.Unlock()
+	connections := p.connections
+	p.connections = nil
+	p.mu.Unlock()
 
 	// 关闭所有SSH连接
-	for host, cmd := range p.connections {
-		if cmd != nil && cmd.Process != nil {
-			cmd.Process.Kill()
+	for _, m := range connections {
+		// 等待正在启动的主连接，未启动的不再启动
+		m.once.Do(func() {})
+		if m.cmd != nil && m.cmd.Process != nil {
+			m.cmd.Process.Kill()
+			<-m.done
 		}
-		delete(p.connections, host)
 	}
+	if p.controlPath != "" {
+		os.RemoveAll(p.controlPath)
+	}
+
+	activeMu.Lock()
+	if active == p {
+		active = nil
+	}
+	activeMu.Unlock()
 }
 
 // GetSSHCommand 获取SSH命令
 func (p *Proxy) GetSSHCommand(host string) []string {
-	p.mu.Lock()
-	defer p.mu.Unlock()
-
 	// 如果不支持ControlMaster，直接返回普通SSH命令
 	if !p.controlMaster {
 		return []string{"ssh", host}
 	}
 
-	// 检查是否已经有连接
-	if _, ok := p.connections[host]; !ok {
-		// 创建新连接
-		controlPath := filepath.Join(p.controlPath, fmt.Sprintf("%s.sock", host))
-
-		// 启动SSH控制主进程
-		cmd := exec.Command("ssh",
-			"-o", "ControlMaster=yes",
-			"-o", fmt.Sprintf("ControlPath=%s", controlPath),
-			"-o", "ControlPersist=yes",
-			"-N", host)
-
-		// 非阻塞启动
-		cmd.Start()
-
-		// 保存连接
-		p.connections[host] = cmd
-	}
+	p.Preconnect("ssh://" + host)
 
 	// 返回使用控制路径的SSH命令
-	controlPath := filepath.Join(p.controlPath, fmt.Sprintf("%s.sock", host))
 	return []string{"ssh",
 		"-o", "ControlMaster=no",
-		"-o", fmt.Sprintf("ControlPath=%s", controlPath),
+		"-o", "ControlPath=" + p.socketPattern(),
 		host}
 }
 
+// socketPattern 返回控制套接字路径模板，由 ssh 按用户、主机和端口展开
+func (p *Proxy) socketPattern() string {
+	return filepath.Join(p.controlPath, "master-%r@%h:%p")
+}
+
+// sshTarget 表示 SSH 地址中的连接信息
+type sshTarget struct {
+	user string
+	host string
+	port string
+}
+
+// key 返回区分主连接的键
+func (t sshTarget) key() string {
+	return t.user + "@" + t.host + ":" + t.port
+}
+
+// args 返回连接该目标的 ssh 参数
+func (t sshTarget) args() []string {
+	var args []string
+	if t.port != "" {
+		args = append(args, "-p", t.port)
+	}
+	if t.user != "" {
+		args = append(args, "-l", t.user)
+	}
+	return append(args, t.host)
+}
+
+// parseSSHURL 解析 ssh://[user@]host[:port]/path 和 [user@]host:path 形式的地址
+func parseSSHURL(rawURL string) (sshTarget, bool) {
+	if strings.Contains(rawURL, "://") {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return sshTarget{}, false
+		}
+		switch u.Scheme {
+		case "ssh", "git+ssh", "ssh+git":
+		default:
+			return sshTarget{}, false
+		}
+		if u.Hostname() == "" {
+			return sshTarget{}, false
+		}
+		return sshTarget{user: u.User.Username(), host: u.Hostname(), port: u.Port()}, true
+	}
+
+	// scp 形式：冒号前不能包含斜杠，单个字母视为 Windows 盘符
+	host, _, ok := strings.Cut(rawURL, ":")
+	if !ok || len(host) <= 1 || strings.Contains(host, "/") {
+		return sshTarget{}, false
+	}
+	target := sshTarget{host: host}
+	if user, h, ok := strings.Cut(host, "@"); ok {
+		target.user, target.host = user, h
+	}
+	if target.host == "" {
+		return sshTarget{}, false
+	}
+	return target, true
+}
+
+// shellQuote 为 GIT_SSH_COMMAND 中的参数加上 shell 引号
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
 // checkControlMasterSupport 检查SSH是否支持ControlMaster
 func checkControlMasterSupport() bool {
 	// Windows不支持ControlMaster
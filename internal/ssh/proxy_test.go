@@ -0,0 +1,65 @@
+package ssh
+
+import "testing"
+
+func TestParseSSHURL(t *testing.T) {
+	tests := []struct {
+		url    string
+		want   sshTarget
+		wantOK bool
+	}{
+		{"ssh://review.example.com:29418/platform/build", sshTarget{host: "review.example.com", port: "29418"}, true},
+		{"ssh://alice@review.example.com/platform/build", sshTarget{user: "alice", host: "review.example.com"}, true},
+		{"git+ssh://git@example.com/a.git", sshTarget{user: "git", host: "example.com"}, true},
+		{"git@github.com:leopardxu/repo-go.git", sshTarget{user: "git", host: "github.com"}, true},
+		{"example.com:platform/build", sshTarget{host: "example.com"}, true},
+		{"https://example.com/platform/build", sshTarget{}, false},
+		{"/srv/git/platform/build", sshTarget{}, false},
+		{"../build", sshTarget{}, false},
+		{`C:\src\build`, sshTarget{}, false},
+		{"./dir:name", sshTarget{}, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := parseSSHURL(tt.url)
+		if ok != tt.wantOK || got != tt.want {
+			t.Errorf("parseSSHURL(%q) = %+v, %v; want %+v, %v", tt.url, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestSSHTargetArgs(t *testing.T) {
+	target := sshTarget{user: "alice", host: "review.example.com", port: "29418"}
+	got := target.args()
+	want := []string{"-p", "29418", "-l", "alice", "review.example.com"}
+	if len(got) != len(want) {
+		t.Fatalf("args() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("args() = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestCommandQuotesControlPath(t *testing.T) {
+	p := &Proxy{controlMaster: true, controlPath: "/tmp/repo ssh"}
+	want := "ssh -o ControlMaster=no -o 'ControlPath=/tmp/repo ssh/master-%r@%h:%p'"
+	if got := p.Command(); got != want {
+		t.Errorf("Command() = %q, want %q", got, want)
+	}
+
+	if got := shellQuote("it's"); got != `'it'\''s'` {
+		t.Errorf("shellQuote() = %q", got)
+	}
+}
+
+func TestPreconnectAfterClose(t *testing.T) {
+	p := &Proxy{controlMaster: true, connections: make(map[string]*master)}
+	p.Close()
+	// 关闭后不再启动主连接
+	p.Preconnect("ssh://example.com/a")
+	if p.connections != nil {
+		t.Errorf("Preconnect started a connection after Close")
+	}
+}
@@ -0,0 +1,79 @@
+// Package testutil 提供测试中创建 git 仓库和项目的辅助函数
+package testutil
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/leopardxu/repo-go/internal/git"
+	"github.com/leopardxu/repo-go/internal/project"
+)
+
+// identity 是测试中 git 提交使用的身份
+var identity = []string{
+	"GIT_AUTHOR_NAME=t", "GIT_AUTHOR_EMAIL=t@example.com",
+	"GIT_COMMITTER_NAME=t", "GIT_COMMITTER_EMAIL=t@example.com",
+}
+
+// SetIdentity 设置被测代码执行 git 提交、变基和 cherry-pick 时使用的身份
+func SetIdentity(t testing.TB) {
+	for _, kv := range identity {
+		name, value, _ := strings.Cut(kv, "=")
+		t.Setenv(name, value)
+	}
+}
+
+// Git 在 dir 中执行 git 命令并返回去掉空白的输出
+func Git(t testing.TB, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), identity...)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// Commit 把 content 写入 dir 中的文件 name 并提交，返回新提交
+func Commit(t testing.TB, dir, name, content, message string) string {
+	t.Helper()
+	path := filepath.Join(dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	Git(t, dir, "add", name)
+	Git(t, dir, "commit", "--quiet", "-m", message)
+	return Git(t, dir, "rev-parse", "HEAD")
+}
+
+// NewRepo 创建一个在 main 上有一个提交（文件 f 内容为 base）的仓库，
+// 远程 origin 指向仓库自身，refs/remotes/origin/main 指向这个提交
+func NewRepo(t testing.TB) string {
+	t.Helper()
+	dir := t.TempDir()
+	Git(t, dir, "init", "--quiet", "-b", "main")
+	Git(t, dir, "remote", "add", "origin", dir)
+	Commit(t, dir, "f", "base\n", "base")
+	Git(t, dir, "update-ref", "refs/remotes/origin/main", "HEAD")
+	return dir
+}
+
+// NewProject 返回检出在 dir、使用远程 origin 和修订版本 main 的项目 name
+func NewProject(dir, name string) *project.Project {
+	return &project.Project{
+		Name:       name,
+		Path:       name,
+		Worktree:   dir,
+		RemoteName: "origin",
+		Revision:   "main",
+		GitRepo:    git.NewRepository(dir, git.NewRunner()),
+	}
+}
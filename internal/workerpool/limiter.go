@@ -0,0 +1,154 @@
+package workerpool
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// HostLimiter 按主机限制同时进行的请求数
+// 请求被服务器限流时主机的上限减半，之后每连续成功一轮（成功数达到当前上限）上限加一，直到初始上限
+type HostLimiter struct {
+	mu           sync.Mutex
+	defaultLimit int
+	hosts        map[string]*hostState
+
+	// OnChange 在主机的并发上限变化时调用，调用时不持有锁
+	OnChange func(host string, oldLimit, newLimit int, throttled bool)
+}
+
+// hostState 记录单个主机的并发状态
+type hostState struct {
+	limit     int           // 当前上限
+	max       int           // 上限的最大值
+	inFlight  int           // 正在进行的请求数
+	successes int           // 上次调整后连续成功的请求数
+	epoch     int           // 每次减小上限时递增，用于忽略按旧上限发出的请求的限流结果
+	wake      chan struct{} // 有请求结束时关闭，唤醒等待者
+}
+
+// HostLimit 是主机并发状态的快照
+type HostLimit struct {
+	Host     string
+	Limit    int
+	Max      int
+	InFlight int
+}
+
+// Slot 表示在主机上占用的一个请求名额
+type Slot struct {
+	limiter *HostLimiter
+	host    string
+	epoch   int
+}
+
+// NewHostLimiter 创建主机并发限制器，defaultLimit 是未单独设置上限的主机的初始上限
+func NewHostLimiter(defaultLimit int) *HostLimiter {
+	if defaultLimit <= 0 {
+		defaultLimit = 1
+	}
+	return &HostLimiter{
+		defaultLimit: defaultLimit,
+		hosts:        make(map[string]*hostState),
+	}
+}
+
+// state 返回主机的状态，调用者需持有锁
+func (l *HostLimiter) state(host string) *hostState {
+	h, ok := l.hosts[host]
+	if !ok {
+		h = &hostState{limit: l.defaultLimit, max: l.defaultLimit, wake: make(chan struct{})}
+		l.hosts[host] = h
+	}
+	return h
+}
+
+// SetLimit 设置主机的并发上限，只能降低已有的上限
+func (l *HostLimiter) SetLimit(host string, limit int) {
+	if limit <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	h := l.state(host)
+	if limit < h.max {
+		h.max = limit
+	}
+	if h.limit > h.max {
+		h.limit = h.max
+	}
+}
+
+// Acquire 在主机上占用一个请求名额，达到上限时等待其他请求结束，ctx 取消时返回错误
+func (l *HostLimiter) Acquire(ctx context.Context, host string) (*Slot, error) {
+	for {
+		l.mu.Lock()
+		h := l.state(host)
+		if h.inFlight < h.limit {
+			h.inFlight++
+			slot := &Slot{limiter: l, host: host, epoch: h.epoch}
+			l.mu.Unlock()
+			return slot, nil
+		}
+		wake := h.wake
+		l.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-wake:
+		}
+	}
+}
+
+// Release 释放请求名额，throttled 表示请求被服务器限流
+// 同一轮请求的多次限流只减半一次
+func (s *Slot) Release(throttled bool) {
+	if s == nil {
+		return
+	}
+	l := s.limiter
+	l.mu.Lock()
+	h := l.state(s.host)
+	h.inFlight--
+	oldLimit := h.limit
+	if throttled {
+		h.successes = 0
+		if s.epoch == h.epoch && h.limit > 1 {
+			h.limit /= 2
+			h.epoch++
+		}
+	} else if h.limit < h.max {
+		h.successes++
+		if h.successes >= h.limit {
+			h.limit++
+			h.successes = 0
+		}
+	}
+	newLimit := h.limit
+	close(h.wake)
+	h.wake = make(chan struct{})
+	onChange := l.OnChange
+	l.mu.Unlock()
+
+	if onChange != nil && newLimit != oldLimit {
+		onChange(s.host, oldLimit, newLimit, throttled)
+	}
+}
+
+// DefaultLimit 返回未单独设置上限的主机的初始上限
+func (l *HostLimiter) DefaultLimit() int {
+	return l.defaultLimit
+}
+
+// Limits 返回所有主机当前的并发状态，按主机名排序
+func (l *HostLimiter) Limits() []HostLimit {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	limits := make([]HostLimit, 0, len(l.hosts))
+	for host, h := range l.hosts {
+		limits = append(limits, HostLimit{Host: host, Limit: h.limit, Max: h.max, InFlight: h.inFlight})
+	}
+	sort.Slice(limits, func(i, j int) bool { return limits[i].Host < limits[j].Host })
+	return limits
+}
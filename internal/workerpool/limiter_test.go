@@ -0,0 +1,108 @@
+package workerpool
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHostLimiterCapsInFlight(t *testing.T) {
+	l := NewHostLimiter(3)
+	l.SetLimit("slow.example.com", 2)
+
+	var running, peak int32
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			slot, err := l.Acquire(context.Background(), "slow.example.com")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			n := atomic.AddInt32(&running, 1)
+			for {
+				p := atomic.LoadInt32(&peak)
+				if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			atomic.AddInt32(&running, -1)
+			slot.Release(false)
+		}()
+	}
+	wg.Wait()
+	if peak != 2 {
+		t.Errorf("peak in-flight = %d, want 2", peak)
+	}
+}
+
+func TestHostLimiterAdapts(t *testing.T) {
+	l := NewHostLimiter(8)
+	var changes [][2]int
+	l.OnChange = func(host string, oldLimit, newLimit int, throttled bool) {
+		changes = append(changes, [2]int{oldLimit, newLimit})
+	}
+	ctx := context.Background()
+
+	// 同一轮请求的多次限流只减半一次
+	var slots []*Slot
+	for i := 0; i < 4; i++ {
+		slot, err := l.Acquire(ctx, "gerrit")
+		if err != nil {
+			t.Fatal(err)
+		}
+		slots = append(slots, slot)
+	}
+	for _, slot := range slots {
+		slot.Release(true)
+	}
+	if got := l.Limits()[0].Limit; got != 4 {
+		t.Fatalf("limit after throttling = %d, want 4", got)
+	}
+
+	// 限流后重新发出的请求再次被限流时继续减半
+	slot, _ := l.Acquire(ctx, "gerrit")
+	slot.Release(true)
+	if got := l.Limits()[0].Limit; got != 2 {
+		t.Fatalf("limit after second throttling = %d, want 2", got)
+	}
+
+	// 每轮成功后上限加一，直到初始上限
+	for i := 0; i < 100; i++ {
+		slot, _ := l.Acquire(ctx, "gerrit")
+		slot.Release(false)
+	}
+	limits := l.Limits()
+	if len(limits) != 1 || limits[0].Limit != 8 || limits[0].Max != 8 || limits[0].InFlight != 0 {
+		t.Fatalf("Limits() = %+v", limits)
+	}
+	want := [][2]int{{8, 4}, {4, 2}, {2, 3}, {3, 4}, {4, 5}, {5, 6}, {6, 7}, {7, 8}}
+	if len(changes) != len(want) {
+		t.Fatalf("changes = %v, want %v", changes, want)
+	}
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Fatalf("changes = %v, want %v", changes, want)
+		}
+	}
+}
+
+func TestHostLimiterCancel(t *testing.T) {
+	l := NewHostLimiter(1)
+	slot, err := l.Acquire(context.Background(), "host")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer slot.Release(false)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := l.Acquire(ctx, "host"); err != context.DeadlineExceeded {
+		t.Fatalf("Acquire() = %v, want context.DeadlineExceeded", err)
+	}
+}
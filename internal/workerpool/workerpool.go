@@ -22,12 +22,12 @@ type WorkerPool struct {
 	workers     int
 	tasks       chan Task
 	once        sync.Once
-	quit        chan struct{}
+	quit        chan struct{} // 停止后所有任务结束时关闭，worker 随之退出
 	ctx         context.Context
 	cancel      context.CancelFunc
-	activeTasks sync.WaitGroup // 追踪活跃任务数
-	closed      bool           // 标记 tasks channel 是否已关闭
-	closeMu     sync.Mutex     // 保护 closed 标记
+	activeTasks sync.WaitGroup // 追踪已提交但未完成的任务数
+	stopped     bool           // 停止后不再接受新任务
+	stopMu      sync.RWMutex   // 保护 stopped 标记
 }
 
 // New 创建工作池
@@ -56,46 +56,44 @@ func (p *WorkerPool) start() {
 		go func() {
 			for {
 				select {
-				case task, ok := <-p.tasks:
-					if !ok {
-						return
-					}
-					p.activeTasks.Add(1)
-					// 直接在 worker goroutine 中执行任务，不再启动额外 goroutine
-					// 这样确保同时执行的任务数不会超过 workers 数
-					result, err := task.Fn()
-					task.Done <- TaskResult{Error: err, Data: result}
-					p.activeTasks.Done()
+				case task := <-p.tasks:
+					p.run(task)
 				case <-p.quit:
 					return
-				case <-p.ctx.Done():
-					return
 				}
 			}
 		}()
 	}
 }
 
-// Submit 提交任务
+// run 执行一个任务，工作池停止后放弃排队中的任务
+func (p *WorkerPool) run(task Task) {
+	defer p.activeTasks.Done()
+	if err := p.ctx.Err(); err != nil {
+		task.Done <- TaskResult{Error: err}
+		return
+	}
+	result, err := task.Fn()
+	task.Done <- TaskResult{Error: err, Data: result}
+}
+
+// Submit 提交任务，工作池停止后提交的任务不会执行，直接返回错误结果
 func (p *WorkerPool) Submit(fn func() (interface{}, error)) <-chan TaskResult {
 	done := make(chan TaskResult, 1)
-	task := Task{
-		Fn:   fn,
-		Done: done,
-	}
 
-	select {
-	case p.tasks <- task:
-	case <-p.ctx.Done():
-		result := TaskResult{Error: p.ctx.Err()}
-		select {
-		case done <- result:
-		default:
-		}
+	// 在提交时计数，Wait 不会在任务被 worker 取走之前返回
+	p.stopMu.RLock()
+	if p.stopped {
+		p.stopMu.RUnlock()
+		done <- TaskResult{Error: p.ctx.Err()}
 		close(done)
 		return done
 	}
+	p.activeTasks.Add(1)
+	p.stopMu.RUnlock()
 
+	// 计数未归零前 worker 不会退出，发送不会永久阻塞
+	p.tasks <- Task{Fn: fn, Done: done}
 	return done
 }
 
@@ -107,30 +105,24 @@ func (p *WorkerPool) SubmitAndWait(fn func() (interface{}, error)) (interface{},
 }
 
 // Wait 等待所有已提交的任务完成
+// 工作池停止后排队中的任务被放弃，Wait 只等待正在执行的任务结束
 func (p *WorkerPool) Wait() {
-	// 等待所有活跃任务完成
 	p.activeTasks.Wait()
-
-	// 安全关闭任务通道（仅关闭一次）
-	p.closeMu.Lock()
-	if !p.closed {
-		p.closed = true
-		close(p.tasks)
-	}
-	p.closeMu.Unlock()
 }
 
-// Stop 停止工作池
+// Stop 停止工作池，正在执行的任务继续完成，排队中的任务不再执行
 func (p *WorkerPool) Stop() {
 	p.once.Do(func() {
+		p.stopMu.Lock()
+		p.stopped = true
+		p.stopMu.Unlock()
 		p.cancel()
-		close(p.quit)
-		p.closeMu.Lock()
-		if !p.closed {
-			p.closed = true
-			close(p.tasks)
-		}
-		p.closeMu.Unlock()
+
+		// 所有任务执行或放弃后 worker 退出
+		go func() {
+			p.activeTasks.Wait()
+			close(p.quit)
+		}()
 	})
 }
 
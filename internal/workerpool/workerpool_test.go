@@ -1,7 +1,11 @@
 package workerpool
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -12,20 +16,29 @@ func TestWorkerPool(t *testing.T) {
 	doneCount := 0
 	tasks := 20
 	resChan := make(chan TaskResult, tasks)
+	var executed int32
+	var forwarders sync.WaitGroup
 
 	for i := 0; i < tasks; i++ {
 		idx := i
 		res := pool.Submit(func() (interface{}, error) {
 			time.Sleep(time.Millisecond * 10)
+			atomic.AddInt32(&executed, 1)
 			return fmt.Sprintf("result %d", idx), nil
 		})
+		forwarders.Add(1)
 		go func(r <-chan TaskResult) {
+			defer forwarders.Done()
 			resChan <- <-r
 		}(res)
 	}
 
 	go func() {
 		pool.Wait()
+		if n := atomic.LoadInt32(&executed); n != int32(tasks) {
+			t.Errorf("Wait returned after %d of %d tasks", n, tasks)
+		}
+		forwarders.Wait()
 		close(resChan)
 	}()
 
@@ -44,33 +57,61 @@ func TestWorkerPool(t *testing.T) {
 func TestWorkerPoolStop(t *testing.T) {
 	pool := New(2)
 
-	// submitting tasks
-	resChan := make(chan TaskResult, 10)
-	for i := 0; i < 10; i++ {
-		res := pool.Submit(func() (interface{}, error) {
-			time.Sleep(time.Millisecond * 30) // taking long time
+	started := make(chan struct{}, 2)
+	release := make(chan struct{})
+	var finished int32
+	var results []<-chan TaskResult
+	// 队列容量为 workers*2，提交 6 个任务不会阻塞
+	for i := 0; i < 6; i++ {
+		results = append(results, pool.Submit(func() (interface{}, error) {
+			started <- struct{}{}
+			<-release
+			atomic.AddInt32(&finished, 1)
 			return nil, nil
-		})
-		go func(r <-chan TaskResult) {
-			resChan <- <-r
-		}(res)
+		}))
 	}
 
-	time.Sleep(time.Millisecond * 15) // let a few tasks start
+	// 两个 worker 各执行一个任务，其余任务在排队
+	<-started
+	<-started
 	pool.Stop()
-	pool.Wait() // ensure Wait behaves properly after Stop
 
-	successCount := 0
-	for {
-		select {
-		case <-resChan:
-			successCount++
-		default:
-			goto CHECK
+	waited := make(chan struct{})
+	go func() {
+		pool.Wait()
+		close(waited)
+	}()
+	select {
+	case <-waited:
+		t.Fatal("Wait returned while tasks were still running")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(release)
+	<-waited
+
+	// Wait 返回时正在执行的任务已经完成，排队中的任务被放弃
+	if n := atomic.LoadInt32(&finished); n != 2 {
+		t.Errorf("%d tasks finished, want the 2 running ones", n)
+	}
+	succeeded := 0
+	for _, r := range results {
+		res := <-r
+		if res.Error == nil {
+			succeeded++
+		} else if !errors.Is(res.Error, context.Canceled) {
+			t.Errorf("abandoned task error = %v, want context.Canceled", res.Error)
 		}
 	}
-CHECK:
-	if successCount == 10 {
-		t.Errorf("Expected fewer than 10 completed tasks due to stop, got 10")
+	if succeeded != 2 {
+		t.Errorf("%d tasks succeeded, want 2", succeeded)
+	}
+
+	// 停止后提交的任务不会执行
+	res := <-pool.Submit(func() (interface{}, error) {
+		t.Error("task submitted after Stop was executed")
+		return nil, nil
+	})
+	if !errors.Is(res.Error, context.Canceled) {
+		t.Errorf("Submit after Stop error = %v, want context.Canceled", res.Error)
 	}
 }